@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+)
+
+// Outcome of adding an event received from a peer
+type AddResult int
+
+const (
+    AddInserted  AddResult = iota // new event, now in the graph
+    AddDuplicate                  // already in the graph, nothing changed
+    AddBuffered                   // held until its room is joined or its parents arrive
+    AddRejected                   // failed a check, see the error
+)
+
+func (r AddResult) String() string {
+    switch r {
+    case AddInserted:
+        return "inserted"
+    case AddDuplicate:
+        return "duplicate"
+    case AddBuffered:
+        return "buffered"
+    case AddRejected:
+        return "rejected"
+    }
+    return "unknown"
+}
+
+// Map a pipeline error to the outcome it stands for
+func addResultOf(err error) AddResult {
+    switch {
+    case err == nil:
+        return AddInserted
+    case errors.Is(err, errDuplicateEvent):
+        return AddDuplicate
+    }
+    return AddRejected
+}
@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestAddRemoteEventOutcomes(t *testing.T) {
+    graph := buildTestGraph(t, 91, 3, 12)
+    hg := NewHashgraph(nil, nil)
+    events := copyTestEvents(graph)
+
+    if result, err := hg.AddRemoteEvent(events[0]); result != AddInserted || err != nil {
+        t.Fatalf("new event: %v %v", result, err)
+    }
+    if result, err := hg.AddRemoteEvent(copyTestEvents(graph[:1])[0]); result != AddDuplicate || err != nil {
+        t.Fatalf("known event: %v %v", result, err)
+    }
+    if hg.EventCount() != 1 {
+        t.Fatal("duplicate changed the graph")
+    }
+
+    // An event ahead of its parents waits for them
+    var child *Event
+    for _, event := range events[2:] {
+        if event.SelfParent != "" && event.SelfParent != events[0].Hash {
+            child = event
+            break
+        }
+    }
+    if result, err := hg.AddRemoteEvent(child); result != AddBuffered || err != nil {
+        t.Fatalf("event with missing parents: %v %v", result, err)
+    }
+    if _, ok := hg.GetEvent(child.Hash); ok {
+        t.Fatal("buffered event inserted")
+    }
+    for _, event := range events[1:] {
+        if event != child {
+            hg.AddRemoteEvent(event)
+        }
+    }
+    if _, ok := hg.GetEvent(child.Hash); !ok {
+        t.Fatal("buffered event not inserted once its parents arrived")
+    }
+
+    forged := *events[0]
+    forged.Transactions = [][]byte{[]byte("forged")}
+    result, err := hg.AddRemoteEvent(&forged)
+    expectRejected(t, result, err, "validate", errHashMismatch)
+
+    for result, name := range map[AddResult]string{AddInserted: "inserted", AddDuplicate: "duplicate", AddBuffered: "buffered", AddRejected: "rejected", AddResult(99): "unknown"} {
+        if result.String() != name {
+            t.Fatalf("%d named %q", result, result.String())
+        }
+    }
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Admin HTTP endpoints for inspecting the local Hashgraph
+func serveAdmin(addr string, rooms *RoomManager, chat *ChatView, deadLetters *DeadLetterStore, metrics *ConnectionMetrics, latency *LatencyTracker, gossiper *Gossiper, store Store) {
+    mux := http.NewServeMux()
+    mux.HandleFunc("/stateroot", func(w http.ResponseWriter, r *http.Request) {
+        stateRootHandler(w, r, rooms)
+    })
+    mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+        if hg, ok := requestRoom(w, r, rooms); ok {
+            json.NewEncoder(w).Encode(hg.Status())
+        }
+    })
+    mux.HandleFunc("GET /admin/peers/{id}/missing", func(w http.ResponseWriter, r *http.Request) {
+        if hg, ok := requestRoom(w, r, rooms); ok {
+            json.NewEncoder(w).Encode(hg.EventsMissingForPeer(r.PathValue("id")))
+        }
+    })
+    mux.HandleFunc("/export", func(w http.ResponseWriter, r *http.Request) {
+        exportHandler(w, r, rooms)
+    })
+    mux.HandleFunc("/event", func(w http.ResponseWriter, r *http.Request) {
+        eventHandler(w, r, rooms)
+    })
+    mux.HandleFunc("/partition", func(w http.ResponseWriter, r *http.Request) {
+        if hg, ok := requestRoom(w, r, rooms); ok {
+            json.NewEncoder(w).Encode(hg.PartitionStatus())
+        }
+    })
+    mux.HandleFunc("/metrics", metricsHandler(metrics))
+    mux.HandleFunc("/graph", func(w http.ResponseWriter, r *http.Request) {
+        graphHandler(w, r, rooms)
+    })
+    mux.HandleFunc("GET /graph/concurrent", func(w http.ResponseWriter, r *http.Request) {
+        concurrentHandler(w, r, rooms)
+    })
+    mux.HandleFunc("/frontier", func(w http.ResponseWriter, r *http.Request) {
+        if hg, ok := requestRoom(w, r, rooms); ok {
+            json.NewEncoder(w).Encode(hg.Frontier())
+        }
+    })
+    mux.HandleFunc("GET /consensus", func(w http.ResponseWriter, r *http.Request) {
+        cursorHandler(w, r, rooms)
+    })
+    mux.HandleFunc("GET /members", func(w http.ResponseWriter, r *http.Request) {
+        membersHandler(w, r, rooms)
+    })
+    mux.HandleFunc("GET /messages", func(w http.ResponseWriter, r *http.Request) {
+        messagesHandler(w, r, chat)
+    })
+    mux.HandleFunc("POST /verify", verifyHandler)
+    mux.HandleFunc("GET /admin/peers/rtt", rttHandler(latency))
+    mux.HandleFunc("POST /admin/gossip/{peerID}", gossipHandler(gossiper))
+    mux.HandleFunc("GET /proof", func(w http.ResponseWriter, r *http.Request) {
+        proofHandler(w, r, rooms)
+    })
+    mux.HandleFunc("GET /history", func(w http.ResponseWriter, r *http.Request) {
+        historyHandler(w, r, store)
+    })
+    mux.HandleFunc("GET /admin/deadletters", func(w http.ResponseWriter, r *http.Request) {
+        json.NewEncoder(w).Encode(deadLetters.List())
+    })
+    log.Printf("Admin endpoints listening on %s", addr)
+    log.Fatal(http.ListenAndServe(addr, mux))
+}
+
+// Look up the room named by the request, defaulting to the lobby
+func requestRoom(w http.ResponseWriter, r *http.Request, rooms *RoomManager) (*Hashgraph, bool) {
+    roomID := r.URL.Query().Get("room")
+    if roomID == "" {
+        roomID = defaultRoom
+    }
+    hg, ok := rooms.Room(roomID)
+    if !ok {
+        http.Error(w, "unknown room", http.StatusNotFound)
+    }
+    return hg, ok
+}
+
+// Get the most recent finalized messages of the lobby, oldest first
+func messagesHandler(w http.ResponseWriter, r *http.Request, chat *ChatView) {
+    limit := 0
+    if value := r.URL.Query().Get("limit"); value != "" {
+        var err error
+        if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+            http.Error(w, "invalid limit", http.StatusBadRequest)
+            return
+        }
+    }
+    json.NewEncoder(w).Encode(chat.Recent(limit))
+}
+
+// Get the co-signed proof that an event reached consensus, with the members a light client
+// should check it against
+func proofHandler(w http.ResponseWriter, r *http.Request, rooms *RoomManager) {
+    hg, ok := requestRoom(w, r, rooms)
+    if !ok {
+        return
+    }
+    proof, err := hg.BuildConsensusProof(r.URL.Query().Get("hash"))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+    members := hg.ProofMembers(proof.Receipt.RoundReceived)
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "proof":   proof,
+        "members": members,
+        "valid":   VerifyConsensusProof(proof, members) == nil,
+    })
+}
+
+// Page through the stored events in insertion order, for a peer or tool syncing history
+func historyHandler(w http.ResponseWriter, r *http.Request, store Store) {
+    if store == nil {
+        http.Error(w, "no event store", http.StatusNotFound)
+        return
+    }
+    query := r.URL.Query()
+    limit := defaultStorePageSize
+    if value := query.Get("limit"); value != "" {
+        var err error
+        if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+            http.Error(w, "invalid limit", http.StatusBadRequest)
+            return
+        }
+    }
+    events, next, err := store.LoadEvents(query.Get("cursor"), limit)
+    if errors.Is(err, errInvalidStoreCursor) {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    if err != nil {
+        log.Println("Failed to load history:", err)
+        http.Error(w, "failed to load history", http.StatusInternalServerError)
+        return
+    }
+    if events == nil {
+        events = []*Event{}
+    }
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "events": events,
+        "cursor": next,
+    })
+}
+
+// Get the state root of the consensus order up to a round
+func stateRootHandler(w http.ResponseWriter, r *http.Request, rooms *RoomManager) {
+    hg, ok := requestRoom(w, r, rooms)
+    if !ok {
+        return
+    }
+    round := 0
+    if value := r.URL.Query().Get("round"); value != "" {
+        var err error
+        if round, err = strconv.Atoi(value); err != nil {
+            http.Error(w, "invalid round", http.StatusBadRequest)
+            return
+        }
+    }
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "round":     round,
+        "stateRoot": hg.StateRoot(round),
+    })
+}
+
+// Inspect a single event along with its local metadata
+func eventHandler(w http.ResponseWriter, r *http.Request, rooms *RoomManager) {
+    hg, ok := requestRoom(w, r, rooms)
+    if !ok {
+        return
+    }
+    event, ok := hg.GetEvent(r.URL.Query().Get("hash"))
+    if !ok {
+        http.Error(w, "unknown event", http.StatusNotFound)
+        return
+    }
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "event":            event,
+        "receivedFrom":     event.ReceivedFrom,
+        "timestampSources": event.TimestampSources,
+    })
+}
+
+// Export the events finalized in a time window, as JSON or CSV
+func exportHandler(w http.ResponseWriter, r *http.Request, rooms *RoomManager) {
+    hg, ok := requestRoom(w, r, rooms)
+    if !ok {
+        return
+    }
+    query := r.URL.Query()
+    from, err := time.Parse(time.RFC3339, query.Get("from"))
+    if err != nil {
+        http.Error(w, "invalid from", http.StatusBadRequest)
+        return
+    }
+    to, err := time.Parse(time.RFC3339, query.Get("to"))
+    if err != nil {
+        http.Error(w, "invalid to", http.StatusBadRequest)
+        return
+    }
+
+    events := hg.FinalizedBetween(from, to)
+    if query.Get("format") == "csv" {
+        w.Header().Set("Content-Type", "text/csv")
+        if err := writeEventsCSV(w, events); err != nil {
+            log.Println("Failed to write CSV export:", err)
+        }
+        return
+    }
+    json.NewEncoder(w).Encode(events)
+}
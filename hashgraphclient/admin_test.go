@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestGetEventReturnsCopy(t *testing.T) {
+    graph := buildTestGraph(t, 2, 4, 10)
+    hg := testHashgraph(t, graph, testCreators(graph))
+
+    event, _ := hg.GetEvent(graph[0].Hash)
+    event.RoundCreated = 99
+    event.ReceivedFrom = "someone"
+    again, _ := hg.GetEvent(graph[0].Hash)
+    if again.RoundCreated == 99 || again.ReceivedFrom == "someone" {
+        t.Fatal("changing a returned event changed the graph")
+    }
+}
+
+// Run with -race: handlers encode events while consensus keeps updating them
+func TestAdminHandlersEncodeWhileInserting(t *testing.T) {
+    graph := buildTestGraph(t, 4, 4, 200)
+    rooms := NewRoomManager(nil, nil)
+    members := testCreators(graph)
+    rooms.Configure(func(hg *Hashgraph) {
+        hg.SetMembers(members)
+    })
+    hg := rooms.Join(defaultRoom)
+    addTestEvents(t, hg, graph[:20])
+
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        addTestEvents(t, hg, graph[20:])
+    }()
+    handlers := map[string]func(http.ResponseWriter, *http.Request){
+        "/event?hash=" + graph[0].Hash: func(w http.ResponseWriter, r *http.Request) { eventHandler(w, r, rooms) },
+        "/consensus":                   func(w http.ResponseWriter, r *http.Request) { cursorHandler(w, r, rooms) },
+        "/export?from=2000-01-01T00:00:00Z&to=2100-01-01T00:00:00Z": func(w http.ResponseWriter, r *http.Request) {
+            exportHandler(w, r, rooms)
+        },
+    }
+    for {
+        select {
+        case <-done:
+            return
+        default:
+        }
+        for target, handler := range handlers {
+            recorder := httptest.NewRecorder()
+            handler(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+            if recorder.Code != http.StatusOK {
+                t.Fatalf("%s: status %d", target, recorder.Code)
+            }
+        }
+    }
+}
+
+func TestEventKeepsFirstDeliveringPeer(t *testing.T) {
+    graph := buildTestGraph(t, 121, 2, 1)
+    rooms := NewRoomManager(nil, nil)
+    hg := rooms.Join(defaultRoom)
+
+    first := copyTestEvents(graph)[0]
+    first.ReceivedFrom = "peer-a"
+    if result, err := hg.AddRemoteEvent(first); result != AddInserted {
+        t.Fatal(result, err)
+    }
+    again := copyTestEvents(graph)[0]
+    again.ReceivedFrom = "peer-b"
+    if result, _ := hg.AddRemoteEvent(again); result != AddDuplicate {
+        t.Fatalf("second copy: %v", result)
+    }
+    if hg.EventsReceivedFrom("peer-a") != 1 || hg.EventsReceivedFrom("peer-b") != 0 {
+        t.Fatal("duplicate counted for its peer")
+    }
+
+    recorder := httptest.NewRecorder()
+    eventHandler(recorder, httptest.NewRequest(http.MethodGet, "/event?hash="+first.Hash, nil), rooms)
+    var body struct {
+        ReceivedFrom string `json:"receivedFrom"`
+    }
+    if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil || body.ReceivedFrom != "peer-a" {
+        t.Fatalf("event reported as received from %q, %v", body.ReceivedFrom, err)
+    }
+
+    recorder = httptest.NewRecorder()
+    eventHandler(recorder, httptest.NewRequest(http.MethodGet, "/event?hash=unknown", nil), rooms)
+    if recorder.Code != http.StatusNotFound {
+        t.Fatalf("unknown event: status %d", recorder.Code)
+    }
+}
+
+func TestEventHandlerServesTimestampSources(t *testing.T) {
+    graph := buildTestGraph(t, 139, 4, 200)
+    rooms := testRoomManager(testCreators(graph))
+    hg := rooms.Join(defaultRoom)
+    addTestEvents(t, hg, graph)
+    if len(hg.ConsensusOrder) == 0 {
+        t.Fatal("nothing finalized")
+    }
+
+    for _, x := range hg.ConsensusOrder {
+        // The sources are exactly the famous witnesses of the round received, one per member
+        hg.mutex.RLock()
+        famous := hg.famousWitnesses(x.RoundReceived)
+        hg.mutex.RUnlock()
+        want := make(map[string]string, len(famous))
+        for _, w := range famous {
+            want[w.Hash] = w.Creator
+        }
+        members := make(map[string]bool)
+        for _, source := range x.TimestampSources {
+            if want[source.Witness] != source.Member || members[source.Member] {
+                t.Fatalf("event %s: source %s through %s is not a famous witness of round %d", shortID(x.Hash), shortID(source.Member), shortID(source.Witness), x.RoundReceived)
+            }
+            members[source.Member] = true
+        }
+        if len(members) != len(famous) {
+            t.Fatalf("event %s: %d sources for %d famous witnesses", shortID(x.Hash), len(members), len(famous))
+        }
+    }
+
+    x := hg.ConsensusOrder[len(hg.ConsensusOrder)-1]
+    recorder := httptest.NewRecorder()
+    eventHandler(recorder, httptest.NewRequest(http.MethodGet, "/event?hash="+x.Hash, nil), rooms)
+    var body struct {
+        TimestampSources []TimestampSource `json:"timestampSources"`
+    }
+    if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+        t.Fatal(err)
+    }
+    if len(body.TimestampSources) != len(x.TimestampSources) {
+        t.Fatalf("served %d timestamp sources, want %d", len(body.TimestampSources), len(x.TimestampSources))
+    }
+    for i, source := range body.TimestampSources {
+        if source.Member != x.TimestampSources[i].Member || source.Median != x.TimestampSources[i].Median || !source.SeenAt.Equal(x.TimestampSources[i].SeenAt) {
+            t.Fatalf("served source %d %+v", i, source)
+        }
+    }
+}
+
+func TestHistoryPagesEveryEventOnce(t *testing.T) {
+    graph := buildTestGraph(t, 149, 4, 1200)
+    for _, store := range []Store{NewMemoryStore(), NewFileEventStore(t.TempDir())} {
+        for _, event := range graph {
+            if err := store.Put(event); err != nil {
+                t.Fatal(err)
+            }
+        }
+
+        // Uneven page sizes still visit every stored event exactly once, in insertion order
+        seen := make(map[string]int, len(graph))
+        var order []string
+        cursor := ""
+        for i := 0; ; i++ {
+            limit := []int{1, 13, 250, 7}[i%4]
+            query := url.Values{"cursor": {cursor}, "limit": {strconv.Itoa(limit)}}
+            w := httptest.NewRecorder()
+            historyHandler(w, httptest.NewRequest("GET", "/history?"+query.Encode(), nil), store)
+            if w.Code != http.StatusOK {
+                t.Fatalf("page %d: status %d: %s", i, w.Code, w.Body)
+            }
+            var page struct {
+                Events []*Event
+                Cursor string
+            }
+            if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+                t.Fatal(err)
+            }
+            if len(page.Events) > limit {
+                t.Fatalf("page %d: %d events over limit %d", i, len(page.Events), limit)
+            }
+            if len(page.Events) == 0 {
+                break
+            }
+            for _, event := range page.Events {
+                seen[event.Hash]++
+                order = append(order, event.Hash)
+            }
+            cursor = page.Cursor
+        }
+        if len(seen) != len(graph) || len(order) != len(graph) {
+            t.Fatalf("paged %d events, %d distinct, of %d", len(order), len(seen), len(graph))
+        }
+        for i, event := range graph {
+            if order[i] != event.Hash {
+                t.Fatalf("event %d paged out of insertion order", i)
+            }
+        }
+
+        // Startup reconstruction pages past the default page size and loads the whole graph
+        reloaded := NewHashgraph(nil, nil)
+        reloaded.roomID = defaultRoom
+        reloaded.SetMembers(testCreators(graph))
+        if n, err := reloaded.LoadStore(store, StoreVerifyStrict, nil); err != nil || n != len(graph) {
+            t.Fatalf("reloaded %d of %d events: %v", n, len(graph), err)
+        }
+    }
+
+    store := NewMemoryStore()
+    for _, target := range []string{"/history?limit=0", "/history?limit=x", "/history?cursor=bogus"} {
+        w := httptest.NewRecorder()
+        historyHandler(w, httptest.NewRequest("GET", target, nil), store)
+        if w.Code != http.StatusBadRequest {
+            t.Fatalf("%s: status %d", target, w.Code)
+        }
+    }
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Version of the state bundle format
+const stateBundleVersion = 1
+
+// scrypt cost parameters for the bundle passphrase
+const (
+    bundleScryptN = 1 << 15
+    bundleScryptR = 8
+    bundleScryptP = 1
+)
+
+// Bundle is of an unsupported version
+var errBundleVersion = errors.New("unsupported state bundle version")
+
+// Bundle MAC does not match, wrong passphrase or tampered contents
+var errBundleIntegrity = errors.New("state bundle failed integrity check")
+
+// Bundle contents are inconsistent with each other
+var errBundleInconsistent = errors.New("state bundle contents are inconsistent")
+
+// Importing a bundle would overwrite an existing node key
+var errKeyExists = errors.New("key file already exists")
+
+// Exporting found no snapshot to move
+var errNoSnapshot = errors.New("no snapshot to export")
+
+// Everything needed to move a node to another machine
+type stateBundlePayload struct {
+    Version      int       `json:"version"`
+    RoomID       string    `json:"roomId"`
+    Salt         []byte    `json:"salt"`
+    Nonce        []byte    `json:"nonce"`
+    EncryptedKey []byte    `json:"encryptedKey"` // AES-GCM sealed x509 EC private key
+    Snapshot     *Snapshot `json:"snapshot"`       // its epochs carry the member set
+}
+
+// Bundle as written: the payload and a MAC over its exact bytes
+type stateBundle struct {
+    Payload json.RawMessage `json:"payload"`
+    MAC     []byte          `json:"mac"`
+}
+
+// Derive the encryption and MAC keys from a passphrase
+func bundleKeys(passphrase, salt []byte) (encKey, macKey []byte, err error) {
+    derived, err := scrypt.Key(passphrase, salt, bundleScryptN, bundleScryptR, bundleScryptP, 64)
+    if err != nil {
+        return nil, nil, err
+    }
+    return derived[:32], derived[32:], nil
+}
+
+// Write the node key, encrypted under the passphrase, with a snapshot of the graph
+func (hg *Hashgraph) ExportState(w io.Writer, passphrase []byte) error {
+    if hg.privateKey == nil {
+        return errors.New("no private key to export")
+    }
+    der, err := x509.MarshalECPrivateKey(hg.privateKey)
+    if err != nil {
+        return err
+    }
+
+    salt := make([]byte, 16)
+    if _, err := rand.Read(salt); err != nil {
+        return err
+    }
+    encKey, macKey, err := bundleKeys(passphrase, salt)
+    if err != nil {
+        return err
+    }
+    block, err := aes.NewCipher(encKey)
+    if err != nil {
+        return err
+    }
+    gcm, err := cipher.NewGCM(block)
+    if err != nil {
+        return err
+    }
+    nonce := make([]byte, gcm.NonceSize())
+    if _, err := rand.Read(nonce); err != nil {
+        return err
+    }
+
+    hg.mutex.RLock()
+    roomID := hg.roomID
+    hg.mutex.RUnlock()
+
+    payload, err := json.Marshal(stateBundlePayload{
+        Version:      stateBundleVersion,
+        RoomID:       roomID,
+        Salt:         salt,
+        Nonce:        nonce,
+        EncryptedKey: gcm.Seal(nil, nonce, der, nil),
+        Snapshot:     hg.Snapshot(),
+    })
+    if err != nil {
+        return err
+    }
+    mac := hmac.New(sha256.New, macKey)
+    mac.Write(payload)
+    return json.NewEncoder(w).Encode(stateBundle{Payload: payload, MAC: mac.Sum(nil)})
+}
+
+// Read a bundle written by ExportState, checking its MAC, decrypting the key and
+// validating every event, so the caller can restore the snapshot into a fresh graph
+func ImportState(r io.Reader, passphrase []byte) (*ecdsa.PrivateKey, *Snapshot, error) {
+    var bundle stateBundle
+    if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+        return nil, nil, err
+    }
+    var payload stateBundlePayload
+    if err := json.Unmarshal(bundle.Payload, &payload); err != nil {
+        return nil, nil, err
+    }
+    if payload.Version != stateBundleVersion {
+        return nil, nil, errBundleVersion
+    }
+
+    encKey, macKey, err := bundleKeys(passphrase, payload.Salt)
+    if err != nil {
+        return nil, nil, err
+    }
+    mac := hmac.New(sha256.New, macKey)
+    mac.Write(bundle.Payload)
+    if !hmac.Equal(mac.Sum(nil), bundle.MAC) {
+        return nil, nil, errBundleIntegrity
+    }
+
+    block, err := aes.NewCipher(encKey)
+    if err != nil {
+        return nil, nil, err
+    }
+    gcm, err := cipher.NewGCM(block)
+    if err != nil {
+        return nil, nil, err
+    }
+    der, err := gcm.Open(nil, payload.Nonce, payload.EncryptedKey, nil)
+    if err != nil {
+        return nil, nil, errBundleIntegrity
+    }
+    privateKey, err := x509.ParseECPrivateKey(der)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    snapshot := payload.Snapshot
+    if snapshot == nil {
+        return nil, nil, errBundleInconsistent
+    }
+    if err := validateBundleSnapshot(snapshot); err != nil {
+        return nil, nil, err
+    }
+    return privateKey, snapshot, nil
+}
+
+// Check that every event is intact and arrives after its parents, and that
+// the consensus order names only those events
+func validateBundleSnapshot(snapshot *Snapshot) error {
+    seen := make(map[string]bool, len(snapshot.Events))
+    for _, event := range snapshot.Events {
+        if err := verifyEventIntegrity(event); err != nil {
+            return fmt.Errorf("%w: event %s: %w", errBundleInconsistent, shortID(event.Hash), err)
+        }
+        if event.SelfParent != "" && !seen[event.SelfParent] {
+            return errBundleInconsistent
+        }
+        for _, parent := range event.OtherParents() {
+            if !seen[parent] {
+                return errBundleInconsistent
+            }
+        }
+        seen[event.Hash] = true
+    }
+    for _, hash := range snapshot.ConsensusOrder {
+        if !seen[hash] {
+            return errBundleInconsistent
+        }
+    }
+    return nil
+}
+
+// Export the persisted key and snapshot to a bundle, or import a bundle into them. Exporting
+// only reads: a missing key or snapshot fails rather than moving a node that never existed.
+func migrateState(exportPath, importPath, keyPath, snapshotPath string) error {
+    passphrase := []byte(os.Getenv("STATE_PASSPHRASE"))
+    if len(passphrase) == 0 {
+        return errors.New("STATE_PASSPHRASE is not set")
+    }
+    if snapshotPath == "" {
+        return errors.New("-snapshot is required to migrate state")
+    }
+
+    if exportPath != "" {
+        privateKey, err := loadKey(keyPath)
+        if err != nil {
+            return err
+        }
+        snapshot, err := LoadSnapshot(snapshotPath)
+        if err != nil {
+            return err
+        }
+        if snapshot == nil {
+            return fmt.Errorf("%w: %s", errNoSnapshot, snapshotPath)
+        }
+        hg := NewHashgraph(privateKey, &privateKey.PublicKey)
+        hg.roomID = defaultRoom
+        hg.RestoreSnapshot(snapshot)
+        f, err := os.OpenFile(exportPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+        if err != nil {
+            return fmt.Errorf("create bundle: %w", err)
+        }
+        defer f.Close()
+        if err := hg.ExportState(f, passphrase); err != nil {
+            return fmt.Errorf("export state: %w", err)
+        }
+        return nil
+    }
+
+    f, err := os.Open(importPath)
+    if err != nil {
+        return fmt.Errorf("open bundle: %w", err)
+    }
+    defer f.Close()
+    privateKey, snapshot, err := ImportState(f, passphrase)
+    if err != nil {
+        return fmt.Errorf("import state: %w", err)
+    }
+    // Refuse to clobber an existing identity
+    if _, err := os.Stat(keyPath); err == nil {
+        return fmt.Errorf("%w: %s", errKeyExists, keyPath)
+    }
+    der, err := x509.MarshalECPrivateKey(privateKey)
+    if err != nil {
+        return fmt.Errorf("encode key: %w", err)
+    }
+    block := &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+    if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+        return fmt.Errorf("write key: %w", err)
+    }
+    return SaveSnapshot(snapshotPath, snapshot)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStateBundleRoundTrip(t *testing.T) {
+    graph, keys, err := BuildTestGraph(51, 4, 150)
+    if err != nil {
+        t.Fatal(err)
+    }
+    members := testCreators(graph)
+    hg := NewHashgraph(keys[0], &keys[0].PublicKey)
+    hg.roomID = defaultRoom
+    hg.SetMembers(members)
+    addTestEvents(t, hg, graph)
+
+    var bundle bytes.Buffer
+    if err := hg.ExportState(&bundle, []byte("secret")); err != nil {
+        t.Fatal(err)
+    }
+    if bytes.Contains(bundle.Bytes(), keys[0].D.Bytes()) {
+        t.Fatal("bundle holds the key unencrypted")
+    }
+    key, snapshot, err := ImportState(bytes.NewReader(bundle.Bytes()), []byte("secret"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !key.Equal(keys[0]) {
+        t.Fatal("imported key differs")
+    }
+    restored := NewHashgraph(key, &key.PublicKey)
+    restored.RestoreSnapshot(snapshot)
+    if restored.EventCount() != hg.EventCount() || !reflect.DeepEqual(orderHashes(restored), orderHashes(hg)) {
+        t.Fatal("imported node differs from the exported one")
+    }
+    if !reflect.DeepEqual(restored.MembersAt(1), hg.MembersAt(1)) {
+        t.Fatal("imported member set differs")
+    }
+
+    if _, _, err := ImportState(bytes.NewReader(bundle.Bytes()), []byte("wrong")); !errors.Is(err, errBundleIntegrity) {
+        t.Fatalf("wrong passphrase: %v", err)
+    }
+    var written stateBundle
+    if err := json.Unmarshal(bundle.Bytes(), &written); err != nil {
+        t.Fatal(err)
+    }
+    written.Payload = bytes.Replace(written.Payload, []byte(`"roomId":"lobby"`), []byte(`"roomId":"other"`), 1)
+    tampered, _ := json.Marshal(written)
+    if _, _, err := ImportState(bytes.NewReader(tampered), []byte("secret")); !errors.Is(err, errBundleIntegrity) {
+        t.Fatalf("tampered payload: %v", err)
+    }
+}
+
+func TestBundleSnapshotValidated(t *testing.T) {
+    graph := buildTestGraph(t, 52, 4, 40)
+    members := testCreators(graph)
+    hg := testHashgraph(t, graph, members)
+    snapshot := hg.Snapshot()
+    if err := validateBundleSnapshot(snapshot); err != nil {
+        t.Fatal(err)
+    }
+    snapshot.ConsensusOrder = append(snapshot.ConsensusOrder, fileHash([]byte("never inserted")))
+    if err := validateBundleSnapshot(snapshot); !errors.Is(err, errBundleInconsistent) {
+        t.Fatalf("consensus order naming a missing event: %v", err)
+    }
+
+    snapshot = hg.Snapshot()
+    snapshot.Events[len(snapshot.Events)-1].Transactions = [][]byte{[]byte("forged")}
+    if err := validateBundleSnapshot(snapshot); !errors.Is(err, errHashMismatch) {
+        t.Fatalf("tampered event: %v", err)
+    }
+    snapshot = hg.Snapshot()
+    snapshot.Events = snapshot.Events[1:]
+    if err := validateBundleSnapshot(snapshot); !errors.Is(err, errBundleInconsistent) {
+        t.Fatalf("event missing its parent: %v", err)
+    }
+}
+
+func TestMigrateStateKeepsExistingKey(t *testing.T) {
+    dir := t.TempDir()
+    graph := buildTestGraph(t, 53, 4, 40)
+    snapshotPath := filepath.Join(dir, "snapshot.json")
+    if err := SaveSnapshot(snapshotPath, testHashgraph(t, graph, testCreators(graph)).Snapshot()); err != nil {
+        t.Fatal(err)
+    }
+    t.Setenv("STATE_PASSPHRASE", "secret")
+
+    keyPath, bundlePath := filepath.Join(dir, "key.pem"), filepath.Join(dir, "bundle.json")
+
+    // Exporting only reads: without a key or a snapshot there is nothing to move
+    if err := migrateState(bundlePath, "", keyPath, snapshotPath); !errors.Is(err, os.ErrNotExist) {
+        t.Fatalf("export without a key: %v", err)
+    }
+    if _, err := os.Stat(keyPath); !os.IsNotExist(err) {
+        t.Fatal("export created a key")
+    }
+    if _, err := loadOrCreateKey(keyPath, elliptic.P256()); err != nil {
+        t.Fatal(err)
+    }
+    if err := migrateState(bundlePath, "", keyPath, filepath.Join(dir, "missing.json")); !errors.Is(err, errNoSnapshot) {
+        t.Fatalf("export without a snapshot: %v", err)
+    }
+
+    if err := migrateState(bundlePath, "", keyPath, snapshotPath); err != nil {
+        t.Fatal(err)
+    }
+    before, err := os.ReadFile(keyPath)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := migrateState("", bundlePath, keyPath, snapshotPath); !errors.Is(err, errKeyExists) {
+        t.Fatalf("import over an existing key: %v", err)
+    }
+
+    target := t.TempDir()
+    importedKey, importedSnapshot := filepath.Join(target, "key.pem"), filepath.Join(target, "snapshot.json")
+    if err := migrateState("", bundlePath, importedKey, importedSnapshot); err != nil {
+        t.Fatal(err)
+    }
+    after, err := os.ReadFile(importedKey)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !bytes.Equal(before, after) {
+        t.Fatal("migrated key differs")
+    }
+    snapshot, err := LoadSnapshot(importedSnapshot)
+    if err != nil || snapshot == nil || len(snapshot.Events) != len(graph) {
+        t.Fatalf("migrated snapshot: %v", err)
+    }
+}
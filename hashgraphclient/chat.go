@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+)
+
+// Chat transaction types
+const (
+    chatMessage = "message"
+    chatEdit    = "edit"
+    chatDelete  = "delete"
+    chatFile    = "file"
+    chatRevoke  = "revoke" // key revocation, a control transaction that is not rendered
+    chatMember  = "member" // membership change, a control transaction that is not rendered
+    chatProfile = "profile" // display name of the author, applied to the view but not rendered as a message
+)
+
+// Chat transaction; plain-text transactions are read as messages
+type ChatTransaction struct {
+    Type      string `json:"type"`
+    MessageID string `json:"messageId,omitempty"` // message an edit or delete refers to
+    Text      string `json:"text,omitempty"`
+    File      *FileReference `json:"file,omitempty"`
+    Name      string `json:"name,omitempty"` // display name set by a profile transaction, empty clears it
+}
+
+// ID of the message carried by a transaction
+func MessageID(eventHash string, index int) string {
+    return eventHash + "/" + strconv.Itoa(index)
+}
+
+// Encode an edit of a prior message
+func EditTransaction(messageID, text string) []byte {
+    data, _ := json.Marshal(ChatTransaction{Type: chatEdit, MessageID: messageID, Text: text})
+    return data
+}
+
+// Encode the deletion of a prior message
+func DeleteTransaction(messageID string) []byte {
+    data, _ := json.Marshal(ChatTransaction{Type: chatDelete, MessageID: messageID})
+    return data
+}
+
+// Decode a transaction, treating anything that is not an edit, delete, file reference, revocation,
+// membership change or profile as a plain message
+func decodeChatTransaction(tx []byte) ChatTransaction {
+    var chatTx ChatTransaction
+    if err := json.Unmarshal(tx, &chatTx); err == nil {
+        switch {
+        case chatTx.Type == chatEdit, chatTx.Type == chatDelete:
+            return chatTx
+        case chatTx.Type == chatFile && chatTx.File != nil:
+            return chatTx
+        case chatTx.Type == chatRevoke, chatTx.Type == chatMember, chatTx.Type == chatProfile:
+            return chatTx
+        }
+    }
+    return ChatTransaction{Type: chatMessage, Text: string(tx)}
+}
+
+// Author of a transaction: its signer for batched events, otherwise the event creator
+func transactionAuthor(event *Event, index int) string {
+    if index < len(event.TransactionSignatures) {
+        return event.TransactionSignatures[index].Author
+    }
+    return event.Creator
+}
+
+// Message as currently rendered
+type RenderedMessage struct {
+    ID        string `json:"id"`
+    Author    string `json:"author"`
+    AuthorName string `json:"authorName,omitempty"` // author's display name as of the latest profile in consensus order
+    Text      string `json:"text"`
+    Edited    bool   `json:"edited"`
+    Deleted   bool   `json:"deleted"`
+    Ephemeral bool   `json:"ephemeral"`
+    File      *FileReference `json:"file,omitempty"`
+    Truncated bool   `json:"truncated,omitempty"` // Text is a preview of a longer message
+    ContentHash string `json:"contentHash,omitempty"` // hash the full text can be fetched by when truncated
+}
+
+// Default bytes of a message shown before it is truncated to a preview
+const defaultPreviewLength = 500
+
+// Default number of finalized messages the chat view retains
+const defaultHistoryLimit = 1000
+
+// Rendered chat of a room, built from events in consensus order so every node shows the same result.
+// Original events stay in the graph unchanged; edits and deletes only change the view.
+type ChatView struct {
+    messages []*RenderedMessage
+    index    map[string]*RenderedMessage
+    previewLength int
+    store    *FileStore
+    historyLimit int
+    names    map[string]string // display name by author
+    holders  map[string]string // author by display name
+    mutex    sync.RWMutex
+}
+
+// create new chat view
+func NewChatView() *ChatView {
+    return &ChatView{
+        index:   make(map[string]*RenderedMessage),
+        names:   make(map[string]string),
+        holders: make(map[string]string),
+    }
+}
+
+// Show long texts as previews of at most length bytes, keeping the full text in store
+// so it can be served to and fetched from peers by hash. 0 disables truncation.
+func (cv *ChatView) SetPreview(length int, store *FileStore) {
+    cv.mutex.Lock()
+    defer cv.mutex.Unlock()
+    cv.previewLength = length
+    cv.store = store
+}
+
+// Keep only the most recent limit messages, 0 keeps all of them.
+// Edits and deletes of a message that has been dropped are ignored.
+func (cv *ChatView) SetHistoryLimit(limit int) {
+    cv.mutex.Lock()
+    defer cv.mutex.Unlock()
+    cv.historyLimit = limit
+    cv.trim()
+}
+
+// Drop the oldest messages beyond the history limit, caller holds the lock
+func (cv *ChatView) trim() {
+    if cv.historyLimit <= 0 || len(cv.messages) <= cv.historyLimit {
+        return
+    }
+    drop := len(cv.messages) - cv.historyLimit
+    for i, message := range cv.messages[:drop] {
+        delete(cv.index, message.ID)
+        cv.messages[i] = nil
+    }
+    // Reslicing only advances past the dropped messages; once the spare capacity is used up,
+    // append moves the retained ones to a new array, so the copy is paid once per limit messages
+    cv.messages = cv.messages[drop:]
+}
+
+// Set a message's text, truncated to a preview if it is too long, caller holds the lock
+func (cv *ChatView) setText(message *RenderedMessage, text string) {
+    message.Text, message.Truncated, message.ContentHash = text, false, ""
+    if cv.previewLength <= 0 || len(text) <= cv.previewLength || cv.store == nil {
+        return
+    }
+    // Cut on a rune boundary so the preview stays valid UTF-8
+    cut := 0
+    for i := range text {
+        if i > cv.previewLength {
+            break
+        }
+        cut = i
+    }
+    message.Text = text[:cut] + "…"
+    message.Truncated = true
+    message.ContentHash = cv.store.Put([]byte(text))
+}
+
+// Apply a finalized event, returning the messages it added or changed.
+// Only a message's author may edit or delete it.
+func (cv *ChatView) Apply(event *Event) []RenderedMessage {
+    cv.mutex.Lock()
+    defer cv.mutex.Unlock()
+
+    var changed []RenderedMessage
+    for i, tx := range event.Transactions {
+        chatTx := decodeChatTransaction(tx)
+        author := transactionAuthor(event, i)
+        if chatTx.Type == chatRevoke || chatTx.Type == chatMember {
+            continue
+        }
+        if chatTx.Type == chatProfile {
+            cv.setName(author, chatTx.Name)
+            continue
+        }
+        if chatTx.Type == chatMessage || chatTx.Type == chatFile {
+            message := &RenderedMessage{
+                ID:        MessageID(event.Hash, i),
+                Author:    author,
+                AuthorName: cv.names[author],
+                Ephemeral: event.Ephemeral,
+                File:      chatTx.File,
+            }
+            if message.File != nil {
+                message.Text = message.File.Name
+            } else {
+                cv.setText(message, chatTx.Text)
+            }
+            cv.messages = append(cv.messages, message)
+            cv.index[message.ID] = message
+            cv.trim()
+            changed = append(changed, *message)
+            continue
+        }
+
+        message, ok := cv.index[chatTx.MessageID]
+        if !ok || message.Author != author || message.Deleted {
+            continue
+        }
+        if chatTx.Type == chatEdit {
+            cv.setText(message, chatTx.Text)
+            message.Edited = true
+        } else {
+            cv.setText(message, "")
+            message.Deleted = true
+        }
+        changed = append(changed, *message)
+    }
+    return changed
+}
+
+// Get the rendered messages in consensus order
+func (cv *ChatView) Messages() []RenderedMessage {
+    cv.mutex.RLock()
+    defer cv.mutex.RUnlock()
+    messages := make([]RenderedMessage, 0, len(cv.messages))
+    for _, message := range cv.messages {
+        messages = append(messages, *message)
+    }
+    return messages
+}
+
+// Get up to limit of the most recent messages, oldest first; 0 returns all retained messages
+func (cv *ChatView) Recent(limit int) []RenderedMessage {
+    cv.mutex.RLock()
+    defer cv.mutex.RUnlock()
+    recent := cv.messages
+    if limit > 0 && len(recent) > limit {
+        recent = recent[len(recent)-limit:]
+    }
+    messages := make([]RenderedMessage, 0, len(recent))
+    for _, message := range recent {
+        messages = append(messages, *message)
+    }
+    return messages
+}
+
+// Look up a file shared in the chat by its content hash
+func (cv *ChatView) File(hash string) (FileReference, bool) {
+    cv.mutex.RLock()
+    defer cv.mutex.RUnlock()
+    for _, message := range cv.messages {
+        if message.File != nil && message.File.Hash == hash && !message.Deleted {
+            return *message.File, true
+        }
+    }
+    return FileReference{}, false
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func chatEvent(hash, creator string, txs ...[]byte) *Event {
+    return &Event{Hash: hash, Creator: creator, Transactions: txs}
+}
+
+func TestChatHistoryLimit(t *testing.T) {
+    cv := NewChatView()
+    cv.SetHistoryLimit(10)
+    // Reslicing keeps the end of the backing array in place, so a new end means the messages moved
+    arrayEnd := func() **RenderedMessage {
+        if cap(cv.messages) == 0 {
+            return nil
+        }
+        return &cv.messages[:cap(cv.messages)][cap(cv.messages)-1]
+    }
+    moves := 0
+    for i := 0; i < 1000; i++ {
+        before := arrayEnd()
+        cv.Apply(chatEvent(fmt.Sprintf("e%d", i), "alice", []byte(fmt.Sprintf("message %d", i))))
+        if arrayEnd() != before {
+            moves++
+        }
+        if cap(cv.messages) > 4*10 {
+            t.Fatalf("after %d messages the history holds capacity for %d", i+1, cap(cv.messages))
+        }
+    }
+    // The retained messages are moved to a new array about once per limit messages, not on every one
+    if moves > 1000/5 {
+        t.Fatalf("history moved %d times for 1000 messages", moves)
+    }
+    messages := cv.Messages()
+    if len(messages) != 10 || len(cv.index) != 10 {
+        t.Fatalf("%d messages and %d indexed, want 10", len(messages), len(cv.index))
+    }
+    for i, message := range messages {
+        if want := fmt.Sprintf("message %d", 990+i); message.Text != want {
+            t.Fatalf("message %d is %q, want %q", i, message.Text, want)
+        }
+    }
+    if recent := cv.Recent(3); len(recent) != 3 || recent[2].Text != "message 999" {
+        t.Fatalf("recent messages %v", recent)
+    }
+
+    // An edit of a dropped message is ignored
+    if changed := cv.Apply(chatEvent("edit", "alice", EditTransaction(MessageID("e0", 0), "late"))); len(changed) != 0 {
+        t.Fatal("edit of a dropped message applied")
+    }
+}
+
+func TestChatEditAndDeleteByAuthorOnly(t *testing.T) {
+    cv := NewChatView()
+    cv.Apply(chatEvent("e1", "alice", []byte("hello")))
+    id := MessageID("e1", 0)
+
+    if changed := cv.Apply(chatEvent("e2", "mallory", EditTransaction(id, "hacked"))); len(changed) != 0 {
+        t.Fatal("edit by another author applied")
+    }
+    cv.Apply(chatEvent("e3", "alice", EditTransaction(id, "hello there")))
+    if message := cv.Messages()[0]; message.Text != "hello there" || !message.Edited {
+        t.Fatalf("edited message %+v", message)
+    }
+    cv.Apply(chatEvent("e4", "alice", DeleteTransaction(id)))
+    cv.Apply(chatEvent("e5", "alice", EditTransaction(id, "back")))
+    if message := cv.Messages()[0]; message.Text != "" || !message.Deleted {
+        t.Fatalf("deleted message %+v", message)
+    }
+}
+
+func TestEditAppliedInConsensusOrder(t *testing.T) {
+    graph := buildTestGraph(t, 41, 4, 300)
+    members := testCreators(graph)
+
+    // One creator posts a message and later, further down its own chain, edits it
+    var chain []int
+    for i, event := range graph[:100] {
+        if event.Creator == graph[0].Creator {
+            chain = append(chain, i)
+        }
+    }
+    original, edit := chain[1], chain[len(chain)-1]
+    posted := rewriteTestEvents(t, graph, func(event *Event) {
+        if event.Hash == graph[original].Hash {
+            event.Transactions = [][]byte{[]byte("helo")}
+        }
+    })
+    id := MessageID(posted[original].Hash, 0)
+    edited := rewriteTestEvents(t, posted, func(event *Event) {
+        if event.Hash == posted[edit].Hash {
+            event.Transactions = [][]byte{EditTransaction(id, "hello")}
+        }
+    })
+    id = MessageID(edited[original].Hash, 0)
+
+    render := func(hg *Hashgraph) []RenderedMessage {
+        cv := NewChatView()
+        hg.mutex.RLock()
+        defer hg.mutex.RUnlock()
+        for _, event := range hg.ConsensusOrder {
+            cv.Apply(event)
+        }
+        return cv.Messages()
+    }
+    first := render(testHashgraph(t, edited, members))
+    second := render(testHashgraph(t, shuffledTopological(edited, 12), members))
+    var message *RenderedMessage
+    for i := range first {
+        if first[i].ID == id {
+            message = &first[i]
+        }
+    }
+    if message == nil || message.Text != "hello" || !message.Edited {
+        t.Fatalf("rendered message %+v, want the edit applied", message)
+    }
+    if !reflect.DeepEqual(first, second) {
+        t.Fatal("nodes rendered the chat differently")
+    }
+}
+
+func TestLongMessagePreviewedAndFetchedFull(t *testing.T) {
+    owner := NewFileStore()
+    cv := NewChatView()
+    cv.SetPreview(20, owner)
+    long := strings.Repeat("héllo wörld ", 50)
+    cv.Apply(chatEvent("e1", "alice", []byte("short"), []byte(long)))
+
+    messages := cv.Messages()
+    if messages[0].Text != "short" || messages[0].Truncated {
+        t.Fatalf("short message %+v", messages[0])
+    }
+    preview := messages[1]
+    if !preview.Truncated || len(preview.Text) > 20+len("…") || !strings.HasPrefix(long, strings.TrimSuffix(preview.Text, "…")) {
+        t.Fatalf("preview %q", preview.Text)
+    }
+    if !utf8.ValidString(preview.Text) {
+        t.Fatal("preview cut inside a rune")
+    }
+    if full, ok := owner.Get(preview.ContentHash); !ok || string(full) != long {
+        t.Fatal("full text not kept under the preview's hash")
+    }
+
+    // A peer fetches the full text by the preview's hash
+    _, local, remote := connectedTestChannel(t, "files")
+    NewFileTransfer(local, owner)
+    full, err := NewFileTransfer(remote, NewFileStore()).FetchFull(preview.ContentHash)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if string(full) != long {
+        t.Fatalf("fetched %d bytes, want %d", len(full), len(long))
+    }
+}
+
+func TestProfileNamesAppliedInConsensusOrder(t *testing.T) {
+    network := newLocalTestNetwork(t, 157, 4)
+    views := make([]*ChatView, len(network))
+    for i, hg := range network {
+        views[i] = NewChatView()
+        hg.OnFinalized(func(event *Event) { views[i].Apply(event) })
+    }
+    hello, err := network[0].SubmitTransaction([]byte("hello"), "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    network.broadcast(t, 0, hello)
+    profile, err := network[0].SubmitTransaction(ProfileTransaction("  alice "), "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    network.broadcast(t, 0, profile)
+
+    author := network[0].CreatorID()
+    if views[1].DisplayName(author) != "" {
+        t.Fatal("name shown before the profile reached consensus")
+    }
+    for i := 0; i < 120; i++ {
+        event, err := network[i%len(network)].Heartbeat()
+        if err != nil {
+            t.Fatal(err)
+        }
+        network.broadcast(t, i%len(network), event)
+    }
+    for i, view := range views {
+        messages := view.Messages()
+        if view.DisplayName(author) != "alice" || len(messages) != 1 || messages[0].Sender() != "alice" {
+            t.Fatalf("node %d renders %q for %+v", i, view.DisplayName(author), messages)
+        }
+    }
+}
+
+func TestProfileNameConflictsLastWriteWins(t *testing.T) {
+    cv := NewChatView()
+    cv.Apply(chatEvent("e1", "alice-key", []byte("hi"), ProfileTransaction("alice")))
+    cv.Apply(chatEvent("e2", "bob-key", ProfileTransaction("bob")))
+    if cv.DisplayName("alice-key") != "alice" || cv.Messages()[0].AuthorName != "alice" {
+        t.Fatal("profile did not rename the author's earlier message")
+    }
+
+    // A name taken later moves to the latest claimant
+    cv.Apply(chatEvent("e3", "bob-key", ProfileTransaction("alice")))
+    if cv.DisplayName("bob-key") != "alice" || cv.DisplayName("alice-key") != "" || cv.Messages()[0].Sender() != shortID("alice-key") {
+        t.Fatal("claimed name not moved to its latest holder")
+    }
+    // The previous name of a renamed author is free again
+    cv.Apply(chatEvent("e4", "alice-key", ProfileTransaction("bob")))
+    if cv.DisplayName("alice-key") != "bob" {
+        t.Fatal("released name not reusable")
+    }
+
+    // Invalid names are ignored, an empty one clears the name
+    cv.Apply(chatEvent("e5", "alice-key", ProfileTransaction(strings.Repeat("x", maxDisplayNameLength+1)), ProfileTransaction("bad\nname")))
+    if cv.DisplayName("alice-key") != "bob" {
+        t.Fatal("invalid name applied")
+    }
+    cv.Apply(chatEvent("e6", "alice-key", ProfileTransaction("")))
+    if cv.DisplayName("alice-key") != "" || len(cv.Messages()) != 1 {
+        t.Fatal("profile not cleared, or rendered as a message")
+    }
+}
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"encoding/hex"
+	"sort"
+	"time"
+)
+
+// Every coinRoundFrequency-th voting round is a coin round
+const coinRoundFrequency = 10
+
+// Number of members in the latest agreed member set, 0 while there is none. Creators outside
+// the set never count, however many of them post, so every node works to the same threshold.
+func (hg *Hashgraph) memberCount() int {
+    if len(hg.epochs) == 0 {
+        return 0
+    }
+    return len(hg.epochs[len(hg.epochs)-1].Members)
+}
+
+// Number of members in the latest set that have events in the graph
+func (hg *Hashgraph) presentMembers() int {
+    if len(hg.epochs) == 0 {
+        return 0
+    }
+    count := 0
+    for member := range hg.epochs[len(hg.epochs)-1].Members {
+        if _, ok := hg.heads[member]; ok {
+            count++
+        }
+    }
+    return count
+}
+
+// More than two thirds of the members
+func (hg *Hashgraph) isSupermajority(count int) bool {
+    members := hg.memberCount()
+    return members > 0 && 3*count > 2*members
+}
+
+// Known parents of an event
+func (hg *Hashgraph) parents(event *Event) []*Event {
+    var parents []*Event
+    if p, ok := hg.Events[event.SelfParent]; ok {
+        parents = append(parents, p)
+    }
+    for _, hash := range event.OtherParents() {
+        if p, ok := hg.Events[hash]; ok {
+            parents = append(parents, p)
+        }
+    }
+    return parents
+}
+
+// Highest Lamport time among an event's known parents, 0 for a first event
+func (hg *Hashgraph) parentLamport(event *Event) int {
+    latest := 0
+    for _, p := range hg.parents(event) {
+        if p.LamportTime > latest {
+            latest = p.LamportTime
+        }
+    }
+    return latest
+}
+
+// Check whether y is an ancestor of x (every event is its own ancestor)
+func (hg *Hashgraph) ancestor(x, y *Event) bool {
+    if x.Hash == y.Hash {
+        return true
+    }
+    if x.RoundCreated < y.RoundCreated {
+        return false
+    }
+    key := x.Hash + y.Hash
+    hg.ancestorMutex.Lock()
+    result, ok := hg.ancestorCache[key]
+    hg.ancestorMutex.Unlock()
+    if ok {
+        return result
+    }
+    for _, p := range hg.parents(x) {
+        if hg.ancestor(p, y) {
+            result = true
+            break
+        }
+    }
+    hg.ancestorMutex.Lock()
+    hg.ancestorCache[key] = result
+    hg.ancestorMutex.Unlock()
+    return result
+}
+
+// Check whether x strongly sees y through a supermajority of the creators that are members in y's round
+func (hg *Hashgraph) stronglySee(x, y *Event) bool {
+    creators := make(map[string]bool)
+    for round := y.RoundCreated; round <= x.RoundCreated; round++ {
+        for _, z := range hg.roundEvents(round) {
+            if !creators[z.Creator] && hg.isMemberAt(y.RoundCreated, z.Creator) && hg.ancestor(x, z) && hg.ancestor(z, y) {
+                creators[z.Creator] = true
+            }
+        }
+    }
+    return hg.isSupermajorityAt(y.RoundCreated, len(creators))
+}
+
+// Order events deterministically within a round: by creator, then by position in the creator's chain
+func roundLess(a, b *Event) bool {
+    if a.Creator != b.Creator {
+        return a.Creator < b.Creator
+    }
+    if a.LamportTime != b.LamportTime {
+        return a.LamportTime < b.LamportTime
+    }
+    return a.Hash < b.Hash
+}
+
+// Insert an event into its round keeping the round sorted, caller holds the lock
+func (hg *Hashgraph) addToRound(event *Event) {
+    events := hg.Rounds[event.RoundCreated]
+    i := sort.Search(len(events), func(i int) bool { return roundLess(event, events[i]) })
+    events = append(events, nil)
+    copy(events[i+1:], events[i:])
+    events[i] = event
+    hg.Rounds[event.RoundCreated] = events
+
+    if hg.roundCounts[event.RoundCreated] == nil {
+        hg.roundCounts[event.RoundCreated] = make(map[string]int)
+    }
+    hg.roundCounts[event.RoundCreated][event.Creator]++
+}
+
+// Events of a round in deterministic order, identical on every node regardless of arrival order
+func (hg *Hashgraph) roundEvents(round int) []*Event {
+    return hg.Rounds[round]
+}
+
+// All events with parents before children, ordered by Lamport time then hash
+func (hg *Hashgraph) topologicalEvents() []*Event {
+    events := make([]*Event, 0, len(hg.Events))
+    for _, event := range hg.Events {
+        events = append(events, event)
+    }
+    sortTopological(events)
+    return events
+}
+
+// Sort events parents first, by Lamport time then hash
+func sortTopological(events []*Event) {
+    sort.Slice(events, func(i, j int) bool {
+        if events[i].LamportTime != events[j].LamportTime {
+            return events[i].LamportTime < events[j].LamportTime
+        }
+        return events[i].Hash < events[j].Hash
+    })
+}
+
+// Copy of every event, parents first. Only the copying happens under the read lock, so long
+// iterations such as exports and verification run without blocking inserts; consensus fields
+// in the copies are those at the moment of the snapshot.
+func (hg *Hashgraph) SnapshotEvents() []*Event {
+    hg.mutex.RLock()
+    events := hg.copyEvents()
+    hg.mutex.RUnlock()
+    sortTopological(events)
+    return events
+}
+
+// Shallow copies of events, so they can be read and encoded after the lock is released; caller holds the lock
+func copyEventList(events []*Event) []*Event {
+    copies := make([]Event, len(events))
+    copied := make([]*Event, len(events))
+    for i, event := range events {
+        copies[i] = *event
+        copied[i] = &copies[i]
+    }
+    return copied
+}
+
+// Shallow copies of every event, caller holds the lock
+func (hg *Hashgraph) copyEvents() []*Event {
+    copies := make([]Event, len(hg.Events))
+    events := make([]*Event, 0, len(hg.Events))
+    for _, event := range hg.Events {
+        copies[len(events)] = *event
+        events = append(events, &copies[len(events)])
+    }
+    return events
+}
+
+// Witnesses of a round, excluding those of creators not members in the round. Forked creators'
+// witnesses still count: when a node learns of a fork depends on arrival order, which consensus must not.
+func (hg *Hashgraph) witnesses(round int) []*Event {
+    var witnesses []*Event
+    for _, event := range hg.roundEvents(round) {
+        if event.Witness && hg.isMemberAt(round, event.Creator) {
+            witnesses = append(witnesses, event)
+        }
+    }
+    return witnesses
+}
+
+// Assign the created round and witness flag of a newly inserted event
+func (hg *Hashgraph) divideRounds(event *Event) {
+    event.RoundCreated = hg.createdRound(event)
+    selfParent, ok := hg.Events[event.SelfParent]
+    event.Witness = !ok || selfParent.RoundCreated < event.RoundCreated
+}
+
+// Round an event is created in, given the parents already in the graph
+func (hg *Hashgraph) createdRound(event *Event) int {
+    round := 1
+    for _, p := range hg.parents(event) {
+        if p.RoundCreated > round {
+            round = p.RoundCreated
+        }
+    }
+    // Strong seeing reads the round of the event, so it is tried on a copy, leaving the
+    // event untouched for callers that only check where it would be placed
+    probe := *event
+    probe.RoundCreated = round
+
+    witnesses := hg.witnesses(round)
+    seen := make([]bool, len(witnesses))
+    hg.parallelFor(len(witnesses), func(i int) {
+        seen[i] = hg.stronglySee(&probe, witnesses[i])
+    })
+    creators := make(map[string]bool)
+    for i, w := range witnesses {
+        if seen[i] {
+            creators[w.Creator] = true
+        }
+    }
+    if hg.isSupermajorityAt(round, len(creators)) {
+        return round + 1
+    }
+    return round
+}
+
+// Rounds in ascending order
+func (hg *Hashgraph) sortedRounds() []int {
+    rounds := make([]int, 0, len(hg.Rounds))
+    for round := range hg.Rounds {
+        rounds = append(rounds, round)
+    }
+    sort.Ints(rounds)
+    return rounds
+}
+
+// Middle bit of the event signature, used as the coin in coin rounds
+func coinBit(event *Event) bool {
+    signature, err := hex.DecodeString(event.Signature)
+    if err != nil || len(signature) == 0 {
+        return false
+    }
+    return signature[len(signature)/2]&1 == 1
+}
+
+// Decide the fame of witnesses by virtual voting
+func (hg *Hashgraph) decideFame() {
+    rounds := hg.sortedRounds()
+    for i, round := range rounds {
+        for _, x := range hg.witnesses(round) {
+            if x.Famous != nil {
+                continue
+            }
+        voting:
+            for _, roundY := range rounds[i+1:] {
+                d := roundY - round
+                witnessesY := hg.witnesses(roundY)
+                // Each witness's tally reads only the previous round's votes, so tallies run in parallel
+                // and are applied below in witness order, exactly as a serial pass would
+                tallies := make([][2]int, len(witnessesY))
+                hg.parallelFor(len(witnessesY), func(j int) {
+                    y := witnessesY[j]
+                    if d == 1 {
+                        if hg.ancestor(y, x) {
+                            tallies[j][0] = 1
+                        }
+                        return
+                    }
+                    for _, w := range hg.witnesses(roundY - 1) {
+                        if !hg.stronglySee(y, w) {
+                            continue
+                        }
+                        if hg.votes[w.Hash][x.Hash] {
+                            tallies[j][0]++
+                        } else {
+                            tallies[j][1]++
+                        }
+                    }
+                })
+                for j, y := range witnessesY {
+                    if hg.votes[y.Hash] == nil {
+                        hg.votes[y.Hash] = make(map[string]bool)
+                    }
+                    if d == 1 {
+                        hg.votes[y.Hash][x.Hash] = tallies[j][0] == 1
+                        continue
+                    }
+
+                    yes, no := tallies[j][0], tallies[j][1]
+                    vote, count := yes >= no, yes
+                    if no > yes {
+                        count = no
+                    }
+
+                    if d%coinRoundFrequency != 0 {
+                        hg.votes[y.Hash][x.Hash] = vote
+                        if hg.isSupermajorityAt(roundY-1, count) {
+                            x.Famous = &vote
+                            break voting
+                        }
+                    } else if hg.isSupermajorityAt(roundY-1, count) {
+                        hg.votes[y.Hash][x.Hash] = vote
+                    } else {
+                        hg.votes[y.Hash][x.Hash] = coinBit(y)
+                    }
+                }
+            }
+        }
+    }
+}
+
+// Check whether every witness of a round has its fame decided
+func (hg *Hashgraph) roundDecided(round int) bool {
+    witnesses := hg.witnesses(round)
+    if len(witnesses) == 0 {
+        return false
+    }
+    for _, w := range witnesses {
+        if w.Famous == nil {
+            return false
+        }
+    }
+    return true
+}
+
+// Famous witnesses of a decided round
+func (hg *Hashgraph) famousWitnesses(round int) []*Event {
+    var famous []*Event
+    for _, w := range hg.witnesses(round) {
+        if w.Famous != nil && *w.Famous {
+            famous = append(famous, w)
+        }
+    }
+    return famous
+}
+
+// Earliest self-ancestor of w that has x as an ancestor: the event in which w's creator first
+// learned of x, as the graph records it, so every node finds the same one whatever order events
+// arrived in. Caller holds the lock and x is an ancestor of w.
+func (hg *Hashgraph) firstSeenIn(w, x *Event) *Event {
+    z := w
+    for {
+        selfParent, ok := hg.Events[z.SelfParent]
+        if !ok || !hg.ancestor(selfParent, x) {
+            return z
+        }
+        z = selfParent
+    }
+}
+
+// Median of timestamps; for an even count the lower of the two middle values, so every node picks the same one
+func medianTime(times []time.Time) time.Time {
+    sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+    return times[(len(times)-1)/2]
+}
+
+// Famous witness creator whose first sight of an event fed its consensus timestamp, kept for audit
+type TimestampSource struct {
+    Member  string    `json:"member"`
+    Witness string    `json:"witness"` // famous witness of the round received through which the member saw the event
+    SeenIn  string    `json:"seenIn"`  // the member's earliest event with the event as an ancestor
+    SeenAt  time.Time `json:"seenAt"`  // timestamp of that event
+    Median  bool      `json:"median"` // the time taken as the consensus timestamp
+}
+
+// Timestamp sources in time order, marking the one medianTime picks
+func timestampSources(sources []TimestampSource) []TimestampSource {
+    sort.Slice(sources, func(i, j int) bool {
+        if !sources[i].SeenAt.Equal(sources[j].SeenAt) {
+            return sources[i].SeenAt.Before(sources[j].SeenAt)
+        }
+        return sources[i].Member < sources[j].Member
+    })
+    sources[(len(sources)-1)/2].Median = true
+    return sources
+}
+
+// Find the round received and consensus timestamp of events, returning newly finalized events in order
+func (hg *Hashgraph) findOrder() []*Event {
+    var finalized []*Event
+    rounds := hg.sortedRounds()
+    for i := 0; i < len(rounds); i++ {
+        round := rounds[i]
+        if round <= hg.lastReceivedRound {
+            continue
+        }
+        if !hg.roundDecided(round) {
+            break
+        }
+        famous := hg.famousWitnesses(round)
+        hg.lastReceivedRound = round
+        if len(famous) == 0 {
+            continue
+        }
+
+        var candidates []*Event
+        for _, x := range hg.Events {
+            if x.RoundReceived == 0 {
+                candidates = append(candidates, x)
+            }
+        }
+        received := make([]bool, len(candidates))
+        var batch []*Event
+        hg.parallelFor(len(candidates), func(j int) {
+            x := candidates[j]
+            // Each famous witness's creator contributes the time it first saw x
+            times := make([]time.Time, 0, len(famous))
+            sources := make([]TimestampSource, 0, len(famous))
+            for _, w := range famous {
+                if !hg.ancestor(w, x) {
+                    return
+                }
+                z := hg.firstSeenIn(w, x)
+                times = append(times, z.Timestamp)
+                sources = append(sources, TimestampSource{Member: w.Creator, Witness: w.Hash, SeenIn: z.Hash, SeenAt: z.Timestamp})
+            }
+            x.ConsensusTimestamp = medianTime(times)
+            x.TimestampSources = timestampSources(sources)
+            received[j] = true
+        })
+        for j, x := range candidates {
+            if received[j] {
+                x.RoundReceived = round
+                batch = append(batch, x)
+            }
+        }
+        sort.Slice(batch, func(i, j int) bool {
+            a, b := batch[i], batch[j]
+            if !a.ConsensusTimestamp.Equal(b.ConsensusTimestamp) {
+                return a.ConsensusTimestamp.Before(b.ConsensusTimestamp)
+            }
+            return a.Hash < b.Hash
+        })
+        batch = hg.applyRevocations(batch)
+        finalized = append(finalized, batch...)
+
+        // A membership change applies from the next round, whose rounds and fame were
+        // worked out under the old member set and are recomputed under the new one
+        if hg.applyMembershipChanges(batch) {
+            hg.reround(round + 1)
+            hg.decideFame()
+            rounds = hg.sortedRounds()
+            i = -1
+        }
+    }
+
+    hg.ConsensusOrder = append(hg.ConsensusOrder, finalized...)
+    return finalized
+}
+
+// Members required before fame and order are decided
+const defaultMinMembers = 1
+
+// set the members required before consensus runs
+func (hg *Hashgraph) SetMinMembers(minMembers int) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.minMembers = minMembers
+}
+
+// Consensus status
+type ConsensusStatus struct {
+    Members    int  `json:"members"`
+    MinMembers int  `json:"minMembers"`
+    Running    bool `json:"running"` // false while events stay provisional for lack of members
+    Partitioned bool `json:"partitioned"`
+}
+
+// Get the consensus status
+func (hg *Hashgraph) Status() ConsensusStatus {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    partitioned := hg.checkPartition(time.Now())
+    return ConsensusStatus{
+        Members:     hg.presentMembers(),
+        MinMembers:  hg.minMembers,
+        Running:     hg.consensusReady() && !partitioned,
+        Partitioned: partitioned,
+    }
+}
+
+// Whether enough members are present for the orderer to run, caller holds the lock.
+// Orderers that do not vote need no member set and count every creator seen instead.
+func (hg *Hashgraph) consensusReady() bool {
+    if len(hg.epochs) == 0 {
+        _, voting := hg.orderer.(HashgraphOrderer)
+        return !voting && len(hg.heads) >= hg.minMembers
+    }
+    return hg.presentMembers() >= hg.minMembers
+}
+
+// Run the configured orderer, returning newly finalized events
+func (hg *Hashgraph) runConsensus() []*Event {
+    if !hg.consensusReady() {
+        return nil
+    }
+    // Finalizing while cut off from a supermajority could diverge from the rest of the network
+    if hg.checkPartition(time.Now()) {
+        return nil
+    }
+    finalized := hg.orderer.Order(hg)
+    if len(finalized) > 0 {
+        hg.pruneReceipts()
+    }
+    return finalized
+}
+
+// Highest round whose events have been finalized
+func (hg *Hashgraph) LastFinalizedRound() int {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    return hg.lastReceivedRound
+}
+
+// Number of events in the graph
+func (hg *Hashgraph) EventCount() int {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    return len(hg.Events)
+}
+
+// Register a callback for events as they reach consensus
+func (hg *Hashgraph) OnFinalized(cb func(*Event)) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.finalizedHandlers = append(hg.finalizedHandlers, cb)
+}
+
+// Hand finalized events to the registered callbacks, called without the lock held
+func (hg *Hashgraph) deliver(finalized []*Event) {
+    if len(finalized) == 0 {
+        return
+    }
+    hg.mutex.RLock()
+    handlers := hg.finalizedHandlers
+    hg.mutex.RUnlock()
+    for _, event := range finalized {
+        for _, cb := range handlers {
+            cb(event)
+        }
+    }
+}
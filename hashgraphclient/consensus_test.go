@@ -0,0 +1,298 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// Creators of a test graph in first-event order
+func testCreators(events []*Event) []string {
+    seen := make(map[string]bool)
+    var creators []string
+    for _, event := range events {
+        if !seen[event.Creator] {
+            seen[event.Creator] = true
+            creators = append(creators, event.Creator)
+        }
+    }
+    return creators
+}
+
+// A random order of events in which every parent still comes before its children
+func shuffledTopological(events []*Event, seed int64) []*Event {
+    random := rand.New(rand.NewSource(seed))
+    placed := make(map[string]bool, len(events))
+    pending := append([]*Event(nil), events...)
+    ordered := make([]*Event, 0, len(events))
+    for len(pending) > 0 {
+        var ready []int
+        for i, event := range pending {
+            parentsPlaced := event.SelfParent == "" || placed[event.SelfParent]
+            for _, parent := range event.OtherParents() {
+                parentsPlaced = parentsPlaced && placed[parent]
+            }
+            if parentsPlaced {
+                ready = append(ready, i)
+            }
+        }
+        i := ready[random.Intn(len(ready))]
+        ordered = append(ordered, pending[i])
+        placed[pending[i].Hash] = true
+        pending = append(pending[:i], pending[i+1:]...)
+    }
+    return ordered
+}
+
+// Graph holding events, with members set, fed in the given order
+func testHashgraph(t testing.TB, events []*Event, members []string) *Hashgraph {
+    t.Helper()
+    hg := NewHashgraph(nil, nil)
+    hg.SetMembers(members)
+    addTestEvents(t, hg, events)
+    return hg
+}
+
+func TestRoundsIndependentOfArrivalOrder(t *testing.T) {
+    graph := buildTestGraph(t, 7, 4, 300)
+    members := testCreators(graph)
+    first := testHashgraph(t, graph, members)
+    second := testHashgraph(t, shuffledTopological(graph, 99), members)
+
+    for _, event := range graph {
+        a, _ := first.GetEvent(event.Hash)
+        b, _ := second.GetEvent(event.Hash)
+        if a.RoundCreated != b.RoundCreated || a.Witness != b.Witness {
+            t.Fatalf("event %s: round %d witness %v in one order, round %d witness %v in another",
+                shortID(event.Hash), a.RoundCreated, a.Witness, b.RoundCreated, b.Witness)
+        }
+        if (a.Famous == nil) != (b.Famous == nil) || (a.Famous != nil && *a.Famous != *b.Famous) {
+            t.Fatalf("event %s: fame differs between arrival orders", shortID(event.Hash))
+        }
+        if a.RoundReceived != b.RoundReceived {
+            t.Fatalf("event %s: received in round %d and %d", shortID(event.Hash), a.RoundReceived, b.RoundReceived)
+        }
+    }
+    if first.LastFinalizedRound() == 0 {
+        t.Fatal("no round was finalized")
+    }
+}
+
+func TestThresholdIgnoresCreatorsOutsideMemberSet(t *testing.T) {
+    graph := buildTestGraph(t, 3, 7, 200)
+    members := testCreators(graph)[:4]
+    hg := testHashgraph(t, graph, members)
+
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    if count := hg.memberCount(); count != 4 {
+        t.Fatalf("member count %d with 7 creators posting, want the 4 members", count)
+    }
+    for _, w := range hg.witnesses(1) {
+        if !hg.isMemberAt(1, w.Creator) {
+            t.Fatalf("witness of non-member %s counted", shortID(w.Creator))
+        }
+    }
+}
+
+func TestNoConsensusWithoutMemberSet(t *testing.T) {
+    graph := buildTestGraph(t, 5, 4, 200)
+    hg := testHashgraph(t, graph, nil)
+    if round := hg.LastFinalizedRound(); round != 0 {
+        t.Fatalf("finalized round %d without a member set", round)
+    }
+    if hg.Status().Running {
+        t.Fatal("consensus reported running without a member set")
+    }
+}
+
+func TestVotelessOrdererRunsWithoutMemberSet(t *testing.T) {
+    graph := buildTestGraph(t, 5, 4, 40)
+    hg := NewHashgraph(nil, nil)
+    hg.SetOrderer(LamportOrderer{})
+    addTestEvents(t, hg, graph)
+    if finalized := len(orderHashes(hg)); finalized != len(graph) {
+        t.Fatalf("finalized %d of %d events without a member set", finalized, len(graph))
+    }
+    if !hg.Status().Running {
+        t.Fatal("ordering reported stopped without a member set")
+    }
+}
+
+func TestForkedWitnessKeepsItsRound(t *testing.T) {
+    graph, keys, err := BuildTestGraph(11, 4, 40)
+    if err != nil {
+        t.Fatal(err)
+    }
+    members := testCreators(graph)
+    hg := testHashgraph(t, graph, members)
+
+    fork := forkTestEvent(t, graph[0], graph, keys)
+    result, err := hg.AddRemoteEvent(fork)
+    if result != AddInserted {
+        t.Fatalf("fork not inserted: %v %v", result, err)
+    }
+    if _, forked := hg.Forked()[fork.Creator]; !forked {
+        t.Fatal("fork not detected")
+    }
+    inserted, _ := hg.GetEvent(fork.Hash)
+    if !inserted.Witness || inserted.RoundCreated != 1 {
+        t.Fatalf("forked event demoted: round %d witness %v", inserted.RoundCreated, inserted.Witness)
+    }
+    if hg.memberCountAt(1) != len(members) {
+        t.Fatal("fork changed the member count")
+    }
+}
+
+func TestRoundEventsIndependentOfArrivalOrder(t *testing.T) {
+    graph := buildTestGraph(t, 13, 4, 200)
+    members := testCreators(graph)
+    first := testHashgraph(t, graph, members)
+    second := testHashgraph(t, shuffledTopological(graph, 17), members)
+
+    hashesOf := func(events []*Event) []string {
+        hashes := make([]string, len(events))
+        for i, event := range events {
+            hashes[i] = event.Hash
+        }
+        return hashes
+    }
+    for round := range first.Rounds {
+        events := first.roundEvents(round)
+        if !reflect.DeepEqual(hashesOf(events), hashesOf(second.roundEvents(round))) {
+            t.Fatalf("round %d ordered differently by arrival order", round)
+        }
+        for i := 1; i < len(events); i++ {
+            if !roundLess(events[i-1], events[i]) {
+                t.Fatalf("round %d not in creator order at %d", round, i)
+            }
+        }
+    }
+
+    topological := first.topologicalEvents()
+    if !reflect.DeepEqual(hashesOf(topological), hashesOf(second.topologicalEvents())) {
+        t.Fatal("topological order depends on arrival order")
+    }
+    seen := make(map[string]bool, len(topological))
+    for _, event := range topological {
+        for _, parent := range append(event.OtherParents(), event.SelfParent) {
+            if parent != "" && !seen[parent] {
+                t.Fatalf("event %s before its parent %s", shortID(event.Hash), shortID(parent))
+            }
+        }
+        seen[event.Hash] = true
+    }
+}
+
+func TestConsensusOrderIndependentOfArrivalOrder(t *testing.T) {
+    graph := buildTestGraph(t, 21, 4, 300)
+    members := testCreators(graph)
+    first := testHashgraph(t, graph, members)
+    second := testHashgraph(t, shuffledTopological(graph, 5), members)
+
+    order := orderHashes(first)
+    if len(order) == 0 {
+        t.Fatal("nothing reached consensus")
+    }
+    if !reflect.DeepEqual(order, orderHashes(second)) {
+        t.Fatal("consensus order depends on arrival order")
+    }
+    for _, hash := range order {
+        a, _ := first.GetEvent(hash)
+        b, _ := second.GetEvent(hash)
+        if !a.ConsensusTimestamp.Equal(b.ConsensusTimestamp) {
+            t.Fatalf("event %s: consensus timestamp %v and %v", shortID(hash), a.ConsensusTimestamp, b.ConsensusTimestamp)
+        }
+    }
+}
+
+func TestConsensusTimestampFromFirstSeeingEvents(t *testing.T) {
+    graph := buildTestGraph(t, 22, 4, 200)
+    hg := testHashgraph(t, graph, testCreators(graph))
+
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    for _, x := range hg.ConsensusOrder {
+        var median *TimestampSource
+        for i, source := range x.TimestampSources {
+            z := hg.Events[source.SeenIn]
+            if z.Creator != source.Member || !z.Timestamp.Equal(source.SeenAt) || !hg.ancestor(z, x) {
+                t.Fatalf("event %s: source %s did not see it in %s", shortID(x.Hash), shortID(source.Member), shortID(source.SeenIn))
+            }
+            if selfParent, ok := hg.Events[z.SelfParent]; ok && hg.ancestor(selfParent, x) {
+                t.Fatalf("event %s: %s is not the first event of its creator to see it", shortID(x.Hash), shortID(z.Hash))
+            }
+            if source.Median {
+                median = &x.TimestampSources[i]
+            }
+        }
+        if median == nil || !median.SeenAt.Equal(x.ConsensusTimestamp) {
+            t.Fatalf("event %s: consensus timestamp is not the median source", shortID(x.Hash))
+        }
+    }
+}
+
+func TestConsensusWaitsForMinMembers(t *testing.T) {
+    graph := buildTestGraph(t, 131, 4, 200)
+    hg := NewHashgraph(nil, nil)
+    hg.SetMembers(testCreators(graph))
+    hg.SetMinMembers(5)
+    addTestEvents(t, hg, graph[:150])
+    if len(orderHashes(hg)) != 0 {
+        t.Fatal("consensus ran with fewer members than required")
+    }
+    if status := hg.Status(); status.Running || status.Members != 4 || status.MinMembers != 5 {
+        t.Fatalf("status %+v", status)
+    }
+
+    hg.SetMinMembers(4)
+    addTestEvents(t, hg, graph[150:])
+    if len(orderHashes(hg)) == 0 || !hg.Status().Running {
+        t.Fatal("consensus did not run once enough members were present")
+    }
+}
+
+// Run with -race: snapshots are taken while events are inserted and finalized
+func TestSnapshotEventsWhileInserting(t *testing.T) {
+    graph := buildTestGraph(t, 86, 4, 300)
+    hg := NewHashgraph(nil, nil)
+    hg.SetMembers(testCreators(graph))
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        addTestEvents(t, hg, graph)
+    }()
+
+    previous := 0
+    for finished := false; !finished; {
+        select {
+        case <-done:
+            finished = true
+        default:
+        }
+        events := hg.SnapshotEvents()
+        if len(events) < previous {
+            t.Fatalf("snapshot shrank from %d to %d events", previous, len(events))
+        }
+        previous = len(events)
+        // Every parent is in the same snapshot and comes before its children
+        position := make(map[string]int, len(events))
+        for i, event := range events {
+            for _, parent := range append(event.OtherParents(), event.SelfParent) {
+                if j, ok := position[parent]; parent != "" && (!ok || j >= i) {
+                    t.Fatalf("event %s before or without its parent %s", shortID(event.Hash), shortID(parent))
+                }
+            }
+            position[event.Hash] = i
+            event.RoundReceived = -1
+        }
+    }
+    if previous != len(graph) {
+        t.Fatalf("final snapshot holds %d of %d events", previous, len(graph))
+    }
+    for _, event := range hg.SnapshotEvents() {
+        if event.RoundReceived == -1 {
+            t.Fatal("snapshot shares events with the graph")
+        }
+    }
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// Default batch size of the consensus cursor endpoint
+const defaultCursorLimit = 100
+
+// Position in the consensus order: the number of finalized events already consumed.
+// The order is append-only and snapshots restore it whole, so a cursor stays valid across restarts.
+type Cursor int
+
+// Copies of up to limit finalized events after the cursor, and the cursor to resume from
+func (hg *Hashgraph) NextBatch(cursor Cursor, limit int) ([]*Event, Cursor) {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+
+    start := int(cursor)
+    if start < 0 {
+        start = 0
+    }
+    if start >= len(hg.ConsensusOrder) {
+        return nil, Cursor(start)
+    }
+    end := len(hg.ConsensusOrder)
+    if limit > 0 && start+limit < end {
+        end = start + limit
+    }
+    return copyEventList(hg.ConsensusOrder[start:end]), Cursor(end)
+}
+
+// Batch of finalized events returned by the cursor endpoint
+type cursorBatch struct {
+    Events []*Event `json:"events"`
+    Cursor Cursor   `json:"cursor"`
+}
+
+// Get the finalized events after a cursor
+func cursorHandler(w http.ResponseWriter, r *http.Request, rooms *RoomManager) {
+    hg, ok := requestRoom(w, r, rooms)
+    if !ok {
+        return
+    }
+    cursor, limit := 0, defaultCursorLimit
+    var err error
+    if value := r.URL.Query().Get("cursor"); value != "" {
+        if cursor, err = strconv.Atoi(value); err != nil || cursor < 0 {
+            http.Error(w, "invalid cursor", http.StatusBadRequest)
+            return
+        }
+    }
+    if value := r.URL.Query().Get("limit"); value != "" {
+        if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+            http.Error(w, "invalid limit", http.StatusBadRequest)
+            return
+        }
+    }
+    events, next := hg.NextBatch(Cursor(cursor), limit)
+    json.NewEncoder(w).Encode(cursorBatch{Events: events, Cursor: next})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCursorBatchesCoverOrderOnce(t *testing.T) {
+    graph := buildTestGraph(t, 61, 4, 150)
+    hg := testHashgraph(t, graph, testCreators(graph))
+    order := orderHashes(hg)
+    if len(order) < 4 {
+        t.Fatalf("only %d events finalized", len(order))
+    }
+
+    first, cursor := hg.NextBatch(0, len(order)/2)
+    // A restored node resumes from the same cursor
+    restored := NewHashgraph(nil, nil)
+    restored.RestoreSnapshot(hg.Snapshot())
+    second, end := restored.NextBatch(cursor, 0)
+
+    var consumed []string
+    for _, event := range append(first, second...) {
+        consumed = append(consumed, event.Hash)
+    }
+    if !reflect.DeepEqual(consumed, order) {
+        t.Fatal("two batches did not yield the consensus order exactly once")
+    }
+    if int(end) != len(order) {
+        t.Fatalf("final cursor %d, want %d", end, len(order))
+    }
+    if events, again := hg.NextBatch(end, 10); events != nil || again != end {
+        t.Fatal("cursor at the end returned events")
+    }
+
+    first[0].RoundReceived = -1
+    if again, _ := hg.NextBatch(0, 1); again[0].RoundReceived == -1 {
+        t.Fatal("batch shares events with the graph")
+    }
+}
+
+func TestCursorHandler(t *testing.T) {
+    graph := buildTestGraph(t, 62, 4, 150)
+    rooms := NewRoomManager(nil, nil)
+    members := testCreators(graph)
+    rooms.Configure(func(hg *Hashgraph) {
+        hg.SetMembers(members)
+    })
+    hg := rooms.Join(defaultRoom)
+    addTestEvents(t, hg, graph)
+    order := orderHashes(hg)
+
+    var consumed []string
+    cursor := 0
+    for {
+        recorder := httptest.NewRecorder()
+        cursorHandler(recorder, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/consensus?cursor=%d&limit=7", cursor), nil), rooms)
+        if recorder.Code != http.StatusOK {
+            t.Fatalf("status %d", recorder.Code)
+        }
+        var batch cursorBatch
+        if err := json.Unmarshal(recorder.Body.Bytes(), &batch); err != nil {
+            t.Fatal(err)
+        }
+        if len(batch.Events) > 7 {
+            t.Fatalf("batch of %d events over the limit", len(batch.Events))
+        }
+        if len(batch.Events) == 0 {
+            break
+        }
+        for _, event := range batch.Events {
+            consumed = append(consumed, event.Hash)
+        }
+        cursor = int(batch.Cursor)
+    }
+    if !reflect.DeepEqual(consumed, order) {
+        t.Fatal("paging through the endpoint did not yield the consensus order")
+    }
+
+    for _, query := range []string{"?cursor=-1", "?cursor=x", "?limit=0"} {
+        recorder := httptest.NewRecorder()
+        cursorHandler(recorder, httptest.NewRequest(http.MethodGet, "/consensus"+query, nil), rooms)
+        if recorder.Code != http.StatusBadRequest {
+            t.Fatalf("%s: status %d", query, recorder.Code)
+        }
+    }
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+)
+
+// Default signing curve
+const defaultCurve = "P256"
+
+// Curve name not supported
+var errUnknownCurve = errors.New("unknown curve")
+
+// Supported signing curves by name
+var curves = map[string]elliptic.Curve{
+    "P256": elliptic.P256(),
+    "P384": elliptic.P384(),
+    "P521": elliptic.P521(),
+}
+
+// Look up a signing curve by name
+func curveByName(name string) (elliptic.Curve, error) {
+    curve, ok := curves[name]
+    if !ok {
+        return nil, errUnknownCurve
+    }
+    return curve, nil
+}
+
+// Name a supported curve is registered under, empty if unsupported
+func curveID(curve elliptic.Curve) string {
+    for name, c := range curves {
+        if c.Params().Name == curve.Params().Name {
+            return name
+        }
+    }
+    return ""
+}
+
+// Byte width of one signature component on a curve
+func scalarSize(curve elliptic.Curve) int {
+    return (curve.Params().BitSize + 7) / 8
+}
+
+// Event signature encodings: raw r||s, the original format, or ASN.1 DER for other ECDSA tooling
+const (
+    signatureFormatRaw = ""
+    signatureFormatDER = "der"
+)
+
+// Signature format not supported
+var errUnknownSignatureFormat = errors.New("unknown signature format")
+
+// Look up a signature format by flag name
+func signatureFormatByName(name string) (string, error) {
+    switch name {
+    case "raw":
+        return signatureFormatRaw, nil
+    case signatureFormatDER:
+        return signatureFormatDER, nil
+    }
+    return "", errUnknownSignatureFormat
+}
+
+// Encode a signature as r||s, each padded to the curve's scalar size
+func encodeSignature(curve elliptic.Curve, r, s *big.Int) []byte {
+    size := scalarSize(curve)
+    signature := make([]byte, 2*size)
+    r.FillBytes(signature[:size])
+    s.FillBytes(signature[size:])
+    return signature
+}
+
+// Split an r||s signature, rejecting any whose width does not match the curve
+func decodeSignature(curve elliptic.Curve, signature []byte) (*big.Int, *big.Int, bool) {
+    size := scalarSize(curve)
+    if len(signature) != 2*size {
+        return nil, nil, false
+    }
+    r := new(big.Int).SetBytes(signature[:size])
+    s := new(big.Int).SetBytes(signature[size:])
+    return r, s, true
+}
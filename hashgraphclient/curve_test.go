@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSigningAcrossCurves(t *testing.T) {
+    widths := map[string]int{"P256": 64, "P384": 96, "P521": 132}
+    var other *Event
+    for _, name := range []string{"P256", "P384", "P521"} {
+        curve, err := curveByName(name)
+        if err != nil {
+            t.Fatal(err)
+        }
+        path := filepath.Join(t.TempDir(), "node.key")
+        key, err := loadOrCreateKey(path, curve)
+        if err != nil {
+            t.Fatal(err)
+        }
+        // The saved key keeps its curve whatever curve is asked for later
+        reloaded, err := loadOrCreateKey(path, curves["P256"])
+        if err != nil {
+            t.Fatal(err)
+        }
+        if reloaded.Curve != curve || !reloaded.Equal(key) {
+            t.Fatalf("%s key reloaded on %s", name, reloaded.Curve.Params().Name)
+        }
+
+        event := testEvent()
+        event.Creator = PublicKeyHex(&key.PublicKey)
+        if !strings.HasPrefix(event.Creator, name+":") {
+            t.Fatalf("creator %s does not record curve %s", shortID(event.Creator), name)
+        }
+        if err := signEvent(event, key); err != nil {
+            t.Fatal(err)
+        }
+        signature, _ := hex.DecodeString(event.Signature)
+        if len(signature) != widths[name] {
+            t.Fatalf("%s signature of %d bytes, want %d", name, len(signature), widths[name])
+        }
+        publicKey, err := publicKeyFromHex(event.Creator)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !verifyEventSignature(event, publicKey) {
+            t.Fatalf("%s signature does not verify", name)
+        }
+        // A signature of another curve's width is rejected rather than misread
+        if other != nil {
+            other.Creator = event.Creator
+            if verifyEventSignature(other, publicKey) {
+                t.Fatalf("%s key accepted a signature of another width", name)
+            }
+        }
+        other = event
+
+        if padded := encodeSignature(curve, big.NewInt(1), big.NewInt(2)); len(padded) != widths[name] {
+            t.Fatalf("%s small signature encoded in %d bytes", name, len(padded))
+        }
+    }
+    if _, err := curveByName("P224"); !errors.Is(err, errUnknownCurve) {
+        t.Fatalf("unsupported curve: %v", err)
+    }
+}
+
+func TestDERSignaturesVerifyAndRoundTrip(t *testing.T) {
+    local := testLocalHashgraph(t, 63)
+    local.SetSignatureFormat(signatureFormatDER)
+    event, err := local.SubmitTransaction([]byte("der"), "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if event.SignatureFormat != signatureFormatDER {
+        t.Fatalf("event signed as %q", event.SignatureFormat)
+    }
+    // Other ECDSA tooling reads the signature as plain ASN.1
+    signature, _ := hex.DecodeString(event.Signature)
+    hash, err := signingDigest(event)
+    if err != nil {
+        t.Fatal(err)
+    }
+    publicKey, err := publicKeyFromHex(event.Creator)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !ecdsa.VerifyASN1(publicKey, hash, signature) {
+        t.Fatal("signature is not ASN.1 DER")
+    }
+
+    data, err := json.Marshal(wireEvent(event))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var received Event
+    if err := json.Unmarshal(data, &received); err != nil {
+        t.Fatal(err)
+    }
+    if received.SignatureFormat != signatureFormatDER || !verifyEventSignature(&received, publicKey) {
+        t.Fatal("DER signature lost in marshalling")
+    }
+    // A raw-format node accepts it
+    if result, err := NewHashgraph(nil, nil).AddRemoteEvent(&received); result != AddInserted {
+        t.Fatalf("DER-signed event: %v %v", result, err)
+    }
+    received.SignatureFormat = signatureFormatRaw
+    if verifyEventSignature(&received, publicKey) {
+        t.Fatal("DER signature verified as raw")
+    }
+
+    // Raw events keep their original encoding, without the format field
+    raw, err := testLocalHashgraph(t, 64).SubmitTransaction([]byte("raw"), "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    data, _ = json.Marshal(wireEvent(raw))
+    if strings.Contains(string(data), "SignatureFormat") {
+        t.Fatal("raw event marshalled with a signature format")
+    }
+
+    for name, want := range map[string]string{"raw": signatureFormatRaw, "der": signatureFormatDER} {
+        if format, err := signatureFormatByName(name); err != nil || format != want {
+            t.Fatalf("%s: got %q %v", name, format, err)
+        }
+    }
+    if _, err := signatureFormatByName("pem"); !errors.Is(err, errUnknownSignatureFormat) {
+        t.Fatalf("unknown format: %v", err)
+    }
+}
+
+func TestCreatorIDsDifferAcrossCurves(t *testing.T) {
+    for _, name := range []string{"P256", "P384", "P521"} {
+        key, err := ecdsa.GenerateKey(curves[name], rand.Reader)
+        if err != nil {
+            t.Fatal(err)
+        }
+        creator := PublicKeyHex(&key.PublicKey)
+        point := hex.EncodeToString(elliptic.Marshal(key.Curve, key.X, key.Y))
+        if creator != name+":"+point {
+            t.Fatalf("%s creator %s", name, shortID(creator))
+        }
+        decoded, err := publicKeyFromHex(creator)
+        if err != nil || !decoded.Equal(&key.PublicKey) {
+            t.Fatalf("%s creator decoded to %v, %v", name, decoded, err)
+        }
+        // The same point bytes under another curve name are a different creator, and not a valid one
+        for other := range curves {
+            if other == name {
+                continue
+            }
+            if _, err := publicKeyFromHex(other + ":" + point); !errors.Is(err, errInvalidCreator) {
+                t.Fatalf("%s point read as %s: %v", name, other, err)
+            }
+        }
+        if _, err := publicKeyFromHex(point); !errors.Is(err, errInvalidCreator) {
+            t.Fatalf("%s point without a curve: %v", name, err)
+        }
+    }
+    if curveID(elliptic.P224()) != "" {
+        t.Fatal("unsupported curve named")
+    }
+}
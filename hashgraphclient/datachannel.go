@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/pion/webrtc/v3"
+)
+
+// Data channel delivery semantics
+type DataChannelConfig struct {
+    Ordered        bool
+    MaxRetransmits int // negative means fully reliable
+}
+
+// Ordered and reliable, for event gossip
+var reliableChannelConfig = DataChannelConfig{Ordered: true, MaxRetransmits: -1}
+
+// Unordered and never retransmitted, for ephemeral presence updates
+var unreliableChannelConfig = DataChannelConfig{Ordered: false, MaxRetransmits: 0}
+
+// Create a data channel with the given delivery semantics
+func createDataChannel(peerConnection *webrtc.PeerConnection, label string, config DataChannelConfig) (*webrtc.DataChannel, error) {
+    ordered := config.Ordered
+    init := &webrtc.DataChannelInit{Ordered: &ordered}
+    if config.MaxRetransmits >= 0 {
+        maxRetransmits := uint16(config.MaxRetransmits)
+        init.MaxRetransmits = &maxRetransmits
+    }
+    return peerConnection.CreateDataChannel(label, init)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+)
+
+func TestCreateDataChannelSemantics(t *testing.T) {
+    peerConnection, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer peerConnection.Close()
+
+    reliable, err := createDataChannel(peerConnection, "events", reliableChannelConfig)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reliable.Ordered() || reliable.MaxRetransmits() != nil {
+        t.Fatal("event channel is not ordered and fully reliable")
+    }
+
+    unreliable, err := createDataChannel(peerConnection, "presence", unreliableChannelConfig)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if unreliable.Ordered() || unreliable.MaxRetransmits() == nil || *unreliable.MaxRetransmits() != 0 {
+        t.Fatal("presence channel is not unordered without retransmits")
+    }
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Default number of dead letters kept, oldest dropped first
+const defaultDeadLetterCapacity = 1000
+
+// Default rejections of the same event before it is dead-lettered
+const defaultDeadLetterAfter = 3
+
+// Event that was rejected for good
+type DeadLetter struct {
+    Event      *Event    `json:"event"`
+    Reason     string    `json:"reason"`
+    Source     string    `json:"source"`
+    Rejections int       `json:"rejections"`
+    RejectedAt time.Time `json:"rejectedAt"`
+}
+
+// Capped in-memory store of rejected events, kept for forensic analysis
+type DeadLetterStore struct {
+    capacity int
+    after    int
+    attempts map[string]int
+    letters  []*DeadLetter
+    index    map[string]*DeadLetter
+    mutex    sync.Mutex
+}
+
+// create new dead-letter store
+func NewDeadLetterStore(capacity, after int) *DeadLetterStore {
+    return &DeadLetterStore{
+        capacity: capacity,
+        after:    after,
+        attempts: make(map[string]int),
+        index:    make(map[string]*DeadLetter),
+    }
+}
+
+// Record a rejection, dead-lettering the event once it has been rejected often enough
+func (ds *DeadLetterStore) Reject(event *Event, reason error, source string) {
+    if event == nil {
+        return
+    }
+    ds.mutex.Lock()
+    defer ds.mutex.Unlock()
+
+    ds.attempts[event.Hash]++
+    rejections := ds.attempts[event.Hash]
+    if letter, ok := ds.index[event.Hash]; ok {
+        letter.Rejections = rejections
+        letter.Reason = reason.Error()
+        letter.RejectedAt = time.Now()
+        return
+    }
+    if rejections < ds.after {
+        return
+    }
+
+    ds.store(&DeadLetter{
+        Event:      event,
+        Reason:     reason.Error(),
+        Source:     source,
+        Rejections: rejections,
+        RejectedAt: time.Now(),
+    })
+}
+
+// Dead-letter an event immediately, regardless of the rejection threshold
+func (ds *DeadLetterStore) Add(event *Event, reason error, source string) {
+    ds.mutex.Lock()
+    defer ds.mutex.Unlock()
+    if _, ok := ds.index[event.Hash]; ok {
+        return
+    }
+    ds.store(&DeadLetter{
+        Event:      event,
+        Reason:     reason.Error(),
+        Source:     source,
+        RejectedAt: time.Now(),
+    })
+}
+
+// Append a dead letter, dropping the oldest when full, caller holds the lock
+func (ds *DeadLetterStore) store(letter *DeadLetter) {
+    if len(ds.letters) >= ds.capacity {
+        oldest := ds.letters[0]
+        delete(ds.index, oldest.Event.Hash)
+        delete(ds.attempts, oldest.Event.Hash)
+        ds.letters = ds.letters[1:]
+    }
+    ds.letters = append(ds.letters, letter)
+    ds.index[letter.Event.Hash] = letter
+}
+
+// Get the dead letters, oldest first
+func (ds *DeadLetterStore) List() []DeadLetter {
+    ds.mutex.Lock()
+    defer ds.mutex.Unlock()
+    letters := make([]DeadLetter, 0, len(ds.letters))
+    for _, letter := range ds.letters {
+        letters = append(letters, *letter)
+    }
+    return letters
+}
+
+// Number of dead letters held
+func (ds *DeadLetterStore) Len() int {
+    ds.mutex.Lock()
+    defer ds.mutex.Unlock()
+    return len(ds.letters)
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestPersistentlyInvalidEventDeadLettered(t *testing.T) {
+    graph := buildTestGraph(t, 6, 4, 4)
+    hg := NewHashgraph(nil, nil)
+    deadLetters := NewDeadLetterStore(defaultDeadLetterCapacity, 3)
+
+    tampered := copyTestEvents(graph[:1])[0]
+    tampered.Timestamp = tampered.Timestamp.Add(1)
+    for attempt := 1; attempt <= 3; attempt++ {
+        result, err := hg.AddRemoteEvent(tampered)
+        if result != AddRejected {
+            t.Fatalf("tampered event %v", result)
+        }
+        deadLetters.Reject(tampered, err, "peer-a")
+        if want := attempt / 3; deadLetters.Len() != want {
+            t.Fatalf("after %d rejections %d dead letters, want %d", attempt, deadLetters.Len(), want)
+        }
+    }
+    result, err := hg.AddRemoteEvent(tampered)
+    expectRejected(t, result, err, "validate", errHashMismatch)
+    deadLetters.Reject(tampered, err, "peer-b")
+
+    letters := deadLetters.List()
+    if len(letters) != 1 {
+        t.Fatalf("%d dead letters", len(letters))
+    }
+    letter := letters[0]
+    if letter.Event.Hash != tampered.Hash || letter.Source != "peer-a" || letter.Rejections != 4 || letter.Reason != err.Error() {
+        t.Fatalf("dead letter %+v", letter)
+    }
+}
+
+func TestDeadLetterStoreDropsOldest(t *testing.T) {
+    graph := buildTestGraph(t, 6, 4, 4)
+    deadLetters := NewDeadLetterStore(2, 1)
+    for _, event := range graph[:3] {
+        deadLetters.Add(event, errAckTimeout, "outbox")
+    }
+    deadLetters.Add(graph[2], errHashMismatch, "again")
+
+    letters := deadLetters.List()
+    if len(letters) != 2 || letters[0].Event.Hash != graph[1].Hash || letters[1].Event.Hash != graph[2].Hash {
+        t.Fatal("store did not keep the newest two dead letters, oldest first")
+    }
+    if letters[1].Reason != errAckTimeout.Error() {
+        t.Fatalf("adding a dead letter twice replaced its reason with %q", letters[1].Reason)
+    }
+}
@@ -0,0 +1,62 @@
+package main
+
+import "time"
+
+// set how consensus recomputes are coalesced: at most once per interval, or sooner once
+// maxEvents insertions are waiting; an interval of 0 recomputes on every insertion
+func (hg *Hashgraph) SetConsensusDebounce(interval time.Duration, maxEvents int) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.debounceInterval = interval
+    hg.debounceEvents = maxEvents
+}
+
+// Number of times consensus has been recomputed
+func (hg *Hashgraph) ConsensusRuns() int {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    return hg.consensusRuns
+}
+
+// Recompute consensus now or defer it to the debounce timer, caller holds the lock.
+// Rounds, fame and order depend only on the graph, so deferring changes when events
+// finalize but never which events or in what order.
+func (hg *Hashgraph) scheduleConsensus() []*Event {
+    if hg.debounceInterval <= 0 {
+        return hg.recomputeConsensus()
+    }
+    hg.pendingConsensus++
+    since := time.Since(hg.lastConsensus)
+    if since >= hg.debounceInterval || (hg.debounceEvents > 0 && hg.pendingConsensus >= hg.debounceEvents) {
+        return hg.recomputeConsensus()
+    }
+    if hg.consensusTimer == nil {
+        hg.consensusTimer = time.AfterFunc(hg.debounceInterval-since, hg.flushConsensus)
+    }
+    return nil
+}
+
+// Run consensus and reset the debounce state, caller holds the lock
+func (hg *Hashgraph) recomputeConsensus() []*Event {
+    if hg.consensusTimer != nil {
+        hg.consensusTimer.Stop()
+        hg.consensusTimer = nil
+    }
+    hg.pendingConsensus = 0
+    hg.lastConsensus = time.Now()
+    hg.consensusRuns++
+    return hg.runConsensus()
+}
+
+// Run consensus deferred by the debounce
+func (hg *Hashgraph) flushConsensus() {
+    var finalized []*Event
+    defer func() { hg.deliver(finalized) }()
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+
+    hg.consensusTimer = nil
+    if hg.pendingConsensus > 0 {
+        finalized = hg.recomputeConsensus()
+    }
+}
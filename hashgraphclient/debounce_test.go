@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConsensusDebounceCoalescesBursts(t *testing.T) {
+    graph := buildTestGraph(t, 65, 4, 200)
+    members := testCreators(graph)
+    eager := testHashgraph(t, graph, members)
+    if eager.ConsensusRuns() != len(graph) {
+        t.Fatalf("undebounced graph recomputed %d times for %d events", eager.ConsensusRuns(), len(graph))
+    }
+
+    // A burst recomputes on the first event, then once per batch of waiting events
+    batched := NewHashgraph(nil, nil)
+    batched.SetMembers(members)
+    batched.SetConsensusDebounce(time.Hour, 25)
+    addTestEvents(t, batched, graph)
+    if runs := batched.ConsensusRuns(); runs != 1+(len(graph)-1)/25 {
+        t.Fatalf("batched graph recomputed %d times for %d events", runs, len(graph))
+    }
+    // Events still waiting only delay the order, they never change it
+    order := orderHashes(batched)
+    if len(order) >= len(eager.ConsensusOrder) || !reflect.DeepEqual(order, orderHashes(eager)[:len(order)]) {
+        t.Fatalf("batched order of %d events is not a prefix of the full order", len(order))
+    }
+    batched.flushConsensus()
+    if !reflect.DeepEqual(orderHashes(batched), orderHashes(eager)) {
+        t.Fatal("batching changed the consensus order")
+    }
+
+    // Events left waiting are ordered once the interval passes
+    timed := NewHashgraph(nil, nil)
+    timed.SetMembers(members)
+    timed.SetConsensusDebounce(50*time.Millisecond, 0)
+    finalized := make(chan *Event, len(graph))
+    timed.OnFinalized(func(event *Event) { finalized <- event })
+    addTestEvents(t, timed, graph)
+    if runs := timed.ConsensusRuns(); runs >= len(graph) {
+        t.Fatalf("timed graph recomputed %d times for %d events", runs, len(graph))
+    }
+    deadline := time.After(2 * time.Second)
+    for count := 0; count < len(eager.ConsensusOrder); count++ {
+        select {
+        case <-finalized:
+        case <-deadline:
+            t.Fatalf("%d of %d events finalized after the interval", count, len(eager.ConsensusOrder))
+        }
+    }
+    if !reflect.DeepEqual(orderHashes(timed), orderHashes(eager)) {
+        t.Fatal("deferring changed the consensus order")
+    }
+}
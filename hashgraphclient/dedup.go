@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"time"
+)
+
+// Digest a sent transaction is remembered by
+type txDigest = [sha256.Size]byte
+
+// Same text was submitted within the dedup window
+var errDuplicateText = errors.New("identical text sent within the dedup window")
+
+// set how long identical text is suppressed after it is sent, 0 to disable.
+// Unlike event dedup, which never matches resends because their timestamps differ,
+// this compares the transaction itself.
+func (hg *Hashgraph) SetDedupWindow(window time.Duration) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.dedupWindow = window
+}
+
+// Check whether the same transaction was sent within the window, caller holds the lock
+func (hg *Hashgraph) isRecentDuplicate(tx []byte, now time.Time) bool {
+    if hg.dedupWindow <= 0 {
+        return false
+    }
+    sentAt, ok := hg.recentSent[sha256.Sum256(tx)]
+    return ok && now.Sub(sentAt) < hg.dedupWindow
+}
+
+// Remember a sent transaction, forgetting those past the window, caller holds the lock
+func (hg *Hashgraph) recordSent(tx []byte, now time.Time) {
+    if hg.dedupWindow <= 0 {
+        return
+    }
+    for digest, sentAt := range hg.recentSent {
+        if now.Sub(sentAt) >= hg.dedupWindow {
+            delete(hg.recentSent, digest)
+        }
+    }
+    hg.recentSent[sha256.Sum256(tx)] = now
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIdenticalTextDedupedWithinWindow(t *testing.T) {
+    hg := testLocalHashgraph(t, 82)
+    hg.SetDedupWindow(time.Minute)
+    if _, err := hg.SubmitTransaction([]byte("hello"), ""); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := hg.SubmitTransaction([]byte("hello"), ""); !errors.Is(err, errDuplicateText) {
+        t.Fatalf("rapid resend: %v", err)
+    }
+    if hg.EventCount() != 1 {
+        t.Fatalf("%d events for two identical sends within the window", hg.EventCount())
+    }
+    if _, err := hg.SubmitTransaction([]byte("hello again"), ""); err != nil {
+        t.Fatalf("different text: %v", err)
+    }
+
+    // Once the window has passed the same text is sent again
+    hg.mutex.Lock()
+    digest := sha256.Sum256([]byte("hello"))
+    hg.recentSent[digest] = hg.recentSent[digest].Add(-time.Minute)
+    hg.mutex.Unlock()
+    if _, err := hg.SubmitTransaction([]byte("hello"), ""); err != nil {
+        t.Fatalf("resend after the window: %v", err)
+    }
+    if hg.EventCount() != 3 {
+        t.Fatalf("%d events, want 3", hg.EventCount())
+    }
+
+    disabled := testLocalHashgraph(t, 83)
+    for i := 0; i < 2; i++ {
+        if _, err := disabled.SubmitTransaction([]byte("hello"), ""); err != nil {
+            t.Fatalf("send %d without a window: %v", i, err)
+        }
+    }
+    if disabled.EventCount() != 2 || len(disabled.recentSent) != 0 {
+        t.Fatal("sends deduplicated or remembered without a window")
+    }
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// mDNS multicast group and the service nodes advertise under
+const (
+    mdnsAddress = "224.0.0.251:5353"
+    mdnsService = "_hashgraph._udp.local."
+)
+
+// Default interval between mDNS announcements
+const defaultDiscoveryInterval = 30 * time.Second
+
+// Peer found on the local network, with the creator ID its events are signed under
+type DiscoveredPeer struct {
+    NodeID  string
+    Creator string
+}
+
+// Source of peers found without the node list server
+type Discovery interface {
+    Announce(self DiscoveredPeer) error
+    Peers() <-chan DiscoveredPeer
+    Close() error
+}
+
+// Discovery by mDNS: nodes announce a TXT record carrying their session and public key,
+// and answer queries for the service so newcomers learn of them straight away
+type MDNSDiscovery struct {
+    conn  *net.UDPConn
+    group *net.UDPAddr
+    peers chan DiscoveredPeer
+    self  DiscoveredPeer
+    mutex sync.Mutex
+}
+
+// Join the mDNS group and ask the network for nodes already running
+func NewMDNSDiscovery() (*MDNSDiscovery, error) {
+    group, err := net.ResolveUDPAddr("udp4", mdnsAddress)
+    if err != nil {
+        return nil, err
+    }
+    conn, err := net.ListenMulticastUDP("udp4", nil, group)
+    if err != nil {
+        return nil, err
+    }
+    d := &MDNSDiscovery{conn: conn, group: group, peers: make(chan DiscoveredPeer, 16)}
+    go d.read()
+    if err := d.query(); err != nil {
+        log.Println("Failed to send mDNS query:", err)
+    }
+    return d, nil
+}
+
+// Peers announced by other nodes; a peer is sent again each time it announces
+func (d *MDNSDiscovery) Peers() <-chan DiscoveredPeer {
+    return d.peers
+}
+
+// Leave the mDNS group
+func (d *MDNSDiscovery) Close() error {
+    return d.conn.Close()
+}
+
+// Advertise this node
+func (d *MDNSDiscovery) Announce(self DiscoveredPeer) error {
+    d.mutex.Lock()
+    d.self = self
+    d.mutex.Unlock()
+
+    packet, err := mdnsAnnouncement(self)
+    if err != nil {
+        return err
+    }
+    _, err = d.conn.WriteToUDP(packet, d.group)
+    return err
+}
+
+// Ask every node on the network to announce itself
+func (d *MDNSDiscovery) query() error {
+    service, err := dnsmessage.NewName(mdnsService)
+    if err != nil {
+        return err
+    }
+    msg := dnsmessage.Message{
+        Questions: []dnsmessage.Question{{Name: service, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}},
+    }
+    packet, err := msg.Pack()
+    if err != nil {
+        return err
+    }
+    _, err = d.conn.WriteToUDP(packet, d.group)
+    return err
+}
+
+// Handle announcements and queries until the connection closes
+func (d *MDNSDiscovery) read() {
+    defer close(d.peers)
+    buf := make([]byte, 9000)
+    for {
+        n, _, err := d.conn.ReadFromUDP(buf)
+        if err != nil {
+            return
+        }
+        var msg dnsmessage.Message
+        if err := msg.Unpack(buf[:n]); err != nil {
+            continue
+        }
+
+        d.mutex.Lock()
+        self := d.self
+        d.mutex.Unlock()
+
+        if !msg.Header.Response {
+            if self.NodeID != "" && queriesService(msg) {
+                if err := d.Announce(self); err != nil {
+                    log.Println("Failed to answer mDNS query:", err)
+                }
+            }
+            continue
+        }
+        for _, peer := range discoveredPeers(msg) {
+            if peer.NodeID == self.NodeID {
+                continue
+            }
+            select {
+            case d.peers <- peer:
+            default:
+            }
+        }
+    }
+}
+
+// Whether a query asks for the service
+func queriesService(msg dnsmessage.Message) bool {
+    for _, question := range msg.Questions {
+        if strings.EqualFold(question.Name.String(), mdnsService) {
+            return true
+        }
+    }
+    return false
+}
+
+// Build the response advertising a node: a PTR from the service to the node's instance,
+// and a TXT on the instance with its session, curve and public key. The key is base64
+// so that even a P521 point fits the 255 byte limit of a TXT string.
+func mdnsAnnouncement(self DiscoveredPeer) ([]byte, error) {
+    curve, point, ok := strings.Cut(self.Creator, ":")
+    if !ok {
+        return nil, errInvalidCreator
+    }
+    key, err := hex.DecodeString(point)
+    if err != nil {
+        return nil, errInvalidCreator
+    }
+    service, err := dnsmessage.NewName(mdnsService)
+    if err != nil {
+        return nil, err
+    }
+    instance, err := dnsmessage.NewName(shortID(point) + "." + mdnsService)
+    if err != nil {
+        return nil, err
+    }
+
+    msg := dnsmessage.Message{
+        Header: dnsmessage.Header{Response: true, Authoritative: true},
+        Answers: []dnsmessage.Resource{
+            {
+                Header: dnsmessage.ResourceHeader{Name: service, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET, TTL: 120},
+                Body:   &dnsmessage.PTRResource{PTR: instance},
+            },
+            {
+                Header: dnsmessage.ResourceHeader{Name: instance, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET, TTL: 120},
+                Body: &dnsmessage.TXTResource{TXT: []string{
+                    "node=" + self.NodeID,
+                    "curve=" + curve,
+                    "key=" + base64.StdEncoding.EncodeToString(key),
+                }},
+            },
+        },
+    }
+    return msg.Pack()
+}
+
+// Peers advertised in a response, skipping records that do not decode to a valid key
+func discoveredPeers(msg dnsmessage.Message) []DiscoveredPeer {
+    var peers []DiscoveredPeer
+    for _, resource := range append(msg.Answers, msg.Additionals...) {
+        txt, ok := resource.Body.(*dnsmessage.TXTResource)
+        if !ok || !strings.HasSuffix(strings.ToLower(resource.Header.Name.String()), mdnsService) {
+            continue
+        }
+        fields := make(map[string]string)
+        for _, entry := range txt.TXT {
+            if name, value, ok := strings.Cut(entry, "="); ok {
+                fields[name] = value
+            }
+        }
+        key, err := base64.StdEncoding.DecodeString(fields["key"])
+        if err != nil || fields["node"] == "" {
+            continue
+        }
+        creator := fields["curve"] + ":" + hex.EncodeToString(key)
+        if _, err := publicKeyFromHex(creator); err != nil {
+            continue
+        }
+        peers = append(peers, DiscoveredPeer{NodeID: fields["node"], Creator: creator})
+    }
+    return peers
+}
+
+// Announce this node periodically once it has a session, and add discovered peers
+// to the partial view, registering their keys so their events can be verified
+func runDiscovery(d Discovery, self func() DiscoveredPeer, interval time.Duration, sampler *PeerSampler, registry *CreatorRegistry) {
+    go func() {
+        for peer := range d.Peers() {
+            if err := registry.Register(peer.Creator); err != nil {
+                log.Println("Discovered peer with an invalid key:", err)
+                continue
+            }
+            sampler.Add([]string{peer.NodeID})
+            log.Printf("Discovered %s on the local network (creator %s)", peer.NodeID, shortID(peer.Creator))
+        }
+    }()
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        if s := self(); s.NodeID != "" {
+            if err := d.Announce(s); err != nil {
+                log.Println("Failed to send mDNS announcement:", err)
+            }
+        }
+        <-ticker.C
+    }
+}
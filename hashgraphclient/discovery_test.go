@@ -0,0 +1,95 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Discovery fed by the test instead of the network
+type fakeDiscovery struct {
+    announced chan DiscoveredPeer
+    peers     chan DiscoveredPeer
+}
+
+func (d *fakeDiscovery) Announce(self DiscoveredPeer) error {
+    d.announced <- self
+    return nil
+}
+
+func (d *fakeDiscovery) Peers() <-chan DiscoveredPeer { return d.peers }
+
+func (d *fakeDiscovery) Close() error {
+    close(d.peers)
+    return nil
+}
+
+func TestDiscoveredPeersJoinPeerSet(t *testing.T) {
+    keys := seededKeys(59, 3)
+    self := DiscoveredPeer{NodeID: "self", Creator: PublicKeyHex(&keys[0].PublicKey)}
+    discovery := &fakeDiscovery{announced: make(chan DiscoveredPeer, 1), peers: make(chan DiscoveredPeer)}
+    defer discovery.Close()
+    sampler := NewPeerSampler(8, 4)
+    sampler.Add([]string{"from-server"})
+    registry := NewCreatorRegistry()
+    go runDiscovery(discovery, func() DiscoveredPeer { return self }, time.Hour, sampler, registry)
+
+    select {
+    case announced := <-discovery.announced:
+        if announced != self {
+            t.Fatalf("announced %+v", announced)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("node not announced")
+    }
+
+    discovery.peers <- DiscoveredPeer{NodeID: "bad-key", Creator: "P256:00"}
+    discovery.peers <- DiscoveredPeer{NodeID: "lan-1", Creator: PublicKeyHex(&keys[1].PublicKey)}
+    discovery.peers <- DiscoveredPeer{NodeID: "lan-2", Creator: PublicKeyHex(&keys[2].PublicKey)}
+    want := []string{"from-server", "lan-1", "lan-2"}
+    deadline := time.Now().Add(time.Second)
+    for {
+        view := sampler.View()
+        sort.Strings(view)
+        if reflect.DeepEqual(view, want) {
+            break
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("peer set %v, want %v", view, want)
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    registry.mutex.Lock()
+    defer registry.mutex.Unlock()
+    if _, ok := registry.creators[PublicKeyHex(&keys[2].PublicKey)]; !ok || len(registry.creators) != 2 {
+        t.Fatalf("registry holds %d creators", len(registry.creators))
+    }
+}
+
+func TestMDNSAnnouncementRoundTrip(t *testing.T) {
+    for _, name := range []string{"P256", "P384", "P521"} {
+        key, err := loadOrCreateKey(filepath.Join(t.TempDir(), "node.key"), curves[name])
+        if err != nil {
+            t.Fatal(err)
+        }
+        self := DiscoveredPeer{NodeID: "node-" + name, Creator: PublicKeyHex(&key.PublicKey)}
+        packed, err := mdnsAnnouncement(self)
+        if err != nil {
+            t.Fatal(err)
+        }
+        var msg dnsmessage.Message
+        if err := msg.Unpack(packed); err != nil {
+            t.Fatal(err)
+        }
+        if peers := discoveredPeers(msg); len(peers) != 1 || peers[0] != self {
+            t.Fatalf("%s announcement read back as %+v", name, peers)
+        }
+    }
+    if _, err := mdnsAnnouncement(DiscoveredPeer{NodeID: "node", Creator: "nocurve"}); err == nil {
+        t.Fatal("announced a creator without a curve")
+    }
+}
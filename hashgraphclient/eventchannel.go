@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// Event gossip over the events data channel, paced so a slow peer pauses sends rather than
+// letting them queue without bound. Events reach only the node at the far end of the
+// channel; every other peer is still reached through the signaling server.
+type EventChannel struct {
+    channel *webrtc.DataChannel
+    paced   *PacedChannel
+    self    func() string
+    deliver func(event *Event, source string)
+    peer    string // session ID of the node at the far end, learned from its messages
+    mutex   sync.Mutex
+}
+
+// Send events on a data channel, introducing this node once it opens, and hand events
+// arriving on it to deliver
+func NewEventChannel(channel *webrtc.DataChannel, self func() string, deliver func(event *Event, source string)) *EventChannel {
+    ec := &EventChannel{
+        channel: channel,
+        paced:   NewPacedChannel(channel, defaultBufferedAmountHigh, defaultBufferedAmountLow),
+        self:    self,
+        deliver: deliver,
+    }
+    channel.OnOpen(ec.introduce)
+    ec.Receive(channel)
+    return ec
+}
+
+// Tell the far end which session this node is, so it can send events here before we send any
+func (ec *EventChannel) introduce() {
+    if err := ec.send(Message{Type: "hello"}); err != nil {
+        log.Println("Failed to introduce on events channel:", err)
+    }
+}
+
+// Handle messages on a channel the far end opened, as well as on our own
+func (ec *EventChannel) Receive(channel *webrtc.DataChannel) {
+    channel.OnMessage(func(raw webrtc.DataChannelMessage) {
+        var msg Message
+        if err := json.Unmarshal(raw.Data, &msg); err != nil {
+            log.Println("Failed to parse events channel message:", err)
+            return
+        }
+        if msg.SourceNode == "" {
+            return
+        }
+        ec.mutex.Lock()
+        ec.peer = msg.SourceNode
+        ec.mutex.Unlock()
+        if msg.Type == "event" && msg.Event != nil {
+            ec.deliver(msg.Event, msg.SourceNode)
+        }
+    })
+}
+
+// Session ID of the node at the far end, empty until it has introduced itself
+func (ec *EventChannel) Peer() string {
+    ec.mutex.Lock()
+    defer ec.mutex.Unlock()
+    return ec.peer
+}
+
+// Whether events for a peer can go over the channel
+func (ec *EventChannel) Reaches(peer string) bool {
+    return peer != "" && ec.channel.ReadyState() == webrtc.DataChannelStateOpen && ec.Peer() == peer
+}
+
+// Send a message stamped with this node's session ID, waiting while the far end is slow
+func (ec *EventChannel) send(msg Message) error {
+    msg.SourceNode = ec.self()
+    data, err := json.Marshal(msg)
+    if err != nil {
+        return err
+    }
+    return ec.paced.Send(data)
+}
+
+// Send an event to a peer over the events data channel when the channel leads there,
+// else through the signaling server
+func sendEvent(c *SignalConn, events *EventChannel, peer string, event *Event) error {
+    msg := Message{Type: "event", Event: wireEvent(event), TargetNode: peer}
+    if events != nil && events.Reaches(peer) {
+        return events.send(msg)
+    }
+    return c.WriteJSON(msg)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Event received on an events channel, with the session it came from
+type channelDelivery struct {
+    event  *Event
+    source string
+}
+
+func TestGossipOverEventChannel(t *testing.T) {
+    _, local, remote := connectedTestChannel(t, "events")
+    graph := buildTestGraph(t, 23, 3, 12)
+    hg := testHashgraph(t, graph, testCreators(graph))
+    c, signaled := testSignalConn(t)
+
+    delivered := make(chan channelDelivery, len(graph))
+    events := NewEventChannel(local, func() string { return "near" }, func(*Event, string) {})
+    NewEventChannel(remote, func() string { return "far" }, func(event *Event, source string) {
+        delivered <- channelDelivery{event, source}
+    })
+
+    // The far end introduces itself once open, and only then does the channel lead to it
+    deadline := time.Now().Add(5 * time.Second)
+    for !events.Reaches("far") {
+        if time.Now().After(deadline) {
+            t.Fatal("far end never introduced itself")
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    if events.Reaches("elsewhere") {
+        t.Fatal("channel reaches a peer not at its far end")
+    }
+
+    // Events for the far end go over the channel, parents first; the watermark still goes by signaling
+    if sent := gossipTo(c, events, hg, "far"); sent != len(graph) {
+        t.Fatalf("sent %d events, want %d", sent, len(graph))
+    }
+    seen := make(map[string]bool)
+    for range graph {
+        select {
+        case got := <-delivered:
+            if got.source != "near" {
+                t.Fatalf("event from %q, want near", got.source)
+            }
+            for _, parent := range got.event.OtherParents() {
+                if !seen[parent] {
+                    t.Fatalf("event %s arrived before its parent", shortID(got.event.Hash))
+                }
+            }
+            if got.event.SelfParent != "" && !seen[got.event.SelfParent] {
+                t.Fatalf("event %s arrived before its self-parent", shortID(got.event.Hash))
+            }
+            seen[got.event.Hash] = true
+        case <-time.After(5 * time.Second):
+            t.Fatalf("%d of %d events arrived over the channel", len(seen), len(graph))
+        }
+    }
+    select {
+    case msg := <-signaled:
+        if msg.Type != "watermark" || msg.TargetNode != "far" {
+            t.Fatalf("signaled %s to %s, want only the watermark", msg.Type, msg.TargetNode)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("watermark not signaled")
+    }
+
+    // A peer the channel does not lead to is still reached through signaling
+    if err := sendEvent(c, events, "elsewhere", graph[0]); err != nil {
+        t.Fatal(err)
+    }
+    select {
+    case msg := <-signaled:
+        if msg.Type != "event" || msg.TargetNode != "elsewhere" || msg.Event.Hash != graph[0].Hash {
+            t.Fatalf("signaled %s to %s", msg.Type, msg.TargetNode)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("event for another peer not signaled")
+    }
+}
+
+func TestEventSendsPausedWhilePeerSlow(t *testing.T) {
+    channel := &fakeBufferedSender{}
+    events := &EventChannel{paced: NewPacedChannel(channel, 100, 10), self: func() string { return "near" }}
+    event := buildTestGraph(t, 24, 2, 1)[0]
+
+    // A peer that stopped reading holds event sends instead of queueing them
+    channel.setBuffered(101)
+    sent := make(chan error, 1)
+    go func() { sent <- events.send(Message{Type: "event", Event: event}) }()
+    select {
+    case <-sent:
+        t.Fatal("event sent with a full buffer")
+    case <-time.After(50 * time.Millisecond):
+    }
+    if !events.paced.Paused() || channel.sentCount() != 0 {
+        t.Fatal("event send not paused above the cap")
+    }
+
+    channel.setBuffered(10)
+    channel.low()
+    select {
+    case err := <-sent:
+        if err != nil {
+            t.Fatal(err)
+        }
+    case <-time.After(2 * pacePollInterval):
+        t.Fatal("event send not resumed once the buffer drained")
+    }
+    if channel.sentCount() != 1 || events.paced.Pauses() != 1 {
+        t.Fatalf("%d events sent after %d pauses", channel.sentCount(), events.paced.Pauses())
+    }
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Copies of the finalized events with a consensus timestamp in [from, to), in consensus order
+func (hg *Hashgraph) FinalizedBetween(from, to time.Time) []*Event {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+
+    var events []*Event
+    for _, event := range hg.ConsensusOrder {
+        if event.ConsensusTimestamp.Before(from) || !event.ConsensusTimestamp.Before(to) {
+            continue
+        }
+        events = append(events, event)
+    }
+    return copyEventList(events)
+}
+
+// Columns of the CSV export
+var exportColumns = []string{"consensus_timestamp", "round_received", "creator", "hash", "message"}
+
+// Write events as CSV, one row per event
+func writeEventsCSV(w io.Writer, events []*Event) error {
+    writer := csv.NewWriter(w)
+    if err := writer.Write(exportColumns); err != nil {
+        return err
+    }
+    for _, event := range events {
+        messages := make([]string, len(event.Transactions))
+        for i, tx := range event.Transactions {
+            messages[i] = string(tx)
+        }
+        record := []string{
+            event.ConsensusTimestamp.UTC().Format(time.RFC3339Nano),
+            strconv.Itoa(event.RoundReceived),
+            event.Creator,
+            event.Hash,
+            strings.Join(messages, "\n"),
+        }
+        if err := writer.Write(record); err != nil {
+            return err
+        }
+    }
+    writer.Flush()
+    return writer.Error()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFinalizedBetweenWindow(t *testing.T) {
+    graph := buildTestGraph(t, 191, 4, 200)
+    hg := testHashgraph(t, graph, testCreators(graph))
+    order := hg.FinalizedBetween(time.Time{}, time.Now().Add(time.Hour))
+    if len(order) < 10 {
+        t.Fatal("too little reached consensus")
+    }
+    from, to := order[3].ConsensusTimestamp, order[8].ConsensusTimestamp
+    var want []string
+    for _, event := range order {
+        if !event.ConsensusTimestamp.Before(from) && event.ConsensusTimestamp.Before(to) {
+            want = append(want, event.Hash)
+        }
+    }
+    var got []string
+    for _, event := range hg.FinalizedBetween(from, to) {
+        got = append(got, event.Hash)
+    }
+    if len(want) == 0 || !reflect.DeepEqual(got, want) {
+        t.Fatalf("window holds %d events, want the %d in [from, to) in consensus order", len(got), len(want))
+    }
+}
+
+func TestExportHandlerCSV(t *testing.T) {
+    graph := buildTestGraph(t, 192, 4, 200)
+    rooms := NewRoomManager(nil, nil)
+    rooms.rooms[defaultRoom] = testHashgraph(t, graph, testCreators(graph))
+    finalized := len(orderHashes(rooms.rooms[defaultRoom]))
+
+    recorder := httptest.NewRecorder()
+    target := "/export?format=csv&from=2000-01-01T00:00:00Z&to=2100-01-01T00:00:00Z"
+    exportHandler(recorder, httptest.NewRequest(http.MethodGet, target, nil), rooms)
+    records, err := csv.NewReader(recorder.Body).ReadAll()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(records) != finalized+1 || records[0][0] != "consensus_timestamp" {
+        t.Fatalf("%d rows for %d finalized events", len(records), finalized)
+    }
+    texts := make(map[string]string)
+    for _, event := range graph {
+        texts[event.Hash] = string(event.Transactions[0])
+    }
+    for _, record := range records[1:] {
+        if record[4] != texts[record[3]] {
+            t.Fatalf("row for %s has message %q", shortID(record[3]), record[4])
+        }
+    }
+
+    recorder = httptest.NewRecorder()
+    exportHandler(recorder, httptest.NewRequest(http.MethodGet, "/export?from=yesterday", nil), rooms)
+    if recorder.Code != http.StatusBadRequest {
+        t.Fatalf("invalid window: status %d", recorder.Code)
+    }
+}
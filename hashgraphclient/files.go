@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// Size of the chunks a file is sent in over the data channel
+const fileChunkSize = 16 * 1024
+
+// Time a file fetch may take before it is abandoned
+const fileFetchTimeout = time.Minute
+
+var (
+    errFileNotFound     = errors.New("file not found")
+    errFileHashMismatch = errors.New("file content does not match its hash")
+    errFileFetchTimeout = errors.New("file fetch timed out")
+)
+
+// Content-addressed reference to a file shared in chat; consensus orders the reference, never the bytes
+type FileReference struct {
+    Hash string `json:"hash"` // hex SHA-256 of the content
+    Name string `json:"name"`
+    Size int64  `json:"size"`
+    MIME string `json:"mime"`
+}
+
+// Encode a file reference transaction
+func FileTransaction(ref FileReference) []byte {
+    data, _ := json.Marshal(ChatTransaction{Type: chatFile, File: &ref})
+    return data
+}
+
+// Content hash of file data
+func fileHash(data []byte) string {
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:])
+}
+
+// Local store of shared file contents by hash
+type FileStore struct {
+    files map[string][]byte
+    mutex sync.RWMutex
+}
+
+// create new file store
+func NewFileStore() *FileStore {
+    return &FileStore{files: make(map[string][]byte)}
+}
+
+// Store file contents, returning their hash
+func (fs *FileStore) Put(data []byte) string {
+    hash := fileHash(data)
+    fs.mutex.Lock()
+    defer fs.mutex.Unlock()
+    fs.files[hash] = data
+    return hash
+}
+
+// Get file contents by hash
+func (fs *FileStore) Get(hash string) ([]byte, bool) {
+    fs.mutex.RLock()
+    defer fs.mutex.RUnlock()
+    data, ok := fs.files[hash]
+    return data, ok
+}
+
+// Message on the files data channel
+type fileMessage struct {
+    Type   string `json:"type"` // "request", "chunk" or "missing"
+    Hash   string `json:"hash"`
+    Offset int64  `json:"offset,omitempty"`
+    Size   int64  `json:"size,omitempty"`
+    Data   []byte `json:"data,omitempty"`
+}
+
+// Download in progress
+type download struct {
+    data []byte
+    size int64
+    done chan error
+}
+
+// Out-of-band transfer of file contents over a data channel, on demand
+type FileTransfer struct {
+    channel   *PacedChannel
+    store     *FileStore
+    downloads map[string]*download
+    mutex     sync.Mutex
+}
+
+// Serve and fetch files over a data channel
+func NewFileTransfer(channel *webrtc.DataChannel, store *FileStore) *FileTransfer {
+    ft := &FileTransfer{
+        channel:   NewPacedChannel(channel, defaultBufferedAmountHigh, defaultBufferedAmountLow),
+        store:     store,
+        downloads: make(map[string]*download),
+    }
+    channel.OnMessage(func(msg webrtc.DataChannelMessage) {
+        var fm fileMessage
+        if err := json.Unmarshal(msg.Data, &fm); err != nil {
+            log.Println("Failed to parse file message:", err)
+            return
+        }
+        ft.handle(fm)
+    })
+    return ft
+}
+
+// Send a message on the files channel
+func (ft *FileTransfer) send(fm fileMessage) error {
+    data, err := json.Marshal(fm)
+    if err != nil {
+        return err
+    }
+    return ft.channel.Send(data)
+}
+
+// Handle a request for a stored file or a chunk of a download
+func (ft *FileTransfer) handle(fm fileMessage) {
+    switch fm.Type {
+    case "request":
+        data, ok := ft.store.Get(fm.Hash)
+        if !ok {
+            ft.send(fileMessage{Type: "missing", Hash: fm.Hash})
+            return
+        }
+        for offset := 0; offset < len(data); offset += fileChunkSize {
+            end := offset + fileChunkSize
+            if end > len(data) {
+                end = len(data)
+            }
+            chunk := fileMessage{Type: "chunk", Hash: fm.Hash, Offset: int64(offset), Size: int64(len(data)), Data: data[offset:end]}
+            if err := ft.send(chunk); err != nil {
+                log.Println("Failed to send file chunk:", err)
+                return
+            }
+        }
+    case "chunk", "missing":
+        ft.mutex.Lock()
+        defer ft.mutex.Unlock()
+        d, ok := ft.downloads[fm.Hash]
+        if !ok {
+            return
+        }
+        if fm.Type == "missing" {
+            delete(ft.downloads, fm.Hash)
+            d.done <- errFileNotFound
+            return
+        }
+        if fm.Offset != int64(len(d.data)) {
+            return
+        }
+        // A fetch by hash alone learns the size from the first chunk
+        if d.size == 0 {
+            d.size = fm.Size
+        }
+        d.data = append(d.data, fm.Data...)
+        if int64(len(d.data)) < d.size {
+            return
+        }
+        delete(ft.downloads, fm.Hash)
+        if fileHash(d.data) != fm.Hash {
+            d.done <- errFileHashMismatch
+            return
+        }
+        ft.store.Put(d.data)
+        d.done <- nil
+    }
+}
+
+// Fetch content known only by its hash, such as the full text of a truncated message
+func (ft *FileTransfer) FetchFull(hash string) ([]byte, error) {
+    return ft.Fetch(FileReference{Hash: hash})
+}
+
+// Fetch a referenced file from the peer, verifying it against its hash
+func (ft *FileTransfer) Fetch(ref FileReference) ([]byte, error) {
+    if data, ok := ft.store.Get(ref.Hash); ok {
+        return data, nil
+    }
+    d := &download{size: ref.Size, done: make(chan error, 1)}
+    ft.mutex.Lock()
+    ft.downloads[ref.Hash] = d
+    ft.mutex.Unlock()
+
+    if err := ft.send(fileMessage{Type: "request", Hash: ref.Hash}); err != nil {
+        ft.mutex.Lock()
+        delete(ft.downloads, ref.Hash)
+        ft.mutex.Unlock()
+        return nil, err
+    }
+    select {
+    case err := <-d.done:
+        if err != nil {
+            return nil, err
+        }
+        data, _ := ft.store.Get(ref.Hash)
+        return data, nil
+    case <-time.After(fileFetchTimeout):
+        ft.mutex.Lock()
+        delete(ft.downloads, ref.Hash)
+        ft.mutex.Unlock()
+        return nil, errFileFetchTimeout
+    }
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestFileReferenceOrderedAndRoundTrips(t *testing.T) {
+    graph := buildTestGraph(t, 18, 4, 200)
+    ref := FileReference{Hash: fileHash([]byte("contents")), Name: "notes.txt", Size: 8, MIME: "text/plain"}
+    shared := rewriteTestEvents(t, graph, func(event *Event) {
+        if event.Hash == graph[20].Hash {
+            event.Transactions = [][]byte{[]byte("see attached"), FileTransaction(ref)}
+        }
+    })
+    hg := testHashgraph(t, shared, testCreators(shared))
+
+    cv := NewChatView()
+    position := -1
+    for i, event := range hg.ConsensusOrder {
+        if event.Hash == shared[20].Hash {
+            position = i
+        }
+        cv.Apply(event)
+    }
+    if position < 0 {
+        t.Fatal("event carrying the file reference not ordered")
+    }
+    got, ok := cv.File(ref.Hash)
+    if !ok || got != ref {
+        t.Fatalf("file reference %+v, want %+v", got, ref)
+    }
+    for _, message := range cv.Messages() {
+        if message.ID == MessageID(shared[20].Hash, 1) && (message.File == nil || *message.File != ref || message.Text != ref.Name) {
+            t.Fatalf("rendered file message %+v", message)
+        }
+    }
+}
+
+func TestFileFetchedOverDataChannel(t *testing.T) {
+    _, local, remote := connectedTestChannel(t, "files")
+    owner, fetcher := NewFileStore(), NewFileStore()
+    NewFileTransfer(local, owner)
+    transfer := NewFileTransfer(remote, fetcher)
+
+    // Several chunks, the last one partial
+    data := bytes.Repeat([]byte("0123456789"), fileChunkSize/4)
+    hash := owner.Put(data)
+    got, err := transfer.Fetch(FileReference{Hash: hash, Name: "big.bin", Size: int64(len(data))})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !bytes.Equal(got, data) {
+        t.Fatalf("fetched %d bytes, want %d", len(got), len(data))
+    }
+    if stored, ok := fetcher.Get(hash); !ok || !bytes.Equal(stored, data) {
+        t.Fatal("fetched file not kept locally")
+    }
+
+    // Known only by hash, the size comes with the first chunk
+    small := owner.Put([]byte("full text"))
+    if got, err := transfer.FetchFull(small); err != nil || string(got) != "full text" {
+        t.Fatalf("fetch by hash: %q %v", got, err)
+    }
+    if _, err := transfer.FetchFull(fileHash([]byte("never shared"))); !errors.Is(err, errFileNotFound) {
+        t.Fatalf("fetching a missing file: %v", err)
+    }
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Bytes a data channel may have queued before sends pause, and the level at which they resume
+const (
+    defaultBufferedAmountHigh = 1 << 20
+    defaultBufferedAmountLow  = 256 << 10
+)
+
+// How often a paused send rechecks the buffer, in case the low callback was missed
+const pacePollInterval = 100 * time.Millisecond
+
+// Longest a send may stay paused before giving up on a peer that stopped reading
+const pacedSendTimeout = 30 * time.Second
+
+// Peer did not drain the data channel in time
+var errSendStalled = errors.New("data channel send stalled, peer is not draining")
+
+// Data channel operations the pacer needs, satisfied by *webrtc.DataChannel
+type bufferedSender interface {
+    Send(data []byte) error
+    BufferedAmount() uint64
+    SetBufferedAmountLowThreshold(th uint64)
+    OnBufferedAmountLow(f func())
+}
+
+// Data channel sender that pauses while the peer is slow, instead of letting the
+// channel's send buffer grow without bound
+type PacedChannel struct {
+    channel bufferedSender
+    high    uint64
+    paused  bool
+    drained chan struct{}
+    pauses  int
+    mutex   sync.Mutex
+}
+
+// Pace sends on a channel, pausing above high buffered bytes until the buffer drains to low
+func NewPacedChannel(channel bufferedSender, high, low uint64) *PacedChannel {
+    pc := &PacedChannel{channel: channel, high: high}
+    channel.SetBufferedAmountLowThreshold(low)
+    channel.OnBufferedAmountLow(pc.resume)
+    return pc
+}
+
+// Wake paused senders once the buffer has drained
+func (pc *PacedChannel) resume() {
+    pc.mutex.Lock()
+    defer pc.mutex.Unlock()
+    if pc.paused {
+        pc.paused = false
+        close(pc.drained)
+    }
+}
+
+// Send once the buffered amount is under the cap, waiting for the peer to drain it if needed
+func (pc *PacedChannel) Send(data []byte) error {
+    deadline := time.Now().Add(pacedSendTimeout)
+    for {
+        pc.mutex.Lock()
+        if pc.channel.BufferedAmount() <= pc.high {
+            pc.mutex.Unlock()
+            return pc.channel.Send(data)
+        }
+        if !pc.paused {
+            pc.paused = true
+            pc.drained = make(chan struct{})
+            pc.pauses++
+        }
+        drained := pc.drained
+        pc.mutex.Unlock()
+
+        if time.Now().After(deadline) {
+            return errSendStalled
+        }
+        select {
+        case <-drained:
+        case <-time.After(pacePollInterval):
+        }
+    }
+}
+
+// Whether sends are currently paused
+func (pc *PacedChannel) Paused() bool {
+    pc.mutex.Lock()
+    defer pc.mutex.Unlock()
+    return pc.paused
+}
+
+// Number of times sends have paused for a full buffer
+func (pc *PacedChannel) Pauses() int {
+    pc.mutex.Lock()
+    defer pc.mutex.Unlock()
+    return pc.pauses
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// Data channel whose buffered amount the test sets, as a slow peer would leave it
+type fakeBufferedSender struct {
+    buffered  uint64
+    threshold uint64
+    low       func()
+    sent      [][]byte
+    mutex     sync.Mutex
+}
+
+func (f *fakeBufferedSender) Send(data []byte) error {
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+    f.sent = append(f.sent, data)
+    return nil
+}
+
+func (f *fakeBufferedSender) BufferedAmount() uint64 {
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+    return f.buffered
+}
+
+func (f *fakeBufferedSender) SetBufferedAmountLowThreshold(th uint64) {
+    f.threshold = th
+}
+
+func (f *fakeBufferedSender) OnBufferedAmountLow(fn func()) {
+    f.low = fn
+}
+
+// Set the buffered amount
+func (f *fakeBufferedSender) setBuffered(amount uint64) {
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+    f.buffered = amount
+}
+
+// Number of messages sent
+func (f *fakeBufferedSender) sentCount() int {
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+    return len(f.sent)
+}
+
+func TestPacedChannelPausesWhileBufferHigh(t *testing.T) {
+    channel := &fakeBufferedSender{}
+    paced := NewPacedChannel(channel, 100, 10)
+    if channel.threshold != 10 || channel.low == nil {
+        t.Fatal("low threshold callback not registered")
+    }
+    if err := paced.Send([]byte("first")); err != nil || channel.sentCount() != 1 || paced.Paused() {
+        t.Fatalf("send under the cap: %v", err)
+    }
+
+    for _, wake := range []string{"callback", "poll"} {
+        channel.setBuffered(101)
+        sent := make(chan error, 1)
+        go func() { sent <- paced.Send([]byte("paced")) }()
+        deadline := time.Now().Add(time.Second)
+        for !paced.Paused() && time.Now().Before(deadline) {
+            time.Sleep(time.Millisecond)
+        }
+        if !paced.Paused() {
+            t.Fatalf("%s: send not paused above the cap", wake)
+        }
+        select {
+        case <-sent:
+            t.Fatalf("%s: send went through with a full buffer", wake)
+        case <-time.After(50 * time.Millisecond):
+        }
+
+        // The peer drains the buffer
+        channel.setBuffered(10)
+        if wake == "callback" {
+            channel.low()
+        }
+        select {
+        case err := <-sent:
+            if err != nil {
+                t.Fatal(err)
+            }
+        case <-time.After(2 * pacePollInterval):
+            t.Fatalf("%s: send not resumed once the buffer drained", wake)
+        }
+    }
+    if channel.sentCount() != 3 {
+        t.Fatalf("%d messages sent, want 3", channel.sentCount())
+    }
+    if paced.Pauses() != 2 {
+        t.Fatalf("%d pauses, want 2", paced.Pauses())
+    }
+}
@@ -0,0 +1,21 @@
+package main
+
+import "errors"
+
+// Follower nodes only observe
+var errFollower = errors.New("follower mode does not create events")
+
+// set whether the node only follows the chat: it syncs and orders others' events but never
+// creates its own, so it never becomes a member or counts towards a supermajority
+func (hg *Hashgraph) SetFollower(follower bool) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.follower = follower
+}
+
+// Whether the node only follows the chat
+func (hg *Hashgraph) Follower() bool {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    return hg.follower
+}
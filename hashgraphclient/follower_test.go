@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFollowerOrdersWithoutParticipating(t *testing.T) {
+    graph := buildTestGraph(t, 97, 4, 240)
+    members := testCreators(graph)
+    reference := testHashgraph(t, graph, members)
+
+    follower := testLocalHashgraph(t, 97)
+    follower.SetFollower(true)
+    follower.SetMembers(members)
+    addTestEvents(t, follower, graph)
+    if len(follower.ConsensusOrder) == 0 || !reflect.DeepEqual(orderHashes(follower), orderHashes(reference)) {
+        t.Fatalf("follower ordered %d events, members %d", len(follower.ConsensusOrder), len(reference.ConsensusOrder))
+    }
+
+    // The follower is never counted, so the supermajority stays that of the members
+    round := follower.LastFinalizedRound()
+    if got := follower.MembersAt(round); len(got) != len(members) {
+        t.Fatalf("follower sees %d members", len(got))
+    }
+    follower.mutex.RLock()
+    withoutFollower, withFollower := follower.isSupermajorityAt(round, 2), follower.isSupermajorityAt(round, 3)
+    follower.mutex.RUnlock()
+    if withoutFollower || !withFollower {
+        t.Fatal("supermajority threshold moved")
+    }
+
+    count := follower.EventCount()
+    if _, err := follower.SubmitTransaction([]byte("hello"), ""); !errors.Is(err, errFollower) {
+        t.Fatalf("follower submitted: %v", err)
+    }
+    if _, err := follower.Heartbeat(); !errors.Is(err, errFollower) {
+        t.Fatalf("follower sent a heartbeat: %v", err)
+    }
+    if follower.EventCount() != count {
+        t.Fatal("follower created an event")
+    }
+
+    // A follower announces itself without a creator
+    c, sent := testSignalConn(t)
+    announceNode(c, follower, true)
+    select {
+    case hello := <-sent:
+        if hello.Type != "hello" || hello.Creator != "" {
+            t.Fatalf("follower announced %+v", hello)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("no hello sent")
+    }
+}
@@ -0,0 +1,110 @@
+package main
+
+import "time"
+
+// Record a fork when a creator already has a different event on the same self-parent, caller holds the lock
+func (hg *Hashgraph) detectFork(event *Event) {
+    key := event.Creator + "/" + event.SelfParent
+    if existing, ok := hg.selfChildren[key]; ok && existing != event.Hash {
+        hg.markForked(event.Creator)
+        return
+    }
+    hg.selfChildren[key] = event.Hash
+}
+
+// Quarantine a creator caught forking, caller holds the lock
+func (hg *Hashgraph) markForked(creator string) {
+    if _, known := hg.forked[creator]; !known {
+        hg.forked[creator] = time.Now()
+    }
+}
+
+// A second witness by one creator in a round is evidence of forking. It stays a witness:
+// which of the two arrived first differs between nodes, and rounds must not.
+// Caller holds the lock, after the event's round is assigned.
+func (hg *Hashgraph) capWitness(event *Event) {
+    if !event.Witness {
+        return
+    }
+    for _, other := range hg.Rounds[event.RoundCreated] {
+        if other.Witness && other.Creator == event.Creator && other.Hash != event.Hash {
+            hg.markForked(event.Creator)
+            return
+        }
+    }
+}
+
+// Check whether an event belongs to a forked creator, caller holds the lock.
+// Quarantined events are neither built on nor kept past PruneForked. Consensus still counts
+// them, since nodes learn of a fork at different times and must reach the same order.
+func (hg *Hashgraph) quarantined(event *Event) bool {
+    _, ok := hg.forked[event.Creator]
+    return ok
+}
+
+// Creators detected forking, with the time each was detected
+func (hg *Hashgraph) Forked() map[string]time.Time {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    forked := make(map[string]time.Time, len(hg.forked))
+    for creator, at := range hg.forked {
+        forked[creator] = at
+    }
+    return forked
+}
+
+// Remove the events of creators detected forking more than grace ago, returning the removed events.
+// An event is kept while an honest event that is not yet final still references it.
+func (hg *Hashgraph) PruneForked(grace time.Duration) []*Event {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+
+    now := time.Now()
+    needed := make(map[string]bool)
+    for _, event := range hg.Events {
+        if hg.quarantined(event) || event.RoundReceived != 0 {
+            continue
+        }
+        needed[event.SelfParent] = true
+        for _, parent := range event.OtherParents() {
+            needed[parent] = true
+        }
+    }
+
+    var pruned []*Event
+    for hash, event := range hg.Events {
+        detectedAt, ok := hg.forked[event.Creator]
+        if !ok || now.Sub(detectedAt) < grace || needed[hash] {
+            continue
+        }
+        delete(hg.Events, hash)
+        delete(hg.votes, hash)
+        delete(hg.receipts, hash)
+        pruned = append(pruned, event)
+    }
+    if len(pruned) == 0 {
+        return nil
+    }
+
+    removed := make(map[string]bool, len(pruned))
+    for _, event := range pruned {
+        removed[event.Hash] = true
+        if hg.heads[event.Creator] == event.Hash {
+            delete(hg.heads, event.Creator)
+        }
+    }
+    for round, events := range hg.Rounds {
+        kept := events[:0]
+        for _, event := range events {
+            if !removed[event.Hash] {
+                kept = append(kept, event)
+            }
+        }
+        hg.Rounds[round] = kept
+    }
+    // Cached ancestry may have run through removed events
+    hg.ancestorMutex.Lock()
+    hg.ancestorCache = make(map[string]bool)
+    hg.ancestorMutex.Unlock()
+    return pruned
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// A second event on original's self-parent, signed by original's creator
+func forkTestEvent(t *testing.T, original *Event, graph []*Event, keys []*ecdsa.PrivateKey) *Event {
+    t.Helper()
+    fork := *original
+    fork.Transactions = [][]byte{[]byte("fork")}
+    fork.Timestamp = fork.Timestamp.Add(1)
+    fork.Hash, _ = hashEvent(&fork)
+    for i, creator := range testCreators(graph) {
+        if creator == fork.Creator {
+            if err := signEvent(&fork, keys[i]); err != nil {
+                t.Fatal(err)
+            }
+        }
+    }
+    return &fork
+}
+
+func TestConsensusProceedsPastForkedCreator(t *testing.T) {
+    graph, keys, err := BuildTestGraph(12, 4, 240)
+    if err != nil {
+        t.Fatal(err)
+    }
+    members := testCreators(graph)
+    honest := testHashgraph(t, graph, members)
+
+    hg := testHashgraph(t, graph[:120], members)
+    fork := forkTestEvent(t, graph[100], graph, keys)
+    if result, err := hg.AddRemoteEvent(fork); result != AddInserted {
+        t.Fatalf("fork not inserted: %v %v", result, err)
+    }
+    if _, forked := hg.Forked()[fork.Creator]; !forked {
+        t.Fatal("fork not detected")
+    }
+    before := hg.LastFinalizedRound()
+    addTestEvents(t, hg, graph[120:])
+    if hg.LastFinalizedRound() <= before {
+        t.Fatalf("no round finalized after the fork, still at %d", before)
+    }
+    // Nothing honest builds on the fork, so the order is the one reached without it
+    if !reflect.DeepEqual(orderHashes(hg), orderHashes(honest)) {
+        t.Fatal("fork changed the consensus order of honest events")
+    }
+
+    if pruned := hg.PruneForked(time.Hour); pruned != nil {
+        t.Fatalf("pruned %d events within the grace period", len(pruned))
+    }
+    pruned := hg.PruneForked(0)
+    removed := make(map[string]bool, len(pruned))
+    for _, event := range pruned {
+        if event.Creator != fork.Creator {
+            t.Fatalf("pruned event %s of honest creator", shortID(event.Hash))
+        }
+        removed[event.Hash] = true
+    }
+    if !removed[fork.Hash] {
+        t.Fatal("fork kept past the grace period")
+    }
+    for _, event := range hg.Events {
+        if event.RoundReceived != 0 || event.Creator == fork.Creator {
+            continue
+        }
+        for _, parent := range append(event.OtherParents(), event.SelfParent) {
+            if removed[parent] {
+                t.Fatalf("pruned %s still referenced by pending event %s", shortID(parent), shortID(event.Hash))
+            }
+        }
+    }
+    if _, ok := hg.GetEvent(fork.Hash); ok {
+        t.Fatal("pruned fork still in the graph")
+    }
+}
+
+func TestSecondSameRoundWitnessFlagsFork(t *testing.T) {
+    graph, keys, err := BuildTestGraph(14, 4, 200)
+    if err != nil {
+        t.Fatal(err)
+    }
+    members := testCreators(graph)
+    honest := testHashgraph(t, graph, members)
+
+    var original *Event
+    for _, event := range graph[40:] {
+        if inserted, _ := honest.GetEvent(event.Hash); inserted.Witness {
+            original = event
+            break
+        }
+    }
+    if original == nil {
+        t.Fatal("no witness past genesis")
+    }
+    hg := testHashgraph(t, graph, members)
+    fork := forkTestEvent(t, original, graph, keys)
+    if result, err := hg.AddRemoteEvent(fork); result != AddInserted {
+        t.Fatalf("fork not inserted: %v %v", result, err)
+    }
+
+    // The cap alone flags the creator, independently of the shared self-parent
+    hg.mutex.Lock()
+    inserted := hg.Events[fork.Hash]
+    delete(hg.forked, fork.Creator)
+    hg.capWitness(inserted)
+    _, flagged := hg.forked[fork.Creator]
+    hg.mutex.Unlock()
+    if !flagged {
+        t.Fatal("second same-round witness not flagged")
+    }
+    for _, creator := range members {
+        if _, forked := hg.Forked()[creator]; forked && creator != fork.Creator {
+            t.Fatalf("honest creator %s flagged", shortID(creator))
+        }
+    }
+
+    // The creator's two witnesses count once toward any supermajority
+    round := inserted.RoundCreated
+    creators := make(map[string]bool)
+    for _, witness := range hg.witnesses(round) {
+        creators[witness.Creator] = true
+    }
+    if len(creators) > hg.memberCountAt(round) {
+        t.Fatalf("round %d counts %d witness creators for %d members", round, len(creators), hg.memberCountAt(round))
+    }
+    if !reflect.DeepEqual(orderHashes(hg), orderHashes(honest)) {
+        t.Fatal("second witness changed the consensus order")
+    }
+}
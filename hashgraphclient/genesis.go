@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+var (
+    errGenesisNotEmpty = errors.New("genesis applied to a non-empty graph")
+    errInvalidGenesis  = errors.New("genesis events must be parentless, one per member")
+)
+
+// Agreed starting point of a permissioned network: the initial members and their first events
+type Genesis struct {
+    Members []string `json:"members"` // creator IDs
+    Events  []*Event `json:"events"`  // one parentless event per member, signed by it
+}
+
+// Load a genesis set from a file
+func LoadGenesis(path string) (*Genesis, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var genesis Genesis
+    if err := json.Unmarshal(data, &genesis); err != nil {
+        return nil, err
+    }
+    return &genesis, nil
+}
+
+// Check that every member has exactly one parentless, correctly signed genesis event
+func (g *Genesis) Validate() error {
+    members := make(map[string]bool, len(g.Members))
+    for _, member := range g.Members {
+        members[member] = true
+    }
+    seen := make(map[string]bool, len(g.Events))
+    for _, event := range g.Events {
+        if !members[event.Creator] || seen[event.Creator] || event.SelfParent != "" || event.OtherParent != "" || len(event.ExtraParents) > 0 {
+            return errInvalidGenesis
+        }
+        seen[event.Creator] = true
+        if err := verifyEventIntegrity(event); err != nil {
+            return err
+        }
+    }
+    if len(seen) != len(members) {
+        return errInvalidGenesis
+    }
+    return nil
+}
+
+// Seed an empty graph with a validated genesis set, making its members the initial member set
+func (hg *Hashgraph) ApplyGenesis(genesis *Genesis) error {
+    if err := genesis.Validate(); err != nil {
+        return err
+    }
+    var finalized []*Event
+    defer func() { hg.deliver(finalized) }()
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+
+    if len(hg.Events) > 0 {
+        return errGenesisNotEmpty
+    }
+    hg.epochs = initialEpochs(genesis.Members)
+    for _, event := range genesis.Events {
+        copied := *event
+        if err := hg.runPipeline(&copied); err != nil {
+            return err
+        }
+    }
+    finalized, hg.finalizedBatch = hg.finalizedBatch, nil
+    return nil
+}
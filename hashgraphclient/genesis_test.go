@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Signed event of a test key, on the given parents
+func testGenesisEvent(t *testing.T, key *ecdsa.PrivateKey, lamport int, parents ...string) *Event {
+    t.Helper()
+    event := &Event{
+        Transactions:  [][]byte{[]byte("genesis")},
+        Creator:       PublicKeyHex(&key.PublicKey),
+        Timestamp:     testGraphEpoch.Add(time.Duration(lamport) * time.Second),
+        HashAlgorithm: defaultHashAlgorithm,
+        RoomID:        defaultRoom,
+        LamportTime:   lamport,
+    }
+    if len(parents) == 2 {
+        event.SelfParent, event.OtherParent = parents[0], parents[1]
+    }
+    event.Hash, _ = hashEvent(event)
+    if err := signEvent(event, key); err != nil {
+        t.Fatal(err)
+    }
+    return event
+}
+
+// Genesis set of one parentless event per key
+func testGenesis(t *testing.T, keys []*ecdsa.PrivateKey) *Genesis {
+    t.Helper()
+    genesis := &Genesis{}
+    for _, key := range keys {
+        genesis.Members = append(genesis.Members, PublicKeyHex(&key.PublicKey))
+        genesis.Events = append(genesis.Events, testGenesisEvent(t, key, 1))
+    }
+    return genesis
+}
+
+// Write a genesis set to a file and load it back
+func reloadTestGenesis(t *testing.T, genesis *Genesis) *Genesis {
+    t.Helper()
+    data, err := json.Marshal(genesis)
+    if err != nil {
+        t.Fatal(err)
+    }
+    path := filepath.Join(t.TempDir(), "genesis.json")
+    if err := os.WriteFile(path, data, 0o644); err != nil {
+        t.Fatal(err)
+    }
+    loaded, err := LoadGenesis(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return loaded
+}
+
+func TestGenesisSeedsMembers(t *testing.T) {
+    keys := seededKeys(41, 4)
+    genesis := testGenesis(t, keys)
+    hg := NewHashgraph(nil, nil)
+    if err := hg.ApplyGenesis(reloadTestGenesis(t, genesis)); err != nil {
+        t.Fatal(err)
+    }
+    members := append([]string(nil), genesis.Members...)
+    sort.Strings(members)
+    if !reflect.DeepEqual(hg.MembersAt(1), members) {
+        t.Fatal("genesis did not set the member set")
+    }
+    if hg.EventCount() != len(keys) {
+        t.Fatalf("%d events after genesis, want %d", hg.EventCount(), len(keys))
+    }
+
+    // Members build on the genesis
+    child := testGenesisEvent(t, keys[0], 2, genesis.Events[0].Hash, genesis.Events[1].Hash)
+    if result, err := hg.AddRemoteEvent(child); result != AddInserted {
+        t.Fatalf("event on genesis: %v %v", result, err)
+    }
+
+    if err := hg.ApplyGenesis(genesis); !errors.Is(err, errGenesisNotEmpty) {
+        t.Fatalf("genesis on a non-empty graph: %v", err)
+    }
+}
+
+func TestTamperedGenesisRejected(t *testing.T) {
+    keys := seededKeys(43, 4)
+    outsider := seededKeys(44, 1)[0]
+    tampers := map[string]struct {
+        change func(*Genesis)
+        cause  error
+    }{
+        "transactions": {func(g *Genesis) { g.Events[0].Transactions = [][]byte{[]byte("forged")} }, errHashMismatch},
+        "signature":    {func(g *Genesis) { g.Events[1].Signature = g.Events[2].Signature }, errInvalidSignature},
+        "missing":      {func(g *Genesis) { g.Events = g.Events[1:] }, errInvalidGenesis},
+        "duplicate":    {func(g *Genesis) { g.Events = append(g.Events, g.Events[0]) }, errInvalidGenesis},
+        "outsider":     {func(g *Genesis) { g.Events[3] = testGenesisEvent(t, outsider, 1) }, errInvalidGenesis},
+        "parent":       {func(g *Genesis) { g.Events[0].SelfParent = g.Events[1].Hash }, errInvalidGenesis},
+    }
+    for name, tamper := range tampers {
+        genesis := testGenesis(t, keys)
+        tamper.change(genesis)
+        hg := NewHashgraph(nil, nil)
+        if err := hg.ApplyGenesis(reloadTestGenesis(t, genesis)); !errors.Is(err, tamper.cause) {
+            t.Fatalf("%s: got %v, want %v", name, err, tamper.cause)
+        }
+        if hg.EventCount() != 0 || hg.MembersAt(1) != nil {
+            t.Fatalf("%s: rejected genesis changed the graph", name)
+        }
+    }
+}
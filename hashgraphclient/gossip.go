@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Default interval between gossip rounds, 0 disables periodic gossip
+const defaultGossipInterval = 5 * time.Second
+
+// Most events sent to a peer in one gossip round
+const defaultGossipBatch = 64
+
+// Default limit on simultaneous outbound gossip sessions
+const defaultMaxGossipSessions = 4
+
+// Peers gossiped with per round
+const gossipFanout = 2
+
+// Default time a manual gossip round waits for the peer's events in return
+const defaultManualGossipWait = 2 * time.Second
+
+// Every gossip session slot is taken
+var errGossipSaturated = errors.New("gossip sessions saturated")
+
+// Send a peer our finalized watermark, then the events it has not acknowledged, parents first,
+// returning how many events were sent. Events go over the events data channel when it leads to the peer.
+func gossipTo(c *SignalConn, events *EventChannel, hg *Hashgraph, peer string) int {
+    watermark := Message{Type: "watermark", Round: hg.LastFinalizedRound(), RoomID: hg.roomID, TargetNode: peer}
+    if err := c.WriteJSON(watermark); err != nil {
+        log.Println("Failed to send watermark:", err)
+        return 0
+    }
+
+    missing := hg.EventsMissingForPeer(peer)
+    if len(missing) > defaultGossipBatch {
+        missing = missing[:defaultGossipBatch]
+    }
+    for i, event := range missing {
+        if err := sendEvent(c, events, peer, event); err != nil {
+            log.Println("Failed to gossip event:", err)
+            return i
+        }
+    }
+    return len(missing)
+}
+
+// Outbound gossip with a bound on concurrent sessions
+type Gossiper struct {
+    c        *SignalConn
+    events   *EventChannel
+    hg       *Hashgraph
+    sessions chan struct{}
+    latency  *LatencyTracker
+    bias     float64
+}
+
+// create new gossiper allowing at most maxSessions sessions at once
+func NewGossiper(c *SignalConn, hg *Hashgraph, maxSessions int) *Gossiper {
+    return &Gossiper{c: c, hg: hg, sessions: make(chan struct{}, maxSessions)}
+}
+
+// Favour low-latency peers, measuring RTTs with pings each round
+func (g *Gossiper) SetLatencyBias(latency *LatencyTracker, bias float64) {
+    g.latency = latency
+    g.bias = bias
+}
+
+// Send events over a data channel to the peer at its far end
+func (g *Gossiper) SetEventChannel(events *EventChannel) {
+    g.events = events
+}
+
+// Start a gossip session with a peer, skipping it when every session slot is taken
+func (g *Gossiper) TryGossip(peer string) bool {
+    select {
+    case g.sessions <- struct{}{}:
+    default:
+        return false
+    }
+    go func() {
+        defer func() { <-g.sessions }()
+        gossipTo(g.c, g.events, g.hg, peer)
+    }()
+    return true
+}
+
+// Outcome of a manual gossip round
+type GossipResult struct {
+    Peer     string `json:"peer"`
+    Sent     int    `json:"sent"`
+    Received int    `json:"received"` // new events from the peer while waiting for its reply
+}
+
+// Gossip with a peer right away, in both directions: our frontier asks the peer to send what we
+// lack, then we send what it lacks and count what arrives from it within wait
+func (g *Gossiper) GossipNow(peer string, wait time.Duration) (GossipResult, error) {
+    select {
+    case g.sessions <- struct{}{}:
+    default:
+        return GossipResult{}, errGossipSaturated
+    }
+    defer func() { <-g.sessions }()
+
+    before := g.hg.EventsReceivedFrom(peer)
+    frontier := Message{Type: "frontier", Frontier: g.hg.Frontier(), RoomID: g.hg.roomID, TargetNode: peer}
+    if err := g.c.WriteJSON(frontier); err != nil {
+        return GossipResult{}, err
+    }
+    result := GossipResult{Peer: peer, Sent: gossipTo(g.c, g.events, g.hg, peer)}
+    time.Sleep(wait)
+    result.Received = g.hg.EventsReceivedFrom(peer) - before
+    return result, nil
+}
+
+// Force a gossip round with the peer in the path, waiting ?wait= for its events
+func gossipHandler(g *Gossiper) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        wait := defaultManualGossipWait
+        if value := r.URL.Query().Get("wait"); value != "" {
+            parsed, err := time.ParseDuration(value)
+            if err != nil || parsed < 0 {
+                http.Error(w, "invalid wait", http.StatusBadRequest)
+                return
+            }
+            wait = parsed
+        }
+        result, err := g.GossipNow(r.PathValue("peerID"), wait)
+        if errors.Is(err, errGossipSaturated) {
+            http.Error(w, err.Error(), http.StatusServiceUnavailable)
+            return
+        }
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadGateway)
+            return
+        }
+        json.NewEncoder(w).Encode(result)
+    }
+}
+
+// Periodically shuffle the partial view and gossip with peers drawn from it
+func (g *Gossiper) Run(sampler *PeerSampler, interval time.Duration) {
+    if interval <= 0 {
+        return
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for range ticker.C {
+        if target, offer, ok := sampler.StartShuffle(); ok {
+            if err := g.c.WriteJSON(Message{Type: "shuffle", Nodes: offer, TargetNode: target}); err != nil {
+                log.Println("Failed to send shuffle:", err)
+            }
+        }
+        peers := sampler.Sample(gossipFanout)
+        if g.latency != nil {
+            now := time.Now()
+            for _, peer := range sampler.View() {
+                ping := Message{Type: "ping", Seq: g.latency.StartPing(peer, now), TargetNode: peer}
+                if err := g.c.WriteJSON(ping); err != nil {
+                    log.Println("Failed to send ping:", err)
+                }
+            }
+            peers = sampler.SampleByLatency(gossipFanout, g.latency.RTTs(), g.bias)
+        }
+        for _, peer := range peers {
+            if !g.TryGossip(peer) {
+                log.Println("Gossip sessions saturated, skipping", peer)
+            }
+        }
+    }
+}
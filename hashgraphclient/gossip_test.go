@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Signaling connection to a test server, returning the messages the server receives
+func testSignalConn(t *testing.T) (*SignalConn, <-chan Message) {
+    t.Helper()
+    upgrader := websocket.Upgrader{}
+    received := make(chan Message, 1024)
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        conn, err := upgrader.Upgrade(w, r, nil)
+        if err != nil {
+            return
+        }
+        defer conn.Close()
+        for {
+            var msg Message
+            if err := conn.ReadJSON(&msg); err != nil {
+                return
+            }
+            select {
+            case received <- msg:
+            default:
+            }
+        }
+    }))
+    t.Cleanup(srv.Close)
+    c, err := DialSignal("ws"+strings.TrimPrefix(srv.URL, "http"), false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { c.Close() })
+    return c, received
+}
+
+func TestGossipSessionsBounded(t *testing.T) {
+    graph := buildTestGraph(t, 21, 4, 40)
+    hg := testHashgraph(t, graph, testCreators(graph))
+    c, received := testSignalConn(t)
+    const maxSessions = 3
+    g := NewGossiper(c, hg, maxSessions)
+
+    // Holding the connection keeps every started session in its first write
+    c.mutex.Lock()
+    var started int
+    var wg sync.WaitGroup
+    var startedMutex sync.Mutex
+    for i := 0; i < 20; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if g.TryGossip("peer") {
+                startedMutex.Lock()
+                started++
+                startedMutex.Unlock()
+            }
+        }()
+    }
+    wg.Wait()
+    if started != maxSessions || len(g.sessions) != maxSessions {
+        t.Fatalf("%d sessions started, %d running, want %d", started, len(g.sessions), maxSessions)
+    }
+    if _, err := g.GossipNow("peer", 0); !errors.Is(err, errGossipSaturated) {
+        t.Fatalf("manual gossip while saturated: %v", err)
+    }
+    mux := http.NewServeMux()
+    mux.HandleFunc("POST /admin/gossip/{peerID}", gossipHandler(g))
+    recorder := httptest.NewRecorder()
+    mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/admin/gossip/peer?wait=0s", nil))
+    if recorder.Code != http.StatusServiceUnavailable {
+        t.Fatalf("saturated gossip answered %d", recorder.Code)
+    }
+    c.mutex.Unlock()
+
+    // Each session sends a watermark and then every event, and frees its slot when done
+    for i := 0; i < maxSessions*(1+len(graph)); i++ {
+        select {
+        case <-received:
+        case <-time.After(5 * time.Second):
+            t.Fatalf("server got %d messages from the released sessions", i)
+        }
+    }
+    for deadline := time.Now().Add(5 * time.Second); len(g.sessions) > 0; time.Sleep(time.Millisecond) {
+        if time.Now().After(deadline) {
+            t.Fatal("finished sessions kept their slots")
+        }
+    }
+    result, err := g.GossipNow("peer", 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if result.Sent != len(graph) {
+        t.Fatalf("manual gossip sent %d events, want %d", result.Sent, len(graph))
+    }
+    if msg := <-received; msg.Type != "frontier" {
+        t.Fatalf("manual gossip opened with %q, want the frontier", msg.Type)
+    }
+}
+
+func TestManualGossipReportsCounts(t *testing.T) {
+    hg := testLocalHashgraph(t, 151)
+    for _, text := range []string{"one", "two", "three"} {
+        if _, err := hg.SubmitTransaction([]byte(text), ""); err != nil {
+            t.Fatal(err)
+        }
+    }
+    peer := testLocalHashgraph(t, 152)
+    var replies []*Event
+    for _, text := range []string{"four", "five"} {
+        event, err := peer.SubmitTransaction([]byte(text), "")
+        if err != nil {
+            t.Fatal(err)
+        }
+        replies = append(replies, wireEvent(event))
+    }
+
+    c, sent := testSignalConn(t)
+    gossiper := NewGossiper(c, hg, 1)
+    mux := http.NewServeMux()
+    mux.HandleFunc("POST /admin/gossip/{peerID}", gossipHandler(gossiper))
+    // The peer answers the frontier with its events while the round waits
+    go func() {
+        for msg := range sent {
+            if msg.Type == "frontier" && msg.TargetNode == "peer-x" {
+                for _, event := range replies {
+                    event.ReceivedFrom = "peer-x"
+                    hg.AddRemoteEvent(event)
+                }
+                return
+            }
+        }
+    }()
+    recorder := httptest.NewRecorder()
+    mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/admin/gossip/peer-x?wait=300ms", nil))
+    var result GossipResult
+    if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+        t.Fatalf("status %d: %v", recorder.Code, err)
+    }
+    if result != (GossipResult{Peer: "peer-x", Sent: 3, Received: 2}) {
+        t.Fatalf("gossip round %+v", result)
+    }
+    for _, want := range []string{"watermark", "event", "event", "event"} {
+        select {
+        case msg := <-sent:
+            if msg.Type != want || msg.TargetNode != "peer-x" {
+                t.Fatalf("sent %s to %q, want %s", msg.Type, msg.TargetNode, want)
+            }
+        case <-time.After(time.Second):
+            t.Fatalf("no %s sent", want)
+        }
+    }
+
+    // Only one round runs at a time, and the wait must be a duration
+    gossiper.sessions <- struct{}{}
+    recorder = httptest.NewRecorder()
+    mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/admin/gossip/peer-x?wait=0s", nil))
+    if recorder.Code != http.StatusServiceUnavailable {
+        t.Fatalf("saturated gossip: status %d", recorder.Code)
+    }
+    <-gossiper.sessions
+    recorder = httptest.NewRecorder()
+    mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/admin/gossip/peer-x?wait=soon", nil))
+    if recorder.Code != http.StatusBadRequest {
+        t.Fatalf("invalid wait: status %d", recorder.Code)
+    }
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Event as a node of the visualized graph
+type GraphNode struct {
+    ID        string    `json:"id"` // short hash
+    Hash      string    `json:"hash"`
+    Creator   string    `json:"creator"`
+    Round     int       `json:"round"`
+    Witness   bool      `json:"witness"`
+    Famous    *bool     `json:"famous,omitempty"`
+    Timestamp time.Time `json:"timestamp"`
+}
+
+// Parent link of the visualized graph, from child to parent
+type GraphEdge struct {
+    Source string `json:"source"`
+    Target string `json:"target"`
+    Type   string `json:"type"` // "self" or "other"
+}
+
+// Graph in a shape suited to d3 or cytoscape front ends
+type Graph struct {
+    Nodes []GraphNode `json:"nodes"`
+    Edges []GraphEdge `json:"edges"`
+}
+
+// Graph of the events created in rounds from..to, 0 leaving that end open
+func (hg *Hashgraph) Graph(from, to int) Graph {
+    graph := Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+    included := make(map[string]bool)
+    events := hg.SnapshotEvents()
+    for _, event := range events {
+        if (from > 0 && event.RoundCreated < from) || (to > 0 && event.RoundCreated > to) {
+            continue
+        }
+        included[event.Hash] = true
+        graph.Nodes = append(graph.Nodes, GraphNode{
+            ID:        shortID(event.Hash),
+            Hash:      event.Hash,
+            Creator:   shortID(event.Creator),
+            Round:     event.RoundCreated,
+            Witness:   event.Witness,
+            Famous:    event.Famous,
+            Timestamp: event.Timestamp,
+        })
+    }
+    for _, event := range events {
+        if !included[event.Hash] {
+            continue
+        }
+        if included[event.SelfParent] {
+            graph.Edges = append(graph.Edges, GraphEdge{Source: shortID(event.Hash), Target: shortID(event.SelfParent), Type: "self"})
+        }
+        for _, parent := range event.OtherParents() {
+            if included[parent] {
+                graph.Edges = append(graph.Edges, GraphEdge{Source: shortID(event.Hash), Target: shortID(parent), Type: "other"})
+            }
+        }
+    }
+    return graph
+}
+
+// Serve the graph as JSON, optionally limited to ?from= and ?to= rounds
+func graphHandler(w http.ResponseWriter, r *http.Request, rooms *RoomManager) {
+    hg, ok := requestRoom(w, r, rooms)
+    if !ok {
+        return
+    }
+    var bounds [2]int
+    for i, name := range []string{"from", "to"} {
+        value := r.URL.Query().Get(name)
+        if value == "" {
+            continue
+        }
+        round, err := strconv.Atoi(value)
+        if err != nil || round < 0 {
+            http.Error(w, "invalid "+name, http.StatusBadRequest)
+            return
+        }
+        bounds[i] = round
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(hg.Graph(bounds[0], bounds[1]))
+}
+
+// Whether neither event is an ancestor of the other, so no member saw one before creating the other
+func (hg *Hashgraph) AreConcurrent(a, b *Event) bool {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    return !hg.ancestor(a, b) && !hg.ancestor(b, a)
+}
+
+// Causal relation of two events
+type Concurrency struct {
+    A          string `json:"a"`
+    B          string `json:"b"`
+    AAncestorB bool   `json:"aAncestorOfB"`
+    BAncestorA bool   `json:"bAncestorOfA"`
+    Concurrent bool   `json:"concurrent"`
+}
+
+// Serve whether the events ?a= and ?b=, given by hash, are concurrent
+func concurrentHandler(w http.ResponseWriter, r *http.Request, rooms *RoomManager) {
+    hg, ok := requestRoom(w, r, rooms)
+    if !ok {
+        return
+    }
+    query := r.URL.Query()
+    a, okA := hg.GetEvent(query.Get("a"))
+    b, okB := hg.GetEvent(query.Get("b"))
+    if !okA || !okB {
+        http.Error(w, "unknown event", http.StatusNotFound)
+        return
+    }
+
+    hg.mutex.Lock()
+    result := Concurrency{A: a.Hash, B: b.Hash, AAncestorB: hg.ancestor(b, a), BAncestorA: hg.ancestor(a, b)}
+    hg.mutex.Unlock()
+    result.Concurrent = !result.AAncestorB && !result.BAncestorA
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Decode the graph served for a query
+func servedGraph(t *testing.T, rooms *RoomManager, query string) Graph {
+    t.Helper()
+    recorder := httptest.NewRecorder()
+    graphHandler(recorder, httptest.NewRequest(http.MethodGet, "/graph"+query, nil), rooms)
+    if recorder.Code != http.StatusOK {
+        t.Fatalf("%s: status %d", query, recorder.Code)
+    }
+    if recorder.Header().Get("Content-Type") != "application/json" {
+        t.Fatalf("%s: content type %q", query, recorder.Header().Get("Content-Type"))
+    }
+    var graph Graph
+    if err := json.Unmarshal(recorder.Body.Bytes(), &graph); err != nil {
+        t.Fatal(err)
+    }
+    return graph
+}
+
+func TestGraphHandler(t *testing.T) {
+    events := buildTestGraph(t, 31, 4, 120)
+    rooms := NewRoomManager(nil, nil)
+    members := testCreators(events)
+    rooms.Configure(func(hg *Hashgraph) {
+        hg.SetMembers(members)
+    })
+    addTestEvents(t, rooms.Join(defaultRoom), events)
+
+    graph := servedGraph(t, rooms, "")
+    if len(graph.Nodes) != len(events) {
+        t.Fatalf("%d nodes for %d events", len(graph.Nodes), len(events))
+    }
+    nodes := make(map[string]GraphNode, len(graph.Nodes))
+    rounds := make(map[int]bool)
+    famous := 0
+    for _, node := range graph.Nodes {
+        if node.ID != shortID(node.Hash) || node.Creator == "" || node.Round <= 0 || node.Timestamp.IsZero() {
+            t.Fatalf("incomplete node %+v", node)
+        }
+        if node.Famous != nil && !node.Witness {
+            t.Fatalf("fame on non-witness %s", node.ID)
+        }
+        if node.Famous != nil {
+            famous++
+        }
+        nodes[node.ID] = node
+        rounds[node.Round] = true
+    }
+    if famous == 0 || len(rounds) < 4 {
+        t.Fatalf("%d famous witnesses over %d rounds", famous, len(rounds))
+    }
+    edges := map[string]int{}
+    for _, edge := range graph.Edges {
+        source, okSource := nodes[edge.Source]
+        target, okTarget := nodes[edge.Target]
+        if !okSource || !okTarget {
+            t.Fatalf("edge %+v to a missing node", edge)
+        }
+        if (edge.Type == "self") != (source.Creator == target.Creator) {
+            t.Fatalf("edge %+v of type %s between creators %s and %s", edge, edge.Type, source.Creator, target.Creator)
+        }
+        edges[edge.Type]++
+    }
+    // Every event but the first of each creator has a self-parent
+    if edges["self"] != len(events)-len(members) || edges["other"] == 0 {
+        t.Fatalf("edges by type %v", edges)
+    }
+
+    filtered := servedGraph(t, rooms, "?from=2&to=3")
+    if len(filtered.Nodes) == 0 || len(filtered.Nodes) >= len(graph.Nodes) {
+        t.Fatalf("filtering kept %d of %d nodes", len(filtered.Nodes), len(graph.Nodes))
+    }
+    kept := make(map[string]bool)
+    for _, node := range filtered.Nodes {
+        if node.Round < 2 || node.Round > 3 {
+            t.Fatalf("node %s of round %d outside 2..3", node.ID, node.Round)
+        }
+        kept[node.ID] = true
+    }
+    for _, edge := range filtered.Edges {
+        if !kept[edge.Source] || !kept[edge.Target] {
+            t.Fatalf("edge %+v leaves the filtered rounds", edge)
+        }
+    }
+    if open := servedGraph(t, rooms, "?from=3"); len(open.Nodes) == 0 || len(open.Nodes) >= len(graph.Nodes) {
+        t.Fatalf("open-ended filter kept %d nodes", len(open.Nodes))
+    }
+
+    for query, status := range map[string]int{"?from=x": http.StatusBadRequest, "?to=-1": http.StatusBadRequest, "?room=none": http.StatusNotFound} {
+        recorder := httptest.NewRecorder()
+        graphHandler(recorder, httptest.NewRequest(http.MethodGet, "/graph"+query, nil), rooms)
+        if recorder.Code != status {
+            t.Fatalf("%s: status %d, want %d", query, recorder.Code, status)
+        }
+    }
+}
+
+// Whether a is an ancestor of b, or b itself, by walking b's parents
+func walksTo(events map[string]*Event, b, a *Event) bool {
+    if a.Hash == b.Hash {
+        return true
+    }
+    for _, parent := range append([]string{b.SelfParent, b.OtherParent}, b.ExtraParents...) {
+        if p, ok := events[parent]; ok && walksTo(events, p, a) {
+            return true
+        }
+    }
+    return false
+}
+
+func TestAreConcurrent(t *testing.T) {
+    events := buildTestGraph(t, 37, 4, 40)
+    rooms := NewRoomManager(nil, nil)
+    hg := rooms.Join(defaultRoom)
+    hg.SetMembers(testCreators(events))
+    addTestEvents(t, hg, events)
+
+    byHash := make(map[string]*Event, len(events))
+    for _, event := range events {
+        byHash[event.Hash] = event
+    }
+    concurrent, ordered := 0, 0
+    var pair [2]*Event
+    for _, a := range events {
+        for _, b := range events {
+            want := !walksTo(byHash, a, b) && !walksTo(byHash, b, a)
+            x, _ := hg.GetEvent(a.Hash)
+            y, _ := hg.GetEvent(b.Hash)
+            if hg.AreConcurrent(x, y) != want {
+                t.Fatalf("events %s and %s concurrent %v, want %v", shortID(a.Hash), shortID(b.Hash), !want, want)
+            }
+            if want {
+                concurrent++
+                pair = [2]*Event{a, b}
+            } else if a != b {
+                ordered++
+            }
+        }
+    }
+    if concurrent == 0 || ordered == 0 {
+        t.Fatalf("%d concurrent and %d ordered pairs", concurrent, ordered)
+    }
+
+    // The debug endpoint serves both directions of the relation
+    child := events[len(events)-1]
+    for _, c := range []struct {
+        a, b       *Event
+        concurrent bool
+    }{
+        {byHash[child.SelfParent], child, false},
+        {child, byHash[child.SelfParent], false},
+        {child, child, false},
+        {pair[0], pair[1], true},
+    } {
+        recorder := httptest.NewRecorder()
+        concurrentHandler(recorder, httptest.NewRequest(http.MethodGet, "/graph/concurrent?a="+c.a.Hash+"&b="+c.b.Hash, nil), rooms)
+        var result Concurrency
+        if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+            t.Fatal(err)
+        }
+        if result.Concurrent != c.concurrent || result.AAncestorB != walksTo(byHash, c.b, c.a) || result.BAncestorA != walksTo(byHash, c.a, c.b) {
+            t.Fatalf("served %+v", result)
+        }
+    }
+
+    recorder := httptest.NewRecorder()
+    concurrentHandler(recorder, httptest.NewRequest(http.MethodGet, "/graph/concurrent?a="+child.Hash+"&b=missing", nil), rooms)
+    if recorder.Code != http.StatusNotFound {
+        t.Fatalf("unknown event: status %d", recorder.Code)
+    }
+}
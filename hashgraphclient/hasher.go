@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"sync"
+)
+
+// Domain separation tags, so event hashing and signing input never share a preimage
+const (
+    eventHashDomain = "hashgraph/event-hash/v1\x00"
+    signHashDomain  = "hashgraph/event-sign/v1\x00"
+    stateRootDomain = "hashgraph/state-root/v1\x00"
+    txSignDomain    = "hashgraph/tx-sign/v1\x00"
+    revokeDomain    = "hashgraph/revoke/v1\x00"
+    receiptDomain   = "hashgraph/receipt/v1\x00"
+)
+
+// Default hash algorithm name
+const defaultHashAlgorithm = "sha256"
+
+// Hasher abstraction
+type Hasher interface {
+    Name() string
+    New() hash.Hash
+}
+
+// SHA-256 hasher
+type sha256Hasher struct{}
+
+func (sha256Hasher) Name() string   { return defaultHashAlgorithm }
+func (sha256Hasher) New() hash.Hash { return sha256.New() }
+
+// Registered hashers by algorithm name, read on every hash
+var (
+    hashers = map[string]Hasher{
+        defaultHashAlgorithm: sha256Hasher{},
+    }
+    hashersMutex sync.RWMutex
+)
+
+// Register a hasher so events recording its name can be verified
+func RegisterHasher(h Hasher) {
+    hashersMutex.Lock()
+    defer hashersMutex.Unlock()
+    hashers[h.Name()] = h
+    digestPools.Delete(h.Name())
+}
+
+// Look up the hasher for an event, falling back to SHA-256
+func hasherFor(name string) (Hasher, bool) {
+    if name == "" {
+        name = defaultHashAlgorithm
+    }
+    hashersMutex.RLock()
+    defer hashersMutex.RUnlock()
+    h, ok := hashers[name]
+    return h, ok
+}
+
+// Hasher for digests that are not tied to an event's algorithm
+func defaultHasher() Hasher {
+    h, _ := hasherFor(defaultHashAlgorithm)
+    return h
+}
+
+// Start a digest with the given domain tag already written
+func newDomainHash(h Hasher, domain string) hash.Hash {
+    d := h.New()
+    d.Write([]byte(domain))
+    return d
+}
+
+// Largest scratch buffer kept when a digest is returned to its pool
+const maxPooledDigestBuffer = 64 << 10
+
+// Reusable digest for the hot event hashing paths. Short fields are gathered in buf
+// and written in one go, so hashing an event allocates little beyond its result.
+type pooledDigest struct {
+    hash hash.Hash
+    buf  []byte
+    sum  []byte
+    pool *sync.Pool
+}
+
+// Digest pools by algorithm name
+var digestPools sync.Map
+
+// Take a digest for the hasher from its pool, with the domain tag already written
+func acquireDigest(h Hasher, domain string) *pooledDigest {
+    value, ok := digestPools.Load(h.Name())
+    if !ok {
+        value, _ = digestPools.LoadOrStore(h.Name(), &sync.Pool{New: func() any {
+            return &pooledDigest{hash: h.New()}
+        }})
+    }
+    pool := value.(*sync.Pool)
+    d := pool.Get().(*pooledDigest)
+    d.pool = pool
+    d.hash.Reset()
+    d.buf = append(d.buf[:0], domain...)
+    return d
+}
+
+// Give the digest back to its pool; neither it nor its sum may be used afterwards
+func (d *pooledDigest) release() {
+    if cap(d.buf) > maxPooledDigestBuffer {
+        d.buf = nil
+    }
+    d.pool.Put(d)
+}
+
+// Write a string field prefixed with its 4-byte big-endian length, as writeField does
+func (d *pooledDigest) writeString(field string) {
+    d.buf = binary.BigEndian.AppendUint32(d.buf, uint32(len(field)))
+    d.buf = append(d.buf, field...)
+}
+
+// Write a byte field prefixed with its length, passing long fields straight to the hash
+func (d *pooledDigest) writeBytes(field []byte) {
+    d.buf = binary.BigEndian.AppendUint32(d.buf, uint32(len(field)))
+    if len(field) > cap(d.buf)-len(d.buf) {
+        d.flush()
+        d.hash.Write(field)
+        return
+    }
+    d.buf = append(d.buf, field...)
+}
+
+// Write a big-endian integer
+func (d *pooledDigest) writeUint32(v uint32) {
+    d.buf = binary.BigEndian.AppendUint32(d.buf, v)
+}
+
+// Write a big-endian integer
+func (d *pooledDigest) writeInt64(v int64) {
+    d.buf = binary.BigEndian.AppendUint64(d.buf, uint64(v))
+}
+
+// Write a bool as a single 0 or 1 byte
+func (d *pooledDigest) writeBool(v bool) {
+    if v {
+        d.buf = append(d.buf, 1)
+    } else {
+        d.buf = append(d.buf, 0)
+    }
+}
+
+// Pass gathered fields to the hash
+func (d *pooledDigest) flush() {
+    d.hash.Write(d.buf)
+    d.buf = d.buf[:0]
+}
+
+// Digest of everything written, valid until the digest is released
+func (d *pooledDigest) Sum() []byte {
+    d.flush()
+    d.sum = d.hash.Sum(d.sum[:0])
+    return d.sum
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"sync"
+	"testing"
+	"time"
+)
+
+// SHA-256 under another name, to tell hashers apart by name alone
+type renamedHasher struct{ name string }
+
+func (h renamedHasher) Name() string   { return h.name }
+func (renamedHasher) New() hash.Hash { return sha256.New() }
+
+func testEvent() *Event {
+    return &Event{
+        Transactions:  [][]byte{[]byte("hello")},
+        Creator:       "creator",
+        Timestamp:     time.Unix(1700000000, 0).UTC(),
+        HashAlgorithm: defaultHashAlgorithm,
+        RoomID:        defaultRoom,
+        LamportTime:   1,
+    }
+}
+
+func TestHashEventCoversAlgorithm(t *testing.T) {
+    RegisterHasher(renamedHasher{name: "sha256-renamed"})
+    event := testEvent()
+    original, err := hashEvent(event)
+    if err != nil {
+        t.Fatal(err)
+    }
+    event.HashAlgorithm = "sha256-renamed"
+    renamed, err := hashEvent(event)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if original == renamed {
+        t.Fatal("changing the hash algorithm name did not change the event hash")
+    }
+}
+
+func TestHashEventCoversLamportTimeAndFormat(t *testing.T) {
+    original, err := hashEvent(testEvent())
+    if err != nil {
+        t.Fatal(err)
+    }
+    for name, change := range map[string]func(*Event){
+        "Lamport time":     func(event *Event) { event.LamportTime = 1 << 40 },
+        "signature format": func(event *Event) { event.SignatureFormat = signatureFormatDER },
+    } {
+        event := testEvent()
+        change(event)
+        if hash, err := hashEvent(event); err != nil || hash == original {
+            t.Fatalf("changing the %s did not change the event hash: %v", name, err)
+        }
+    }
+
+    // A relay rewriting a signed event's Lamport time is caught before the event is placed
+    relayed := copyTestEvents(buildTestGraph(t, 5, 4, 1))[0]
+    relayed.LamportTime++
+    result, err := NewHashgraph(nil, nil).AddRemoteEvent(relayed)
+    expectRejected(t, result, err, "validate", errHashMismatch)
+}
+
+func TestHashEventIsStable(t *testing.T) {
+    first, err := hashEvent(testEvent())
+    if err != nil {
+        t.Fatal(err)
+    }
+    second, err := hashEvent(testEvent())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if first != second {
+        t.Fatalf("same event hashed to %s and %s", first, second)
+    }
+}
+
+func TestHashEventUnknownAlgorithm(t *testing.T) {
+    event := testEvent()
+    event.HashAlgorithm = "no-such-hash"
+    if _, err := hashEvent(event); err != errUnknownHashAlgorithm {
+        t.Fatalf("got %v, want errUnknownHashAlgorithm", err)
+    }
+}
+
+func TestDomainsSeparateDigests(t *testing.T) {
+    event := testEvent()
+    hashHex, err := hashEvent(event)
+    if err != nil {
+        t.Fatal(err)
+    }
+    event.Hash = hashHex
+    signing, err := signingDigest(event)
+    if err != nil {
+        t.Fatal(err)
+    }
+    eventHash, _ := hex.DecodeString(hashHex)
+    if bytes.Equal(signing, eventHash) {
+        t.Fatal("signing input equals the event hash")
+    }
+
+    seen := make(map[string]string)
+    for _, domain := range []string{eventHashDomain, signHashDomain, stateRootDomain, txSignDomain, revokeDomain, receiptDomain} {
+        h := newDomainHash(defaultHasher(), domain)
+        h.Write([]byte("same input"))
+        sum := hex.EncodeToString(h.Sum(nil))
+        if other, ok := seen[sum]; ok {
+            t.Fatalf("domains %q and %q share a digest", other, domain)
+        }
+        seen[sum] = domain
+    }
+}
+
+func TestRegisterHasherConcurrentWithHashing(t *testing.T) {
+    var wg sync.WaitGroup
+    for i := 0; i < 4; i++ {
+        wg.Add(2)
+        go func() {
+            defer wg.Done()
+            for j := 0; j < 100; j++ {
+                RegisterHasher(renamedHasher{name: "sha256-concurrent"})
+            }
+        }()
+        go func() {
+            defer wg.Done()
+            for j := 0; j < 100; j++ {
+                if _, err := hashEvent(testEvent()); err != nil {
+                    t.Error(err)
+                    return
+                }
+            }
+        }()
+    }
+    wg.Wait()
+}
+
+// Hash the server computes for integration events of the same content, see its integration tests
+const integrationHashVector = "73713eda8cf306d6f3d5e879768062e4b8f088b2ae416ef648b614277e09e299"
+
+func TestHashMatchesServerIntegrationEvents(t *testing.T) {
+    event := &Event{
+        Transactions:  [][]byte{[]byte("hello")},
+        SelfParent:    "ab",
+        Creator:       "P256:04cd",
+        Timestamp:     time.Unix(1700000000, 5).UTC(),
+        LamportTime:   2,
+        HashAlgorithm: defaultHashAlgorithm,
+        RoomID:        defaultRoom,
+    }
+    hash, err := hashEvent(event)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if hash != integrationHashVector {
+        t.Fatalf("got %s, want %s", hash, integrationHashVector)
+    }
+}
+
+// Hashing an event and deriving its signing input, the per-event cost of creating or
+// verifying one
+func BenchmarkHashAndSigningDigest(b *testing.B) {
+    event := testEvent()
+    event.SelfParent, event.OtherParent = "aaaa", "bbbb"
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        hash, err := hashEvent(event)
+        if err != nil {
+            b.Fatal(err)
+        }
+        event.Hash = hash
+        if _, err := signingDigest(event); err != nil {
+            b.Fatal(err)
+        }
+    }
+}
+
+// Moving bytes across a field or transaction boundary, or reordering transactions, changes the hash
+func TestHashEventFieldBoundaries(t *testing.T) {
+    variants := []func(*Event){
+        func(e *Event) { e.Transactions = [][]byte{[]byte("ab"), []byte("c")} },
+        func(e *Event) { e.Transactions = [][]byte{[]byte("a"), []byte("bc")} },
+        func(e *Event) { e.Transactions = [][]byte{[]byte("abc")} },
+        func(e *Event) { e.Transactions = [][]byte{[]byte("c"), []byte("ab")} },
+        func(e *Event) { e.Transactions = [][]byte{[]byte("abc"), {}} },
+        func(e *Event) { e.RoomID, e.Creator = "lob", "bycreator" },
+        func(e *Event) { e.SelfParent, e.OtherParent = "ab", "c" },
+        func(e *Event) { e.SelfParent, e.OtherParent = "a", "bc" },
+    }
+    seen := make(map[string]int)
+    for i, variant := range variants {
+        event := testEvent()
+        variant(event)
+        hash, err := hashEvent(event)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if j, ok := seen[hash]; ok {
+            t.Fatalf("variants %d and %d hash alike", j, i)
+        }
+        seen[hash] = i
+    }
+}
+
+// A reused digest gives the same bytes as a fresh hash, whatever it hashed before and however
+// long the fields, and concurrent hashing never mixes events up
+func TestPooledDigestMatchesFreshHash(t *testing.T) {
+    fields := [][]byte{{}, []byte("short"), bytes.Repeat([]byte("x"), 200), bytes.Repeat([]byte("y"), maxPooledDigestBuffer+1)}
+    for round := 0; round < 3; round++ {
+        for i := range fields {
+            pooled := acquireDigest(defaultHasher(), eventHashDomain)
+            fresh := newDomainHash(defaultHasher(), eventHashDomain)
+            for _, field := range fields[i:] {
+                pooled.writeBytes(field)
+                writeField(fresh, field)
+            }
+            if !bytes.Equal(pooled.Sum(), fresh.Sum(nil)) {
+                t.Fatalf("pooled digest of fields %d onwards differs on reuse %d", i, round)
+            }
+            pooled.release()
+        }
+    }
+
+    graph := buildTestGraph(t, 107, 4, 200)
+    var wait sync.WaitGroup
+    for worker := 0; worker < 8; worker++ {
+        wait.Add(1)
+        go func() {
+            defer wait.Done()
+            for _, event := range graph {
+                if hash, err := hashEvent(event); err != nil || hash != event.Hash {
+                    t.Errorf("event %s hashed concurrently to %s: %v", shortID(event.Hash), shortID(hash), err)
+                    return
+                }
+            }
+        }()
+    }
+    wait.Wait()
+}
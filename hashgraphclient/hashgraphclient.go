@@ -2,24 +2,31 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
-
-	//"fmt"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
-	"math/big"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/pion/webrtc/v3"
 )
 
@@ -32,21 +39,47 @@ type Message struct {
     OtherParent string `json:"otherParent,omitempty"`
     Event      *Event `json:"event,omitempty"`
     TargetNode string `json:"targetNode,omitempty"` 
+    SourceNode string `json:"sourceNode,omitempty"`
+    EventHash  string `json:"eventHash,omitempty"`
+    Nodes      []string `json:"nodes,omitempty"`
+    Error      string   `json:"error,omitempty"`
+    RoomID     string   `json:"roomId,omitempty"`
+    Status     string   `json:"status,omitempty"`
+    Creator    string   `json:"creator,omitempty"`
+    Events     []*Event `json:"events,omitempty"`
+    NodeID     string   `json:"nodeId,omitempty"`
+    Round      int      `json:"round,omitempty"`
+    Seq        int      `json:"seq,omitempty"` // ping sequence number, echoed in the pong
+    Frontier   map[string]string `json:"frontier,omitempty"` // latest event hash per creator
+    Token      string   `json:"token,omitempty"` // session token to reclaim the session on reconnect
+    Rooms      []string `json:"rooms,omitempty"` // rooms of a reclaimed session
+    Receipt    *ReceiptSignature `json:"receipt,omitempty"` // co-signed consensus receipt
 }
 
 // event structure
 type Event struct {
-    Transactions [][]byte
-    SelfParent   string
-    OtherParent  string
+    Transactions [][]byte `json:",omitempty"`
+    TransactionSignatures []TransactionSignature `json:",omitempty"` // optional, one per transaction
+    SelfParent   string `json:",omitempty"`
+    OtherParent  string `json:",omitempty"`
+    ExtraParents []string `json:",omitempty"` // other-parents beyond OtherParent, when more than one is allowed
     Creator      string
     Timestamp    time.Time
     Signature    string
     Hash         string
-    RoundCreated int
-    Famous       *bool
-    Witness      bool
+    RoundCreated int   `json:",omitempty"`
+    Famous       *bool `json:",omitempty"`
+    Witness      bool  `json:",omitempty"`
     LamportTime  int
+    HashAlgorithm string `json:",omitempty"`
+    RoomID       string `json:",omitempty"`
+    RoundReceived int  `json:",omitempty"`
+    ConsensusTimestamp time.Time // omitted while zero, see MarshalJSON
+    Ephemeral    bool `json:",omitempty"` // creator key lives for one session only and cannot be linked across sessions
+    IdempotencyKey string `json:"-"` // client-supplied key for retried submissions, local only
+    ReceivedFrom string `json:"-"` // peer that first delivered the event, not part of the hash
+    TimestampSources []TimestampSource `json:"-"` // members whose receive times set ConsensusTimestamp, local audit only
+    SignatureFormat string `json:",omitempty"` // encoding of Signature, raw r||s when empty
 }
 
 // WebRTC configuration information
@@ -64,97 +97,567 @@ var (
 type Hashgraph struct {
     Events      map[string]*Event
     Rounds      map[int][]*Event
+    ConsensusOrder []*Event
     privateKey  *ecdsa.PrivateKey
     publicKey   *ecdsa.PublicKey
+    creatorID   string
+    roomID      string
+    ephemeral   bool
+    follower    bool
+    hasher      Hasher
+    signatureFormat string
+    maxLamport  int
+    lamportSkew int
+    maxDepth    int
+    maxOtherParents int
+    minMembers  int
+    heads       map[string]string
+    otherParent OtherParentStrategy
+    lastMerged  map[string]int
+    lastContact map[string]time.Time
+    partitionTimeout time.Duration
+    partitionedSince time.Time
+    partitions  int
+    mergeCount  int
+    ancestorCache map[string]bool
+    ancestorMutex sync.Mutex // guards ancestorCache, which parallel consensus queries share
+    workers     int
+    votes       map[string]map[string]bool
+    lastReceivedRound int
+    peerKnown   map[string]map[string]bool
+    peerWatermarks map[string]int
+    receivedCounts map[string]int
+    selfChildren map[string]string
+    forked      map[string]time.Time
+    roundQuota  int
+    roundCounts map[int]map[string]int
+    epochs      []memberEpoch // member sets by round when membership is managed, nil otherwise
+    revocationAdmins map[string]bool
+    revocationQuorum int
+    revoked     map[string]int
+    signatures  *SignatureCache // verified signatures, forgotten for a creator once it is revoked
+    receipts    map[string]map[string]*ReceiptSignature // consensus receipt signatures by event and signer
+    receiptRetention int
+    orphans     map[string][]*Event // received events by the parent they are waiting for
+    orphanHashes map[string]bool
+    pipeline    []Stage
+    orderer     Orderer
+    persist     func(*Event) error
+    finalizedBatch []*Event
+    debounceInterval time.Duration
+    debounceEvents int
+    pendingConsensus int
+    lastConsensus time.Time
+    consensusTimer *time.Timer
+    consensusRuns int
+    idempotencyKeys map[string]string
+    dedupWindow time.Duration
+    recentSent  map[txDigest]time.Time
+    creatorPolicy *CreatorPolicy
+    finalizedHandlers []func(*Event)
     mutex       sync.RWMutex
 }
 
 // create new Hashgraph
 func NewHashgraph(privateKey *ecdsa.PrivateKey, publicKey *ecdsa.PublicKey) *Hashgraph {
-    return &Hashgraph{
+    hg := &Hashgraph{
         Events:     make(map[string]*Event),
         Rounds:     make(map[int][]*Event),
         privateKey: privateKey,
         publicKey:  publicKey,
+        hasher:     sha256Hasher{},
+        lamportSkew: defaultLamportSkew,
+        minMembers: defaultMinMembers,
+        maxOtherParents: defaultMaxOtherParents,
+        heads:      make(map[string]string),
+        ancestorCache: make(map[string]bool),
+        workers:    defaultConsensusWorkers,
+        votes:      make(map[string]map[string]bool),
+        peerKnown:  make(map[string]map[string]bool),
+        peerWatermarks: make(map[string]int),
+        receivedCounts: make(map[string]int),
+        selfChildren: make(map[string]string),
+        forked:     make(map[string]time.Time),
+        roundCounts: make(map[int]map[string]int),
+        revoked:    make(map[string]int),
+        receipts:   make(map[string]map[string]*ReceiptSignature),
+        receiptRetention: defaultReceiptRetention,
+        orphans:    make(map[string][]*Event),
+        orphanHashes: make(map[string]bool),
+        pipeline:   defaultPipeline(),
+        orderer:    HashgraphOrderer{},
+        otherParent: NewRandomPeerTipStrategy(),
+        lastMerged: make(map[string]int),
+        lastContact: make(map[string]time.Time),
+        idempotencyKeys: make(map[string]string),
+        recentSent: make(map[txDigest]time.Time),
+    }
+    // A graph without a key only verifies and orders others' events
+    if publicKey != nil {
+        hg.creatorID = PublicKeyHex(publicKey)
+    }
+    return hg
+}
+
+// Creator ID of a public key, the curve name and the hex point, so equal bytes on different curves never share an ID
+func PublicKeyHex(publicKey *ecdsa.PublicKey) string {
+    return curveID(publicKey.Curve) + ":" + hex.EncodeToString(elliptic.Marshal(publicKey.Curve, publicKey.X, publicKey.Y))
+}
+
+// shortened creator ID for display
+func shortID(creator string) string {
+    if len(creator) <= 8 {
+        return creator
+    }
+    return creator[len(creator)-8:]
+}
+
+// creator ID of the local node
+func (hg *Hashgraph) CreatorID() string {
+    return hg.creatorID
+}
+
+// look up an event by hash, returning a copy that consensus will not change underneath the caller
+func (hg *Hashgraph) GetEvent(hash string) (*Event, bool) {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    event, ok := hg.Events[hash]
+    if !ok {
+        return nil, false
     }
+    copied := *event
+    return &copied, true
+}
+
+// hash of the latest known event of a creator
+func (hg *Hashgraph) Head(creator string) string {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    return hg.heads[creator]
+}
+
+// Hash of each creator's latest known event, the tips of the DAG
+func (hg *Hashgraph) Frontier() map[string]string {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    frontier := make(map[string]string, len(hg.heads))
+    for creator, hash := range hg.heads {
+        frontier[creator] = hash
+    }
+    return frontier
+}
+
+// hash of the event the configured strategy would use as the next other-parent
+func (hg *Hashgraph) OtherParent() string {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    return hg.otherParent.Select(hg)
+}
+
+// How far a received event's Lamport time may run ahead of its latest parent's
+const defaultLamportSkew = 1
+
+// set the Lamport skew allowed for received events
+func (hg *Hashgraph) SetLamportSkew(skew int) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.lamportSkew = skew
+}
+
+// set how many Lamport steps behind the frontier an other-parent may be, 0 for no limit
+func (hg *Hashgraph) SetMaxDepth(depth int) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.maxDepth = depth
+}
+
+// set the hash function used for locally created events
+func (hg *Hashgraph) SetHasher(h Hasher) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    RegisterHasher(h)
+    hg.hasher = h
+}
+
+// set the signature encoding used for locally created events
+func (hg *Hashgraph) SetSignatureFormat(format string) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.signatureFormat = format
 }
 
 // add event
 func (hg *Hashgraph) AddEvent(event *Event) error {
+    var finalized []*Event
+    defer func() { hg.deliver(finalized) }()
     hg.mutex.Lock()
     defer hg.mutex.Unlock()
 
-    eventHash := hashEvent(event)
+    var err error
+    finalized, err = hg.addLocalEvent(event)
+    return err
+}
+
+// hash, sign and insert a locally created event, caller holds the lock
+func (hg *Hashgraph) addLocalEvent(event *Event) ([]*Event, error) {
+    if hg.follower {
+        return nil, errFollower
+    }
+    if event.HashAlgorithm == "" {
+        event.HashAlgorithm = hg.hasher.Name()
+    }
+    if event.SignatureFormat == "" {
+        event.SignatureFormat = hg.signatureFormat
+    }
+    // Merge further peers' tips when more than one other-parent is allowed
+    if len(event.ExtraParents) == 0 {
+        event.ExtraParents = hg.extraOtherParents(event.OtherParent)
+    }
+    // Count from the parents rather than every event seen, so a peer holding only the ancestry accepts it
+    event.LamportTime = hg.parentLamport(event) + 1
+    // Keep our own chain's timestamps strictly increasing even if the clock steps back
+    if selfParent, ok := hg.Events[event.SelfParent]; ok && !event.Timestamp.After(selfParent.Timestamp) {
+        event.Timestamp = selfParent.Timestamp.Add(time.Nanosecond)
+    }
+    eventHash, err := hashEvent(event)
+    if err != nil {
+        return nil, fmt.Errorf("hash event: %w", err)
+    }
     event.Hash = eventHash
+    // Peers would reject the event and every descendant, so wait for the next round instead
+    if err := quotaStage(hg, event); err != nil {
+        return nil, err
+    }
+    if err := hg.checkRevoked(event); err != nil {
+        return nil, err
+    }
 
     if err := signEvent(event, hg.privateKey); err != nil {
-        return err
+        return nil, err
+    }
+    if hg.persist != nil {
+        if err := hg.persist(event); err != nil {
+            return nil, fmt.Errorf("%w: %s: %w", errPersistFailed, shortID(event.Hash), err)
+        }
     }
 
-    hg.Events[event.Hash] = event
-    hg.Rounds[event.RoundCreated] = append(hg.Rounds[event.RoundCreated], event)
+    hg.insertEvent(event)
+    hg.recordMerge(event)
+    return hg.scheduleConsensus(), nil
+}
 
-    return nil
+// Create and add an event carrying a transaction; a reused idempotency key returns the earlier event instead
+func (hg *Hashgraph) SubmitTransaction(tx []byte, idempotencyKey string) (*Event, error) {
+    var finalized []*Event
+    defer func() { hg.deliver(finalized) }()
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+
+    if idempotencyKey != "" {
+        if hash, ok := hg.idempotencyKeys[idempotencyKey]; ok {
+            return hg.Events[hash], nil
+        }
+    }
+    now := time.Now()
+    if hg.isRecentDuplicate(tx, now) {
+        return nil, errDuplicateText
+    }
+
+    event := &Event{
+        Transactions: [][]byte{tx},
+        SelfParent:   hg.heads[hg.creatorID],
+        OtherParent:  hg.otherParent.Select(hg),
+        Creator:      hg.creatorID,
+        Timestamp:    now,
+        RoomID:       hg.roomID,
+        Ephemeral:    hg.ephemeral,
+        IdempotencyKey: idempotencyKey,
+    }
+    var err error
+    if finalized, err = hg.addLocalEvent(event); err != nil {
+        return nil, err
+    }
+    if idempotencyKey != "" {
+        hg.idempotencyKeys[idempotencyKey] = event.Hash
+    }
+    hg.recordSent(tx, now)
+    return event, nil
+}
+
+// set whether locally created events are flagged ephemeral
+func (hg *Hashgraph) SetEphemeral(ephemeral bool) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.ephemeral = ephemeral
 }
 
+// Lamport time too far ahead error
+var errLamportTooLarge = errors.New("lamport time exceeds local maximum")
+
+// Lamport time not after a parent's
+var errLamportNotAfterParents = errors.New("lamport time not after parents")
+
+// Timestamp not after the self-parent's
+var errTimestampNotIncreasing = errors.New("timestamp not after self-parent")
+
+// Other-parent lies too far behind the frontier
+var errOtherParentTooDeep = errors.New("other-parent exceeds maximum depth")
+
+// add event received from a peer, keeping its hash and signature
+func (hg *Hashgraph) AddRemoteEvent(event *Event) (AddResult, error) {
+    var finalized []*Event
+    defer func() { hg.deliver(finalized) }()
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+
+    err := hg.runPipeline(event)
+    if errors.Is(err, errMissingParent) {
+        if err := hg.holdOrphan(event); err != nil {
+            return AddRejected, err
+        }
+        return AddBuffered, nil
+    }
+    if err == nil {
+        hg.adoptOrphans(event.Hash)
+    }
+    finalized, hg.finalizedBatch = hg.finalizedBatch, nil
+    result := addResultOf(err)
+    if result == AddDuplicate {
+        return result, nil
+    }
+    return result, err
+}
+
+// insert event into the graph, caller holds the lock
+func (hg *Hashgraph) insertEvent(event *Event) {
+    hg.Events[event.Hash] = event
+    hg.heads[event.Creator] = event.Hash
+    hg.lastContact[event.Creator] = time.Now()
+    hg.detectFork(event)
+    hg.divideRounds(event)
+    hg.capWitness(event)
+    hg.addToRound(event)
+    if event.LamportTime > hg.maxLamport {
+        hg.maxLamport = event.LamportTime
+    }
+}
+
+// Event signature does not verify against the creator's key
+var errInvalidSignature = errors.New("invalid event signature")
+
+// Signing a local event failed
+var errSigningFailed = errors.New("signing event failed")
+
+// Persisting a local event failed
+var errPersistFailed = errors.New("persisting event failed")
+
+// Signaling server answered with an unexpected HTTP status
+var errUnexpectedStatus = errors.New("unexpected status")
+
+// Node list could not be fetched
+var errNodesUnavailable = errors.New("node list unavailable")
+
+// unknown hash algorithm error
+var errUnknownHashAlgorithm = errors.New("unknown hash algorithm")
+
 // hash event
-func hashEvent(event *Event) string {
-    hash := sha256.New()
-    hash.Write([]byte(event.Creator))
-    hash.Write([]byte(event.SelfParent))
-    hash.Write([]byte(event.OtherParent))
-    hash.Write([]byte(event.Timestamp.String())) 
+func hashEvent(event *Event) (string, error) {
+    h, ok := hasherFor(event.HashAlgorithm)
+    if !ok {
+        return "", errUnknownHashAlgorithm
+    }
+    // Pooled, as every event is hashed on creation, receipt and replay
+    d := acquireDigest(h, eventHashDomain)
+    defer d.release()
+    // The algorithm and signature format are covered too, so neither name can be swapped under a valid signature
+    d.writeString(event.HashAlgorithm)
+    d.writeString(event.SignatureFormat)
+    d.writeString(event.RoomID)
+    d.writeString(event.Creator)
+    d.writeString(event.SelfParent)
+    d.writeString(event.OtherParent)
+    d.writeInt64(event.Timestamp.UnixNano())
+    // Ordering and the Lamport bound trust it, so a relay must not be able to rewrite it
+    d.writeInt64(int64(event.LamportTime))
+    d.writeBool(event.Ephemeral)
+    d.writeUint32(uint32(len(event.Transactions)))
     for _, tx := range event.Transactions {
-        hash.Write(tx)
+        d.writeBytes(tx)
+    }
+    if len(event.TransactionSignatures) > 0 {
+        d.writeUint32(uint32(len(event.TransactionSignatures)))
+        for _, txSignature := range event.TransactionSignatures {
+            d.writeString(txSignature.Author)
+            d.writeString(txSignature.Signature)
+        }
     }
-    return hex.EncodeToString(hash.Sum(nil))
+    // Tagged, so single-other-parent hashes are unchanged and cannot be confused with this block
+    if len(event.ExtraParents) > 0 {
+        d.writeString("extraParents")
+        d.writeUint32(uint32(len(event.ExtraParents)))
+        for _, parent := range event.ExtraParents {
+            d.writeString(parent)
+        }
+    }
+    return hex.EncodeToString(d.Sum()), nil
+}
+
+// write a field prefixed with its 4-byte big-endian length, so field boundaries are unambiguous
+func writeField(w io.Writer, field []byte) {
+    var length [4]byte
+    binary.BigEndian.PutUint32(length[:], uint32(len(field)))
+    w.Write(length[:])
+    w.Write(field)
+}
+
+// signing input for an event, domain separated from the event hash
+func signingDigest(event *Event) ([]byte, error) {
+    h, ok := hasherFor(event.HashAlgorithm)
+    if !ok {
+        return nil, errUnknownHashAlgorithm
+    }
+    d := acquireDigest(h, signHashDomain)
+    defer d.release()
+    d.buf = append(d.buf, event.Hash...)
+    return append([]byte(nil), d.Sum()...), nil
 }
 
 // sign event
 func signEvent(event *Event, privateKey *ecdsa.PrivateKey) error {
-    hash := sha256.Sum256([]byte(event.Hash))
-    r, s, err := ecdsa.Sign(rand.Reader, privateKey, hash[:])
+    hash, err := signingDigest(event)
     if err != nil {
-        return err
+        return fmt.Errorf("%w: %w", errSigningFailed, err)
+    }
+    switch event.SignatureFormat {
+    case signatureFormatRaw:
+        r, s, err := ecdsa.Sign(rand.Reader, privateKey, hash)
+        if err != nil {
+            return fmt.Errorf("%w: %w", errSigningFailed, err)
+        }
+        event.Signature = hex.EncodeToString(encodeSignature(privateKey.Curve, r, s))
+    case signatureFormatDER:
+        signature, err := ecdsa.SignASN1(rand.Reader, privateKey, hash)
+        if err != nil {
+            return fmt.Errorf("%w: %w", errSigningFailed, err)
+        }
+        event.Signature = hex.EncodeToString(signature)
+    default:
+        return fmt.Errorf("%w: %w %q", errSigningFailed, errUnknownSignatureFormat, event.SignatureFormat)
     }
-    signature := append(r.Bytes(), s.Bytes()...)
-    event.Signature = hex.EncodeToString(signature)
     return nil
 }
 
 // Verifying event signatures
 func verifyEventSignature(event *Event, publicKey *ecdsa.PublicKey) bool {
-    hash := sha256.Sum256([]byte(event.Hash))
+    hash, err := signingDigest(event)
+    if err != nil {
+        return false
+    }
     signature, err := hex.DecodeString(event.Signature)
     if err != nil {
         return false
     }
-    r := big.NewInt(0).SetBytes(signature[:len(signature)/2])
-    s := big.NewInt(0).SetBytes(signature[len(signature)/2:])
-    return ecdsa.Verify(publicKey, hash[:], r, s)
+    switch event.SignatureFormat {
+    case signatureFormatRaw:
+        r, s, ok := decodeSignature(publicKey.Curve, signature)
+        if !ok {
+            return false
+        }
+        return ecdsa.Verify(publicKey, hash, r, s)
+    case signatureFormatDER:
+        return ecdsa.VerifyASN1(publicKey, hash, signature)
+    }
+    return false
+}
+
+// Get the list of online nodes from the signaling server's node list URL
+func getNodes(url string) ([]string, error) {
+    backoff := nodesInitialBackoff
+    var err error
+    for attempt := 1; attempt <= nodesAttempts; attempt++ {
+        var nodes []string
+        if nodes, err = fetchNodes(url); err == nil {
+            return nodes, nil
+        }
+        if attempt < nodesAttempts {
+            log.Printf("Failed to get online node list (attempt %d/%d), retrying in %s: %v", attempt, nodesAttempts, backoff, err)
+            time.Sleep(backoff)
+            backoff *= 2
+        }
+    }
+    return nil, fmt.Errorf("%w after %d attempts: %w", errNodesUnavailable, nodesAttempts, err)
 }
 
-// Get the list of online nodes
-func getNodes() ([]string, error) {
-    resp, err := http.Get("http://13.208.252.171:8080/nodes")
+// Attempts, first retry delay (doubled each time) and per-attempt timeout when fetching the node list
+const (
+    nodesAttempts       = 5
+    nodesInitialBackoff = 500 * time.Millisecond
+    nodesTimeout        = 5 * time.Second
+)
+
+// Fetch the node list once
+func fetchNodes(url string) ([]string, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), nodesTimeout)
+    defer cancel()
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
     if err != nil {
         return nil, err
     }
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("fetch node list: %w", err)
+    }
     defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("%w %s", errUnexpectedStatus, resp.Status)
+    }
 
     var nodes []string
     if err := json.NewDecoder(resp.Body).Decode(&nodes); err != nil {
-        return nil, err
+        return nil, fmt.Errorf("decode node list: %w", err)
     }
     return nodes, nil
 }
 
+// ICE servers served by the signaling server
+type iceResponse struct {
+    ICEServers []struct {
+        URLs       []string `json:"urls"`
+        Username   string   `json:"username"`
+        Credential string   `json:"credential"`
+    } `json:"iceServers"`
+}
+
+// Get the ICE servers, with short-lived TURN credentials, from the signaling server
+func getICEServers() ([]webrtc.ICEServer, error) {
+    resp, err := http.Get("http://13.208.252.171:8080/ice")
+    if err != nil {
+        return nil, fmt.Errorf("fetch ICE servers: %w", err)
+    }
+    defer resp.Body.Close()
+
+    var ice iceResponse
+    if err := json.NewDecoder(resp.Body).Decode(&ice); err != nil {
+        return nil, fmt.Errorf("decode ICE servers: %w", err)
+    }
+    servers := make([]webrtc.ICEServer, 0, len(ice.ICEServers))
+    for _, s := range ice.ICEServers {
+        servers = append(servers, webrtc.ICEServer{
+            URLs:       s.URLs,
+            Username:   s.Username,
+            Credential: s.Credential,
+        })
+    }
+    return servers, nil
+}
+
 // Creating a new WebRTC connection
-func createPeerConnection() (*webrtc.PeerConnection, error) {
+func createPeerConnection(metrics *ConnectionMetrics) (*webrtc.PeerConnection, error) {
     peerConnection, err := webrtc.NewPeerConnection(webrtcConfig)
     if err != nil {
         return nil, err
     }
+    connectionID := metrics.Add()
 
     // Setting up ICE candidate processing
     peerConnection.OnICECandidate(func(c *webrtc.ICECandidate) {
@@ -167,12 +670,159 @@ func createPeerConnection() (*webrtc.PeerConnection, error) {
     // Setting up ICE connection status processing
     peerConnection.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
         log.Printf("ICE connection status: %s\n", state.String())
+        metrics.SetState(connectionID, state)
     })
 
     return peerConnection, nil
 }
 
+// Announce a node's key, frontier and presence to the signaling server.
+// Followers never create events, so they have no creator to announce.
+func announceNode(c *SignalConn, hg *Hashgraph, follower bool) {
+    hello := Message{Type: "hello", RoomID: defaultRoom}
+    if !follower {
+        hello.Creator = hg.CreatorID()
+    }
+    if err := c.WriteJSON(hello); err != nil {
+        log.Println("Failed to send hello:", err)
+    }
+    frontier := Message{Type: "frontier", Frontier: hg.Frontier(), RoomID: defaultRoom}
+    if err := c.WriteJSON(frontier); err != nil {
+        log.Println("Failed to send frontier:", err)
+    }
+    if err := c.WriteJSON(Message{Type: "presence", Status: presenceOnline, RoomID: defaultRoom}); err != nil {
+        log.Println("Failed to send presence:", err)
+    }
+}
+
 func main() {
+    adminAddr := flag.String("admin", "", "address for the admin HTTP endpoints, disabled if empty")
+    eventsOrdered := flag.Bool("events-ordered", reliableChannelConfig.Ordered, "deliver events in order on the data channel")
+    eventsMaxRetransmits := flag.Int("events-max-retransmits", reliableChannelConfig.MaxRetransmits, "retransmit limit for the events data channel, negative for fully reliable")
+    minMembers := flag.Int("min-members", defaultMinMembers, "members required before consensus runs")
+    maxOtherParents := flag.Int("max-other-parents", defaultMaxOtherParents, "other-parents an event may merge; above 1, local events also merge the latest tips of further peers")
+    watchdogInterval := flag.Duration("watchdog-interval", defaultWatchdogInterval, "time consensus may go without finalizing a round before a stall is logged")
+    strict := flag.Bool("strict", false, "reply to unknown message types with a protocol_error")
+    strictDisconnect := flag.Bool("strict-disconnect", false, "in strict mode, also disconnect on unknown message types")
+    snapshotPath := flag.String("snapshot", "", "file to persist consensus snapshots to, disabled if empty")
+    snapshotInterval := flag.Duration("snapshot-interval", defaultSnapshotInterval, "interval between snapshot saves")
+    creatorPolicyPath := flag.String("creator-policy", "", "JSON file with creator allow and deny lists, reloaded on SIGHUP")
+    gatheringTimeout := flag.Duration("gathering-timeout", defaultGatheringTimeout, "time ICE gathering may take before the offer is sent with the candidates gathered so far, 0 to wait indefinitely")
+    negotiationTimeout := flag.Duration("negotiation-timeout", defaultNegotiationTimeout, "time an offer may go without completing before its PeerConnection is closed, 0 to disable")
+    roomCreate := flag.String("room-create", "strict", "posting to a room that does not exist: strict creates only -room-create-allow rooms, permissive any not denied")
+    roomCreateAllow := flag.String("room-create-allow", "", "comma-separated rooms posting may create")
+    roomCreateDeny := flag.String("room-create-deny", "", "comma-separated rooms posting may never create")
+    unknownRoomName := flag.String("unknown-room", string(UnknownRoomIgnore), "events for unjoined rooms: ignore, buffer or auto-join")
+    maxUnknownRooms := flag.Int("max-unknown-rooms", defaultMaxUnknownRooms, "unjoined rooms peers' events may have buffered or auto-joined at once")
+    genesisPath := flag.String("genesis", "", "JSON file with the signed genesis event set to seed an empty graph with")
+    partitionTimeout := flag.Duration("partition-timeout", 0, "time a supermajority may go unheard before finalizing stops on a suspected partition, 0 to disable")
+    otherParentName := flag.String("other-parent", "random-peer-tip", "other-parent strategy: random-peer-tip, highest-round, least-recently-merged or none")
+    ordererName := flag.String("orderer", HashgraphOrderer{}.Name(), "ordering strategy: hashgraph, lamport or solo")
+    solo := flag.Bool("solo", false, "run alone for local testing: no other-parents, own events finalized in Lamport order; same as -other-parent none -orderer solo")
+    reportPath := flag.String("shutdown-report", "", "file to write the shutdown report to, in addition to the log")
+    verifyWorkers := flag.Int("verify-workers", defaultVerifyWorkers, "inbound events verified concurrently")
+    verifyQueue := flag.Int("verify-queue", defaultVerifyQueue, "inbound events waiting for a verification worker before further ones are rejected")
+    maxGossipSessions := flag.Int("max-gossip-sessions", defaultMaxGossipSessions, "simultaneous outbound gossip sessions, rounds are skipped when saturated")
+    gossipInterval := flag.Duration("gossip-interval", defaultGossipInterval, "interval between gossip rounds with peers from the partial view, 0 to disable")
+    curveName := flag.String("curve", defaultCurve, "curve for newly generated keys: P256, P384 or P521")
+    printIdentity := flag.Bool("print-identity", false, "print the node's public key and creator ID, then exit")
+    keyPath := flag.String("key", defaultKeyPath, "file holding the node's persistent key")
+    flag.BoolVar(&compactWire, "compact-events", true, "leave consensus-derived fields out of events sent to peers")
+    discover := flag.Bool("mdns", false, "discover and announce peers on the local network with mDNS")
+    bootstrap := flag.String("bootstrap", "", "comma-separated bootstrap peers to pin ahead of the server's node list")
+    follower := flag.Bool("follower", false, "observe the chat read-only, syncing and ordering events without creating any")
+    ephemeral := flag.Bool("ephemeral", false, "chat under a fresh key that is discarded when the session ends")
+    deadLetterAfter := flag.Int("deadletter-after", defaultDeadLetterAfter, "rejections of the same event before it is kept as a dead letter")
+    maxDepth := flag.Int("max-depth", 0, "Lamport steps behind the frontier an other-parent may be, 0 for no limit")
+    ackWindow := flag.Duration("ack-window", defaultAckWindow, "time an event is retransmitted without an acknowledgment before it is dead-lettered")
+    retransmitInterval := flag.Duration("retransmit-interval", defaultRetransmitInterval, "interval between retransmissions of unacknowledged events, 0 to disable")
+    consensusWorkers := flag.Int("consensus-workers", defaultConsensusWorkers, "goroutines consensus queries run on, 0 for one per CPU")
+    consensusDebounce := flag.Duration("consensus-debounce", 0, "coalesce consensus recomputes to at most one per interval, 0 to recompute on every event")
+    consensusDebounceEvents := flag.Int("consensus-debounce-events", 0, "recompute early once this many events are waiting, 0 for no limit")
+    roundQuota := flag.Int("round-quota", 0, "events a creator may have in one round before further ones are rejected, 0 for no limit")
+    revocationAdmins := flag.String("revocation-admins", "", "comma-separated creator IDs of the admins who may revoke keys")
+    receipts := flag.Bool("receipts", false, "co-sign a consensus receipt for every finalized event and share it with peers, for GET /proof")
+    receiptRetention := flag.Int("receipt-retention", defaultReceiptRetention, "rounds behind the latest round received that receipt signatures are kept for proofs, 0 to keep them all")
+    initialMembers := flag.String("members", "", "comma-separated creator IDs of the initial members, changed by join and leave transactions; empty takes the -genesis members")
+    revocationQuorum := flag.Int("revocation-quorum", 0, "admin signatures a revocation needs, 0 for a majority of the admins")
+    signRevocation := flag.String("sign-revocation", "", "sign a revocation of <creator>@<round> with the node key as an admin, print the signature, then exit")
+    latencyBias := flag.Float64("latency-bias", defaultLatencyBias, "probability gossip picks the lowest-latency peer instead of a random one, 0 for uniform sampling")
+    heartbeatInterval := flag.Duration("heartbeat-interval", defaultHeartbeatInterval, "interval between empty heartbeat events while idle, 0 to disable")
+    dedupWindow := flag.Duration("dedup-window", 0, "suppress sending the same text again within this window, 0 to disable")
+    previewLength := flag.Int("preview-length", defaultPreviewLength, "bytes of a message shown before it is truncated, 0 to show messages in full")
+    historyLimit := flag.Int("history", defaultHistoryLimit, "finalized messages kept in memory and served by GET /messages, 0 to keep all")
+    storeSpec := flag.String("store", "", "event store: memory, file:<dir> for one file per event, or a mongodb://<host>/<db> URI; disabled if empty")
+    storeVerifyName := flag.String("store-verify", string(StoreVerifyOff), "check events reloaded from the store: off, lenient to quarantine corrupt events, strict to refuse to start")
+    reconnect := flag.Bool("reconnect", true, "redial the signaling server when the connection drops, re-announcing identity and frontier")
+    signatureFormatName := flag.String("signature-format", "raw", "encoding of event signatures: raw (r||s) or der (ASN.1)")
+    exportPath := flag.String("export-state", "", "write the key and snapshot to a state bundle encrypted with $STATE_PASSPHRASE, then exit")
+    importPath := flag.String("import-state", "", "restore the key and snapshot from a state bundle encrypted with $STATE_PASSPHRASE, then exit")
+    checkStore := flag.Bool("check-store", false, "check the -store event store for corrupt hashes or signatures and missing parents, print a report and exit")
+    flag.Parse()
+    startedAt := time.Now()
+
+    curve, err := curveByName(*curveName)
+    if err != nil {
+        log.Fatal("Invalid curve:", err)
+    }
+
+    // Print the node's identity and exit without connecting
+    if *printIdentity {
+        if err := writeIdentity(os.Stdout, *keyPath, curve); err != nil {
+            log.Fatal("Failed to load ECDSA key:", err)
+        }
+        return
+    }
+
+    // Sign a key revocation as an admin and exit without connecting
+    if *signRevocation != "" {
+        creator, round, ok := strings.Cut(*signRevocation, "@")
+        afterRound, err := strconv.Atoi(round)
+        if !ok || err != nil {
+            log.Fatal("Invalid revocation, want <creator>@<round>")
+        }
+        privateKey, err := loadOrCreateKey(*keyPath, curve)
+        if err != nil {
+            log.Fatal("Failed to load ECDSA key:", err)
+        }
+        signature, err := SignRevocation(creator, afterRound, privateKey)
+        if err != nil {
+            log.Fatal("Failed to sign revocation:", err)
+        }
+        json.NewEncoder(os.Stdout).Encode(signature)
+        return
+    }
+
+    // Check a store after a crash and exit without connecting
+    if *checkStore {
+        store, err := openStore(*storeSpec)
+        if err != nil {
+            log.Fatal("Failed to open store:", err)
+        }
+        report, err := CheckStore(store)
+        if closer, ok := store.(io.Closer); ok {
+            closer.Close()
+        }
+        if err != nil {
+            log.Fatal("Store check failed:", err)
+        }
+        encoder := json.NewEncoder(os.Stdout)
+        encoder.SetIndent("", "  ")
+        encoder.Encode(report)
+        if !report.OK() {
+            os.Exit(1)
+        }
+        return
+    }
+
+    // Move node state between machines without connecting
+    if *exportPath != "" || *importPath != "" {
+        if err := migrateState(*exportPath, *importPath, *keyPath, *snapshotPath); err != nil {
+            log.Fatal("State migration failed:", err)
+        }
+        return
+    }
+
     // WebSocket server address
     addr := "13.208.252.171:8080"
 
@@ -180,26 +830,342 @@ func main() {
     u := url.URL{Scheme: "ws", Host: addr, Path: "/signal"}
     log.Printf("connect to %s", u.String())
 
-    c, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+    c, err := DialSignal(u.String(), *reconnect)
     if err != nil {
         log.Fatal("dial-up failure:", err)
     }
     defer c.Close()
 
+    // Add TURN servers from the signaling server, falling back to STUN only
+    iceServers, err := getICEServers()
+    if err != nil {
+        log.Println("Failed to get ICE servers, using STUN only:", err)
+    }
+    webrtcConfig.ICEServers = append(webrtcConfig.ICEServers, iceServers...)
+
     // create WebRTC PeerConnection
-    peerConnection, err := createPeerConnection()
+    connectionMetrics := NewConnectionMetrics()
+    peerConnection, err := createPeerConnection(connectionMetrics)
     if err != nil {
         log.Fatal("Failed to create PeerConnection:", err)
     }
 
-    // Generate ECDSA key pairs
-    privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    // Create the data channels for event gossip and presence
+    eventsConfig := DataChannelConfig{Ordered: *eventsOrdered, MaxRetransmits: *eventsMaxRetransmits}
+    eventsChannel, err := createDataChannel(peerConnection, "events", eventsConfig)
+    if err != nil {
+        log.Fatal("Failed to create events data channel:", err)
+    }
+    if _, err := createDataChannel(peerConnection, "presence", unreliableChannelConfig); err != nil {
+        log.Fatal("Failed to create presence data channel:", err)
+    }
+    filesChannel, err := createDataChannel(peerConnection, "files", reliableChannelConfig)
+    if err != nil {
+        log.Fatal("Failed to create files data channel:", err)
+    }
+    files := NewFileStore()
+    transfer := NewFileTransfer(filesChannel, files)
+
+    // Load the persistent key, or generate a throwaway one in ephemeral mode
+    var privateKey *ecdsa.PrivateKey
+    if *ephemeral {
+        privateKey, err = ecdsa.GenerateKey(curve, rand.Reader)
+    } else {
+        privateKey, err = loadOrCreateKey(*keyPath, curve)
+    }
+    if err != nil {
+        log.Fatal("Failed to load ECDSA key:", err)
+    }
+    registry := NewCreatorRegistry()
+    deadLetters := NewDeadLetterStore(defaultDeadLetterCapacity, *deadLetterAfter)
+    signatures := NewSignatureCache(defaultSignatureCacheSize)
+
+    if *solo {
+        *ordererName = SoloOrderer{}.Name()
+        *otherParentName = NoOtherParentStrategy{}.Name()
+        log.Println("Solo mode: events are finalized locally, not by consensus")
+    }
+    orderer, err := ordererByName(*ordererName)
     if err != nil {
-        log.Fatal("Failed to generate ECDSA key:", err)
+        log.Fatal("Invalid orderer:", err)
+    }
+
+    if _, err := otherParentStrategyByName(*otherParentName); err != nil {
+        log.Fatal("Invalid other-parent strategy:", err)
+    }
+
+    unknownRoom, err := parseUnknownRoomPolicy(*unknownRoomName)
+    if err != nil {
+        log.Fatal("Invalid unknown room policy:", err)
+    }
+    var createAllow, createDeny []string
+    if *roomCreateAllow != "" {
+        createAllow = strings.Split(*roomCreateAllow, ",")
+    }
+    if *roomCreateDeny != "" {
+        createDeny = strings.Split(*roomCreateDeny, ",")
+    }
+    roomCreatePolicy, err := NewRoomCreatePolicy(*roomCreate, createAllow, createDeny)
+    if err != nil {
+        log.Fatal("Invalid room create policy:", err)
+    }
+
+    signatureFormat, err := signatureFormatByName(*signatureFormatName)
+    if err != nil {
+        log.Fatal("Invalid signature format:", err)
+    }
+
+    var admins []string
+    if *revocationAdmins != "" {
+        admins = strings.Split(*revocationAdmins, ",")
+    }
+    var members []string
+    if *initialMembers != "" {
+        members = strings.Split(*initialMembers, ",")
+    }
+    var genesis *Genesis
+    if *genesisPath != "" {
+        genesis, err = LoadGenesis(*genesisPath)
+        if err != nil {
+            log.Fatal("Failed to load genesis:", err)
+        }
+        if len(members) == 0 {
+            members = genesis.Members
+        }
+    }
+    // Consensus thresholds come from an agreed member set, never from the creators a node happens to have seen
+    if len(members) == 0 && orderer.Name() == (HashgraphOrderer{}).Name() {
+        log.Fatal("Hashgraph consensus needs a member set: pass -members or -genesis")
+    }
+    quorum := *revocationQuorum
+    if quorum == 0 {
+        quorum = len(admins)/2 + 1
     }
 
     publicKey := &privateKey.PublicKey
-    hashgraph := NewHashgraph(privateKey, publicKey)
+    rooms := NewRoomManager(privateKey, publicKey)
+    rooms.SetUnknownRoomPolicy(unknownRoom)
+    rooms.SetMaxUnknownRooms(*maxUnknownRooms)
+    rooms.SetRoomCreatePolicy(roomCreatePolicy)
+    rooms.Configure(func(hg *Hashgraph) {
+        hg.SetOrderer(orderer)
+        strategy, _ := otherParentStrategyByName(*otherParentName)
+        hg.SetOtherParentStrategy(strategy)
+        hg.SetMinMembers(*minMembers)
+        hg.SetEphemeral(*ephemeral)
+        hg.SetFollower(*follower)
+        hg.SetMaxDepth(*maxDepth)
+        hg.SetMaxOtherParents(*maxOtherParents)
+        hg.SetPartitionTimeout(*partitionTimeout)
+        hg.SetSignatureFormat(signatureFormat)
+        hg.SetRoundQuota(*roundQuota)
+        hg.SetConsensusDebounce(*consensusDebounce, *consensusDebounceEvents)
+        hg.SetConsensusWorkers(*consensusWorkers)
+        hg.SetDedupWindow(*dedupWindow)
+        hg.SetRevocationAdmins(admins, quorum)
+        hg.SetSignatureCache(signatures)
+        hg.SetMembers(members)
+    })
+    if *receipts {
+        rooms.Configure(func(hg *Hashgraph) {
+            hg.SetReceiptRetention(*receiptRetention)
+            hg.OnFinalized(func(event *Event) {
+                signed, err := hg.SignReceipt(event)
+                if err != nil {
+                    log.Println("Failed to sign consensus receipt:", err)
+                    return
+                }
+                if signed == nil {
+                    return
+                }
+                if err := c.WriteJSON(Message{Type: "receipt", Receipt: signed, RoomID: event.RoomID}); err != nil {
+                    log.Println("Failed to share consensus receipt:", err)
+                }
+            })
+        })
+    }
+    if *creatorPolicyPath != "" {
+        policy, err := LoadCreatorPolicy(*creatorPolicyPath)
+        if err != nil {
+            log.Fatal("Failed to load creator policy:", err)
+        }
+        rooms.Configure(func(hg *Hashgraph) {
+            hg.SetCreatorPolicy(policy)
+        })
+        go reloadOnHangup(policy)
+    }
+    var store Store
+    storeVerify, err := storeVerifyByName(*storeVerifyName)
+    if err != nil {
+        log.Fatal("Invalid store verification:", err)
+    }
+    if *storeSpec != "" {
+        if store, err = openStore(*storeSpec); err != nil {
+            log.Fatal("Failed to open store:", err)
+        }
+        if closer, ok := store.(io.Closer); ok {
+            defer closer.Close()
+        }
+        rooms.Configure(func(hg *Hashgraph) {
+            hg.SetStore(store)
+        })
+    }
+    hashgraph := rooms.Join(defaultRoom)
+    if *snapshotPath != "" {
+        snapshot, err := LoadSnapshot(*snapshotPath)
+        if err != nil {
+            log.Fatal("Failed to load snapshot:", err)
+        }
+        if snapshot != nil {
+            hashgraph.RestoreSnapshot(snapshot)
+            log.Printf("Restored %d events from snapshot", len(snapshot.Events))
+        }
+        go runSnapshots(hashgraph, *snapshotPath, *snapshotInterval)
+    }
+    if store != nil && hashgraph.EventCount() == 0 {
+        loaded, err := hashgraph.LoadStore(store, storeVerify, func(event *Event, reason error) {
+            log.Printf("Quarantined corrupt event %s from store: %v", shortID(event.Hash), reason)
+            deadLetters.Add(event, reason, "store")
+        })
+        if err != nil {
+            log.Fatal("Failed to load events from store:", err)
+        }
+        log.Printf("Loaded %d events from store", loaded)
+    }
+    if genesis != nil && hashgraph.EventCount() == 0 {
+        if err := hashgraph.ApplyGenesis(genesis); err != nil {
+            log.Fatal("Invalid genesis:", err)
+        }
+        log.Printf("Seeded %d members from genesis", len(genesis.Members))
+    }
+    go NewWatchdog(hashgraph, *watchdogInterval).Run()
+
+    // Print messages, edits and deletions once they reach consensus
+    chat := NewChatView()
+    chat.SetPreview(*previewLength, files)
+    chat.SetHistoryLimit(*historyLimit)
+    rooms.SubscribeRoom(defaultRoom, func(event *Event) {
+        for _, message := range chat.Apply(event) {
+            switch {
+            case message.File != nil && !message.Deleted:
+                log.Printf("[%s] %s %s: file %s (%d bytes, %s) %s", defaultRoom, message.Sender(), message.ID, message.File.Name, message.File.Size, message.File.MIME, message.File.Hash)
+            case message.Deleted:
+                log.Printf("[%s] %s deleted %s", defaultRoom, message.Sender(), message.ID)
+            case message.Edited:
+                log.Printf("[%s] %s edited %s: %s", defaultRoom, message.Sender(), message.ID, message.Text)
+            case message.Truncated:
+                log.Printf("[%s] %s %s: %s (/more %s)", defaultRoom, message.Sender(), message.ID, message.Text, message.ContentHash)
+            case message.Ephemeral:
+                log.Printf("[%s] %s (ephemeral) %s: %s", defaultRoom, message.Sender(), message.ID, message.Text)
+            default:
+                log.Printf("[%s] %s %s: %s", defaultRoom, message.Sender(), message.ID, message.Text)
+            }
+        }
+    })
+
+    latency := NewLatencyTracker()
+
+    presence := NewPresenceTracker()
+
+    // Session ID the signaling server assigned to this node, learned from its welcome
+    var selfID atomic.Value
+    selfID.Store("")
+    sampler := NewPeerSampler(defaultViewSize, defaultShuffleLength)
+
+    // Open the outbox of unacknowledged events
+    outbox, err := OpenOutbox(defaultOutboxPath)
+    if err != nil {
+        log.Fatal("Failed to open outbox:", err)
+    }
+
+    // Verify an event against its creator's key and add it to the local Hashgraph
+    addReceived := func(event *Event, source string) AddResult {
+        creatorKey, err := registry.Observe(event)
+        if err != nil {
+            log.Println("Unknown event creator:", err)
+            deadLetters.Reject(event, err, source)
+            return AddRejected
+        }
+        // The cache also checks that the creator is the ID of the key. The signature covers the
+        // hash alone; the pipeline's validate stage checks the hash against the contents.
+        if !signatures.Verify(event, creatorKey) {
+            log.Println("Event signature verification failed")
+            deadLetters.Reject(event, errInvalidSignature, source)
+            return AddRejected
+        }
+        event.ReceivedFrom = source
+        result, err := rooms.AddRemoteEvent(event)
+        if err != nil {
+            log.Println("Failed to add event:", err)
+            var stageErr *StageError
+            if errors.As(err, &stageErr) && stageErr.Stage == "validate" {
+                deadLetters.Reject(event, err, source)
+            }
+        }
+        return result
+    }
+
+    // Inbound events are verified by a bounded pool; when it is saturated they are shed
+    // and the sender told, leaving them in its outbox to be retransmitted
+    verifier := NewVerifyPool(*verifyWorkers, *verifyQueue)
+    shed := func(event *Event, source string) {
+        log.Printf("Shedding event %s from %s: %v", shortID(event.Hash), shortID(source), errVerifyQueueFull)
+        reply := Message{Type: "event_rejected", EventHash: event.Hash, Error: errVerifyQueueFull.Error(), TargetNode: source}
+        if err := c.WriteJSON(reply); err != nil {
+            log.Println("Failed to send event rejection:", err)
+        }
+    }
+
+    // Outbound gossip, also started when a peer advertises its frontier
+    gossiper := NewGossiper(c, hashgraph, *maxGossipSessions)
+    if *latencyBias > 0 {
+        gossiper.SetLatencyBias(latency, *latencyBias)
+    }
+    if *adminAddr != "" {
+        go serveAdmin(*adminAddr, rooms, chat, deadLetters, connectionMetrics, latency, gossiper, store)
+    }
+
+    // Verify a received event, acknowledge it to its sender and pass it on
+    receiveEvent := func(event *Event, source string) {
+        err := verifier.Submit(func() {
+            result := addReceived(event, source)
+            if result == AddRejected {
+                return
+            }
+
+            // Acknowledge the event to its sender
+            ack := Message{
+                Type:       "ack",
+                EventHash:  event.Hash,
+                TargetNode: source,
+            }
+            if err := c.WriteJSON(ack); err != nil {
+                log.Println("Failed to send ack:", err)
+            }
+
+            // Pass new events on to another peer, duplicates are already spreading
+            if result == AddInserted {
+                for _, peer := range sampler.Sample(1) {
+                    if peer != source {
+                        gossiper.TryGossip(peer)
+                    }
+                }
+            }
+        })
+        if err != nil {
+            shed(event, source)
+        }
+    }
+
+    // Gossip events over the events data channel to the peer at its far end, which sends
+    // its own on the channel it opened
+    events := NewEventChannel(eventsChannel, func() string { return selfID.Load().(string) }, receiveEvent)
+    gossiper.SetEventChannel(events)
+    peerConnection.OnDataChannel(func(channel *webrtc.DataChannel) {
+        if channel.Label() == "events" {
+            events.Receive(channel)
+        }
+    })
 
     go func() {
         for {
@@ -241,6 +1207,9 @@ func main() {
                     return
                 }
 
+            case "answer":
+                log.Println("Answer received")
+
             case "candidate":
                 log.Println("Received ICE candidate")
                 // Add ICE Candidate
@@ -254,15 +1223,165 @@ func main() {
 
             case "event":
                 log.Println("Receive event")
-                // Verifying event signatures
-                if !verifyEventSignature(msg.Event, publicKey) {
-                    log.Println("Event signature verification failed")
-                    return
+                if msg.Event == nil {
+                    continue
                 }
+                receiveEvent(msg.Event, msg.SourceNode)
 
-                // Adding Events to the Local Hashgraph
-                if err := hashgraph.AddEvent(msg.Event); err != nil {
-                    log.Println("Failed to add event:", err)
+            case "events_since":
+                // Reply with the creator's events after the given hash
+                roomID := msg.RoomID
+                if roomID == "" {
+                    roomID = defaultRoom
+                }
+                chain, err := rooms.EventsSince(roomID, msg.Creator, msg.EventHash)
+                if err != nil {
+                    log.Println("Failed to answer events-since request:", err)
+                    continue
+                }
+                reply := Message{Type: "events_chain", Events: wireEvents(chain), RoomID: roomID, TargetNode: msg.SourceNode}
+                if err := c.WriteJSON(reply); err != nil {
+                    log.Println("Failed to send events chain:", err)
+                }
+
+            case "events_chain":
+                // Add a creator's missing tail, oldest first, as one verification job
+                if len(msg.Events) == 0 {
+                    continue
+                }
+                chain, source := msg.Events, msg.SourceNode
+                err := verifier.Submit(func() {
+                    for _, event := range chain {
+                        if addReceived(event, source) == AddRejected {
+                            break
+                        }
+                    }
+                })
+                if err != nil {
+                    shed(chain[0], source)
+                }
+
+            case "welcome":
+                if msg.Error != "" {
+                    log.Println("Session not reclaimed, starting a new one:", msg.Error)
+                } else if len(msg.Rooms) > 0 {
+                    log.Printf("Session reclaimed with rooms %v", msg.Rooms)
+                    for _, roomID := range msg.Rooms {
+                        rooms.Join(roomID)
+                    }
+                }
+                selfID.Store(msg.NodeID)
+                sampler.SetSelf(msg.NodeID)
+                c.SetSessionToken(msg.Token)
+                log.Println("Signaling session:", msg.NodeID)
+
+            case "nodes":
+                log.Printf("Online Node List: %v", withoutSelf(msg.Nodes, selfID.Load().(string)))
+                sampler.Add(msg.Nodes)
+                // Sessions no longer connected take their ephemeral creators with them
+                for _, creator := range registry.EndSessionsNotIn(msg.Nodes) {
+                    signatures.InvalidateCreator(creator)
+                    log.Printf("Ephemeral creator %s left", shortID(creator))
+                }
+
+            case "watermark":
+                roomID := msg.RoomID
+                if roomID == "" {
+                    roomID = defaultRoom
+                }
+                rooms.RecordWatermark(roomID, msg.SourceNode, msg.Round)
+
+            case "shuffle":
+                reply := Message{Type: "shuffle_reply", Nodes: sampler.HandleShuffle(msg.SourceNode, msg.Nodes), TargetNode: msg.SourceNode}
+                if err := c.WriteJSON(reply); err != nil {
+                    log.Println("Failed to answer shuffle:", err)
+                }
+
+            case "shuffle_reply":
+                sampler.HandleShuffleReply(msg.SourceNode, msg.Nodes)
+
+            case "ping":
+                if err := c.WriteJSON(Message{Type: "pong", Seq: msg.Seq, TargetNode: msg.SourceNode}); err != nil {
+                    log.Println("Failed to send pong:", err)
+                }
+
+            case "pong":
+                latency.RecordPong(msg.SourceNode, msg.Seq, time.Now())
+
+            case "ack":
+                if err := outbox.Ack(msg.EventHash); err != nil {
+                    log.Println("Failed to update outbox:", err)
+                }
+                rooms.RecordAck(msg.SourceNode, msg.EventHash)
+
+            case "hello":
+                // A peer (re)joined: keep it in the partial view
+                sampler.Add([]string{msg.SourceNode})
+                registry.Announce(msg.Creator, msg.SourceNode)
+                log.Printf("%s announced creator %s", shortID(msg.SourceNode), shortID(msg.Creator))
+
+            case "frontier":
+                // Resume sync from where the peer's graph ends
+                hashgraph.RecordPeerFrontier(msg.SourceNode, msg.Frontier)
+                gossiper.TryGossip(msg.SourceNode)
+
+            case "receipt":
+                // A member co-signed an event's consensus receipt
+                if msg.Receipt == nil {
+                    continue
+                }
+                hg, ok := rooms.Room(msg.Receipt.Receipt.RoomID)
+                if !ok {
+                    continue
+                }
+                if err := hg.AddReceiptSignature(msg.Receipt); err != nil {
+                    log.Printf("Ignored receipt from %s: %v", shortID(msg.SourceNode), err)
+                }
+
+            case "presence", "typing":
+                // Ephemeral indicators, tracked in memory only and never added to the Hashgraph
+                presence.Observe(msg, time.Now())
+                if msg.Type == "typing" {
+                    log.Printf("[%s] %s is typing", msg.RoomID, shortID(msg.SourceNode))
+                } else {
+                    log.Printf("%s is %s", shortID(msg.SourceNode), msg.Status)
+                }
+                // A session going offline ends the ephemeral creators scoped to it
+                if msg.Status == presenceOffline {
+                    for _, creator := range registry.EndSession(msg.SourceNode) {
+                        signatures.InvalidateCreator(creator)
+                        log.Printf("Ephemeral creator %s left", shortID(creator))
+                    }
+                }
+
+            case "protocol_error":
+                log.Println("Protocol error from server:", msg.Error)
+
+            case "queue_full":
+                // The server discarded a message; unacked events are retransmitted from the outbox
+                log.Println("Server inbound queue full:", msg.Error)
+
+            case "draining":
+                // The server is closing this session after flushing it; the connection redials
+                // and unacked events are retransmitted from the outbox
+                log.Println("Signaling server is draining this session")
+
+            case "event_rejected":
+                // A peer shed the event unverified; it stays in the outbox and is retransmitted
+                log.Printf("%s rejected event %s: %s", shortID(msg.SourceNode), shortID(msg.EventHash), msg.Error)
+
+            default:
+                if !*strict {
+                    log.Println("Ignoring unknown message type:", msg.Type)
+                    continue
+                }
+                log.Println("Rejecting unknown message type:", msg.Type)
+                reply := Message{Type: "protocol_error", Error: "unknown message type: " + msg.Type, TargetNode: msg.SourceNode}
+                if err := c.WriteJSON(reply); err != nil {
+                    log.Println("Failed to send protocol error:", err)
+                }
+                if *strictDisconnect {
+                    c.Close()
                     return
                 }
             }
@@ -280,8 +1399,8 @@ func main() {
         log.Fatal("Failed to set local SDP:", err)
     }
 
-    // Waiting for ICE candidate collection to be completed
-    <-webrtc.GatheringCompletePromise(peerConnection)
+    // Waiting for ICE candidate collection to be completed, or as far as it got
+    waitForGathering(peerConnection, *gatheringTimeout)
 
     // Send offer to signaling server
     offerMsg := Message{
@@ -291,13 +1410,57 @@ func main() {
     if err := c.WriteJSON(offerMsg); err != nil {
         log.Fatal("Failed to send offer:", err)
     }
+    closeOnNegotiationTimeout(peerConnection, *negotiationTimeout)
 
-    // Get the list of online nodes
-    nodes, err := getNodes()
+    // Get the list of online nodes, falling back to the bootstrap peers
+    var pinned []string
+    if *bootstrap != "" {
+        pinned = strings.Split(*bootstrap, ",")
+    }
+    nodes, err := getNodes("http://" + addr + "/nodes")
     if err != nil {
-        log.Fatal("Failed to get online node list:", err)
+        if len(pinned) == 0 {
+            log.Println("Continuing without peers, use /nodes once the server is reachable:", err)
+        } else {
+            log.Println("Failed to get online node list, using bootstrap peers:", err)
+        }
     }
+    nodes = mergePeers(pinned, nodes)
     log.Printf("Online Node List: %v", nodes)
+    sampler.Add(nodes)
+
+    // Merge peers found on the local network with the server's
+    if *discover {
+        discovery, err := NewMDNSDiscovery()
+        if err != nil {
+            log.Println("Failed to start mDNS discovery:", err)
+        } else {
+            defer discovery.Close()
+            self := func() DiscoveredPeer {
+                return DiscoveredPeer{NodeID: selfID.Load().(string), Creator: hashgraph.CreatorID()}
+            }
+            go runDiscovery(discovery, self, defaultDiscoveryInterval, sampler, registry)
+        }
+    }
+    go gossiper.Run(sampler, *gossipInterval)
+    if !*follower {
+        go runHeartbeats(hashgraph, *heartbeatInterval)
+    }
+
+    // Announce this node's key, frontier and presence, and again after every reconnect
+    announce := func() { announceNode(c, hashgraph, *follower) }
+    announce()
+    c.OnReconnect(announce)
+
+    // Replay events left unacknowledged by a previous run
+    for _, entry := range outbox.PendingList() {
+        if err := sendEvent(c, events, entry.TargetNode, entry.Event); err != nil {
+            log.Println("Failed to replay event:", err)
+        }
+    }
+    if *retransmitInterval > 0 {
+        go runRetransmits(c, events, outbox, deadLetters, *retransmitInterval, *ackWindow)
+    }
 
     // Logic for users to create and send events
     go func() {
@@ -310,13 +1473,168 @@ func main() {
                     continue
                 }
 
-                // Select a target node
-                if len(nodes) == 0 {
+                // Ask the signaling server for the current node list
+                if text == "/nodes" {
+                    if err := c.WriteJSON(Message{Type: "list_nodes"}); err != nil {
+                        log.Println("Failed to request node list:", err)
+                    }
+                    continue
+                }
+
+                // Let room peers know a message is being written
+                if text == "/typing" {
+                    if err := c.WriteJSON(Message{Type: "typing", RoomID: defaultRoom}); err != nil {
+                        log.Println("Failed to send typing indicator:", err)
+                    }
+                    continue
+                }
+
+                // Mark this node as away or back online
+                if text == "/away" || text == "/online" {
+                    status := presenceOnline
+                    if text == "/away" {
+                        status = presenceAway
+                    }
+                    if err := c.WriteJSON(Message{Type: "presence", Status: status, RoomID: defaultRoom}); err != nil {
+                        log.Println("Failed to send presence:", err)
+                    }
+                    continue
+                }
+
+                // Ask a peer for a creator's events after our latest one: /since <peer> <creator>
+                if strings.HasPrefix(text, "/since ") {
+                    fields := strings.Fields(text)
+                    if len(fields) != 3 {
+                        log.Println("Usage: /since <peer> <creator>")
+                        continue
+                    }
+                    request := Message{
+                        Type:       "events_since",
+                        Creator:    fields[2],
+                        EventHash:  hashgraph.Head(fields[2]),
+                        RoomID:     defaultRoom,
+                        TargetNode: fields[1],
+                    }
+                    if err := c.WriteJSON(request); err != nil {
+                        log.Println("Failed to send events-since request:", err)
+                    }
+                    continue
+                }
+
+                // Print the state root of the consensus order
+                if text == "/stateroot" {
+                    log.Printf("State root: %s", hashgraph.StateRoot(0))
+                    continue
+                }
+
+                // Fetch a shared file from the peer and save it: /fetch <hash>
+                if strings.HasPrefix(text, "/fetch ") {
+                    ref, ok := chat.File(strings.TrimSpace(strings.TrimPrefix(text, "/fetch ")))
+                    if !ok {
+                        log.Println("Unknown file")
+                        continue
+                    }
+                    go func() {
+                        data, err := transfer.Fetch(ref)
+                        if err != nil {
+                            log.Println("Failed to fetch file:", err)
+                            return
+                        }
+                        if err := os.WriteFile(filepath.Base(ref.Name), data, 0644); err != nil {
+                            log.Println("Failed to save file:", err)
+                            return
+                        }
+                        log.Printf("Saved %s", filepath.Base(ref.Name))
+                    }()
+                    continue
+                }
+
+                // Show the full text of a truncated message: /more <content hash>
+                if strings.HasPrefix(text, "/more ") {
+                    hash := strings.TrimSpace(strings.TrimPrefix(text, "/more "))
+                    go func() {
+                        data, err := transfer.FetchFull(hash)
+                        if err != nil {
+                            log.Println("Failed to fetch full message:", err)
+                            return
+                        }
+                        log.Printf("%s", data)
+                    }()
+                    continue
+                }
+
+                // Edit or delete a prior message: /edit <message id> <text>, /delete <message id>
+                tx := []byte(text)
+                roomID := defaultRoom
+                if strings.HasPrefix(text, "/to ") {
+                    // Post to another room, creating it if the room create policy allows
+                    fields := strings.SplitN(text, " ", 3)
+                    if len(fields) != 3 {
+                        log.Println("Usage: /to <room> <text>")
+                        continue
+                    }
+                    roomID, tx = fields[1], []byte(fields[2])
+                } else if strings.HasPrefix(text, "/edit ") {
+                    fields := strings.SplitN(text, " ", 3)
+                    if len(fields) != 3 {
+                        log.Println("Usage: /edit <message id> <text>")
+                        continue
+                    }
+                    tx = EditTransaction(fields[1], fields[2])
+                } else if strings.HasPrefix(text, "/delete ") {
+                    tx = DeleteTransaction(strings.TrimSpace(strings.TrimPrefix(text, "/delete ")))
+                } else if strings.HasPrefix(text, "/revoke ") {
+                    // Submit a revocation whose admin signatures were collected with -sign-revocation
+                    revocation, err := LoadRevocation(strings.TrimSpace(strings.TrimPrefix(text, "/revoke ")))
+                    if err != nil {
+                        log.Println("Failed to read revocation:", err)
+                        continue
+                    }
+                    tx = revocation.Transaction()
+                } else if text == "/nick" || strings.HasPrefix(text, "/nick ") {
+                    // Set the display name others see for this creator, or clear it with no name
+                    name, ok := normalizeDisplayName(strings.TrimPrefix(text, "/nick"))
+                    if !ok {
+                        log.Printf("Display names are at most %d characters without control characters", maxDisplayNameLength)
+                        continue
+                    }
+                    tx = ProfileTransaction(name)
+                } else if strings.HasPrefix(text, "/member ") {
+                    // Add or remove a consensus member: /member join|leave <creator id>
+                    fields := strings.Fields(text)
+                    if len(fields) != 3 || (fields[1] != memberJoin && fields[1] != memberLeave) {
+                        log.Println("Usage: /member join|leave <creator id>")
+                        continue
+                    }
+                    tx = MembershipTransaction(fields[1], fields[2])
+                } else if strings.HasPrefix(text, "/file ") {
+                    // Share a file by reference, its bytes are served on request
+                    path := strings.TrimSpace(strings.TrimPrefix(text, "/file "))
+                    data, err := os.ReadFile(path)
+                    if err != nil {
+                        log.Println("Failed to read file:", err)
+                        continue
+                    }
+                    mimeType := mime.TypeByExtension(filepath.Ext(path))
+                    if mimeType == "" {
+                        mimeType = http.DetectContentType(data)
+                    }
+                    tx = FileTransaction(FileReference{
+                        Hash: files.Put(data),
+                        Name: filepath.Base(path),
+                        Size: int64(len(data)),
+                        MIME: mimeType,
+                    })
+                }
+
+                // Select a target node, never this node itself
+                targets := withoutSelf(nodes, selfID.Load().(string))
+                if len(targets) == 0 {
                     log.Println("No other online nodes")
                     continue
                 }
                 log.Println("Please select the target node:")
-                for i, node := range nodes {
+                for i, node := range targets {
                     log.Printf("%d: %s\n", i+1, node)
                 }
 
@@ -326,43 +1644,42 @@ func main() {
                     if scanner.Scan() {
                         input := scanner.Text()
                         index, err := strconv.Atoi(input)
-                        if err == nil && index > 0 && index <= len(nodes) {
+                        if err == nil && index > 0 && index <= len(targets) {
                             targetNodeIndex = index - 1
                             break
                         }
                         log.Println("Invalid input, please enter a valid node number")
                     }
                 }
-                targetNode := nodes[targetNodeIndex]
+                targetNode := targets[targetNodeIndex]
 
-                // Creating a new event
-                event := &Event{
-                    Transactions: [][]byte{[]byte(text)},
-                    SelfParent:   "selfParentHash",
-                    OtherParent:  "otherParentHash",
-                    Creator:      "userID",
-                    Timestamp:    time.Now(),
+                // Creating a new event and adding it to the local Hashgraph
+                event, err := rooms.Post(roomID, tx)
+                if err != nil {
+                    log.Println("Failed to add event:", err)
+                    continue
                 }
 
-                // Adding Events to the Local Hashgraph
-                if err := hashgraph.AddEvent(event); err != nil {
-                    log.Println("Failed to add event:", err)
+                // Keep the event until the target node acknowledges it
+                if err := outbox.Add(event, targetNode); err != nil {
+                    log.Println("Failed to store event in outbox:", err)
                 }
 
                 // Send event to target node
-                eventMsg := Message{
-                    Type:      "event",
-                    Event:     event,
-                    TargetNode: targetNode,
-                }
-                if err := c.WriteJSON(eventMsg); err != nil {
+                if err := sendEvent(c, events, targetNode, event); err != nil {
                     log.Println("Failed to send event:", err)
                 }
             }
         }
     }()
 
-    // Waiting for terminal input to keep the program running
+    // Run until interrupted, then report on the session
     log.Println("Press Ctrl+C to exit")
-    select {}
+    interrupt := make(chan os.Signal, 1)
+    signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+    <-interrupt
+    if err := c.WriteJSON(Message{Type: "presence", Status: presenceOffline, RoomID: defaultRoom}); err != nil {
+        log.Println("Failed to send presence:", err)
+    }
+    emitShutdownReport(buildShutdownReport(rooms, deadLetters, startedAt), *reportPath)
 }
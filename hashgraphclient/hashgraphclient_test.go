@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// Hashgraph of a node with its own key, seeded so runs are repeatable
+func testLocalHashgraph(t testing.TB, seed int64) *Hashgraph {
+    t.Helper()
+    key := seededKeys(seed, 2)[0]
+    return NewHashgraph(key, &key.PublicKey)
+}
+
+func TestSubmitTransactionIdempotencyKey(t *testing.T) {
+    hg := testLocalHashgraph(t, 171)
+    first, err := hg.SubmitTransaction([]byte("hello"), "key-1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    again, err := hg.SubmitTransaction([]byte("hello"), "key-1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if again.Hash != first.Hash || hg.EventCount() != 1 {
+        t.Fatal("resubmission under the same key created another event")
+    }
+
+    second, err := hg.SubmitTransaction([]byte("hello again"), "key-2")
+    if err != nil {
+        t.Fatal(err)
+    }
+    unkeyed, err := hg.SubmitTransaction([]byte("no key"), "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if second.Hash == first.Hash || unkeyed.IdempotencyKey != "" || hg.EventCount() != 3 {
+        t.Fatal("distinct submissions not kept apart")
+    }
+    if second.SelfParent != first.Hash || !verifyEventSignature(second, publicKeyOf(t, second)) {
+        t.Fatal("submitted event not chained and signed")
+    }
+}
+
+func publicKeyOf(t testing.TB, event *Event) *ecdsa.PublicKey {
+    t.Helper()
+    publicKey, err := publicKeyFromHex(event.Creator)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return publicKey
+}
+
+func TestFrontierPointsToLatestPerCreator(t *testing.T) {
+    graph := buildTestGraph(t, 19, 4, 60)
+    hg := testHashgraph(t, shuffledTopological(graph, 2), testCreators(graph))
+
+    want := make(map[string]string)
+    for _, event := range graph {
+        want[event.Creator] = event.Hash
+    }
+    frontier := hg.Frontier()
+    if !reflect.DeepEqual(frontier, want) {
+        t.Fatalf("frontier %v, want %v", frontier, want)
+    }
+    frontier[graph[0].Creator] = "changed"
+    if hg.Frontier()[graph[0].Creator] != want[graph[0].Creator] {
+        t.Fatal("changing the returned frontier changed the graph")
+    }
+
+    local := testLocalHashgraph(t, 20)
+    var last *Event
+    for _, text := range []string{"one", "two"} {
+        event, err := local.SubmitTransaction([]byte(text), "")
+        if err != nil {
+            t.Fatal(err)
+        }
+        last = event
+    }
+    if frontier := local.Frontier(); len(frontier) != 1 || frontier[last.Creator] != last.Hash {
+        t.Fatalf("local frontier %v, want the second event", frontier)
+    }
+}
+
+func TestGetNodesRetriesFlakyServer(t *testing.T) {
+    var attempts atomic.Int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if attempts.Add(1) < 3 {
+            http.Error(w, "starting up", http.StatusServiceUnavailable)
+            return
+        }
+        json.NewEncoder(w).Encode([]string{"a", "b"})
+    }))
+    defer srv.Close()
+
+    nodes, err := getNodes(srv.URL)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(nodes, []string{"a", "b"}) || attempts.Load() != 3 {
+        t.Fatalf("got %v after %d attempts", nodes, attempts.Load())
+    }
+
+    // A failed attempt is reported with its cause rather than ending the process
+    attempts.Store(0)
+    if _, err := fetchNodes(srv.URL); !errors.Is(err, errUnexpectedStatus) {
+        t.Fatalf("fetch from a failing server: %v", err)
+    }
+    srv.Close()
+    if _, err := fetchNodes(srv.URL); err == nil {
+        t.Fatal("fetch succeeded against a closed server")
+    }
+}
+
+func TestWrappedErrorsMatchSentinels(t *testing.T) {
+    hg := testLocalHashgraph(t, 23)
+    key := seededKeys(23, 2)[0]
+
+    // Signing with an unknown format keeps both the stage and the cause
+    event := &Event{Creator: hg.creatorID, SignatureFormat: "bogus"}
+    err := signEvent(event, key)
+    if !errors.Is(err, errSigningFailed) || !errors.Is(err, errUnknownSignatureFormat) {
+        t.Fatalf("sign with unknown format: %v", err)
+    }
+
+    // A persister failure surfaces through SubmitTransaction with its cause
+    hg.SetPersister(func(*Event) error { return io.ErrShortWrite })
+    if _, err := hg.SubmitTransaction([]byte("lost"), ""); !errors.Is(err, errPersistFailed) || !errors.Is(err, io.ErrShortWrite) {
+        t.Fatalf("submit with failing persister: %v", err)
+    }
+
+    // A key file without a PEM block is reported rather than overwritten
+    path := filepath.Join(t.TempDir(), "node.key")
+    if err := os.WriteFile(path, []byte("not a key"), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := loadOrCreateKey(path, elliptic.P256()); !errors.Is(err, errNoPEMBlock) {
+        t.Fatalf("load garbage key file: %v", err)
+    }
+    if data, _ := os.ReadFile(path); string(data) != "not a key" {
+        t.Fatalf("key file rewritten to %q", data)
+    }
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// Default interval between heartbeat events, 0 disables them
+const defaultHeartbeatInterval = 10 * time.Second
+
+// Create an event with no transactions that links the latest heads, so rounds keep advancing
+// while nobody is chatting. Chat views render nothing for it.
+func (hg *Hashgraph) Heartbeat() (*Event, error) {
+    var finalized []*Event
+    defer func() { hg.deliver(finalized) }()
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+
+    event := &Event{
+        SelfParent:  hg.heads[hg.creatorID],
+        OtherParent: hg.otherParent.Select(hg),
+        Creator:     hg.creatorID,
+        Timestamp:   time.Now(),
+        RoomID:      hg.roomID,
+        Ephemeral:   hg.ephemeral,
+    }
+    var err error
+    if finalized, err = hg.addLocalEvent(event); err != nil {
+        return nil, err
+    }
+    return event, nil
+}
+
+// Check whether the local node created an event after the given time
+func (hg *Hashgraph) createdSince(since time.Time) bool {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    head, ok := hg.Events[hg.heads[hg.creatorID]]
+    return ok && head.Timestamp.After(since)
+}
+
+// Emit a heartbeat every interval in which the node created no other event; gossip spreads them
+func runHeartbeats(hg *Hashgraph, interval time.Duration) {
+    if interval <= 0 {
+        return
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for now := range ticker.C {
+        if hg.createdSince(now.Add(-interval)) {
+            continue
+        }
+        if _, err := hg.Heartbeat(); err != nil && !errors.Is(err, errRoundQuotaExceeded) {
+            log.Println("Failed to create heartbeat:", err)
+        }
+    }
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Members that each hold their own graph, with every event delivered to all of them
+type localTestNetwork []*Hashgraph
+
+func newLocalTestNetwork(t *testing.T, seed int64, size int) localTestNetwork {
+    t.Helper()
+    keys := seededKeys(seed, size)
+    members := make([]string, size)
+    for i, key := range keys {
+        members[i] = PublicKeyHex(&key.PublicKey)
+    }
+    network := make(localTestNetwork, size)
+    for i, key := range keys {
+        network[i] = NewHashgraph(key, &key.PublicKey)
+        network[i].roomID = defaultRoom
+        network[i].SetMembers(members)
+    }
+    return network
+}
+
+// Deliver a node's new event to the other nodes
+func (n localTestNetwork) broadcast(t *testing.T, from int, event *Event) {
+    t.Helper()
+    for i, hg := range n {
+        if i == from {
+            continue
+        }
+        if result, err := hg.AddRemoteEvent(wireEvent(event)); result != AddInserted {
+            t.Fatalf("node %d: %v %v", i, result, err)
+        }
+    }
+}
+
+func TestHeartbeatsAdvanceRoundsSilently(t *testing.T) {
+    network := newLocalTestNetwork(t, 81, 4)
+    chat := NewChatView()
+    finalized := 0
+    network[0].OnFinalized(func(event *Event) {
+        finalized++
+        chat.Apply(event)
+    })
+
+    message, err := network[0].SubmitTransaction([]byte("hello"), "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    network.broadcast(t, 0, message)
+    for i := 0; i < 80; i++ {
+        event, err := network[i%len(network)].Heartbeat()
+        if err != nil {
+            t.Fatal(err)
+        }
+        if len(event.Transactions) != 0 {
+            t.Fatalf("heartbeat carries %d transactions", len(event.Transactions))
+        }
+        if i > 0 && event.SelfParent == "" && event.OtherParent == "" {
+            t.Fatal("heartbeat links no parents")
+        }
+        network.broadcast(t, i%len(network), event)
+    }
+
+    if network[0].LastFinalizedRound() < 3 || finalized < 20 {
+        t.Fatalf("heartbeats finalized %d events up to round %d", finalized, network[0].LastFinalizedRound())
+    }
+    // Only the one chat message is displayed
+    if messages := chat.Messages(); len(messages) != 1 || messages[0].Text != "hello" {
+        t.Fatalf("displayed %+v", messages)
+    }
+
+    if !network[1].createdSince(time.Now().Add(-time.Minute)) || network[1].createdSince(time.Now()) {
+        t.Fatal("recent heartbeat not seen as recent activity")
+    }
+}
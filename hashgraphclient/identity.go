@@ -0,0 +1,239 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Default file holding the node's persistent key
+const defaultKeyPath = "node.key"
+
+// Creator ID that does not decode to a public key
+var errInvalidCreator = errors.New("invalid creator public key")
+
+// Ephemeral flag differs from what was first seen for the creator
+var errEphemeralMismatch = errors.New("ephemeral flag does not match creator registration")
+
+// Event claims a creator other than the key it is verified with
+var errCreatorKeyMismatch = errors.New("event creator does not match its signing key")
+
+// Key file does not hold a PEM-encoded key
+var errNoPEMBlock = errors.New("no PEM block in key file")
+
+// Load the node key from disk, failing if there is none
+func loadKey(path string) (*ecdsa.PrivateKey, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("read key: %w", err)
+    }
+    block, _ := pem.Decode(data)
+    if block == nil {
+        return nil, fmt.Errorf("%w: %s", errNoPEMBlock, path)
+    }
+    privateKey, err := x509.ParseECPrivateKey(block.Bytes)
+    if err != nil {
+        return nil, fmt.Errorf("parse key %s: %w", path, err)
+    }
+    return privateKey, nil
+}
+
+// Load the node key from disk, generating and saving one on the given curve on first run.
+// The PEM key records its curve, so an existing key keeps the curve it was created with.
+func loadOrCreateKey(path string, curve elliptic.Curve) (*ecdsa.PrivateKey, error) {
+    privateKey, err := loadKey(path)
+    if !errors.Is(err, os.ErrNotExist) {
+        return privateKey, err
+    }
+
+    privateKey, err = ecdsa.GenerateKey(curve, rand.Reader)
+    if err != nil {
+        return nil, fmt.Errorf("generate key: %w", err)
+    }
+    der, err := x509.MarshalECPrivateKey(privateKey)
+    if err != nil {
+        return nil, fmt.Errorf("encode key: %w", err)
+    }
+    block := &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+    if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+        return nil, fmt.Errorf("write key: %w", err)
+    }
+    return privateKey, nil
+}
+
+// Write the public key of the persisted node key, as PEM and hex, and its creator ID
+func writeIdentity(w io.Writer, path string, curve elliptic.Curve) error {
+    privateKey, err := loadOrCreateKey(path, curve)
+    if err != nil {
+        return err
+    }
+    der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+    if err != nil {
+        return err
+    }
+    if err := pem.Encode(w, &pem.Block{Type: "PUBLIC KEY", Bytes: der}); err != nil {
+        return err
+    }
+    creatorID := PublicKeyHex(&privateKey.PublicKey)
+    _, err = fmt.Fprintf(w, "Public key (hex): %s\nCurve: %s\nCreator ID: %s\nShort ID: %s\n",
+        hex.EncodeToString(der), privateKey.Curve.Params().Name, creatorID, shortID(creatorID))
+    return err
+}
+
+// Public key encoded in a creator ID, on the curve the ID names
+func publicKeyFromHex(creator string) (*ecdsa.PublicKey, error) {
+    name, point, ok := strings.Cut(creator, ":")
+    if !ok {
+        return nil, errInvalidCreator
+    }
+    curve, err := curveByName(name)
+    if err != nil {
+        return nil, errInvalidCreator
+    }
+    data, err := hex.DecodeString(point)
+    if err != nil || len(data) != 1+2*scalarSize(curve) {
+        return nil, errInvalidCreator
+    }
+    x, y := elliptic.Unmarshal(curve, data)
+    if x == nil {
+        return nil, errInvalidCreator
+    }
+    return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// Known creator
+type CreatorInfo struct {
+    PublicKey *ecdsa.PublicKey
+    Ephemeral bool
+}
+
+// Registry of creators seen on the network
+type CreatorRegistry struct {
+    creators map[string]*CreatorInfo
+    sessions map[string]string // creator to the session it announced itself from
+    mutex    sync.Mutex
+}
+
+// create new creator registry
+func NewCreatorRegistry() *CreatorRegistry {
+    return &CreatorRegistry{creators: make(map[string]*CreatorInfo), sessions: make(map[string]string)}
+}
+
+// Record the session a creator announced itself from, which scopes an ephemeral creator.
+// A creator keeps its first session until that ends, so a peer cannot move another's
+// identity onto a session of its choosing.
+func (cr *CreatorRegistry) Announce(creator, session string) {
+    if creator == "" || session == "" {
+        return
+    }
+    cr.mutex.Lock()
+    defer cr.mutex.Unlock()
+    if _, ok := cr.sessions[creator]; !ok {
+        cr.sessions[creator] = session
+    }
+}
+
+// Session a creator announced itself from, empty if it has not
+func (cr *CreatorRegistry) Session(creator string) string {
+    cr.mutex.Lock()
+    defer cr.mutex.Unlock()
+    return cr.sessions[creator]
+}
+
+// Register the creator of a received event, returning the key to verify it with. The event
+// may have been relayed, so the peer it came from says nothing about the creator's session.
+func (cr *CreatorRegistry) Observe(event *Event) (*ecdsa.PublicKey, error) {
+    cr.mutex.Lock()
+    defer cr.mutex.Unlock()
+
+    if info, ok := cr.creators[event.Creator]; ok {
+        if info.Ephemeral != event.Ephemeral {
+            return nil, errEphemeralMismatch
+        }
+        return info.PublicKey, nil
+    }
+
+    publicKey, err := publicKeyFromHex(event.Creator)
+    if err != nil {
+        return nil, err
+    }
+    cr.creators[event.Creator] = &CreatorInfo{PublicKey: publicKey, Ephemeral: event.Ephemeral}
+    return publicKey, nil
+}
+
+// Check that an event's creator is the ID of the key its signature is checked against,
+// so a valid signature cannot vouch for an event claiming someone else's creator
+func checkCreatorKey(event *Event, publicKey *ecdsa.PublicKey) error {
+    if PublicKeyHex(publicKey) != event.Creator {
+        return errCreatorKeyMismatch
+    }
+    return nil
+}
+
+// Register a creator learned outside of an event, such as from local discovery
+func (cr *CreatorRegistry) Register(creator string) error {
+    cr.mutex.Lock()
+    defer cr.mutex.Unlock()
+    if _, ok := cr.creators[creator]; ok {
+        return nil
+    }
+    publicKey, err := publicKeyFromHex(creator)
+    if err != nil {
+        return err
+    }
+    cr.creators[creator] = &CreatorInfo{PublicKey: publicKey}
+    return nil
+}
+
+// Forget the ephemeral creators scoped to a session that has ended, returning them, and
+// the session of every creator that announced itself from it
+func (cr *CreatorRegistry) EndSession(session string) []string {
+    cr.mutex.Lock()
+    defer cr.mutex.Unlock()
+    var ended []string
+    for creator, announced := range cr.sessions {
+        if announced != session {
+            continue
+        }
+        delete(cr.sessions, creator)
+        if info, ok := cr.creators[creator]; ok && info.Ephemeral {
+            delete(cr.creators, creator)
+            ended = append(ended, creator)
+        }
+    }
+    sort.Strings(ended)
+    return ended
+}
+
+// End every announced session missing from a list of the connected ones, returning the
+// ephemeral creators forgotten
+func (cr *CreatorRegistry) EndSessionsNotIn(online []string) []string {
+    connected := make(map[string]bool, len(online))
+    for _, session := range online {
+        connected[session] = true
+    }
+    cr.mutex.Lock()
+    gone := make(map[string]bool)
+    for _, session := range cr.sessions {
+        if !connected[session] {
+            gone[session] = true
+        }
+    }
+    cr.mutex.Unlock()
+    var ended []string
+    for session := range gone {
+        ended = append(ended, cr.EndSession(session)...)
+    }
+    sort.Strings(ended)
+    return ended
+}
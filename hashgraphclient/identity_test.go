@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegistryKeepsEphemeralFlagOfCreator(t *testing.T) {
+    graph := buildTestGraph(t, 151, 2, 4)
+    registry := NewCreatorRegistry()
+
+    ephemeral := copyTestEvents(graph)[0]
+    ephemeral.Ephemeral = true
+    registry.Announce(ephemeral.Creator, "session-1")
+    publicKey, err := registry.Observe(ephemeral)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if PublicKeyHex(publicKey) != ephemeral.Creator {
+        t.Fatal("registry returned a key other than the creator's")
+    }
+    if info := registry.creators[ephemeral.Creator]; !info.Ephemeral || registry.Session(ephemeral.Creator) != "session-1" {
+        t.Fatalf("ephemeral creator registered as %+v", info)
+    }
+
+    // The same key cannot later pass as a persistent identity, nor the other way round
+    persistent := copyTestEvents(graph)[0]
+    if _, err := registry.Observe(persistent); !errors.Is(err, errEphemeralMismatch) {
+        t.Fatalf("persistent event from an ephemeral creator: %v", err)
+    }
+    var other *Event
+    for _, event := range copyTestEvents(graph) {
+        if event.Creator != ephemeral.Creator {
+            other = event
+            break
+        }
+    }
+    if _, err := registry.Observe(other); err != nil {
+        t.Fatal(err)
+    }
+    other.Ephemeral = true
+    if _, err := registry.Observe(other); !errors.Is(err, errEphemeralMismatch) {
+        t.Fatalf("ephemeral event from a persistent creator: %v", err)
+    }
+}
+
+func TestEphemeralCreatorScopedToItsOwnSession(t *testing.T) {
+    graph := buildTestGraph(t, 152, 2, 4)
+    registry := NewCreatorRegistry()
+    ephemeral := copyTestEvents(graph)[0]
+    ephemeral.Ephemeral = true
+
+    // The creator announced itself from its session; a relay delivering its event and a
+    // later hello claiming it elsewhere do not rescope it
+    registry.Announce(ephemeral.Creator, "creator-session")
+    registry.Announce(ephemeral.Creator, "other-session")
+    if _, err := registry.Observe(ephemeral); err != nil {
+        t.Fatal(err)
+    }
+    if ended := registry.EndSession("relay-session"); len(ended) != 0 {
+        t.Fatalf("relay's session ended creators %v", ended)
+    }
+    if ended := registry.EndSession("other-session"); len(ended) != 0 {
+        t.Fatalf("a later claim ended creators %v", ended)
+    }
+    if ended := registry.EndSessionsNotIn([]string{"creator-session", "relay-session"}); len(ended) != 0 {
+        t.Fatalf("connected session ended creators %v", ended)
+    }
+    if ended := registry.EndSessionsNotIn([]string{"relay-session"}); !reflect.DeepEqual(ended, []string{ephemeral.Creator}) {
+        t.Fatalf("ending the creator's session forgot %v", ended)
+    }
+    if _, ok := registry.creators[ephemeral.Creator]; ok || registry.Session(ephemeral.Creator) != "" {
+        t.Fatal("ephemeral creator outlived its session")
+    }
+}
+
+func TestEphemeralSessionsUnlinkable(t *testing.T) {
+    registry := NewCreatorRegistry()
+    var creators []string
+    for _, session := range []string{"session-1", "session-2"} {
+        // Each session of the same user runs under a fresh key, as -ephemeral does
+        key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+        if err != nil {
+            t.Fatal(err)
+        }
+        hg := NewHashgraph(key, &key.PublicKey)
+        hg.SetEphemeral(true)
+        event, err := hg.SubmitTransaction([]byte("hello"), "")
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !event.Ephemeral || event.SelfParent != "" {
+            t.Fatalf("session's first event %+v", event)
+        }
+        registry.Announce(event.Creator, session)
+        if _, err := registry.Observe(copyTestEvents([]*Event{event})[0]); err != nil {
+            t.Fatal(err)
+        }
+        creators = append(creators, event.Creator)
+        if ended := registry.EndSession(session); !reflect.DeepEqual(ended, []string{event.Creator}) {
+            t.Fatalf("ending %s forgot %v", session, ended)
+        }
+    }
+
+    // Nothing ties the second identity to the first: another key, and no record of the first left
+    if creators[0] == creators[1] {
+        t.Fatal("two sessions share a creator ID")
+    }
+    if len(registry.creators) != 0 || len(registry.sessions) != 0 {
+        t.Fatalf("registry still holds %d creators and %d sessions", len(registry.creators), len(registry.sessions))
+    }
+}
+
+func TestRegistryRejectsInvalidCreator(t *testing.T) {
+    registry := NewCreatorRegistry()
+    for _, creator := range []string{"", "nocurve", "P256:zz", "P256:04ab", "unknown:04ab"} {
+        if _, err := registry.Observe(&Event{Creator: creator}); !errors.Is(err, errInvalidCreator) {
+            t.Fatalf("creator %q: %v", creator, err)
+        }
+    }
+}
+
+func TestWriteIdentityMatchesKeyOnDisk(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "node.key")
+    var first bytes.Buffer
+    if err := writeIdentity(&first, path, elliptic.P384()); err != nil {
+        t.Fatal(err)
+    }
+    key, err := loadOrCreateKey(path, elliptic.P256())
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    block, rest := pem.Decode(first.Bytes())
+    if block == nil || block.Type != "PUBLIC KEY" {
+        t.Fatalf("output does not start with a public key PEM block: %s", first.String())
+    }
+    publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !key.PublicKey.Equal(publicKey) {
+        t.Fatal("printed public key is not the key on disk")
+    }
+    creatorID := PublicKeyHex(&key.PublicKey)
+    for _, line := range []string{
+        "Public key (hex): " + hex.EncodeToString(block.Bytes),
+        "Curve: P-384",
+        "Creator ID: " + creatorID,
+        "Short ID: " + shortID(creatorID),
+    } {
+        if !strings.Contains(string(rest), line+"\n") {
+            t.Fatalf("output lacks %q:\n%s", line, rest)
+        }
+    }
+
+    // A second run prints the same identity rather than generating a new key
+    var second bytes.Buffer
+    if err := writeIdentity(&second, path, elliptic.P256()); err != nil {
+        t.Fatal(err)
+    }
+    if second.String() != first.String() {
+        t.Fatal("identity changed between runs")
+    }
+}
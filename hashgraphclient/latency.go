@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default probability that gossip picks the lowest-latency peer rather than a random one
+const defaultLatencyBias = 0.7
+
+// Weight of a new sample in the smoothed round-trip time, as in TCP's SRTT
+const rttSmoothing = 0.125
+
+// Pings awaiting a pong are dropped after this long
+const pingTimeout = 30 * time.Second
+
+// Ping sent to a peer, awaiting its pong
+type pendingPing struct {
+    seq    int
+    sentAt time.Time
+}
+
+// Smoothed round-trip times to peers, measured with ping and pong messages
+type LatencyTracker struct {
+    seq     int
+    pending map[string]pendingPing
+    rtt     map[string]time.Duration
+    mutex   sync.Mutex
+}
+
+// create new latency tracker
+func NewLatencyTracker() *LatencyTracker {
+    return &LatencyTracker{
+        pending: make(map[string]pendingPing),
+        rtt:     make(map[string]time.Duration),
+    }
+}
+
+// Start a ping to a peer, returning the sequence number the pong must echo
+func (lt *LatencyTracker) StartPing(peer string, now time.Time) int {
+    lt.mutex.Lock()
+    defer lt.mutex.Unlock()
+    lt.seq++
+    lt.pending[peer] = pendingPing{seq: lt.seq, sentAt: now}
+    return lt.seq
+}
+
+// Record a peer's pong, returning the measured round trip. Pongs to an older or unknown ping are ignored.
+func (lt *LatencyTracker) RecordPong(peer string, seq int, now time.Time) (time.Duration, bool) {
+    lt.mutex.Lock()
+    defer lt.mutex.Unlock()
+    ping, ok := lt.pending[peer]
+    if !ok || ping.seq != seq || now.Sub(ping.sentAt) > pingTimeout {
+        return 0, false
+    }
+    delete(lt.pending, peer)
+    sample := now.Sub(ping.sentAt)
+    lt.record(peer, sample)
+    return sample, true
+}
+
+// Fold a round-trip sample into the peer's smoothed RTT, caller holds the lock
+func (lt *LatencyTracker) record(peer string, sample time.Duration) {
+    rtt, ok := lt.rtt[peer]
+    if !ok {
+        lt.rtt[peer] = sample
+        return
+    }
+    lt.rtt[peer] = rtt + time.Duration(rttSmoothing*float64(sample-rtt))
+}
+
+// Smoothed RTTs of every measured peer
+func (lt *LatencyTracker) RTTs() map[string]time.Duration {
+    lt.mutex.Lock()
+    defer lt.mutex.Unlock()
+    rtts := make(map[string]time.Duration, len(lt.rtt))
+    for peer, rtt := range lt.rtt {
+        rtts[peer] = rtt
+    }
+    return rtts
+}
+
+// Peers from the view to gossip with, favouring low latency. Each pick is the fastest remaining
+// measured peer with probability bias, otherwise a random remaining peer, so slower and
+// unmeasured peers are still sampled for coverage.
+func (ps *PeerSampler) SampleByLatency(n int, rtts map[string]time.Duration, bias float64) []string {
+    ps.mutex.Lock()
+    defer ps.mutex.Unlock()
+
+    remaining := append([]string(nil), ps.view...)
+    var picked []string
+    for len(picked) < n && len(remaining) > 0 {
+        i := -1
+        if ps.rand.Float64() < bias {
+            i = fastestPeer(remaining, rtts)
+        }
+        if i < 0 {
+            i = ps.rand.Intn(len(remaining))
+        }
+        picked = append(picked, remaining[i])
+        remaining = append(remaining[:i], remaining[i+1:]...)
+    }
+    return picked
+}
+
+// Index of the measured peer with the lowest RTT, -1 if none is measured
+func fastestPeer(peers []string, rtts map[string]time.Duration) int {
+    best := -1
+    for i, peer := range peers {
+        rtt, ok := rtts[peer]
+        if !ok {
+            continue
+        }
+        if best < 0 || rtt < rtts[peers[best]] || (rtt == rtts[peers[best]] && peer < peers[best]) {
+            best = i
+        }
+    }
+    return best
+}
+
+// Measured RTT of a peer, as served on the admin endpoint
+type peerRTT struct {
+    Peer      string  `json:"peer"`
+    RTTMillis float64 `json:"rttMillis"`
+}
+
+// Get the measured RTTs, fastest first
+func rttHandler(latency *LatencyTracker) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        rtts := latency.RTTs()
+        peers := make([]peerRTT, 0, len(rtts))
+        for peer, rtt := range rtts {
+            peers = append(peers, peerRTT{Peer: peer, RTTMillis: float64(rtt) / float64(time.Millisecond)})
+        }
+        sort.Slice(peers, func(i, j int) bool { return peers[i].RTTMillis < peers[j].RTTMillis })
+        json.NewEncoder(w).Encode(peers)
+    }
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRTTMeasuredFromPongs(t *testing.T) {
+    latency := NewLatencyTracker()
+    start := time.Now()
+    seq := latency.StartPing("peer", start)
+    if _, ok := latency.RecordPong("peer", seq+1, start.Add(time.Millisecond)); ok {
+        t.Fatal("pong to another ping recorded")
+    }
+    if rtt, ok := latency.RecordPong("peer", seq, start.Add(80*time.Millisecond)); !ok || rtt != 80*time.Millisecond {
+        t.Fatalf("first pong: %v %v", rtt, ok)
+    }
+    if _, ok := latency.RecordPong("peer", seq, start.Add(90*time.Millisecond)); ok {
+        t.Fatal("second pong to one ping recorded")
+    }
+    // Later samples move the smoothed RTT only part of the way
+    seq = latency.StartPing("peer", start)
+    latency.RecordPong("peer", seq, start.Add(160*time.Millisecond))
+    if rtt := latency.RTTs()["peer"]; rtt != 90*time.Millisecond {
+        t.Fatalf("smoothed RTT %v, want 90ms", rtt)
+    }
+    seq = latency.StartPing("late", start)
+    if _, ok := latency.RecordPong("late", seq, start.Add(pingTimeout+time.Second)); ok {
+        t.Fatal("pong after the timeout recorded")
+    }
+
+    latency.StartPing("fast", start)
+    latency.RecordPong("fast", latency.seq, start.Add(5*time.Millisecond))
+    recorder := httptest.NewRecorder()
+    rttHandler(latency)(recorder, httptest.NewRequest(http.MethodGet, "/rtt", nil))
+    var served []peerRTT
+    if err := json.Unmarshal(recorder.Body.Bytes(), &served); err != nil {
+        t.Fatal(err)
+    }
+    if len(served) != 2 || served[0].Peer != "fast" || served[0].RTTMillis != 5 || served[1].RTTMillis != 90 {
+        t.Fatalf("served RTTs %+v", served)
+    }
+}
+
+func TestSelectionFavorsLowLatencyPeer(t *testing.T) {
+    peers := []string{"fast", "slow", "unmeasured-a", "unmeasured-b"}
+    rtts := map[string]time.Duration{"fast": 10 * time.Millisecond, "slow": 200 * time.Millisecond}
+    const trials = 20000
+    for _, bias := range []float64{0, 0.5, defaultLatencyBias, 1} {
+        sampler := NewPeerSampler(len(peers), 1)
+        sampler.rand = rand.New(rand.NewSource(73))
+        sampler.Add(peers)
+        fast := 0
+        for i := 0; i < trials; i++ {
+            if sampler.SampleByLatency(1, rtts, bias)[0] == "fast" {
+                fast++
+            }
+        }
+        // The fastest peer with probability bias, otherwise any peer uniformly
+        want := bias + (1-bias)/float64(len(peers))
+        if got := float64(fast) / trials; math.Abs(got-want) > 0.02 {
+            t.Fatalf("bias %.1f: fast peer picked %.3f of the time, want %.3f", bias, got, want)
+        }
+    }
+
+    // Every peer is still reached when several are picked
+    sampler := NewPeerSampler(len(peers), 1)
+    sampler.Add(peers)
+    picked := sampler.SampleByLatency(len(peers), rtts, 1)
+    if len(picked) != len(peers) || picked[0] != "fast" || picked[1] != "slow" {
+        t.Fatalf("picked %v", picked)
+    }
+}
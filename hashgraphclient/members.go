@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// Member of a room as seen by consensus. Every member votes with a stake of one; forked
+// members are flagged but keep their stake, so every node computes the same supermajority.
+type Member struct {
+    ID        string `json:"id"`
+    PublicKey string `json:"publicKey"` // hex PKIX DER
+    Curve     string `json:"curve"`
+    Stake     int    `json:"stake"`
+    Forked    bool   `json:"forked"`
+    Revoked   bool   `json:"revoked"`
+}
+
+// Member set and the stake needed for a supermajority
+type MemberSet struct {
+    Members       []Member `json:"members"`
+    TotalStake    int      `json:"totalStake"`
+    Supermajority int      `json:"supermajority"` // smallest stake above two thirds of the total
+}
+
+// Get the current member set, sorted by ID
+func (hg *Hashgraph) Members() MemberSet {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+
+    // The member set in force for the rounds now being decided
+    var ids []string
+    if len(hg.epochs) > 0 {
+        for id := range hg.epochs[len(hg.epochs)-1].Members {
+            ids = append(ids, id)
+        }
+        sort.Strings(ids)
+    }
+
+    set := MemberSet{Members: []Member{}}
+    for _, id := range ids {
+        member := Member{ID: id, Stake: 1}
+        if publicKey, err := publicKeyFromHex(id); err == nil {
+            if der, err := x509.MarshalPKIXPublicKey(publicKey); err == nil {
+                member.PublicKey = hex.EncodeToString(der)
+            }
+            member.Curve = publicKey.Curve.Params().Name
+        }
+        _, member.Forked = hg.forked[id]
+        _, member.Revoked = hg.revoked[id]
+        set.TotalStake += member.Stake
+        set.Members = append(set.Members, member)
+    }
+    set.Supermajority = 2*set.TotalStake/3 + 1
+    return set
+}
+
+// Get the member set of a room
+func membersHandler(w http.ResponseWriter, r *http.Request, rooms *RoomManager) {
+    if hg, ok := requestRoom(w, r, rooms); ok {
+        json.NewEncoder(w).Encode(hg.Members())
+    }
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Decode the member set served for a room manager
+func servedMembers(t *testing.T, rooms *RoomManager) MemberSet {
+    t.Helper()
+    recorder := httptest.NewRecorder()
+    membersHandler(recorder, httptest.NewRequest(http.MethodGet, "/members", nil), rooms)
+    if recorder.Code != http.StatusOK {
+        t.Fatalf("status %d", recorder.Code)
+    }
+    var set MemberSet
+    if err := json.Unmarshal(recorder.Body.Bytes(), &set); err != nil {
+        t.Fatal(err)
+    }
+    return set
+}
+
+func TestMembersHandlerReflectsJoin(t *testing.T) {
+    network := newLocalTestNetwork(t, 84, 4)
+    rooms := NewRoomManager(nil, nil)
+    rooms.rooms[defaultRoom] = network[0]
+
+    set := servedMembers(t, rooms)
+    if len(set.Members) != 4 || set.TotalStake != 4 || set.Supermajority != 3 {
+        t.Fatalf("initial member set %+v", set)
+    }
+    for _, member := range set.Members {
+        der, err := hex.DecodeString(member.PublicKey)
+        if err != nil {
+            t.Fatal(err)
+        }
+        publicKey, err := x509.ParsePKIXPublicKey(der)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if member.ID != PublicKeyHex(publicKey.(*ecdsa.PublicKey)) || member.Curve != "P-256" || member.Stake != 1 {
+            t.Fatalf("member %+v does not match its key", member)
+        }
+    }
+
+    joiner := seededKeys(85, 1)[0]
+    joinerID := PublicKeyHex(&joiner.PublicKey)
+    join, err := network[0].SubmitTransaction(MembershipTransaction(memberJoin, joinerID), "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    network.broadcast(t, 0, join)
+    for i := 0; i < 80 && join.RoundReceived == 0; i++ {
+        event, err := network[i%len(network)].Heartbeat()
+        if err != nil {
+            t.Fatal(err)
+        }
+        network.broadcast(t, i%len(network), event)
+        join, _ = network[0].GetEvent(join.Hash)
+    }
+    if join.RoundReceived == 0 {
+        t.Fatal("join never finalized")
+    }
+
+    set = servedMembers(t, rooms)
+    ids := make(map[string]bool)
+    for _, member := range set.Members {
+        ids[member.ID] = true
+    }
+    if len(set.Members) != 5 || !ids[joinerID] || set.TotalStake != 5 || set.Supermajority != 4 {
+        t.Fatalf("member set after the join %+v", set)
+    }
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Membership change actions
+const (
+    memberJoin  = "join"
+    memberLeave = "leave"
+)
+
+// Transaction adding or removing a consensus member. It takes effect from the round after
+// the one it is received in, so every node switches member sets at the same round.
+type MembershipChange struct {
+    Type   string `json:"type"` // always chatMember
+    Action string `json:"action"`
+    Member string `json:"member"`
+}
+
+// Encode a membership change as a transaction
+func MembershipTransaction(action, member string) []byte {
+    data, _ := json.Marshal(MembershipChange{Type: chatMember, Action: action, Member: member})
+    return data
+}
+
+// Decode a transaction as a membership change, if it is one
+func decodeMembershipChange(tx []byte) (*MembershipChange, bool) {
+    var change MembershipChange
+    if err := json.Unmarshal(tx, &change); err != nil || change.Type != chatMember {
+        return nil, false
+    }
+    if change.Action != memberJoin && change.Action != memberLeave {
+        return nil, false
+    }
+    return &change, true
+}
+
+// Member set in force from a round until the next epoch
+type memberEpoch struct {
+    FromRound int
+    Members   map[string]bool
+}
+
+// Set the initial members and manage membership through join and leave transactions from
+// then on. Consensus runs only once members are set; events already in the graph are
+// re-rounded under the new set, so set members before anything is finalized.
+func (hg *Hashgraph) SetMembers(members []string) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.epochs = initialEpochs(members)
+    if len(hg.Events) > 0 {
+        hg.reround(1)
+    }
+}
+
+// Epochs starting from an initial member set, nil for no members
+func initialEpochs(members []string) []memberEpoch {
+    if len(members) == 0 {
+        return nil
+    }
+    initial := make(map[string]bool, len(members))
+    for _, member := range members {
+        initial[member] = true
+    }
+    return []memberEpoch{{FromRound: 1, Members: initial}}
+}
+
+// Members in force at a round, sorted, or nil when membership is not managed
+func (hg *Hashgraph) MembersAt(round int) []string {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    members := hg.membersAt(round)
+    if members == nil {
+        return nil
+    }
+    list := make([]string, 0, len(members))
+    for member := range members {
+        list = append(list, member)
+    }
+    sort.Strings(list)
+    return list
+}
+
+// Member set in force at a round, nil when membership is not managed, caller holds the lock
+func (hg *Hashgraph) membersAt(round int) map[string]bool {
+    var members map[string]bool
+    for _, epoch := range hg.epochs {
+        if epoch.FromRound > round {
+            break
+        }
+        members = epoch.Members
+    }
+    return members
+}
+
+// Whether a creator counts towards consensus in a round, caller holds the lock
+func (hg *Hashgraph) isMemberAt(round int, creator string) bool {
+    return hg.membersAt(round)[creator]
+}
+
+// Number of members in force at a round, 0 without a member set, caller holds the lock.
+// Forked members still count, as every node must agree on the threshold.
+func (hg *Hashgraph) memberCountAt(round int) int {
+    return len(hg.membersAt(round))
+}
+
+// More than two thirds of the members in force at a round, never true without members
+func (hg *Hashgraph) isSupermajorityAt(round, count int) bool {
+    members := hg.memberCountAt(round)
+    return members > 0 && 3*count > 2*members
+}
+
+// Apply the membership changes among events received in one round, in consensus order.
+// A change counts only if its author is a member at that round. Reports whether the member
+// set changed, in which case it applies from the next round. Caller holds the lock.
+func (hg *Hashgraph) applyMembershipChanges(received []*Event) bool {
+    if len(hg.epochs) == 0 || len(received) == 0 {
+        return false
+    }
+    round := received[0].RoundReceived
+    current := hg.membersAt(round)
+    next := make(map[string]bool, len(current))
+    for member := range current {
+        next[member] = true
+    }
+    changed := false
+    for _, event := range received {
+        for i, tx := range event.Transactions {
+            change, ok := decodeMembershipChange(tx)
+            if !ok || !current[transactionAuthor(event, i)] {
+                continue
+            }
+            if change.Action == memberJoin && !next[change.Member] {
+                next[change.Member] = true
+                changed = true
+            } else if change.Action == memberLeave && next[change.Member] {
+                delete(next, change.Member)
+                changed = true
+            }
+        }
+    }
+    if changed {
+        hg.epochs = append(hg.epochs, memberEpoch{FromRound: round + 1, Members: next})
+    }
+    return changed
+}
+
+// Recompute the rounds, witnesses and fame of every event created in round from or later,
+// after the member set in force from that round changed. None of these events has been
+// received yet, so only undecided state is recomputed. Caller holds the lock.
+func (hg *Hashgraph) reround(from int) {
+    var affected []*Event
+    for round, events := range hg.Rounds {
+        if round < from {
+            continue
+        }
+        affected = append(affected, events...)
+        delete(hg.Rounds, round)
+        delete(hg.roundCounts, round)
+    }
+    sortTopological(affected)
+    for _, event := range affected {
+        event.Famous = nil
+        delete(hg.votes, event.Hash)
+    }
+    for _, event := range affected {
+        hg.divideRounds(event)
+        hg.capWitness(event)
+        hg.addToRound(event)
+    }
+}
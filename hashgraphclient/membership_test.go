@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMembershipChangeTakesEffectNextRound(t *testing.T) {
+    network := newLocalTestNetwork(t, 127, 4)
+    before := network[0].MembersAt(1)
+    joiner := PublicKeyHex(&seededKeys(128, 1)[0].PublicKey)
+    join, err := network[1].SubmitTransaction(MembershipTransaction(memberJoin, joiner), "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    network.broadcast(t, 1, join)
+    for i := 0; i < 120; i++ {
+        event, err := network[i%len(network)].Heartbeat()
+        if err != nil {
+            t.Fatal(err)
+        }
+        network.broadcast(t, i%len(network), event)
+    }
+
+    received := 0
+    for _, hg := range network {
+        event, _ := hg.GetEvent(join.Hash)
+        if event.RoundReceived == 0 {
+            t.Fatal("join never finalized")
+        }
+        if received != 0 && event.RoundReceived != received {
+            t.Fatalf("join received in round %d and %d", received, event.RoundReceived)
+        }
+        received = event.RoundReceived
+    }
+
+    after := append(append([]string(nil), before...), joiner)
+    sort.Strings(after)
+    for _, hg := range network {
+        // Rounds up to the one the join is received in keep the old set and its threshold
+        for round := 1; round <= received; round++ {
+            if !reflect.DeepEqual(hg.MembersAt(round), before) {
+                t.Fatalf("round %d members %d, want the %d before the join", round, len(hg.MembersAt(round)), len(before))
+            }
+        }
+        if !reflect.DeepEqual(hg.MembersAt(received+1), after) {
+            t.Fatalf("round %d members %d after the join", received+1, len(hg.MembersAt(received+1)))
+        }
+        hg.mutex.RLock()
+        oldThreshold, newThreshold := hg.isSupermajorityAt(received, 3), hg.isSupermajorityAt(received+1, 3)
+        hg.mutex.RUnlock()
+        if !oldThreshold || newThreshold {
+            t.Fatal("supermajority threshold did not follow the member set")
+        }
+    }
+}
+
+func TestMembershipChangeNeedsMemberAuthor(t *testing.T) {
+    keys := seededKeys(129, 3)
+    member, outsider, other := PublicKeyHex(&keys[0].PublicKey), PublicKeyHex(&keys[1].PublicKey), PublicKeyHex(&keys[2].PublicKey)
+    hg := NewHashgraph(nil, nil)
+    hg.SetMembers([]string{member, other})
+
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    ignored := []*Event{{Creator: outsider, RoundReceived: 2, Transactions: [][]byte{MembershipTransaction(memberJoin, outsider)}}}
+    if hg.applyMembershipChanges(ignored) || len(hg.epochs) != 1 {
+        t.Fatal("an outsider changed the member set")
+    }
+    leave := []*Event{{Creator: member, RoundReceived: 3, Transactions: [][]byte{
+        []byte("hello"),
+        MembershipTransaction(memberLeave, other),
+        MembershipTransaction(memberLeave, outsider),
+    }}}
+    if !hg.applyMembershipChanges(leave) {
+        t.Fatal("leave not applied")
+    }
+    if hg.memberCountAt(3) != 2 || hg.memberCountAt(4) != 1 || !hg.isMemberAt(4, member) {
+        t.Fatalf("members %d at round 3 and %d at round 4", hg.memberCountAt(3), hg.memberCountAt(4))
+    }
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// ICE states reported as gauges, always present so dashboards see zeros
+var trackedICEStates = []webrtc.ICEConnectionState{
+    webrtc.ICEConnectionStateNew,
+    webrtc.ICEConnectionStateChecking,
+    webrtc.ICEConnectionStateConnected,
+    webrtc.ICEConnectionStateCompleted,
+    webrtc.ICEConnectionStateDisconnected,
+    webrtc.ICEConnectionStateFailed,
+    webrtc.ICEConnectionStateClosed,
+}
+
+// PeerConnection counts by ICE state, and transitions into each state
+type ConnectionMetrics struct {
+    next        int
+    current     map[int]webrtc.ICEConnectionState
+    transitions map[webrtc.ICEConnectionState]int
+    mutex       sync.Mutex
+}
+
+// create new connection metrics
+func NewConnectionMetrics() *ConnectionMetrics {
+    return &ConnectionMetrics{
+        current:     make(map[int]webrtc.ICEConnectionState),
+        transitions: make(map[webrtc.ICEConnectionState]int),
+    }
+}
+
+// Start tracking a PeerConnection, returning its ID
+func (cm *ConnectionMetrics) Add() int {
+    cm.mutex.Lock()
+    defer cm.mutex.Unlock()
+    cm.next++
+    cm.current[cm.next] = webrtc.ICEConnectionStateNew
+    return cm.next
+}
+
+// Record an ICE state change of a tracked PeerConnection
+func (cm *ConnectionMetrics) SetState(id int, state webrtc.ICEConnectionState) {
+    cm.mutex.Lock()
+    defer cm.mutex.Unlock()
+    cm.current[id] = state
+    cm.transitions[state]++
+}
+
+// Number of tracked PeerConnections in each ICE state
+func (cm *ConnectionMetrics) Gauges() map[webrtc.ICEConnectionState]int {
+    cm.mutex.Lock()
+    defer cm.mutex.Unlock()
+    gauges := make(map[webrtc.ICEConnectionState]int)
+    for _, state := range cm.current {
+        gauges[state]++
+    }
+    return gauges
+}
+
+// Write the metrics in the Prometheus text format
+func (cm *ConnectionMetrics) Write(w io.Writer) {
+    gauges := cm.Gauges()
+    cm.mutex.Lock()
+    transitions := make(map[webrtc.ICEConnectionState]int, len(cm.transitions))
+    for state, count := range cm.transitions {
+        transitions[state] = count
+    }
+    cm.mutex.Unlock()
+
+    states := append([]webrtc.ICEConnectionState(nil), trackedICEStates...)
+    sort.Slice(states, func(i, j int) bool { return states[i].String() < states[j].String() })
+
+    fmt.Fprintln(w, "# HELP webrtc_peer_connections PeerConnections by current ICE state.")
+    fmt.Fprintln(w, "# TYPE webrtc_peer_connections gauge")
+    for _, state := range states {
+        fmt.Fprintf(w, "webrtc_peer_connections{state=%q} %d\n", state.String(), gauges[state])
+    }
+    fmt.Fprintln(w, "# HELP webrtc_ice_transitions_total ICE state transitions by new state.")
+    fmt.Fprintln(w, "# TYPE webrtc_ice_transitions_total counter")
+    for _, state := range states {
+        fmt.Fprintf(w, "webrtc_ice_transitions_total{state=%q} %d\n", state.String(), transitions[state])
+    }
+}
+
+// Serve the metrics
+func metricsHandler(metrics *ConnectionMetrics) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+        metrics.Write(w)
+    }
+}
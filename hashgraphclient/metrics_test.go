@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// Wait until the gauge of a state reaches count
+func waitForGauge(metrics *ConnectionMetrics, state webrtc.ICEConnectionState, count int, timeout time.Duration) bool {
+    deadline := time.Now().Add(timeout)
+    for time.Now().Before(deadline) {
+        if metrics.Gauges()[state] == count {
+            return true
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    return false
+}
+
+func TestICEStateTransitionsUpdateGauges(t *testing.T) {
+    metrics := NewConnectionMetrics()
+    first, second := metrics.Add(), metrics.Add()
+    if metrics.Gauges()[webrtc.ICEConnectionStateNew] != 2 {
+        t.Fatalf("gauges after adding: %v", metrics.Gauges())
+    }
+    metrics.SetState(first, webrtc.ICEConnectionStateChecking)
+    metrics.SetState(first, webrtc.ICEConnectionStateConnected)
+    metrics.SetState(second, webrtc.ICEConnectionStateChecking)
+    metrics.SetState(second, webrtc.ICEConnectionStateFailed)
+    gauges := metrics.Gauges()
+    if gauges[webrtc.ICEConnectionStateConnected] != 1 || gauges[webrtc.ICEConnectionStateFailed] != 1 ||
+        gauges[webrtc.ICEConnectionStateChecking] != 0 || gauges[webrtc.ICEConnectionStateNew] != 0 {
+        t.Fatalf("gauges after transitions: %v", gauges)
+    }
+
+    recorder := httptest.NewRecorder()
+    metricsHandler(metrics)(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+    body := recorder.Body.String()
+    for _, line := range []string{
+        `webrtc_peer_connections{state="connected"} 1`,
+        `webrtc_peer_connections{state="failed"} 1`,
+        `webrtc_peer_connections{state="checking"} 0`,
+        `webrtc_peer_connections{state="closed"} 0`,
+        `webrtc_ice_transitions_total{state="checking"} 2`,
+        `webrtc_ice_transitions_total{state="connected"} 1`,
+    } {
+        if !strings.Contains(body, line+"\n") {
+            t.Fatalf("metrics missing %q:\n%s", line, body)
+        }
+    }
+
+    // Real PeerConnections report their state changes through their callbacks
+    metrics = NewConnectionMetrics()
+    var peers [2]*webrtc.PeerConnection
+    for i := range peers {
+        peerConnection, err := createPeerConnection(metrics)
+        if err != nil {
+            t.Fatal(err)
+        }
+        t.Cleanup(func() { peerConnection.Close() })
+        peers[i] = peerConnection
+    }
+    if metrics.Gauges()[webrtc.ICEConnectionStateNew] != 2 {
+        t.Fatalf("new connections not tracked: %v", metrics.Gauges())
+    }
+    connectTestPeers(t, peers[0], peers[1], "events")
+    if !waitForGauge(metrics, webrtc.ICEConnectionStateConnected, 2, 2*time.Second) {
+        t.Fatalf("connecting did not update the gauges: %v", metrics.Gauges())
+    }
+    peers[1].Close()
+    if !waitForGauge(metrics, webrtc.ICEConnectionStateClosed, 1, 2*time.Second) {
+        t.Fatalf("closing did not update the gauges: %v", metrics.Gauges())
+    }
+}
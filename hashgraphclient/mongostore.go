@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// Database used when the MongoDB URI names none
+const defaultMongoDatabase = "hashgraphDB"
+
+// Collection holding one document per event
+const mongoEventsCollection = "events"
+
+// Time one MongoDB operation may take
+const mongoTimeout = 10 * time.Second
+
+// Document holding one event. The event is kept as JSON so every field its hash covers
+// survives, and seq, assigned by the writer, orders documents by insertion.
+type mongoEvent struct {
+    Hash    string             `bson:"_id"`
+    Seq     primitive.ObjectID `bson:"seq"`
+    Creator string             `bson:"creator"`
+    Event   []byte             `bson:"event"`
+}
+
+// Store keeping events in a MongoDB collection, one document per event keyed by hash
+type MongoStore struct {
+    client *mongo.Client
+    events *mongo.Collection
+}
+
+// Connect to the MongoDB store at a mongodb:// URI, whose path names the database
+func OpenMongoStore(uri string) (*MongoStore, error) {
+    parsed, err := connstring.ParseAndValidate(uri)
+    if err != nil {
+        return nil, fmt.Errorf("parse MongoDB URI: %w", err)
+    }
+    database := parsed.Database
+    if database == "" {
+        database = defaultMongoDatabase
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+    defer cancel()
+    client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+    if err != nil {
+        return nil, fmt.Errorf("connect to MongoDB: %w", err)
+    }
+    if err := client.Ping(ctx, nil); err != nil {
+        client.Disconnect(context.Background())
+        return nil, fmt.Errorf("connect to MongoDB: %w", err)
+    }
+    return &MongoStore{client: client, events: client.Database(database).Collection(mongoEventsCollection)}, nil
+}
+
+// Whether a store flag value names a MongoDB store
+func isMongoURI(spec string) bool {
+    return strings.HasPrefix(spec, "mongodb://") || strings.HasPrefix(spec, "mongodb+srv://")
+}
+
+// Index documents by insertion order, for paging, and by creator
+func (ms *MongoStore) EnsureIndexes() error {
+    ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+    defer cancel()
+    _, err := ms.events.Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {Keys: bson.D{{Key: "seq", Value: 1}}, Options: options.Index().SetUnique(true)},
+        {Keys: bson.D{{Key: "creator", Value: 1}}},
+    })
+    if err != nil {
+        return fmt.Errorf("create store indexes: %w", err)
+    }
+    return nil
+}
+
+// Write an event, keeping the seq it was first stored with so a rewrite does not move it in the order
+func (ms *MongoStore) Put(event *Event) error {
+    data, err := json.Marshal(event)
+    if err != nil {
+        return fmt.Errorf("encode event %s: %w", shortID(event.Hash), err)
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+    defer cancel()
+    update := bson.M{
+        "$set":         bson.M{"creator": event.Creator, "event": data},
+        "$setOnInsert": bson.M{"seq": primitive.NewObjectID()},
+    }
+    if _, err := ms.events.UpdateByID(ctx, event.Hash, update, options.Update().SetUpsert(true)); err != nil {
+        return fmt.Errorf("write event: %w", err)
+    }
+    return nil
+}
+
+func (ms *MongoStore) Get(hash string) (*Event, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+    defer cancel()
+    var doc mongoEvent
+    err := ms.events.FindOne(ctx, bson.M{"_id": hash}).Decode(&doc)
+    if errors.Is(err, mongo.ErrNoDocuments) {
+        return nil, errStoreNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("read event: %w", err)
+    }
+    return doc.decode()
+}
+
+func (ms *MongoStore) List() ([]*Event, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+    defer cancel()
+    found, err := ms.events.Find(ctx, bson.M{})
+    if err != nil {
+        return nil, fmt.Errorf("find stored events: %w", err)
+    }
+    var docs []mongoEvent
+    if err := found.All(ctx, &docs); err != nil {
+        return nil, fmt.Errorf("read stored events: %w", err)
+    }
+    events := make([]*Event, 0, len(docs))
+    for _, doc := range docs {
+        event, err := doc.decode()
+        if err != nil {
+            return nil, err
+        }
+        events = append(events, event)
+    }
+    sortTopological(events)
+    return events, nil
+}
+
+// The cursor is the seq of the last event of the previous page
+func (ms *MongoStore) LoadEvents(cursor string, limit int) ([]*Event, string, error) {
+    if limit <= 0 {
+        limit = defaultStorePageSize
+    }
+    filter := bson.M{}
+    if cursor != "" {
+        after, err := primitive.ObjectIDFromHex(cursor)
+        if err != nil {
+            return nil, "", errInvalidStoreCursor
+        }
+        filter["seq"] = bson.M{"$gt": after}
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+    defer cancel()
+    found, err := ms.events.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}).SetLimit(int64(limit)))
+    if err != nil {
+        return nil, "", fmt.Errorf("find stored events: %w", err)
+    }
+    var docs []mongoEvent
+    if err := found.All(ctx, &docs); err != nil {
+        return nil, "", fmt.Errorf("read stored events: %w", err)
+    }
+    events := make([]*Event, 0, len(docs))
+    for _, doc := range docs {
+        event, err := doc.decode()
+        if err != nil {
+            return nil, "", err
+        }
+        events = append(events, event)
+        cursor = doc.Seq.Hex()
+    }
+    return events, cursor, nil
+}
+
+func (ms *MongoStore) Delete(hash string) error {
+    ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+    defer cancel()
+    if _, err := ms.events.DeleteOne(ctx, bson.M{"_id": hash}); err != nil {
+        return fmt.Errorf("delete event: %w", err)
+    }
+    return nil
+}
+
+// Event held in a document
+func (doc *mongoEvent) decode() (*Event, error) {
+    var event Event
+    if err := json.Unmarshal(doc.Event, &event); err != nil {
+        return nil, fmt.Errorf("decode event %s: %w", shortID(doc.Hash), err)
+    }
+    return &event, nil
+}
+
+// Disconnect from MongoDB
+func (ms *MongoStore) Close() error {
+    ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+    defer cancel()
+    return ms.client.Disconnect(ctx)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"sort"
+)
+
+// Other-parents an event may have unless configured otherwise: the classic single one
+const defaultMaxOtherParents = 1
+
+// Event has more other-parents than allowed
+var errTooManyOtherParents = errors.New("too many other-parents")
+
+// Event names the same parent twice, or an extra other-parent without a first one
+var errInvalidOtherParents = errors.New("invalid other-parents")
+
+// Every other-parent of the event, OtherParent first then ExtraParents
+func (event *Event) OtherParents() []string {
+    if event.OtherParent == "" {
+        return nil
+    }
+    return append([]string{event.OtherParent}, event.ExtraParents...)
+}
+
+// set how many other-parents an event may have, both when creating and when receiving one
+func (hg *Hashgraph) SetMaxOtherParents(max int) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    if max < 1 {
+        max = 1
+    }
+    hg.maxOtherParents = max
+}
+
+// Check the other-parents of an event against the configured maximum, caller holds the lock
+func (hg *Hashgraph) checkOtherParents(event *Event) error {
+    if len(event.ExtraParents) == 0 {
+        return nil
+    }
+    if event.OtherParent == "" {
+        return errInvalidOtherParents
+    }
+    if 1+len(event.ExtraParents) > hg.maxOtherParents {
+        return errTooManyOtherParents
+    }
+    seen := map[string]bool{event.SelfParent: true}
+    for _, parent := range event.OtherParents() {
+        if parent == "" || seen[parent] {
+            return errInvalidOtherParents
+        }
+        seen[parent] = true
+    }
+    return nil
+}
+
+// Further other-parents for a local event besides the one the strategy chose: the tips of
+// other peers, most recent first, up to the configured maximum. Caller holds the lock.
+func (hg *Hashgraph) extraOtherParents(first string) []string {
+    if hg.maxOtherParents <= 1 || first == "" {
+        return nil
+    }
+    chosen, ok := hg.Events[first]
+    if !ok {
+        return nil
+    }
+    var tips []*Event
+    for _, tip := range hg.peerTips() {
+        if tip.Creator != chosen.Creator {
+            tips = append(tips, tip)
+        }
+    }
+    sort.SliceStable(tips, func(i, j int) bool { return tips[i].LamportTime > tips[j].LamportTime })
+    if len(tips) > hg.maxOtherParents-1 {
+        tips = tips[:hg.maxOtherParents-1]
+    }
+    extra := make([]string, len(tips))
+    for i, tip := range tips {
+        extra[i] = tip.Hash
+    }
+    return extra
+}
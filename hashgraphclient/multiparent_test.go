@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestEventMergesSeveralOtherParents(t *testing.T) {
+    network := newLocalTestNetwork(t, 149, 4)
+    for _, hg := range network[:3] {
+        hg.SetMaxOtherParents(3)
+    }
+    var tips []string
+    for i := 1; i < 3; i++ {
+        event, err := network[i].SubmitTransaction([]byte("from a peer"), "")
+        if err != nil {
+            t.Fatal(err)
+        }
+        network.broadcast(t, i, event)
+        tips = append(tips, event.Hash)
+    }
+
+    merged, err := network[0].SubmitTransaction([]byte("merge"), "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    parents := merged.OtherParents()
+    sort.Strings(parents)
+    sort.Strings(tips)
+    if !reflect.DeepEqual(parents, tips) {
+        t.Fatalf("merged %d other-parents, want both peer tips", len(parents))
+    }
+    // Ancestry runs through every other-parent, not only the first
+    for _, tip := range tips {
+        parent, _ := network[0].GetEvent(tip)
+        network[0].mutex.RLock()
+        ok := network[0].ancestor(merged, parent)
+        network[0].mutex.RUnlock()
+        if !ok || network[0].AreConcurrent(merged, parent) {
+            t.Fatalf("tip %s not an ancestor of the merging event", shortID(tip))
+        }
+    }
+
+    // Peers allowing as many other-parents take the event, a single-parent peer refuses it
+    for i := 1; i < 3; i++ {
+        if result, err := network[i].AddRemoteEvent(wireEvent(merged)); result != AddInserted {
+            t.Fatalf("node %d: %v %v", i, result, err)
+        }
+    }
+    result, err := network[3].AddRemoteEvent(wireEvent(merged))
+    expectRejected(t, result, err, "validate", errTooManyOtherParents)
+
+    // The extra parents are hashed, so they cannot be swapped under the signature
+    swapped := *merged
+    swapped.ExtraParents = []string{merged.OtherParent}
+    swapped.OtherParent = merged.ExtraParents[0]
+    if hash, _ := hashEvent(&swapped); hash == merged.Hash {
+        t.Fatal("reordering other-parents kept the hash")
+    }
+
+    hg := network[0]
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    for _, event := range []*Event{
+        {SelfParent: "self", ExtraParents: []string{"a"}},
+        {SelfParent: "self", OtherParent: "a", ExtraParents: []string{"a"}},
+        {SelfParent: "self", OtherParent: "a", ExtraParents: []string{"self"}},
+        {SelfParent: "self", OtherParent: "a", ExtraParents: []string{""}},
+    } {
+        if err := hg.checkOtherParents(event); !errors.Is(err, errInvalidOtherParents) {
+            t.Fatalf("other-parents %v: %v", event.OtherParents(), err)
+        }
+    }
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// Default time an offer may go unanswered before its PeerConnection is torn down
+const defaultNegotiationTimeout = 30 * time.Second
+
+// Default time ICE gathering may take before the offer goes out with the candidates found so far
+const defaultGatheringTimeout = 10 * time.Second
+
+// Wait for ICE gathering to complete, or for the timeout when a STUN or TURN server never answers,
+// reporting whether gathering completed. On timeout the local description still carries the
+// candidates gathered so far, host candidates at least. 0 waits without a limit.
+func waitForGathering(peerConnection *webrtc.PeerConnection, timeout time.Duration) bool {
+    gathered := webrtc.GatheringCompletePromise(peerConnection)
+    if timeout <= 0 {
+        <-gathered
+        return true
+    }
+    timer := time.NewTimer(timeout)
+    defer timer.Stop()
+    select {
+    case <-gathered:
+        return true
+    case <-timer.C:
+        log.Printf("ICE gathering did not complete within %s, continuing with the candidates gathered so far", timeout)
+        return false
+    }
+}
+
+// Check whether a PeerConnection is still half-open: offer unanswered or ICE never connected
+func negotiationStuck(peerConnection *webrtc.PeerConnection) bool {
+    if peerConnection.SignalingState() == webrtc.SignalingStateHaveLocalOffer {
+        return true
+    }
+    switch peerConnection.ICEConnectionState() {
+    case webrtc.ICEConnectionStateNew, webrtc.ICEConnectionStateChecking:
+        return true
+    }
+    return false
+}
+
+// Close the PeerConnection if negotiation has not completed within the timeout, 0 disables the check
+func closeOnNegotiationTimeout(peerConnection *webrtc.PeerConnection, timeout time.Duration) *time.Timer {
+    if timeout <= 0 {
+        return nil
+    }
+    return time.AfterFunc(timeout, func() {
+        if !negotiationStuck(peerConnection) {
+            return
+        }
+        log.Printf("Negotiation did not complete within %s, closing PeerConnection", timeout)
+        if err := peerConnection.Close(); err != nil {
+            log.Println("Failed to close PeerConnection:", err)
+        }
+    })
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// Wait until a PeerConnection reaches a connection state, reporting whether it did in time
+func waitForConnectionState(peerConnection *webrtc.PeerConnection, state webrtc.PeerConnectionState, timeout time.Duration) bool {
+    deadline := time.Now().Add(timeout)
+    for time.Now().Before(deadline) {
+        if peerConnection.ConnectionState() == state {
+            return true
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    return false
+}
+
+// New PeerConnection closed when the test ends
+func testPeerConnection(t *testing.T) *webrtc.PeerConnection {
+    t.Helper()
+    peerConnection, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { peerConnection.Close() })
+    return peerConnection
+}
+
+func TestUnansweredOfferTornDown(t *testing.T) {
+    if closeOnNegotiationTimeout(testPeerConnection(t), 0) != nil {
+        t.Fatal("timeout of 0 scheduled a close")
+    }
+
+    peerConnection := testPeerConnection(t)
+    if _, err := peerConnection.CreateDataChannel("events", nil); err != nil {
+        t.Fatal(err)
+    }
+    offer, err := peerConnection.CreateOffer(nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := peerConnection.SetLocalDescription(offer); err != nil {
+        t.Fatal(err)
+    }
+    closeOnNegotiationTimeout(peerConnection, 100*time.Millisecond)
+    if waitForConnectionState(peerConnection, webrtc.PeerConnectionStateClosed, 50*time.Millisecond) {
+        t.Fatal("closed before the timeout")
+    }
+    if !waitForConnectionState(peerConnection, webrtc.PeerConnectionStateClosed, 2*time.Second) {
+        t.Fatalf("offer with no answer left %s", peerConnection.SignalingState())
+    }
+}
+
+// Offering PeerConnection negotiated with a second one over loopback, and the two ends of a
+// data channel it opened between them; skips the test when ICE cannot connect locally
+func connectedTestChannel(t *testing.T, label string) (*webrtc.PeerConnection, *webrtc.DataChannel, *webrtc.DataChannel) {
+    t.Helper()
+    offerer := testPeerConnection(t)
+    local, remote := connectTestPeers(t, offerer, testPeerConnection(t), label)
+    return offerer, local, remote
+}
+
+// Negotiate two PeerConnections over loopback and return both ends of a data channel the
+// offerer opened; skips the test when ICE cannot connect locally
+func connectTestPeers(t *testing.T, offerer, answerer *webrtc.PeerConnection, label string) (*webrtc.DataChannel, *webrtc.DataChannel) {
+    t.Helper()
+    local, err := offerer.CreateDataChannel(label, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    opened := make(chan struct{})
+    local.OnOpen(func() { close(opened) })
+    remote := make(chan *webrtc.DataChannel, 1)
+    answerer.OnDataChannel(func(channel *webrtc.DataChannel) {
+        channel.OnOpen(func() { remote <- channel })
+    })
+
+    offer, err := offerer.CreateOffer(nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := offerer.SetLocalDescription(offer); err != nil {
+        t.Fatal(err)
+    }
+    waitForGathering(offerer, defaultGatheringTimeout)
+    if err := answerer.SetRemoteDescription(*offerer.LocalDescription()); err != nil {
+        t.Fatal(err)
+    }
+    answer, err := answerer.CreateAnswer(nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := answerer.SetLocalDescription(answer); err != nil {
+        t.Fatal(err)
+    }
+    waitForGathering(answerer, defaultGatheringTimeout)
+    if err := offerer.SetRemoteDescription(*answerer.LocalDescription()); err != nil {
+        t.Fatal(err)
+    }
+    timeout := time.After(5 * time.Second)
+    select {
+    case <-opened:
+    case <-timeout:
+        t.Skip("no local ICE connectivity")
+    }
+    select {
+    case channel := <-remote:
+        return local, channel
+    case <-timeout:
+        t.Skip("no local ICE connectivity")
+    }
+    return nil, nil
+}
+
+func TestCompletedNegotiationKept(t *testing.T) {
+    offerer, _, _ := connectedTestChannel(t, "events")
+    closeOnNegotiationTimeout(offerer, 50*time.Millisecond)
+    if waitForConnectionState(offerer, webrtc.PeerConnectionStateClosed, 300*time.Millisecond) {
+        t.Fatal("connected PeerConnection closed on timeout")
+    }
+}
+
+func TestGatheringTimeoutProceedsWithoutSTUN(t *testing.T) {
+    // A STUN server that never answers holds gathering open
+    stun, err := net.ListenPacket("udp4", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer stun.Close()
+    peerConnection, err := webrtc.NewPeerConnection(webrtc.Configuration{
+        ICEServers: []webrtc.ICEServer{{URLs: []string{"stun:" + stun.LocalAddr().String()}}},
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer peerConnection.Close()
+    if _, err := peerConnection.CreateDataChannel("events", nil); err != nil {
+        t.Fatal(err)
+    }
+    offer, err := peerConnection.CreateOffer(nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := peerConnection.SetLocalDescription(offer); err != nil {
+        t.Fatal(err)
+    }
+
+    start := time.Now()
+    if waitForGathering(peerConnection, 200*time.Millisecond) {
+        t.Skip("gathering completed despite the silent STUN server")
+    }
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Fatalf("gave up on gathering after %s", elapsed)
+    }
+    if peerConnection.ICEGatheringState() == webrtc.ICEGatheringStateComplete {
+        t.Fatal("gathering reported incomplete but completed")
+    }
+    // The offer still carries the host candidates found so far
+    if !strings.Contains(peerConnection.LocalDescription().SDP, "typ host") {
+        t.Skip("no host candidates on this machine")
+    }
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"sort"
+)
+
+// Orderer name not registered
+var errUnknownOrderer = errors.New("unknown orderer")
+
+// Strategy producing the delivered order, run with the graph lock held and returning newly ordered events
+type Orderer interface {
+    Name() string
+    Order(hg *Hashgraph) []*Event
+}
+
+// Full Hashgraph consensus: virtual voting on fame, then order by round received and median timestamp
+type HashgraphOrderer struct{}
+
+func (HashgraphOrderer) Name() string { return "hashgraph" }
+
+func (HashgraphOrderer) Order(hg *Hashgraph) []*Event {
+    // Without an agreed member set there is no supermajority every node would compute alike
+    if len(hg.epochs) == 0 {
+        return nil
+    }
+    hg.decideFame()
+    return hg.findOrder()
+}
+
+// Delivers every event as soon as it is known, ordered by Lamport time then hash within each batch.
+// Cheaper, but events arriving late may be delivered after ones they precede; meant for small chats.
+type LamportOrderer struct{}
+
+func (LamportOrderer) Name() string { return "lamport" }
+
+func (LamportOrderer) Order(hg *Hashgraph) []*Event {
+    var ordered []*Event
+    for _, event := range hg.Events {
+        if event.RoundReceived == 0 {
+            ordered = append(ordered, event)
+        }
+    }
+    sort.Slice(ordered, func(i, j int) bool {
+        if ordered[i].LamportTime != ordered[j].LamportTime {
+            return ordered[i].LamportTime < ordered[j].LamportTime
+        }
+        return ordered[i].Hash < ordered[j].Hash
+    })
+    for _, event := range ordered {
+        event.RoundReceived = event.RoundCreated
+        event.ConsensusTimestamp = event.Timestamp
+        if event.RoundReceived > hg.lastReceivedRound {
+            hg.lastReceivedRound = event.RoundReceived
+        }
+    }
+    ordered = hg.applyRevocations(ordered)
+    hg.ConsensusOrder = append(hg.ConsensusOrder, ordered...)
+    return ordered
+}
+
+// Finalizes a lone node's own chain in Lamport order, without peers or voting.
+// This is not consensus: once another creator joins the graph nothing more is
+// finalized, since a solo order cannot be agreed with anyone.
+type SoloOrderer struct{}
+
+func (SoloOrderer) Name() string { return "solo" }
+
+func (SoloOrderer) Order(hg *Hashgraph) []*Event {
+    if len(hg.heads) > 1 {
+        return nil
+    }
+    return LamportOrderer{}.Order(hg)
+}
+
+// Registered orderers by name
+var orderers = map[string]Orderer{
+    HashgraphOrderer{}.Name(): HashgraphOrderer{},
+    LamportOrderer{}.Name():   LamportOrderer{},
+    SoloOrderer{}.Name():      SoloOrderer{},
+}
+
+// Look up an orderer by name
+func ordererByName(name string) (Orderer, error) {
+    orderer, ok := orderers[name]
+    if !ok {
+        return nil, errUnknownOrderer
+    }
+    return orderer, nil
+}
+
+// set the strategy producing the delivered order
+func (hg *Hashgraph) SetOrderer(orderer Orderer) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.orderer = orderer
+}
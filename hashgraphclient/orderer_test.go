@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// Hashgraph fed events with the given orderer
+func orderedTestHashgraph(t *testing.T, events []*Event, orderer Orderer) *Hashgraph {
+    t.Helper()
+    hg := NewHashgraph(nil, nil)
+    hg.SetMembers(testCreators(events))
+    hg.SetOrderer(orderer)
+    addTestEvents(t, hg, events)
+    return hg
+}
+
+func TestOrderersOnSameEvents(t *testing.T) {
+    graph := buildTestGraph(t, 16, 4, 200)
+
+    consensus := orderedTestHashgraph(t, graph, HashgraphOrderer{})
+    order := orderHashes(consensus)
+    if len(order) == 0 || len(order) == len(graph) {
+        t.Fatalf("hashgraph orderer delivered %d of %d events", len(order), len(graph))
+    }
+    if !reflect.DeepEqual(order, orderHashes(testHashgraph(t, graph, testCreators(graph)))) {
+        t.Fatal("hashgraph orderer differs from the default consensus")
+    }
+    for i := 1; i < len(consensus.ConsensusOrder); i++ {
+        if consensus.ConsensusOrder[i].RoundReceived < consensus.ConsensusOrder[i-1].RoundReceived {
+            t.Fatalf("event %d delivered out of round received order", i)
+        }
+    }
+
+    // Events wait until every creator is present, are then delivered as one batch ordered by
+    // Lamport time then hash, and after that each one as it arrives
+    arrival := shuffledTopological(graph, 4)
+    lamport := NewHashgraph(nil, nil)
+    lamport.SetOrderer(LamportOrderer{})
+    lamport.SetMinMembers(4)
+    addTestEvents(t, lamport, arrival)
+    creators := make(map[string]bool)
+    batch := 0
+    for len(creators) < 4 {
+        creators[arrival[batch].Creator] = true
+        batch++
+    }
+    expected := copyTestEvents(arrival[:batch])
+    sort.Slice(expected, func(i, j int) bool {
+        if expected[i].LamportTime != expected[j].LamportTime {
+            return expected[i].LamportTime < expected[j].LamportTime
+        }
+        return expected[i].Hash < expected[j].Hash
+    })
+    var want []string
+    for _, event := range append(expected, arrival[batch:]...) {
+        want = append(want, event.Hash)
+    }
+    if batch < 5 {
+        t.Fatalf("first batch of only %d events", batch)
+    }
+    if !reflect.DeepEqual(orderHashes(lamport), want) {
+        t.Fatal("lamport orderer did not deliver in Lamport order within each batch")
+    }
+
+    // Solo finalizes only what arrives before a second creator joins
+    alone := 0
+    for alone < len(graph) && graph[alone].Creator == graph[0].Creator {
+        alone++
+    }
+    solo := orderedTestHashgraph(t, graph, SoloOrderer{})
+    if order := orderHashes(solo); len(order) != alone || order[0] != graph[0].Hash {
+        t.Fatalf("solo orderer finalized %d events, want the %d before another creator joined", len(order), alone)
+    }
+    if _, err := ordererByName("random"); !errors.Is(err, errUnknownOrderer) {
+        t.Fatalf("unknown orderer: %v", err)
+    }
+}
+
+func TestSoloOrdererFinalizesOwnChain(t *testing.T) {
+    hg := testLocalHashgraph(t, 17)
+    hg.SetOrderer(SoloOrderer{})
+    hg.SetOtherParentStrategy(NoOtherParentStrategy{})
+    var created []string
+    for _, text := range []string{"one", "two", "three"} {
+        event, err := hg.SubmitTransaction([]byte(text), "")
+        if err != nil {
+            t.Fatal(err)
+        }
+        if event.OtherParent != "" {
+            t.Fatal("solo event merged an other-parent")
+        }
+        created = append(created, event.Hash)
+    }
+    if order := orderHashes(hg); !reflect.DeepEqual(order, created) {
+        t.Fatalf("solo order %v, want %v", order, created)
+    }
+
+    // Once another creator appears, a solo order can no longer be agreed
+    peer := buildTestGraph(t, 18, 2, 2)[0]
+    peer.SelfParent, peer.OtherParent = "", ""
+    peer.Hash, _ = hashEvent(peer)
+    if result, err := hg.AddRemoteEvent(peer); result != AddInserted {
+        t.Fatalf("peer event: %v %v", result, err)
+    }
+    if _, err := hg.SubmitTransaction([]byte("four"), ""); err != nil {
+        t.Fatal(err)
+    }
+    if order := orderHashes(hg); !reflect.DeepEqual(order, created) {
+        t.Fatalf("solo node finalized %d events alongside a peer", len(order))
+    }
+
+    if strategy, err := otherParentStrategyByName("none"); err != nil || strategy.Name() != "none" {
+        t.Fatalf("none strategy: %v %v", strategy, err)
+    }
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Events held per graph until their parents arrive; further ones are rejected and left to the sender to retransmit
+const defaultMaxOrphans = 1024
+
+// Event references a parent the graph does not hold
+var errMissingParent = errors.New("parent not in graph")
+
+// Too many events are already waiting for parents
+var errTooManyOrphans = errors.New("too many events awaiting parents")
+
+// First parent of an event the graph does not hold, empty when every parent is known; caller holds the lock
+func (hg *Hashgraph) missingParent(event *Event) string {
+    if event.SelfParent != "" {
+        if _, ok := hg.Events[event.SelfParent]; !ok {
+            return event.SelfParent
+        }
+    }
+    for _, hash := range event.OtherParents() {
+        if _, ok := hg.Events[hash]; !ok {
+            return hash
+        }
+    }
+    return ""
+}
+
+// Reject an event whose parents are not all in the graph, caller holds the lock
+func (hg *Hashgraph) checkParentsKnown(event *Event) error {
+    if parent := hg.missingParent(event); parent != "" {
+        return fmt.Errorf("%w: %s", errMissingParent, shortID(parent))
+    }
+    return nil
+}
+
+// Hold an event until the parent it is missing arrives, caller holds the lock
+func (hg *Hashgraph) holdOrphan(event *Event) error {
+    if hg.orphanHashes[event.Hash] {
+        return nil
+    }
+    if len(hg.orphanHashes) >= defaultMaxOrphans {
+        return errTooManyOrphans
+    }
+    parent := hg.missingParent(event)
+    hg.orphans[parent] = append(hg.orphans[parent], event)
+    hg.orphanHashes[event.Hash] = true
+    return nil
+}
+
+// Pass the events that were waiting for a newly inserted event through the pipeline, and in
+// turn those waiting for them. One still missing another parent waits for that one. Caller holds the lock.
+func (hg *Hashgraph) adoptOrphans(hash string) {
+    queue := []string{hash}
+    for len(queue) > 0 {
+        parent := queue[0]
+        queue = queue[1:]
+        waiting := hg.orphans[parent]
+        delete(hg.orphans, parent)
+        for _, event := range waiting {
+            delete(hg.orphanHashes, event.Hash)
+            err := hg.runPipeline(event)
+            switch {
+            case err == nil:
+                queue = append(queue, event.Hash)
+            case errors.Is(err, errMissingParent):
+                hg.holdOrphan(event)
+            case !errors.Is(err, errDuplicateEvent):
+                log.Printf("[%s] Dropped event %s that was awaiting parents: %v", hg.roomID, shortID(event.Hash), err)
+            }
+        }
+    }
+}
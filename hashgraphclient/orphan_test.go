@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOrphansWaitForParents(t *testing.T) {
+    graph := buildTestGraph(t, 8, 4, 80)
+    members := testCreators(graph)
+    ordered := testHashgraph(t, graph, members)
+
+    hg := NewHashgraph(nil, nil)
+    hg.SetMembers(members)
+    reversed := copyTestEvents(graph)
+    for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+        reversed[i], reversed[j] = reversed[j], reversed[i]
+    }
+    for _, event := range reversed {
+        want := AddBuffered
+        if event.SelfParent == "" && len(event.OtherParents()) == 0 {
+            want = AddInserted
+        }
+        if result, err := hg.AddRemoteEvent(event); result != want {
+            t.Fatalf("event %s: got %v %v, want %v", shortID(event.Hash), result, err, want)
+        }
+    }
+
+    if count := hg.EventCount(); count != len(graph) {
+        t.Fatalf("%d of %d events placed once the first arrived", count, len(graph))
+    }
+    if len(hg.orphanHashes) != 0 || len(hg.orphans) != 0 {
+        t.Fatal("events still waiting after all parents arrived")
+    }
+    for _, event := range graph {
+        a, _ := ordered.GetEvent(event.Hash)
+        b, _ := hg.GetEvent(event.Hash)
+        if a.RoundCreated != b.RoundCreated || a.Witness != b.Witness || a.RoundReceived != b.RoundReceived {
+            t.Fatalf("event %s placed differently when its parents arrived late", shortID(event.Hash))
+        }
+    }
+}
+
+func TestOrphanNotTakenForFirstEvent(t *testing.T) {
+    graph := buildTestGraph(t, 8, 4, 10)
+    hg := NewHashgraph(nil, nil)
+    event := copyTestEvents(graph[len(graph)-1:])[0]
+    if result, _ := hg.AddRemoteEvent(event); result != AddBuffered {
+        t.Fatalf("got %v, want buffered", result)
+    }
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    if _, ok := hg.Events[event.Hash]; ok || len(hg.Rounds[1]) != 0 {
+        t.Fatal("orphan was placed as a round 1 event")
+    }
+}
+
+func TestOrphanBufferIsBounded(t *testing.T) {
+    graph := buildTestGraph(t, 9, 2, defaultMaxOrphans+10)
+    hg := NewHashgraph(nil, nil)
+    var rejected error
+    for _, event := range copyTestEvents(graph[1:]) {
+        result, err := hg.AddRemoteEvent(event)
+        if result == AddRejected {
+            rejected = err
+            break
+        }
+    }
+    if !errors.Is(rejected, errTooManyOrphans) {
+        t.Fatalf("got %v, want errTooManyOrphans once the buffer is full", rejected)
+    }
+    if len(hg.orphanHashes) != defaultMaxOrphans {
+        t.Fatalf("%d events held, want %d", len(hg.orphanHashes), defaultMaxOrphans)
+    }
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Other-parent strategy name not registered
+var errUnknownOtherParentStrategy = errors.New("unknown other-parent strategy")
+
+// Chooses the other-parent of locally created events, run with the graph lock held.
+// The choice shapes the DAG and so how quickly rounds advance.
+type OtherParentStrategy interface {
+    Name() string
+    Select(hg *Hashgraph) string
+}
+
+// Tips of every other non-forked creator, in creator order, caller holds the lock
+func (hg *Hashgraph) peerTips() []*Event {
+    creators := make([]string, 0, len(hg.heads))
+    for creator := range hg.heads {
+        if _, forked := hg.forked[creator]; creator != hg.creatorID && !forked {
+            creators = append(creators, creator)
+        }
+    }
+    sort.Strings(creators)
+    tips := make([]*Event, 0, len(creators))
+    for _, creator := range creators {
+        if tip, ok := hg.Events[hg.heads[creator]]; ok {
+            tips = append(tips, tip)
+        }
+    }
+    return tips
+}
+
+// Classic choice: the latest event of a randomly chosen peer
+type RandomPeerTipStrategy struct {
+    rand *rand.Rand
+}
+
+func NewRandomPeerTipStrategy() *RandomPeerTipStrategy {
+    return &RandomPeerTipStrategy{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (*RandomPeerTipStrategy) Name() string { return "random-peer-tip" }
+
+func (s *RandomPeerTipStrategy) Select(hg *Hashgraph) string {
+    tips := hg.peerTips()
+    if len(tips) == 0 {
+        return ""
+    }
+    return tips[s.rand.Intn(len(tips))].Hash
+}
+
+// The peer tip in the highest round, to help rounds advance
+type HighestRoundStrategy struct{}
+
+func (HighestRoundStrategy) Name() string { return "highest-round" }
+
+func (HighestRoundStrategy) Select(hg *Hashgraph) string {
+    var best *Event
+    for _, tip := range hg.peerTips() {
+        if best == nil || tip.RoundCreated > best.RoundCreated ||
+            (tip.RoundCreated == best.RoundCreated && tip.LamportTime > best.LamportTime) {
+            best = tip
+        }
+    }
+    if best == nil {
+        return ""
+    }
+    return best.Hash
+}
+
+// The tip of the peer merged longest ago, so every peer is merged in turn
+type LeastRecentlyMergedStrategy struct{}
+
+func (LeastRecentlyMergedStrategy) Name() string { return "least-recently-merged" }
+
+func (LeastRecentlyMergedStrategy) Select(hg *Hashgraph) string {
+    var best *Event
+    for _, tip := range hg.peerTips() {
+        if best == nil || hg.lastMerged[tip.Creator] < hg.lastMerged[best.Creator] {
+            best = tip
+        }
+    }
+    if best == nil {
+        return ""
+    }
+    return best.Hash
+}
+
+// No other-parent: events chain on their self-parent only, for a node running solo
+type NoOtherParentStrategy struct{}
+
+func (NoOtherParentStrategy) Name() string { return "none" }
+
+func (NoOtherParentStrategy) Select(hg *Hashgraph) string { return "" }
+
+// Look up an other-parent strategy by name
+func otherParentStrategyByName(name string) (OtherParentStrategy, error) {
+    switch name {
+    case "random-peer-tip":
+        return NewRandomPeerTipStrategy(), nil
+    case "highest-round":
+        return HighestRoundStrategy{}, nil
+    case "least-recently-merged":
+        return LeastRecentlyMergedStrategy{}, nil
+    case "none":
+        return NoOtherParentStrategy{}, nil
+    }
+    return nil, errUnknownOtherParentStrategy
+}
+
+// set the strategy choosing other-parents
+func (hg *Hashgraph) SetOtherParentStrategy(strategy OtherParentStrategy) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.otherParent = strategy
+}
+
+// Note that a local event merged its other-parents' creators, caller holds the lock
+func (hg *Hashgraph) recordMerge(event *Event) {
+    for _, hash := range event.OtherParents() {
+        if other, ok := hg.Events[hash]; ok {
+            hg.mergeCount++
+            hg.lastMerged[other.Creator] = hg.mergeCount
+        }
+    }
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// Node that created the first member's events, holding the whole graph
+func otherParentTestHashgraph(t *testing.T) (*Hashgraph, map[string]*Event) {
+    t.Helper()
+    graph, keys, err := BuildTestGraph(22, 4, 60)
+    if err != nil {
+        t.Fatal(err)
+    }
+    hg := NewHashgraph(keys[0], &keys[0].PublicKey)
+    hg.SetMembers(testCreators(graph))
+    addTestEvents(t, hg, graph)
+    tips := make(map[string]*Event)
+    for creator, hash := range hg.Frontier() {
+        if creator != hg.creatorID {
+            tips[creator], _ = hg.GetEvent(hash)
+        }
+    }
+    if len(tips) != 3 {
+        t.Fatalf("%d peer tips", len(tips))
+    }
+    return hg, tips
+}
+
+func TestOtherParentStrategies(t *testing.T) {
+    hg, tips := otherParentTestHashgraph(t)
+
+    hg.SetOtherParentStrategy(HighestRoundStrategy{})
+    best := tips[creatorOf(tips, hg.OtherParent())]
+    if best == nil {
+        t.Fatal("highest-round chose something other than a peer's tip")
+    }
+    for _, tip := range tips {
+        if tip.RoundCreated > best.RoundCreated || (tip.RoundCreated == best.RoundCreated && tip.LamportTime > best.LamportTime) {
+            t.Fatalf("highest-round chose round %d time %d over round %d time %d",
+                best.RoundCreated, best.LamportTime, tip.RoundCreated, tip.LamportTime)
+        }
+    }
+
+    hg.SetOtherParentStrategy(&RandomPeerTipStrategy{rand: rand.New(rand.NewSource(1))})
+    picked := make(map[string]bool)
+    for i := 0; i < 50; i++ {
+        creator := creatorOf(tips, hg.OtherParent())
+        if creator == "" {
+            t.Fatal("random-peer-tip chose something other than a peer's tip")
+        }
+        picked[creator] = true
+    }
+    if len(picked) != len(tips) {
+        t.Fatalf("random-peer-tip chose %d of %d peers", len(picked), len(tips))
+    }
+
+    hg.SetOtherParentStrategy(NoOtherParentStrategy{})
+    if chosen := hg.OtherParent(); chosen != "" {
+        t.Fatalf("none chose %s", shortID(chosen))
+    }
+
+    if _, err := otherParentStrategyByName("newest"); !errors.Is(err, errUnknownOtherParentStrategy) {
+        t.Fatalf("unknown strategy: %v", err)
+    }
+}
+
+func TestLeastRecentlyMergedCyclesThroughPeers(t *testing.T) {
+    hg, tips := otherParentTestHashgraph(t)
+    hg.SetOtherParentStrategy(LeastRecentlyMergedStrategy{})
+
+    merged := make(map[string]bool)
+    for i := 0; i < len(tips); i++ {
+        event, err := hg.SubmitTransaction([]byte("hello"), "")
+        if err != nil {
+            t.Fatal(err)
+        }
+        creator := creatorOf(tips, event.OtherParent)
+        if creator == "" || merged[creator] {
+            t.Fatalf("event %d merged %s again before every peer was merged", i, shortID(creator))
+        }
+        merged[creator] = true
+    }
+}
+
+// Creator whose tip is hash, empty if none
+func creatorOf(tips map[string]*Event, hash string) string {
+    for creator, tip := range tips {
+        if tip.Hash == hash {
+            return creator
+        }
+    }
+    return ""
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Default outbox file
+const defaultOutboxPath = "outbox.json"
+
+// Default time an event is retransmitted without an acknowledgment before it is dead-lettered
+const defaultAckWindow = 2 * time.Minute
+
+// Default interval between retransmissions of unacknowledged events
+const defaultRetransmitInterval = 10 * time.Second
+
+// Event went unacknowledged for the whole ack window
+var errAckTimeout = errors.New("no acknowledgment within the ack window")
+
+// Event waiting for an acknowledgment from its target node
+type OutboxEntry struct {
+    Event      *Event    `json:"event"`
+    TargetNode string    `json:"targetNode"`
+    AddedAt    time.Time `json:"addedAt"`
+}
+
+// Outbox of locally created events not yet acknowledged, persisted to disk
+type Outbox struct {
+    path    string
+    entries []*OutboxEntry
+    mutex   sync.Mutex
+}
+
+// Open an outbox, loading any entries left by a previous run
+func OpenOutbox(path string) (*Outbox, error) {
+    outbox := &Outbox{path: path}
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return outbox, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("read outbox: %w", err)
+    }
+    if err := json.Unmarshal(data, &outbox.entries); err != nil {
+        return nil, fmt.Errorf("decode outbox %s: %w", path, err)
+    }
+    return outbox, nil
+}
+
+// Add an event before it is sent
+func (o *Outbox) Add(event *Event, targetNode string) error {
+    o.mutex.Lock()
+    defer o.mutex.Unlock()
+    o.entries = append(o.entries, &OutboxEntry{
+        Event:      event,
+        TargetNode: targetNode,
+        AddedAt:    time.Now(),
+    })
+    return o.save()
+}
+
+// Acknowledge an event, removing it from the outbox
+func (o *Outbox) Ack(hash string) error {
+    o.mutex.Lock()
+    defer o.mutex.Unlock()
+    for i, entry := range o.entries {
+        if entry.Event.Hash == hash {
+            o.entries = append(o.entries[:i], o.entries[i+1:]...)
+            return o.save()
+        }
+    }
+    return nil
+}
+
+// Unacknowledged events in the order they were added
+func (o *Outbox) PendingList() []*OutboxEntry {
+    o.mutex.Lock()
+    defer o.mutex.Unlock()
+    pending := make([]*OutboxEntry, len(o.entries))
+    copy(pending, o.entries)
+    return pending
+}
+
+// Remove and return entries added at least window ago
+func (o *Outbox) Expire(now time.Time, window time.Duration) ([]*OutboxEntry, error) {
+    o.mutex.Lock()
+    defer o.mutex.Unlock()
+    var expired []*OutboxEntry
+    kept := o.entries[:0]
+    for _, entry := range o.entries {
+        if !now.Before(entry.AddedAt.Add(window)) {
+            expired = append(expired, entry)
+        } else {
+            kept = append(kept, entry)
+        }
+    }
+    o.entries = kept
+    if len(expired) == 0 {
+        return nil, nil
+    }
+    return expired, o.save()
+}
+
+// Retransmit unacknowledged events every interval, dead-lettering those that outlive the ack window
+func runRetransmits(c *SignalConn, events *EventChannel, outbox *Outbox, deadLetters *DeadLetterStore, interval, window time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for now := range ticker.C {
+        expired, err := outbox.Expire(now, window)
+        if err != nil {
+            log.Println("Failed to update outbox:", err)
+        }
+        for _, entry := range expired {
+            deadLetters.Add(entry.Event, errAckTimeout, entry.TargetNode)
+        }
+        for _, entry := range outbox.PendingList() {
+            if err := sendEvent(c, events, entry.TargetNode, entry.Event); err != nil {
+                log.Println("Failed to retransmit event:", err)
+            }
+        }
+    }
+}
+
+// Write the outbox atomically, caller holds the lock
+func (o *Outbox) save() error {
+    data, err := json.Marshal(o.entries)
+    if err != nil {
+        return fmt.Errorf("encode outbox: %w", err)
+    }
+    tmp := o.path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0600); err != nil {
+        return fmt.Errorf("write outbox: %w", err)
+    }
+    if err := os.Rename(tmp, o.path); err != nil {
+        return fmt.Errorf("write outbox: %w", err)
+    }
+    return nil
+}
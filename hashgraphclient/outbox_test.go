@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOutboxSurvivesRestart(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "outbox.json")
+    outbox, err := OpenOutbox(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    graph := buildTestGraph(t, 101, 2, 3)
+    for _, event := range graph {
+        if err := outbox.Add(event, "peer"); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := outbox.Ack(graph[1].Hash); err != nil {
+        t.Fatal(err)
+    }
+
+    reopened, err := OpenOutbox(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    pending := reopened.PendingList()
+    if len(pending) != 2 || pending[0].Event.Hash != graph[0].Hash || pending[1].Event.Hash != graph[2].Hash {
+        t.Fatalf("reopened outbox holds %d entries, want the two unacknowledged in order", len(pending))
+    }
+    if pending[0].TargetNode != "peer" || pending[0].Event.Signature != graph[0].Signature {
+        t.Fatal("entry not restored whole")
+    }
+}
+
+func TestOpenOutboxRejectsCorruptFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "outbox.json")
+    if err := os.WriteFile(path, []byte("{not json"), 0600); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := OpenOutbox(path); err == nil {
+        t.Fatal("corrupt outbox opened")
+    }
+}
+
+func TestUnackedEventDeadLetteredAfterWindow(t *testing.T) {
+    outbox, err := OpenOutbox(filepath.Join(t.TempDir(), "outbox.json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    graph := buildTestGraph(t, 102, 2, 2)
+    for _, event := range graph {
+        if err := outbox.Add(event, "peer"); err != nil {
+            t.Fatal(err)
+        }
+    }
+    window := time.Minute
+    added := outbox.PendingList()[0].AddedAt
+    outbox.PendingList()[1].AddedAt = added.Add(time.Second)
+
+    if expired, _ := outbox.Expire(added.Add(window-time.Nanosecond), window); expired != nil {
+        t.Fatalf("%d entries expired before the window", len(expired))
+    }
+    expired, err := outbox.Expire(added.Add(window), window)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(expired) != 1 || expired[0].Event.Hash != graph[0].Hash {
+        t.Fatal("entry not expired exactly at the end of its window")
+    }
+    if pending := outbox.PendingList(); len(pending) != 1 || pending[0].Event.Hash != graph[1].Hash {
+        t.Fatal("entry still inside its window removed")
+    }
+
+    // Retransmitted until the window ends, then dead-lettered
+    outbox, err = OpenOutbox(filepath.Join(t.TempDir(), "outbox.json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    outbox.Add(graph[0], "peer")
+    outbox.Add(graph[1], "peer")
+    outbox.Ack(graph[1].Hash)
+    c, sent := testSignalConn(t)
+    deadLetters := NewDeadLetterStore(10, 1)
+    go runRetransmits(c, nil, outbox, deadLetters, 10*time.Millisecond, 200*time.Millisecond)
+
+    select {
+    case msg := <-sent:
+        if msg.Type != "event" || msg.Event.Hash != graph[0].Hash || msg.TargetNode != "peer" {
+            t.Fatalf("retransmitted %+v", msg)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("unacknowledged event not retransmitted")
+    }
+    deadline := time.Now().Add(2 * time.Second)
+    for deadLetters.Len() == 0 && time.Now().Before(deadline) {
+        time.Sleep(10 * time.Millisecond)
+    }
+    letters := deadLetters.List()
+    if len(letters) != 1 || letters[0].Event.Hash != graph[0].Hash || letters[0].Reason != errAckTimeout.Error() {
+        t.Fatalf("dead letters %+v", letters)
+    }
+    if len(outbox.PendingList()) != 0 {
+        t.Fatal("dead-lettered event still retransmitted")
+    }
+}
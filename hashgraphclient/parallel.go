@@ -0,0 +1,54 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Default number of goroutines consensus queries are spread over, 1 runs them serially
+const defaultConsensusWorkers = 1
+
+// set how many goroutines independent consensus queries run on, 0 for one per CPU.
+// Only the ancestry queries run in parallel; their results are applied in the serial
+// order, so rounds, fame and consensus order do not depend on the worker count.
+func (hg *Hashgraph) SetConsensusWorkers(workers int) {
+    if workers <= 0 {
+        workers = runtime.NumCPU()
+    }
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.workers = workers
+}
+
+// Loops shorter than this run serially, as goroutines would cost more than they save
+const minParallelItems = 8
+
+// Run fn for every index below n on the consensus workers, returning once all are done
+func (hg *Hashgraph) parallelFor(n int, fn func(i int)) {
+    workers := hg.workers
+    if workers > n {
+        workers = n
+    }
+    if workers <= 1 || n < minParallelItems {
+        for i := 0; i < n; i++ {
+            fn(i)
+        }
+        return
+    }
+    var wg sync.WaitGroup
+    next := make(chan int)
+    for w := 0; w < workers; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range next {
+                fn(i)
+            }
+        }()
+    }
+    for i := 0; i < n; i++ {
+        next <- i
+    }
+    close(next)
+    wg.Wait()
+}
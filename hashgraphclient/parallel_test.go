@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// Graph with enough members that the per-witness loops run on the workers
+func parallelTestGraph(t testing.TB) ([]*Event, []string) {
+    graph := buildTestGraph(t, 41, 8, 320)
+    return graph, testCreators(graph)
+}
+
+func consensusWithWorkers(t testing.TB, graph []*Event, members []string, workers int) *Hashgraph {
+    hg := NewHashgraph(nil, nil)
+    hg.SetMembers(members)
+    hg.SetConsensusWorkers(workers)
+    addTestEvents(t, hg, graph)
+    return hg
+}
+
+func TestConsensusIndependentOfWorkerCount(t *testing.T) {
+    graph, members := parallelTestGraph(t)
+    serial := consensusWithWorkers(t, graph, members, 1)
+    parallel := consensusWithWorkers(t, graph, members, 8)
+
+    order := orderHashes(serial)
+    if len(order) == 0 {
+        t.Fatal("nothing reached consensus")
+    }
+    if !reflect.DeepEqual(order, orderHashes(parallel)) {
+        t.Fatal("consensus order depends on the worker count")
+    }
+    for _, event := range graph {
+        a, _ := serial.GetEvent(event.Hash)
+        b, _ := parallel.GetEvent(event.Hash)
+        if a.RoundCreated != b.RoundCreated || !reflect.DeepEqual(a.Famous, b.Famous) || a.RoundReceived != b.RoundReceived ||
+            !a.ConsensusTimestamp.Equal(b.ConsensusTimestamp) {
+            t.Fatalf("event %s: consensus differs between 1 and 8 workers", shortID(event.Hash))
+        }
+    }
+}
+
+func TestParallelForVisitsEveryIndexOnce(t *testing.T) {
+    hg := NewHashgraph(nil, nil)
+    hg.SetConsensusWorkers(4)
+    for _, n := range []int{0, 1, minParallelItems - 1, minParallelItems, 100} {
+        visits := make([]int, n)
+        hg.parallelFor(n, func(i int) { visits[i]++ })
+        for i, count := range visits {
+            if count != 1 {
+                t.Fatalf("n=%d: index %d visited %d times", n, i, count)
+            }
+        }
+    }
+}
+
+// Inserting a graph and reaching consensus on it, serially and on several workers
+func BenchmarkConsensusWorkers(b *testing.B) {
+    graph, members := parallelTestGraph(b)
+    for _, workers := range []int{1, 2, 4, 8} {
+        b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+            for i := 0; i < b.N; i++ {
+                consensusWithWorkers(b, graph, members, workers)
+            }
+        })
+    }
+}
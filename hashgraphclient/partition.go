@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// Partition state of a graph
+type PartitionStatus struct {
+    Partitioned bool      `json:"partitioned"`
+    Reachable   int       `json:"reachable"` // members heard from within the timeout, including us
+    Members     int       `json:"members"`
+    Since       time.Time `json:"since,omitempty"` // when the current partition was suspected
+    Partitions  int       `json:"partitions"`      // partitions suspected so far
+}
+
+// set how long a supermajority may go unheard before a partition is suspected, 0 disables detection
+func (hg *Hashgraph) SetPartitionTimeout(timeout time.Duration) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.partitionTimeout = timeout
+}
+
+// Members heard from within the partition timeout, caller holds the lock
+func (hg *Hashgraph) reachableMembers(now time.Time) int {
+    if len(hg.epochs) == 0 {
+        return 0
+    }
+    reachable := 0
+    for creator := range hg.epochs[len(hg.epochs)-1].Members {
+        if _, heard := hg.lastContact[creator]; !heard {
+            continue
+        }
+        if creator == hg.creatorID || now.Sub(hg.lastContact[creator]) <= hg.partitionTimeout {
+            reachable++
+        }
+    }
+    return reachable
+}
+
+// Update the partition state, reporting whether finalizing must stop; caller holds the lock
+func (hg *Hashgraph) checkPartition(now time.Time) bool {
+    if hg.partitionTimeout <= 0 {
+        return false
+    }
+    partitioned := !hg.isSupermajority(hg.reachableMembers(now))
+    if partitioned && hg.partitionedSince.IsZero() {
+        hg.partitionedSince = now
+        hg.partitions++
+        log.Printf("[%s] Suspected network partition, finalizing paused", hg.roomID)
+    } else if !partitioned && !hg.partitionedSince.IsZero() {
+        hg.partitionedSince = time.Time{}
+        log.Printf("[%s] Partition healed, finalizing resumed", hg.roomID)
+    }
+    return partitioned
+}
+
+// Get the partition state
+func (hg *Hashgraph) PartitionStatus() PartitionStatus {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    now := time.Now()
+    return PartitionStatus{
+        Partitioned: hg.checkPartition(now),
+        Reachable:   hg.reachableMembers(now),
+        Members:     hg.memberCount(),
+        Since:       hg.partitionedSince,
+        Partitions:  hg.partitions,
+    }
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPartitionPausesFinalizing(t *testing.T) {
+    graph := buildTestGraph(t, 23, 4, 300)
+    members := testCreators(graph)
+    reference := testHashgraph(t, graph, members)
+
+    hg := testHashgraph(t, graph[:150], members)
+    hg.SetPartitionTimeout(time.Minute)
+    if status := hg.PartitionStatus(); status.Partitioned || status.Reachable != 4 || status.Members != 4 {
+        t.Fatalf("status with every member heard from %+v", status)
+    }
+
+    // Two of four members go quiet, leaving no supermajority reachable
+    hg.mutex.Lock()
+    for _, member := range members[:2] {
+        hg.lastContact[member] = time.Now().Add(-2 * time.Minute)
+    }
+    hg.mutex.Unlock()
+    status := hg.PartitionStatus()
+    if !status.Partitioned || status.Reachable != 2 || status.Partitions != 1 || status.Since.IsZero() {
+        t.Fatalf("status after losing two members %+v", status)
+    }
+    if hg.Status().Running {
+        t.Fatal("consensus reported running while partitioned")
+    }
+
+    // Events keep arriving but, with every contact counted stale, nothing is finalized
+    finalized := hg.LastFinalizedRound()
+    hg.SetPartitionTimeout(time.Nanosecond)
+    addTestEvents(t, hg, graph[150:len(graph)-1])
+    if hg.LastFinalizedRound() != finalized || finalized >= reference.LastFinalizedRound() {
+        t.Fatalf("finalized up to round %d while partitioned, from %d", hg.LastFinalizedRound(), finalized)
+    }
+
+    // Once members are reachable again the backlog is finalized as if nothing happened
+    hg.SetPartitionTimeout(time.Minute)
+    addTestEvents(t, hg, graph[len(graph)-1:])
+    status = hg.PartitionStatus()
+    if status.Partitioned || !status.Since.IsZero() || status.Partitions != 1 {
+        t.Fatalf("status after healing %+v", status)
+    }
+    if !reflect.DeepEqual(orderHashes(hg), orderHashes(reference)) {
+        t.Fatal("order after healing differs from the order without a partition")
+    }
+}
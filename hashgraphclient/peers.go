@@ -0,0 +1,121 @@
+package main
+
+// Record that a peer has an event, caller holds the lock
+func (hg *Hashgraph) markKnownByPeer(peerID, hash string) {
+    if peerID == "" {
+        return
+    }
+    if hg.peerKnown[peerID] == nil {
+        hg.peerKnown[peerID] = make(map[string]bool)
+    }
+    hg.peerKnown[peerID][hash] = true
+}
+
+// Number of events a peer has delivered that were new to the graph
+func (hg *Hashgraph) EventsReceivedFrom(peerID string) int {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    return hg.receivedCounts[peerID]
+}
+
+// Record an acknowledgment of an event from a peer
+func (hg *Hashgraph) RecordAck(peerID, hash string) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    if _, ok := hg.Events[hash]; ok {
+        hg.markKnownByPeer(peerID, hash)
+    }
+}
+
+// Copies of the events the peer has neither acknowledged nor sent us, parents first
+func (hg *Hashgraph) EventsMissingForPeer(peerID string) []*Event {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+
+    known := hg.peerKnown[peerID]
+    watermark := hg.peerWatermarks[peerID]
+    var missing []*Event
+    for _, event := range hg.topologicalEvents() {
+        // The peer already finalized every event received up to its watermark
+        if event.RoundReceived != 0 && event.RoundReceived <= watermark {
+            continue
+        }
+        if !known[event.Hash] {
+            missing = append(missing, event)
+        }
+    }
+    return copyEventList(missing)
+}
+
+// Record a peer's advertised frontier: it has each head there and every ancestor of it
+func (hg *Hashgraph) RecordPeerFrontier(peerID string, frontier map[string]string) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    var stack []*Event
+    for _, hash := range frontier {
+        if event, ok := hg.Events[hash]; ok {
+            stack = append(stack, event)
+        }
+    }
+    for len(stack) > 0 {
+        event := stack[len(stack)-1]
+        stack = stack[:len(stack)-1]
+        if hg.peerKnown[peerID][event.Hash] {
+            continue
+        }
+        hg.markKnownByPeer(peerID, event.Hash)
+        stack = append(stack, hg.parents(event)...)
+    }
+}
+
+// Record the last round a peer reports as finalized
+func (hg *Hashgraph) RecordWatermark(peerID string, round int) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    if round > hg.peerWatermarks[peerID] {
+        hg.peerWatermarks[peerID] = round
+    }
+}
+
+// Record a peer's finalized watermark for a room
+func (rm *RoomManager) RecordWatermark(roomID, peerID string, round int) {
+    if hg, ok := rm.Room(roomID); ok {
+        hg.RecordWatermark(peerID, round)
+    }
+}
+
+// Record an acknowledgment in whichever room holds the event
+func (rm *RoomManager) RecordAck(peerID, hash string) {
+    rm.mutex.RLock()
+    defer rm.mutex.RUnlock()
+    for _, hg := range rm.rooms {
+        hg.RecordAck(peerID, hash)
+    }
+}
+
+// Merge pinned bootstrap peers with the server's node list, pinned peers first
+func mergePeers(bootstrap, nodes []string) []string {
+    seen := make(map[string]bool)
+    var peers []string
+    for _, list := range [][]string{bootstrap, nodes} {
+        for _, peer := range list {
+            if peer == "" || seen[peer] {
+                continue
+            }
+            seen[peer] = true
+            peers = append(peers, peer)
+        }
+    }
+    return peers
+}
+
+// Peer list without the local node's own ID
+func withoutSelf(nodes []string, self string) []string {
+    peers := make([]string, 0, len(nodes))
+    for _, node := range nodes {
+        if node != self {
+            peers = append(peers, node)
+        }
+    }
+    return peers
+}
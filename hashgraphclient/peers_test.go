@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func missingHashes(hg *Hashgraph, peerID string) map[string]bool {
+    missing := make(map[string]bool)
+    for _, event := range hg.EventsMissingForPeer(peerID) {
+        missing[event.Hash] = true
+    }
+    return missing
+}
+
+func TestEventsMissingForPeer(t *testing.T) {
+    graph := buildTestGraph(t, 141, 3, 30)
+    hg := NewHashgraph(nil, nil)
+    events := copyTestEvents(graph)
+    for i, event := range events {
+        // The peer sent the first ten events itself
+        if i < 10 {
+            event.ReceivedFrom = "peer"
+        }
+        if result, err := hg.AddRemoteEvent(event); result != AddInserted {
+            t.Fatal(result, err)
+        }
+    }
+    hg.RecordAck("peer", graph[10].Hash)
+
+    missing := hg.EventsMissingForPeer("peer")
+    if len(missing) != len(graph)-11 {
+        t.Fatalf("%d events missing, want %d", len(missing), len(graph)-11)
+    }
+    position := make(map[string]int)
+    for i, event := range missing {
+        position[event.Hash] = i
+        if event.Hash == graph[10].Hash || event.ReceivedFrom == "peer" {
+            t.Fatalf("event %s the peer has is reported missing", shortID(event.Hash))
+        }
+    }
+    for i, event := range missing {
+        if p, ok := position[event.SelfParent]; ok && p > i {
+            t.Fatal("events not parents first")
+        }
+    }
+    if len(hg.EventsMissingForPeer("stranger")) != len(graph) {
+        t.Fatal("an unknown peer is not missing every event")
+    }
+}
+
+func TestMergePeersPutsPinnedFirst(t *testing.T) {
+    peers := mergePeers([]string{"pinned", "", "also-pinned"}, []string{"a", "pinned", "b"})
+    if want := []string{"pinned", "also-pinned", "a", "b"}; !reflect.DeepEqual(peers, want) {
+        t.Fatalf("merged peers %v, want %v", peers, want)
+    }
+    if peers := mergePeers(nil, []string{"a"}); !reflect.DeepEqual(peers, []string{"a"}) {
+        t.Fatalf("no pinned peers: %v", peers)
+    }
+}
+
+func TestOwnIDNeverSelectable(t *testing.T) {
+    nodes := []string{"a", "self", "b", "self"}
+    if targets := withoutSelf(nodes, "self"); !reflect.DeepEqual(targets, []string{"a", "b"}) {
+        t.Fatalf("targets %v", targets)
+    }
+    if !reflect.DeepEqual(nodes, []string{"a", "self", "b", "self"}) {
+        t.Fatal("filtering changed the node list")
+    }
+
+    // Gossip targets leave the node out whether it learns its ID before or after its peers
+    sampler := NewPeerSampler(8, 4)
+    sampler.Add(nodes)
+    sampler.SetSelf("self")
+    sampler.Add([]string{"self", "c"})
+    for _, peer := range append(sampler.View(), sampler.Sample(8)...) {
+        if peer == "self" {
+            t.Fatalf("own ID in gossip targets %v", sampler.View())
+        }
+    }
+    if len(sampler.View()) != 3 {
+        t.Fatalf("view %v, want a, b and c", sampler.View())
+    }
+}
+
+func TestWatermarkSkipsPeerFinalizedEvents(t *testing.T) {
+    graph := buildTestGraph(t, 14, 4, 200)
+    hg := testHashgraph(t, graph, testCreators(graph))
+    watermark := hg.LastFinalizedRound() - 1
+    if watermark < 1 {
+        t.Fatalf("only round %d finalized", hg.LastFinalizedRound())
+    }
+    if len(hg.EventsMissingForPeer("peer")) != len(graph) {
+        t.Fatal("peer without a watermark is not offered every event")
+    }
+
+    hg.RecordWatermark("peer", watermark)
+    hg.RecordWatermark("peer", watermark-1)
+    missing := missingHashes(hg, "peer")
+    for _, event := range graph {
+        held, _ := hg.GetEvent(event.Hash)
+        below := held.RoundReceived != 0 && held.RoundReceived <= watermark
+        if below == missing[event.Hash] {
+            t.Fatalf("event received in round %d offered %v under watermark %d", held.RoundReceived, missing[event.Hash], watermark)
+        }
+    }
+    if len(hg.EventsMissingForPeer("other")) != len(graph) {
+        t.Fatal("one peer's watermark applied to another")
+    }
+}
+
+func TestPeerFrontierCoversAncestors(t *testing.T) {
+    graph := buildTestGraph(t, 142, 3, 30)
+    hg := NewHashgraph(nil, nil)
+    addTestEvents(t, hg, graph)
+
+    head := graph[len(graph)-1]
+    hg.RecordPeerFrontier("peer", map[string]string{head.Creator: head.Hash})
+    missing := missingHashes(hg, "peer")
+    if missing[head.Hash] || missing[head.SelfParent] || missing[head.OtherParent] {
+        t.Fatal("frontier head or its parents reported missing")
+    }
+    if len(missing) == 0 {
+        t.Fatal("events outside the frontier's ancestry not reported missing")
+    }
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Default number of peers in the partial view
+const defaultViewSize = 8
+
+// Default number of peers exchanged in a shuffle, including the sender
+const defaultShuffleLength = 4
+
+// Bounded partial view of the network, refreshed by shuffles with the peers in it, so gossip
+// never needs the full node list. Peers are kept oldest first.
+type PeerSampler struct {
+    self     string
+    size     int
+    shuffle  int
+    view     []string
+    pending  map[string][]string // peers offered in a shuffle awaiting reply, by target
+    rand     *rand.Rand
+    mutex    sync.Mutex
+}
+
+// create new peer sampler
+func NewPeerSampler(size, shuffleLength int) *PeerSampler {
+    return &PeerSampler{
+        size:    size,
+        shuffle: shuffleLength,
+        pending: make(map[string][]string),
+        rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+    }
+}
+
+// Set the local node's ID, which is never kept in the view
+func (ps *PeerSampler) SetSelf(self string) {
+    ps.mutex.Lock()
+    defer ps.mutex.Unlock()
+    ps.self = self
+    ps.view = withoutSelf(ps.view, self)
+}
+
+// Check whether a peer is in the view, caller holds the lock
+func (ps *PeerSampler) contains(peer string) bool {
+    for _, p := range ps.view {
+        if p == peer {
+            return true
+        }
+    }
+    return false
+}
+
+// Remove a peer from the view, caller holds the lock
+func (ps *PeerSampler) remove(peer string) bool {
+    for i, p := range ps.view {
+        if p == peer {
+            ps.view = append(ps.view[:i], ps.view[i+1:]...)
+            return true
+        }
+    }
+    return false
+}
+
+// Add peers to the view, evicting those in evictFirst and then the oldest when full; caller holds the lock
+func (ps *PeerSampler) merge(peers, evictFirst []string) {
+    for _, peer := range peers {
+        if peer == "" || peer == ps.self || ps.contains(peer) {
+            continue
+        }
+        for len(ps.view) >= ps.size {
+            evicted := false
+            for len(evictFirst) > 0 && !evicted {
+                evicted = ps.remove(evictFirst[0])
+                evictFirst = evictFirst[1:]
+            }
+            if !evicted {
+                ps.view = ps.view[1:]
+            }
+        }
+        ps.view = append(ps.view, peer)
+    }
+}
+
+// Add peers learned elsewhere, such as the server's node list
+func (ps *PeerSampler) Add(peers []string) {
+    ps.mutex.Lock()
+    defer ps.mutex.Unlock()
+    ps.merge(peers, nil)
+}
+
+// Random subset of the view, caller holds the lock
+func (ps *PeerSampler) subset(n int, exclude string) []string {
+    var candidates []string
+    for _, p := range ps.view {
+        if p != exclude {
+            candidates = append(candidates, p)
+        }
+    }
+    ps.rand.Shuffle(len(candidates), func(i, j int) {
+        candidates[i], candidates[j] = candidates[j], candidates[i]
+    })
+    if len(candidates) > n {
+        candidates = candidates[:n]
+    }
+    return candidates
+}
+
+// Random peers from the view to gossip with
+func (ps *PeerSampler) Sample(n int) []string {
+    ps.mutex.Lock()
+    defer ps.mutex.Unlock()
+    return ps.subset(n, "")
+}
+
+// Start a shuffle with the oldest peer, returning it and the peers to offer it, including ourselves
+func (ps *PeerSampler) StartShuffle() (string, []string, bool) {
+    ps.mutex.Lock()
+    defer ps.mutex.Unlock()
+    if len(ps.view) == 0 {
+        return "", nil, false
+    }
+    target := ps.view[0]
+    ps.view = ps.view[1:]
+    offer := append(ps.subset(ps.shuffle-1, target), ps.self)
+    ps.pending[target] = offer
+    return target, offer, true
+}
+
+// Answer a shuffle from a peer, returning the peers to send back
+func (ps *PeerSampler) HandleShuffle(from string, offer []string) []string {
+    ps.mutex.Lock()
+    defer ps.mutex.Unlock()
+    reply := ps.subset(ps.shuffle, from)
+    ps.merge(offer, reply)
+    return reply
+}
+
+// Merge the reply to a shuffle we started
+func (ps *PeerSampler) HandleShuffleReply(from string, reply []string) {
+    ps.mutex.Lock()
+    defer ps.mutex.Unlock()
+    sent := ps.pending[from]
+    delete(ps.pending, from)
+    ps.merge(reply, sent)
+}
+
+// Get the current view
+func (ps *PeerSampler) View() []string {
+    ps.mutex.Lock()
+    defer ps.mutex.Unlock()
+    return append([]string(nil), ps.view...)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestPeerSamplerViewBoundedAndCovering(t *testing.T) {
+    const nodes, viewSize, shuffleLength, rounds = 40, 6, 3, 300
+    ids := make([]string, nodes)
+    samplers := make(map[string]*PeerSampler, nodes)
+    for i := range ids {
+        ids[i] = fmt.Sprintf("node-%02d", i)
+    }
+    // Each node starts knowing only its successor on a ring
+    for i, id := range ids {
+        sampler := NewPeerSampler(viewSize, shuffleLength)
+        sampler.rand = rand.New(rand.NewSource(int64(i)))
+        sampler.SetSelf(id)
+        sampler.Add([]string{ids[(i+1)%nodes]})
+        samplers[id] = sampler
+    }
+
+    seen := make(map[string]map[string]bool, nodes)
+    for _, id := range ids {
+        seen[id] = make(map[string]bool)
+    }
+    for round := 0; round < rounds; round++ {
+        for _, id := range ids {
+            target, offer, ok := samplers[id].StartShuffle()
+            if !ok {
+                t.Fatalf("round %d: %s has an empty view", round, id)
+            }
+            reply := samplers[target].HandleShuffle(id, offer)
+            samplers[id].HandleShuffleReply(target, reply)
+        }
+        for _, id := range ids {
+            view := samplers[id].View()
+            if len(view) > viewSize {
+                t.Fatalf("round %d: %s view of %d peers", round, id, len(view))
+            }
+            unique := make(map[string]bool, len(view))
+            for _, peer := range view {
+                if peer == id || unique[peer] {
+                    t.Fatalf("round %d: %s view %v", round, id, view)
+                }
+                unique[peer] = true
+                seen[id][peer] = true
+            }
+        }
+    }
+    for _, id := range ids {
+        if len(seen[id]) != nodes-1 {
+            t.Fatalf("%s saw %d of %d peers across shuffles", id, len(seen[id]), nodes-1)
+        }
+    }
+}
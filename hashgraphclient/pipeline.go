@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Event already present in the graph
+var errDuplicateEvent = errors.New("duplicate event")
+
+// One step of the pipeline received events flow through, run with the graph lock held
+type Stage struct {
+    Name string
+    Run  func(hg *Hashgraph, event *Event) error
+}
+
+// Error that stopped an event at a pipeline stage
+type StageError struct {
+    Stage string
+    Err   error
+}
+
+func (e *StageError) Error() string {
+    return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+    return e.Err
+}
+
+// Default pipeline: Validate -> Dedup -> Quota -> Persist -> Insert -> Consensus
+func defaultPipeline() []Stage {
+    return []Stage{
+        {Name: "validate", Run: validateStage},
+        {Name: "dedup", Run: dedupStage},
+        {Name: "quota", Run: quotaStage},
+        {Name: "persist", Run: persistStage},
+        {Name: "insert", Run: insertStage},
+        {Name: "consensus", Run: consensusStage},
+    }
+}
+
+// Get the stages of the pipeline
+func (hg *Hashgraph) Pipeline() []Stage {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    return append([]Stage(nil), hg.pipeline...)
+}
+
+// Replace the stages of the pipeline
+func (hg *Hashgraph) SetPipeline(stages []Stage) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.pipeline = stages
+}
+
+// Set the function the persist stage stores events with
+func (hg *Hashgraph) SetPersister(persist func(*Event) error) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.persist = persist
+}
+
+// Pass an event through every stage, stopping at the first error
+func (hg *Hashgraph) runPipeline(event *Event) error {
+    for _, stage := range hg.pipeline {
+        if err := stage.Run(hg, event); err != nil {
+            return &StageError{Stage: stage.Name, Err: err}
+        }
+    }
+    return nil
+}
+
+// Reject events whose fields cannot be trusted
+func validateStage(hg *Hashgraph, event *Event) error {
+    // Every other check and the signature speak for the claimed hash, so it must match the contents
+    if err := checkEventHash(event); err != nil {
+        return err
+    }
+    if hg.creatorPolicy != nil && !hg.creatorPolicy.Allowed(event.Creator) {
+        return errCreatorNotAllowed
+    }
+    // An event is only placed once its parents are, or it would be taken for a first event
+    if err := hg.checkParentsKnown(event); err != nil {
+        return err
+    }
+    // Lamport times count up from the parents, so one cannot be inflated to win tie-breaks
+    if event.LamportTime < 0 || event.LamportTime > hg.parentLamport(event)+hg.lamportSkew {
+        return errLamportTooLarge
+    }
+    // Timestamps increase along each creator's chain, so a creator cannot reuse one to game ordering
+    if selfParent, ok := hg.Events[event.SelfParent]; ok && !event.Timestamp.After(selfParent.Timestamp) {
+        return errTimestampNotIncreasing
+    }
+    // Parents must come before their children in Lamport order
+    for _, p := range hg.parents(event) {
+        if event.LamportTime <= p.LamportTime {
+            return errLamportNotAfterParents
+        }
+    }
+    if err := hg.checkOtherParents(event); err != nil {
+        return err
+    }
+    for _, hash := range event.OtherParents() {
+        if otherParent, ok := hg.Events[hash]; ok && hg.maxDepth > 0 && hg.maxLamport-otherParent.LamportTime > hg.maxDepth {
+            return errOtherParentTooDeep
+        }
+    }
+    return verifyTransactionSignatures(event)
+}
+
+// Keep the first copy, and with it the peer it came from
+func dedupStage(hg *Hashgraph, event *Event) error {
+    if _, ok := hg.Events[event.Hash]; ok {
+        return errDuplicateEvent
+    }
+    return nil
+}
+
+// Store the event if a persister is configured
+func persistStage(hg *Hashgraph, event *Event) error {
+    if hg.persist == nil {
+        return nil
+    }
+    return hg.persist(event)
+}
+
+// Add the event to the graph
+func insertStage(hg *Hashgraph, event *Event) error {
+    // Consensus fields are recomputed locally, never taken from the wire
+    event.Famous = nil
+    event.RoundReceived = 0
+    event.ConsensusTimestamp = time.Time{}
+
+    hg.insertEvent(event)
+    hg.markKnownByPeer(event.ReceivedFrom, event.Hash)
+    if event.ReceivedFrom != "" {
+        hg.receivedCounts[event.ReceivedFrom]++
+    }
+    return nil
+}
+
+// Recompute consensus, collecting events that became final
+func consensusStage(hg *Hashgraph, event *Event) error {
+    hg.finalizedBatch = append(hg.finalizedBatch, hg.scheduleConsensus()...)
+    return nil
+}
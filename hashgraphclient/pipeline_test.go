@@ -0,0 +1,285 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Copy events so a graph's consensus fields never leak into another graph under test
+func copyTestEvents(events []*Event) []*Event {
+    copied := make([]*Event, len(events))
+    for i, event := range events {
+        c := *event
+        copied[i] = &c
+    }
+    return copied
+}
+
+// Build a test graph, failing the test if it cannot be built
+func buildTestGraph(t testing.TB, seed int64, members, events int) []*Event {
+    t.Helper()
+    graph, _, err := BuildTestGraph(seed, members, events)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return graph
+}
+
+// Feed events to a graph in order, failing on the first one not inserted
+func addTestEvents(t testing.TB, hg *Hashgraph, events []*Event) {
+    t.Helper()
+    for _, event := range copyTestEvents(events) {
+        if result, err := hg.AddRemoteEvent(event); result != AddInserted {
+            t.Fatalf("event %s: %v %v", shortID(event.Hash), result, err)
+        }
+    }
+}
+
+// Copy events with a change applied to each, rehashed parents first so parent links follow.
+// Signatures are left stale, as the graph does not verify them.
+func rewriteTestEvents(t testing.TB, events []*Event, change func(*Event)) []*Event {
+    t.Helper()
+    rewritten := copyTestEvents(events)
+    renamed := make(map[string]string, len(events))
+    for _, event := range rewritten {
+        change(event)
+        if hash, ok := renamed[event.SelfParent]; ok {
+            event.SelfParent = hash
+        }
+        if hash, ok := renamed[event.OtherParent]; ok {
+            event.OtherParent = hash
+        }
+        hash, err := hashEvent(event)
+        if err != nil {
+            t.Fatal(err)
+        }
+        renamed[event.Hash] = hash
+        event.Hash = hash
+    }
+    return rewritten
+}
+
+// Expect an add to be rejected at a stage with a given cause
+func expectRejected(t *testing.T, result AddResult, err error, stage string, cause error) {
+    t.Helper()
+    var stageErr *StageError
+    if result != AddRejected || !errors.As(err, &stageErr) || stageErr.Stage != stage || !errors.Is(err, cause) {
+        t.Fatalf("got %v %v, want rejected at %s with %v", result, err, stage, cause)
+    }
+}
+
+func TestValidateRejectsHashMismatch(t *testing.T) {
+    graph := buildTestGraph(t, 1, 4, 20)
+    hg := NewHashgraph(nil, nil)
+    addTestEvents(t, hg, graph[:10])
+
+    tampered := *graph[10]
+    tampered.Transactions = [][]byte{[]byte("forged")}
+    result, err := hg.AddRemoteEvent(&tampered)
+    expectRejected(t, result, err, "validate", errHashMismatch)
+    if _, ok := hg.GetEvent(tampered.Hash); ok {
+        t.Fatal("tampered event was inserted")
+    }
+
+    addTestEvents(t, hg, graph[10:])
+}
+
+func TestHashCheckedBeforeCreatorPolicy(t *testing.T) {
+    graph := buildTestGraph(t, 1, 4, 2)
+    hg := NewHashgraph(nil, nil)
+    hg.SetCreatorPolicy(&CreatorPolicy{deny: map[string]bool{graph[0].Creator: true}})
+
+    tampered := *graph[0]
+    tampered.Timestamp = tampered.Timestamp.Add(1)
+    result, err := hg.AddRemoteEvent(&tampered)
+    expectRejected(t, result, err, "validate", errHashMismatch)
+
+    result, err = hg.AddRemoteEvent(copyTestEvents(graph[:1])[0])
+    expectRejected(t, result, err, "validate", errCreatorNotAllowed)
+}
+
+func TestValidateBoundsLamportTime(t *testing.T) {
+    graph := buildTestGraph(t, 2, 4, 20)
+    hg := NewHashgraph(nil, nil)
+    addTestEvents(t, hg, graph[:10])
+    parentLamport := hg.parentLamport(graph[10])
+
+    ahead := rewriteTestEvents(t, graph[10:11], func(event *Event) {
+        event.LamportTime = parentLamport + defaultLamportSkew + 1
+    })[0]
+    result, err := hg.AddRemoteEvent(ahead)
+    expectRejected(t, result, err, "validate", errLamportTooLarge)
+
+    hg.SetLamportSkew(defaultLamportSkew + 1)
+    if result, err := hg.AddRemoteEvent(ahead); result != AddInserted {
+        t.Fatalf("event within the raised skew: %v %v", result, err)
+    }
+    if hg.maxLamport < ahead.LamportTime {
+        t.Fatalf("local maximum %d not raised to the inserted event", hg.maxLamport)
+    }
+}
+
+func TestLamportTimeBoundedByParents(t *testing.T) {
+    graph := buildTestGraph(t, 3, 4, 40)
+    early := graph[3]
+
+    // A node that has seen far later events still stamps a new event from its parents
+    local := testLocalHashgraph(t, 31)
+    addTestEvents(t, local, graph)
+    event := &Event{Creator: local.CreatorID(), OtherParent: early.Hash, Timestamp: time.Now(), RoomID: defaultRoom}
+    if err := local.AddEvent(event); err != nil {
+        t.Fatal(err)
+    }
+    if event.LamportTime != early.LamportTime+1 || local.maxLamport <= event.LamportTime {
+        t.Fatalf("Lamport time %d, want %d below the local maximum %d", event.LamportTime, early.LamportTime+1, local.maxLamport)
+    }
+
+    // A peer holding only the ancestry accepts it
+    byHash := make(map[string]*Event, len(graph))
+    for _, e := range graph {
+        byHash[e.Hash] = e
+    }
+    peer := NewHashgraph(nil, nil)
+    for _, ancestor := range graph[:4] {
+        if walksTo(byHash, early, ancestor) {
+            addTestEvents(t, peer, []*Event{ancestor})
+        }
+    }
+    if result, err := peer.AddRemoteEvent(copyTestEvents([]*Event{event})[0]); result != AddInserted {
+        t.Fatalf("event from a node ahead of the peer: %v %v", result, err)
+    }
+
+    // The bound follows the parents, not the receiver's maximum
+    inflated := rewriteTestEvents(t, []*Event{graph[len(graph)-1]}, func(e *Event) {
+        e.SelfParent, e.OtherParent, e.LamportTime = "", early.Hash, local.maxLamport
+    })[0]
+    result, err := local.AddRemoteEvent(inflated)
+    expectRejected(t, result, err, "validate", errLamportTooLarge)
+}
+
+func TestValidateRequiresLamportAfterParents(t *testing.T) {
+    graph := buildTestGraph(t, 2, 4, 20)
+    hg := NewHashgraph(nil, nil)
+    addTestEvents(t, hg, graph[:10])
+
+    selfParent, _ := hg.GetEvent(graph[10].SelfParent)
+    stale := rewriteTestEvents(t, graph[10:11], func(event *Event) {
+        event.LamportTime = selfParent.LamportTime
+    })[0]
+    result, err := hg.AddRemoteEvent(stale)
+    expectRejected(t, result, err, "validate", errLamportNotAfterParents)
+    addTestEvents(t, hg, graph[10:])
+}
+
+func TestValidateRequiresIncreasingTimestamps(t *testing.T) {
+    graph := buildTestGraph(t, 2, 4, 20)
+    hg := NewHashgraph(nil, nil)
+    addTestEvents(t, hg, graph[:10])
+
+    selfParent, _ := hg.GetEvent(graph[10].SelfParent)
+    for _, timestamp := range []time.Time{selfParent.Timestamp, selfParent.Timestamp.Add(-time.Second)} {
+        replayed := rewriteTestEvents(t, graph[10:11], func(event *Event) {
+            event.Timestamp = timestamp
+        })[0]
+        result, err := hg.AddRemoteEvent(replayed)
+        expectRejected(t, result, err, "validate", errTimestampNotIncreasing)
+    }
+    addTestEvents(t, hg, graph[10:])
+
+    // A local clock stepping back still yields a later timestamp than the self-parent's
+    local := testLocalHashgraph(t, 24)
+    first, err := local.SubmitTransaction([]byte("one"), "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    local.mutex.Lock()
+    local.Events[first.Hash].Timestamp = time.Now().Add(time.Hour)
+    ahead := local.Events[first.Hash].Timestamp
+    local.mutex.Unlock()
+    second, err := local.SubmitTransaction([]byte("two"), "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !second.Timestamp.After(ahead) {
+        t.Fatalf("local timestamp %v not after self-parent's %v", second.Timestamp, ahead)
+    }
+}
+
+func TestValidateBoundsOtherParentDepth(t *testing.T) {
+    graph := buildTestGraph(t, 4, 4, 40)
+    // Find the event whose other-parent lies furthest behind the frontier when it arrives
+    probe := NewHashgraph(nil, nil)
+    deepest, depth := -1, 0
+    for i, event := range graph {
+        if otherParent, ok := probe.Events[event.OtherParent]; ok && probe.maxLamport-otherParent.LamportTime > depth {
+            deepest, depth = i, probe.maxLamport-otherParent.LamportTime
+        }
+        addTestEvents(t, probe, graph[i:i+1])
+    }
+    if deepest < 0 {
+        t.Fatal("no event references an other-parent behind the frontier")
+    }
+
+    within := NewHashgraph(nil, nil)
+    addTestEvents(t, within, graph[:deepest])
+    within.SetMaxDepth(depth)
+    addTestEvents(t, within, graph[deepest:deepest+1])
+
+    beyond := NewHashgraph(nil, nil)
+    addTestEvents(t, beyond, graph[:deepest])
+    beyond.SetMaxDepth(depth - 1)
+    result, err := beyond.AddRemoteEvent(copyTestEvents(graph[deepest : deepest+1])[0])
+    expectRejected(t, result, err, "validate", errOtherParentTooDeep)
+}
+
+func TestPipelineRunsStagesInOrder(t *testing.T) {
+    hg := NewHashgraph(nil, nil)
+    var names []string
+    for _, stage := range hg.Pipeline() {
+        names = append(names, stage.Name)
+    }
+    if want := []string{"validate", "dedup", "quota", "persist", "insert", "consensus"}; !reflect.DeepEqual(names, want) {
+        t.Fatalf("default pipeline %v, want %v", names, want)
+    }
+
+    // A custom stage after validation stops the event before it is stored or inserted
+    errHeld := errors.New("held for review")
+    var ran []string
+    stages := hg.Pipeline()
+    trace := func(name string, run func(*Hashgraph, *Event) error) Stage {
+        return Stage{Name: name, Run: func(hg *Hashgraph, event *Event) error {
+            ran = append(ran, name)
+            return run(hg, event)
+        }}
+    }
+    review := trace("review", func(hg *Hashgraph, event *Event) error { return errHeld })
+    custom := []Stage{trace(stages[0].Name, stages[0].Run), review}
+    for _, stage := range stages[1:] {
+        custom = append(custom, trace(stage.Name, stage.Run))
+    }
+    hg.SetPipeline(custom)
+
+    event := copyTestEvents(buildTestGraph(t, 161, 2, 1))[0]
+    result, err := hg.AddRemoteEvent(event)
+    expectRejected(t, result, err, "review", errHeld)
+    if !reflect.DeepEqual(ran, []string{"validate", "review"}) {
+        t.Fatalf("stages run %v", ran)
+    }
+    if _, ok := hg.GetEvent(event.Hash); ok {
+        t.Fatal("event inserted past a failing stage")
+    }
+}
+
+func TestPersistFailureKeepsEventOut(t *testing.T) {
+    errDiskFull := errors.New("disk full")
+    hg := NewHashgraph(nil, nil)
+    hg.SetPersister(func(*Event) error { return errDiskFull })
+    event := copyTestEvents(buildTestGraph(t, 162, 2, 1))[0]
+    result, err := hg.AddRemoteEvent(event)
+    expectRejected(t, result, err, "persist", errDiskFull)
+    if hg.EventCount() != 0 {
+        t.Fatal("event inserted though it was not persisted")
+    }
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreatorPolicyReloadChangesValidation(t *testing.T) {
+    graph := buildTestGraph(t, 3, 4, 8)
+    denied := graph[0].Creator
+    path := filepath.Join(t.TempDir(), "policy.json")
+    if err := os.WriteFile(path, []byte(`{"deny":["`+denied+`"]}`), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    policy, err := LoadCreatorPolicy(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    hg := NewHashgraph(nil, nil)
+    hg.SetCreatorPolicy(policy)
+
+    result, err := hg.AddRemoteEvent(copyTestEvents(graph[:1])[0])
+    expectRejected(t, result, err, "validate", errCreatorNotAllowed)
+    if _, ok := hg.GetEvent(graph[0].Hash); ok {
+        t.Fatal("event of a denied creator was inserted")
+    }
+
+    // Lifting the ban lets the creator's events in without restarting the node
+    if err := os.WriteFile(path, []byte(`{"allow":[]}`), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    if err := policy.Reload(); err != nil {
+        t.Fatal(err)
+    }
+    addTestEvents(t, hg, graph)
+}
+
+func TestCreatorPolicyAllowlist(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "policy.json")
+    if err := os.WriteFile(path, []byte(`{"allow":["alice","mallory"],"deny":["mallory"]}`), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    policy, err := LoadCreatorPolicy(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !policy.Allowed("alice") || policy.Allowed("bob") || policy.Allowed("mallory") {
+        t.Fatal("allowlist should admit only alice, with the denylist winning for mallory")
+    }
+
+    if err := os.WriteFile(path, []byte(`{"allow":`), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    if err := policy.Reload(); err == nil {
+        t.Fatal("malformed policy reloaded")
+    }
+    if !policy.Allowed("alice") || policy.Allowed("bob") {
+        t.Fatal("failed reload changed the policy")
+    }
+    if _, err := LoadCreatorPolicy(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, os.ErrNotExist) {
+        t.Fatalf("loading a missing file: %v", err)
+    }
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Presence statuses
+const (
+    presenceOnline  = "online"
+    presenceAway    = "away"
+    presenceOffline = "offline" // sent when a node shuts down, ending its session
+)
+
+// How long a typing indicator is shown without a refresh
+const typingTimeout = 5 * time.Second
+
+// Last known presence of a peer
+type PeerPresence struct {
+    Status     string
+    RoomID     string
+    TypingAt   time.Time // zero if the peer has not been seen typing
+    LastSeenAt time.Time
+}
+
+// In-memory presence and typing state of peers; never hashed, persisted or added to the Hashgraph
+type PresenceTracker struct {
+    peers map[string]*PeerPresence
+    mutex sync.RWMutex
+}
+
+// create new presence tracker
+func NewPresenceTracker() *PresenceTracker {
+    return &PresenceTracker{peers: make(map[string]*PeerPresence)}
+}
+
+// Get or create the entry of a peer, caller holds the lock
+func (pt *PresenceTracker) peer(peerID string) *PeerPresence {
+    p, ok := pt.peers[peerID]
+    if !ok {
+        p = &PeerPresence{}
+        pt.peers[peerID] = p
+    }
+    return p
+}
+
+// Record a presence or typing message from a peer
+func (pt *PresenceTracker) Observe(msg Message, now time.Time) {
+    if msg.SourceNode == "" {
+        return
+    }
+    pt.mutex.Lock()
+    defer pt.mutex.Unlock()
+    p := pt.peer(msg.SourceNode)
+    p.LastSeenAt = now
+    p.RoomID = msg.RoomID
+    switch msg.Type {
+    case "presence":
+        p.Status = msg.Status
+    case "typing":
+        p.TypingAt = now
+    }
+}
+
+// Check whether a peer is typing in a room
+func (pt *PresenceTracker) Typing(peerID, roomID string, now time.Time) bool {
+    pt.mutex.RLock()
+    defer pt.mutex.RUnlock()
+    p, ok := pt.peers[peerID]
+    return ok && p.RoomID == roomID && !p.TypingAt.IsZero() && now.Sub(p.TypingAt) < typingTimeout
+}
+
+// Get the presence of a peer
+func (pt *PresenceTracker) Presence(peerID string) (PeerPresence, bool) {
+    pt.mutex.RLock()
+    defer pt.mutex.RUnlock()
+    p, ok := pt.peers[peerID]
+    if !ok {
+        return PeerPresence{}, false
+    }
+    return *p, true
+}
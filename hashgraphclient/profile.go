@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Longest display name, in runes
+const maxDisplayNameLength = 32
+
+// Encode a profile setting the author's display name; an empty name clears it
+func ProfileTransaction(name string) []byte {
+    data, _ := json.Marshal(ChatTransaction{Type: chatProfile, Name: name})
+    return data
+}
+
+// Trim a display name, rejecting names that are too long or carry control characters
+func normalizeDisplayName(name string) (string, bool) {
+    name = strings.TrimSpace(name)
+    if utf8.RuneCountInString(name) > maxDisplayNameLength {
+        return "", false
+    }
+    for _, r := range name {
+        if unicode.IsControl(r) {
+            return "", false
+        }
+    }
+    return name, true
+}
+
+// Apply a profile, caller holds the lock. Profiles are applied in consensus order, so every node
+// resolves changes the same way: an author's latest name wins, and a name taken by another author
+// moves to the latest claimant, leaving the previous holder unnamed.
+func (cv *ChatView) setName(author, name string) {
+    name, ok := normalizeDisplayName(name)
+    if !ok {
+        return
+    }
+    if previous, ok := cv.names[author]; ok {
+        delete(cv.holders, previous)
+        delete(cv.names, author)
+    }
+    if name != "" {
+        if holder, ok := cv.holders[name]; ok {
+            delete(cv.names, holder)
+            cv.renameMessages(holder, "")
+        }
+        cv.names[author] = name
+        cv.holders[name] = author
+    }
+    cv.renameMessages(author, name)
+}
+
+// Update the author name of retained messages, caller holds the lock
+func (cv *ChatView) renameMessages(author, name string) {
+    for _, message := range cv.messages {
+        if message.Author == author {
+            message.AuthorName = name
+        }
+    }
+}
+
+// Display name of an author, empty when none is set
+func (cv *ChatView) DisplayName(author string) string {
+    cv.mutex.RLock()
+    defer cv.mutex.RUnlock()
+    return cv.names[author]
+}
+
+// Name to show for a message's author: its display name, or its short creator ID
+func (message RenderedMessage) Sender() string {
+    if message.AuthorName != "" {
+        return message.AuthorName
+    }
+    return shortID(message.Author)
+}
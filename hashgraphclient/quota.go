@@ -0,0 +1,28 @@
+package main
+
+import "errors"
+
+// Creator already has its quota of events in the event's round
+var errRoundQuotaExceeded = errors.New("creator exceeded its events-per-round quota")
+
+// set how many events a creator may have in one round, 0 for no limit
+func (hg *Hashgraph) SetRoundQuota(quota int) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.roundQuota = quota
+}
+
+// Reject an event that would take its creator past the per-round quota, so no single
+// member can dominate a round. The created round depends only on the event's ancestry,
+// so every node counts the same events against the same round.
+func quotaStage(hg *Hashgraph, event *Event) error {
+    if hg.roundQuota <= 0 {
+        return nil
+    }
+    round := hg.createdRound(event)
+    if hg.roundCounts[round][event.Creator] >= hg.roundQuota {
+        return errRoundQuotaExceeded
+    }
+    return nil
+}
+
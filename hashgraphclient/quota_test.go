@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRoundQuotaRejectsExcessEvents(t *testing.T) {
+    graph := buildTestGraph(t, 71, 4, 120)
+    members := testCreators(graph)
+    reference := testHashgraph(t, graph, members)
+
+    // Quota one below the busiest creator's count in any round
+    counts := make(map[int]map[string]int)
+    busiest := 0
+    for _, event := range graph {
+        inserted, _ := reference.GetEvent(event.Hash)
+        if counts[inserted.RoundCreated] == nil {
+            counts[inserted.RoundCreated] = make(map[string]int)
+        }
+        counts[inserted.RoundCreated][event.Creator]++
+        if n := counts[inserted.RoundCreated][event.Creator]; n > busiest {
+            busiest = n
+        }
+    }
+    if busiest < 2 {
+        t.Fatal("no creator has several events in a round")
+    }
+    quota := busiest - 1
+
+    hg := NewHashgraph(nil, nil)
+    hg.SetMembers(members)
+    hg.SetRoundQuota(quota)
+    seen := make(map[int]map[string]int)
+    for _, event := range copyTestEvents(graph) {
+        inserted, _ := reference.GetEvent(event.Hash)
+        round := inserted.RoundCreated
+        if seen[round] == nil {
+            seen[round] = make(map[string]int)
+        }
+        seen[round][event.Creator]++
+        result, err := hg.AddRemoteEvent(event)
+        if seen[round][event.Creator] <= quota {
+            if result != AddInserted {
+                t.Fatalf("event %d of its creator in round %d: %v %v", seen[round][event.Creator], round, result, err)
+            }
+            continue
+        }
+        expectRejected(t, result, err, "quota", errRoundQuotaExceeded)
+        return
+    }
+    t.Fatal("no event exceeded the quota")
+}
+
+func TestRoundQuotaHoldsBackLocalEvents(t *testing.T) {
+    hg := testLocalHashgraph(t, 72)
+    hg.SetRoundQuota(2)
+    for i := 0; i < 2; i++ {
+        if _, err := hg.SubmitTransaction([]byte("within quota"), ""); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if _, err := hg.SubmitTransaction([]byte("over quota"), ""); !errors.Is(err, errRoundQuotaExceeded) {
+        t.Fatalf("third event in the round: %v", err)
+    }
+    if hg.EventCount() != 2 {
+        t.Fatalf("%d events in the graph", hg.EventCount())
+    }
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Event has not reached consensus locally
+var errNotFinalized = errors.New("event has not reached consensus")
+
+// Node has no key to sign receipts with
+var errNoSigningKey = errors.New("no private key to sign with")
+
+// Receipt signature does not verify against its signer's key
+var errInvalidReceiptSignature = errors.New("invalid receipt signature")
+
+// Receipt names an event the graph does not hold
+var errUnknownReceiptEvent = errors.New("receipt for unknown event")
+
+// Receipt disagrees with the local consensus for its event
+var errReceiptMismatch = errors.New("receipt does not match local consensus")
+
+// Receipt is for an event finalized too long ago for its signatures to be kept
+var errReceiptExpired = errors.New("receipt for an event beyond the retention window")
+
+// Proof lacks signatures from more than two thirds of the members
+var errProofQuorum = errors.New("consensus proof not signed by a member quorum")
+
+// Default number of rounds behind the latest round received that receipt signatures are kept
+const defaultReceiptRetention = 64
+
+// Statement that an event reached consensus, which members co-sign
+type ConsensusReceipt struct {
+    RoomID             string    `json:"roomId"`
+    EventHash          string    `json:"eventHash"`
+    RoundReceived      int       `json:"roundReceived"`
+    ConsensusTimestamp time.Time `json:"consensusTimestamp"`
+}
+
+// One member's signature over a receipt, as shared with peers
+type ReceiptSignature struct {
+    Receipt   ConsensusReceipt     `json:"receipt"`
+    Signature TransactionSignature `json:"signature"`
+}
+
+// Receipt with the member signatures collected for it, checkable by a light client that
+// knows only the member set, without the graph
+type ConsensusProof struct {
+    Receipt    ConsensusReceipt       `json:"receipt"`
+    Signatures []TransactionSignature `json:"signatures"`
+}
+
+// signing input for a receipt
+func receiptDigest(receipt ConsensusReceipt) []byte {
+    hash := newDomainHash(defaultHasher(), receiptDomain)
+    writeField(hash, []byte(receipt.RoomID))
+    writeField(hash, []byte(receipt.EventHash))
+    binary.Write(hash, binary.BigEndian, int64(receipt.RoundReceived))
+    binary.Write(hash, binary.BigEndian, receipt.ConsensusTimestamp.UnixNano())
+    return hash.Sum(nil)
+}
+
+// Receipt for a finalized event as the local node sees it
+func receiptFor(event *Event) ConsensusReceipt {
+    return ConsensusReceipt{
+        RoomID:             event.RoomID,
+        EventHash:          event.Hash,
+        RoundReceived:      event.RoundReceived,
+        ConsensusTimestamp: event.ConsensusTimestamp.UTC(),
+    }
+}
+
+// Whether two receipts attest the same consensus
+func (r ConsensusReceipt) equal(other ConsensusReceipt) bool {
+    return r.RoomID == other.RoomID && r.EventHash == other.EventHash &&
+        r.RoundReceived == other.RoundReceived && r.ConsensusTimestamp.Equal(other.ConsensusTimestamp)
+}
+
+// Check a signature over a receipt against the key its author ID encodes
+func verifyReceiptSignature(receipt ConsensusReceipt, txSignature TransactionSignature) bool {
+    publicKey, err := publicKeyFromHex(txSignature.Author)
+    if err != nil {
+        return false
+    }
+    signature, err := hex.DecodeString(txSignature.Signature)
+    if err != nil {
+        return false
+    }
+    r, s, ok := decodeSignature(publicKey.Curve, signature)
+    return ok && ecdsa.Verify(publicKey, receiptDigest(receipt), r, s)
+}
+
+// Co-sign the receipt of an event that reached consensus, keeping the signature for proofs
+// and returning it to share with peers, or nil when the node is not a member at the round
+func (hg *Hashgraph) SignReceipt(event *Event) (*ReceiptSignature, error) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    if hg.privateKey == nil {
+        return nil, errNoSigningKey
+    }
+    if event.RoundReceived <= 0 {
+        return nil, errNotFinalized
+    }
+    if !hg.isMemberAt(event.RoundReceived, hg.creatorID) {
+        return nil, nil
+    }
+    receipt := receiptFor(event)
+    r, s, err := ecdsa.Sign(rand.Reader, hg.privateKey, receiptDigest(receipt))
+    if err != nil {
+        return nil, fmt.Errorf("%w: %w", errSigningFailed, err)
+    }
+    signed := &ReceiptSignature{
+        Receipt: receipt,
+        Signature: TransactionSignature{
+            Author:    hg.creatorID,
+            Signature: hex.EncodeToString(encodeSignature(hg.privateKey.Curve, r, s)),
+        },
+    }
+    hg.addReceiptSignature(signed)
+    return signed, nil
+}
+
+// Keep a peer's receipt signature for an event the graph holds. A receipt for an event not
+// yet finalized locally is kept and checked against the local consensus once it is reached.
+func (hg *Hashgraph) AddReceiptSignature(signed *ReceiptSignature) error {
+    if !verifyReceiptSignature(signed.Receipt, signed.Signature) {
+        return errInvalidReceiptSignature
+    }
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    event, ok := hg.Events[signed.Receipt.EventHash]
+    if !ok || event.RoomID != signed.Receipt.RoomID {
+        return errUnknownReceiptEvent
+    }
+    if event.RoundReceived > 0 && !receiptFor(event).equal(signed.Receipt) {
+        return errReceiptMismatch
+    }
+    if hg.receiptExpired(event) {
+        return errReceiptExpired
+    }
+    hg.addReceiptSignature(signed)
+    return nil
+}
+
+// Record a verified receipt signature, one per signer, caller holds the lock
+func (hg *Hashgraph) addReceiptSignature(signed *ReceiptSignature) {
+    hash := signed.Receipt.EventHash
+    if hg.receipts[hash] == nil {
+        hg.receipts[hash] = make(map[string]*ReceiptSignature)
+    }
+    hg.receipts[hash][signed.Signature.Author] = signed
+}
+
+// set how many rounds behind the latest round received receipt signatures are kept, 0 keeps
+// them all. Proofs can only be built for events finalized within the window.
+func (hg *Hashgraph) SetReceiptRetention(rounds int) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.receiptRetention = rounds
+    hg.pruneReceipts()
+}
+
+// Whether an event was finalized before the retention window, caller holds the lock
+func (hg *Hashgraph) receiptExpired(event *Event) bool {
+    return hg.receiptRetention > 0 && event.RoundReceived > 0 &&
+        event.RoundReceived < hg.lastReceivedRound-hg.receiptRetention
+}
+
+// Drop the receipt signatures of events finalized before the retention window, caller holds the lock
+func (hg *Hashgraph) pruneReceipts() {
+    for hash := range hg.receipts {
+        if event, ok := hg.Events[hash]; !ok || hg.receiptExpired(event) {
+            delete(hg.receipts, hash)
+        }
+    }
+}
+
+// Assemble a proof that an event reached consensus from the collected signatures that
+// agree with the local consensus, sorted by signer so the proof is deterministic
+func (hg *Hashgraph) BuildConsensusProof(hash string) (*ConsensusProof, error) {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    event, ok := hg.Events[hash]
+    if !ok || event.RoundReceived <= 0 {
+        return nil, errNotFinalized
+    }
+    proof := &ConsensusProof{Receipt: receiptFor(event), Signatures: []TransactionSignature{}}
+    for _, signed := range hg.receipts[hash] {
+        if signed.Receipt.equal(proof.Receipt) {
+            proof.Signatures = append(proof.Signatures, signed.Signature)
+        }
+    }
+    sort.Slice(proof.Signatures, func(i, j int) bool {
+        return proof.Signatures[i].Author < proof.Signatures[j].Author
+    })
+    return proof, nil
+}
+
+// Members a proof for a round is checked against, the members in force at the round
+func (hg *Hashgraph) ProofMembers(round int) []string {
+    return hg.MembersAt(round)
+}
+
+// Check that more than two thirds of the members, each counted once, signed the proof's
+// receipt. Signatures from non-members or that do not verify count for nothing.
+func VerifyConsensusProof(proof *ConsensusProof, members []string) error {
+    memberSet := make(map[string]bool, len(members))
+    for _, member := range members {
+        memberSet[member] = true
+    }
+    signers := make(map[string]bool)
+    for _, txSignature := range proof.Signatures {
+        if !memberSet[txSignature.Author] || signers[txSignature.Author] {
+            continue
+        }
+        if verifyReceiptSignature(proof.Receipt, txSignature) {
+            signers[txSignature.Author] = true
+        }
+    }
+    if len(memberSet) == 0 || 3*len(signers) <= 2*len(memberSet) {
+        return errProofQuorum
+    }
+    return nil
+}
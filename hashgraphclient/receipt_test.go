@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// Member's signature over the receipt of an event
+func testReceiptSignature(t *testing.T, event *Event, key *ecdsa.PrivateKey) *ReceiptSignature {
+    t.Helper()
+    receipt := receiptFor(event)
+    r, s, err := ecdsa.Sign(rand.Reader, key, receiptDigest(receipt))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return &ReceiptSignature{
+        Receipt: receipt,
+        Signature: TransactionSignature{
+            Author:    PublicKeyHex(&key.PublicKey),
+            Signature: hex.EncodeToString(encodeSignature(key.Curve, r, s)),
+        },
+    }
+}
+
+func lastFinalized(t *testing.T, hg *Hashgraph) *Event {
+    t.Helper()
+    order := orderHashes(hg)
+    if len(order) == 0 {
+        t.Fatal("nothing reached consensus")
+    }
+    event, _ := hg.GetEvent(order[len(order)-1])
+    return event
+}
+
+func TestConsensusProofNeedsMemberQuorum(t *testing.T) {
+    graph, keys, err := BuildTestGraph(61, 4, 200)
+    if err != nil {
+        t.Fatal(err)
+    }
+    members := testCreators(graph)
+    hg := testHashgraph(t, graph, members)
+    event := lastFinalized(t, hg)
+
+    for _, key := range keys[:2] {
+        if err := hg.AddReceiptSignature(testReceiptSignature(t, event, key)); err != nil {
+            t.Fatal(err)
+        }
+    }
+    proof, err := hg.BuildConsensusProof(event.Hash)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := VerifyConsensusProof(proof, members); !errors.Is(err, errProofQuorum) {
+        t.Fatalf("two of four signatures: %v", err)
+    }
+
+    if err := hg.AddReceiptSignature(testReceiptSignature(t, event, keys[2])); err != nil {
+        t.Fatal(err)
+    }
+    proof, _ = hg.BuildConsensusProof(event.Hash)
+    if err := VerifyConsensusProof(proof, members); err != nil {
+        t.Fatalf("three of four signatures: %v", err)
+    }
+
+    // A receipt disagreeing with the local consensus is refused, and a proof whose receipt was changed fails
+    wrong := *event
+    wrong.RoundReceived++
+    if err := hg.AddReceiptSignature(testReceiptSignature(t, &wrong, keys[3])); !errors.Is(err, errReceiptMismatch) {
+        t.Fatalf("mismatched receipt: %v", err)
+    }
+    proof.Receipt.RoundReceived++
+    if err := VerifyConsensusProof(proof, members); !errors.Is(err, errProofQuorum) {
+        t.Fatalf("altered proof: %v", err)
+    }
+}
+
+func TestReceiptsPrunedBeyondRetention(t *testing.T) {
+    graph, keys, err := BuildTestGraph(62, 4, 400)
+    if err != nil {
+        t.Fatal(err)
+    }
+    hg := NewHashgraph(nil, nil)
+    hg.SetMembers(testCreators(graph))
+    hg.SetReceiptRetention(2)
+    addTestEvents(t, hg, graph[:100])
+    event := lastFinalized(t, hg)
+    if err := hg.AddReceiptSignature(testReceiptSignature(t, event, keys[0])); err != nil {
+        t.Fatal(err)
+    }
+
+    addTestEvents(t, hg, graph[100:])
+    if hg.LastFinalizedRound() <= event.RoundReceived+2 {
+        t.Fatal("consensus did not move past the retention window")
+    }
+    hg.mutex.RLock()
+    _, kept := hg.receipts[event.Hash]
+    hg.mutex.RUnlock()
+    if kept {
+        t.Fatal("receipt kept beyond the retention window")
+    }
+    if err := hg.AddReceiptSignature(testReceiptSignature(t, event, keys[1])); !errors.Is(err, errReceiptExpired) {
+        t.Fatalf("receipt beyond the window: %v", err)
+    }
+
+    if err := hg.AddReceiptSignature(testReceiptSignature(t, lastFinalized(t, hg), keys[1])); err != nil {
+        t.Fatalf("receipt within the window: %v", err)
+    }
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"sort"
+)
+
+// Event hash does not match its contents
+var errHashMismatch = errors.New("event hash does not match contents")
+
+// Check that an event's hash matches its contents
+func checkEventHash(event *Event) error {
+    hash, err := hashEvent(event)
+    if err != nil {
+        return err
+    }
+    if hash != event.Hash {
+        return errHashMismatch
+    }
+    return nil
+}
+
+// Check that an event's hash matches its contents and that its creator signed it
+func verifyEventIntegrity(event *Event) error {
+    if err := checkEventHash(event); err != nil {
+        return err
+    }
+    publicKey, err := publicKeyFromHex(event.Creator)
+    if err != nil {
+        return err
+    }
+    if !verifyEventSignature(event, publicKey) {
+        return errInvalidSignature
+    }
+    return nil
+}
+
+// Ingest events into a fresh Hashgraph, validating each and recomputing consensus,
+// so a node can check another node's claimed state independently. Members is the
+// initial member set; nil takes every creator in events.
+func Replay(events []*Event, members []string) (*Hashgraph, error) {
+    hg := NewHashgraph(nil, nil)
+    if members == nil {
+        creators := make(map[string]bool)
+        for _, event := range events {
+            if !creators[event.Creator] {
+                creators[event.Creator] = true
+                members = append(members, event.Creator)
+            }
+        }
+    }
+    hg.epochs = initialEpochs(members)
+
+    ordered := make([]*Event, len(events))
+    for i, event := range events {
+        copied := *event
+        ordered[i] = &copied
+    }
+    sort.Slice(ordered, func(i, j int) bool {
+        if ordered[i].LamportTime != ordered[j].LamportTime {
+            return ordered[i].LamportTime < ordered[j].LamportTime
+        }
+        return ordered[i].Hash < ordered[j].Hash
+    })
+
+    for _, event := range ordered {
+        if err := verifyEventIntegrity(event); err != nil {
+            return nil, err
+        }
+        if hg.roomID == "" {
+            hg.roomID = event.RoomID
+        }
+        err := hg.runPipeline(event)
+        hg.finalizedBatch = nil
+        if err != nil && !errors.Is(err, errDuplicateEvent) {
+            return nil, err
+        }
+    }
+    return hg, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReplayReproducesConsensus(t *testing.T) {
+    graph := buildTestGraph(t, 31, 4, 250)
+    members := testCreators(graph)
+    original := testHashgraph(t, graph, members)
+
+    // Export the graph as another node would hand it over, consensus fields included
+    exported := make([]*Event, 0, len(graph))
+    for _, event := range shuffledTopological(graph, 8) {
+        held, _ := original.GetEvent(event.Hash)
+        exported = append(exported, held)
+    }
+    data, err := json.Marshal(exported)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var received []*Event
+    if err := json.Unmarshal(data, &received); err != nil {
+        t.Fatal(err)
+    }
+
+    replayed, err := Replay(received, members)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if replayed.EventCount() != len(graph) {
+        t.Fatalf("replayed %d of %d events", replayed.EventCount(), len(graph))
+    }
+    want := orderHashes(original)
+    if got := orderHashes(replayed); len(want) == 0 || !reflect.DeepEqual(got, want) {
+        t.Fatalf("replay ordered %d events, original %d, or in another order", len(got), len(want))
+    }
+    if root := replayed.StateRoot(0); root != original.StateRoot(0) {
+        t.Fatalf("replayed state root %q, want %q", root, original.StateRoot(0))
+    }
+
+    // A tampered event makes the claimed state fail verification
+    received[len(received)/2].Transactions = [][]byte{[]byte("forged")}
+    if _, err := Replay(received, members); !errors.Is(err, errHashMismatch) {
+        t.Fatalf("replaying a tampered event: %v", err)
+    }
+}
+
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+)
+
+// Event counts of a graph
+type EventCounts struct {
+    Created   int `json:"created"`
+    Received  int `json:"received"`
+    Finalized int `json:"finalized"`
+    Pending   int `json:"pending"`
+}
+
+// Count the graph's events by origin and consensus state
+func (hg *Hashgraph) Counts() EventCounts {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    var counts EventCounts
+    for _, event := range hg.Events {
+        if event.Creator == hg.creatorID {
+            counts.Created++
+        } else {
+            counts.Received++
+        }
+    }
+    counts.Finalized = len(hg.ConsensusOrder)
+    counts.Pending = len(hg.Events) - counts.Finalized
+    return counts
+}
+
+// Rooms currently joined
+func (rm *RoomManager) Rooms() map[string]*Hashgraph {
+    rm.mutex.RLock()
+    defer rm.mutex.RUnlock()
+    rooms := make(map[string]*Hashgraph, len(rm.rooms))
+    for id, hg := range rm.rooms {
+        rooms[id] = hg
+    }
+    return rooms
+}
+
+// Summary of a session, emitted on shutdown
+type ShutdownReport struct {
+    EventCounts
+    DeadLettered int                    `json:"deadLettered"`
+    Uptime       string                 `json:"uptime"`
+    Rooms        map[string]EventCounts `json:"rooms"`
+}
+
+// Build the shutdown report across all joined rooms
+func buildShutdownReport(rooms *RoomManager, deadLetters *DeadLetterStore, startedAt time.Time) ShutdownReport {
+    report := ShutdownReport{
+        DeadLettered: deadLetters.Len(),
+        Uptime:       time.Since(startedAt).Round(time.Second).String(),
+        Rooms:        make(map[string]EventCounts),
+    }
+    for id, hg := range rooms.Rooms() {
+        counts := hg.Counts()
+        report.Rooms[id] = counts
+        report.Created += counts.Created
+        report.Received += counts.Received
+        report.Finalized += counts.Finalized
+        report.Pending += counts.Pending
+    }
+    return report
+}
+
+// Log the shutdown report and write it to a file if a path is given
+func emitShutdownReport(report ShutdownReport, path string) {
+    log.Printf("Shutdown report: created=%d received=%d finalized=%d pending=%d dead-lettered=%d uptime=%s",
+        report.Created, report.Received, report.Finalized, report.Pending, report.DeadLettered, report.Uptime)
+    if path == "" {
+        return
+    }
+    data, err := json.MarshalIndent(report, "", "  ")
+    if err != nil {
+        log.Println("Failed to encode shutdown report:", err)
+        return
+    }
+    if err := os.WriteFile(path, data, 0644); err != nil {
+        log.Println("Failed to write shutdown report:", err)
+    }
+}
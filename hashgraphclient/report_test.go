@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShutdownReportCounts(t *testing.T) {
+    graph, keys, err := BuildTestGraph(15, 4, 200)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // The lobby node is one of the graph's members, so its own events count as created
+    lobby := NewHashgraph(keys[0], &keys[0].PublicKey)
+    lobby.SetMembers(testCreators(graph))
+    addTestEvents(t, lobby, graph)
+    own := 0
+    for _, event := range graph {
+        if event.Creator == lobby.creatorID {
+            own++
+        }
+    }
+
+    side := testLocalHashgraph(t, 16)
+    for _, text := range []string{"one", "two", "three"} {
+        if _, err := side.SubmitTransaction([]byte(text), ""); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    rooms := NewRoomManager(nil, nil)
+    rooms.rooms["lobby"] = lobby
+    rooms.rooms["side"] = side
+    deadLetters := NewDeadLetterStore(defaultDeadLetterCapacity, 1)
+    deadLetters.Add(graph[0], errAckTimeout, "outbox")
+    deadLetters.Add(graph[1], errAckTimeout, "outbox")
+
+    report := buildShutdownReport(rooms, deadLetters, time.Now().Add(-90*time.Second))
+    finalized := len(orderHashes(lobby))
+    if finalized == 0 {
+        t.Fatal("nothing finalized in the lobby")
+    }
+    wantLobby := EventCounts{Created: own, Received: len(graph) - own, Finalized: finalized, Pending: len(graph) - finalized}
+    if report.Rooms["lobby"] != wantLobby {
+        t.Fatalf("lobby counts %+v, want %+v", report.Rooms["lobby"], wantLobby)
+    }
+    if wantSide := (EventCounts{Created: 3, Pending: 3}); report.Rooms["side"] != wantSide {
+        t.Fatalf("side counts %+v, want %+v", report.Rooms["side"], wantSide)
+    }
+    want := EventCounts{Created: own + 3, Received: len(graph) - own, Finalized: finalized, Pending: len(graph) - finalized + 3}
+    if report.EventCounts != want || report.DeadLettered != 2 || report.Uptime != "1m30s" {
+        t.Fatalf("report %+v, want totals %+v, 2 dead letters and 1m30s uptime", report, want)
+    }
+
+    path := filepath.Join(t.TempDir(), "report.json")
+    emitShutdownReport(report, path)
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var written ShutdownReport
+    if err := json.Unmarshal(data, &written); err != nil {
+        t.Fatal(err)
+    }
+    if written.EventCounts != want || written.Rooms["lobby"] != wantLobby {
+        t.Fatalf("written report %+v", written)
+    }
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// Creator's key was revoked before the event's round
+var errCreatorRevoked = errors.New("creator key revoked")
+
+// Revocation lacks signatures from enough admins
+var errRevocationQuorum = errors.New("revocation not signed by an admin quorum")
+
+// Transaction tombstoning a leaked creator key: once it reaches consensus, events the key
+// created after AfterRound are left out of the consensus order, while its earlier history stays valid
+type Revocation struct {
+    Type       string                 `json:"type"` // always chatRevoke
+    Creator    string                 `json:"creator"`
+    AfterRound int                    `json:"afterRound"`
+    Signatures []TransactionSignature `json:"signatures"`
+}
+
+// signing input for a revocation
+func revocationDigest(creator string, afterRound int) []byte {
+    hash := newDomainHash(defaultHasher(), revokeDomain)
+    writeField(hash, []byte(creator))
+    binary.Write(hash, binary.BigEndian, int64(afterRound))
+    return hash.Sum(nil)
+}
+
+// Sign a revocation as one of the admins
+func SignRevocation(creator string, afterRound int, privateKey *ecdsa.PrivateKey) (TransactionSignature, error) {
+    r, s, err := ecdsa.Sign(rand.Reader, privateKey, revocationDigest(creator, afterRound))
+    if err != nil {
+        return TransactionSignature{}, err
+    }
+    return TransactionSignature{
+        Author:    PublicKeyHex(&privateKey.PublicKey),
+        Signature: hex.EncodeToString(encodeSignature(privateKey.Curve, r, s)),
+    }, nil
+}
+
+// Read a revocation with its collected admin signatures from a JSON file
+func LoadRevocation(path string) (*Revocation, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var revocation Revocation
+    if err := json.Unmarshal(data, &revocation); err != nil {
+        return nil, err
+    }
+    revocation.Type = chatRevoke
+    return &revocation, nil
+}
+
+// Encode a revocation as a transaction
+func (rv *Revocation) Transaction() []byte {
+    data, _ := json.Marshal(rv)
+    return data
+}
+
+// Decode a transaction as a revocation, if it is one
+func decodeRevocation(tx []byte) (*Revocation, bool) {
+    var revocation Revocation
+    if err := json.Unmarshal(tx, &revocation); err != nil || revocation.Type != chatRevoke {
+        return nil, false
+    }
+    return &revocation, true
+}
+
+// Check that distinct admins, at least quorum of them, signed the revocation
+func (rv *Revocation) Verify(admins map[string]bool, quorum int) error {
+    if quorum <= 0 {
+        return errRevocationQuorum
+    }
+    digest := revocationDigest(rv.Creator, rv.AfterRound)
+    signers := make(map[string]bool)
+    for _, txSignature := range rv.Signatures {
+        if !admins[txSignature.Author] || signers[txSignature.Author] {
+            continue
+        }
+        publicKey, err := publicKeyFromHex(txSignature.Author)
+        if err != nil {
+            continue
+        }
+        signature, err := hex.DecodeString(txSignature.Signature)
+        if err != nil {
+            continue
+        }
+        r, s, ok := decodeSignature(publicKey.Curve, signature)
+        if ok && ecdsa.Verify(publicKey, digest, r, s) {
+            signers[txSignature.Author] = true
+        }
+    }
+    if len(signers) < quorum {
+        return errRevocationQuorum
+    }
+    return nil
+}
+
+// set the admins allowed to revoke keys, and how many of them must sign
+func (hg *Hashgraph) SetRevocationAdmins(admins []string, quorum int) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.revocationAdmins = make(map[string]bool, len(admins))
+    for _, admin := range admins {
+        hg.revocationAdmins[admin] = true
+    }
+    hg.revocationQuorum = quorum
+}
+
+// Apply the valid revocations in events received in consensus order, returning them without
+// the events their creators made after a cutoff already applied. Every node reaches the same
+// revocations at the same place in the order, so all drop the same events. Caller holds the lock.
+func (hg *Hashgraph) applyRevocations(received []*Event) []*Event {
+    kept := received[:0]
+    for _, event := range received {
+        if after, ok := hg.revoked[event.Creator]; ok && event.RoundCreated > after {
+            continue
+        }
+        kept = append(kept, event)
+        if len(hg.revocationAdmins) == 0 {
+            continue
+        }
+        for _, tx := range event.Transactions {
+            revocation, ok := decodeRevocation(tx)
+            if !ok || revocation.Verify(hg.revocationAdmins, hg.revocationQuorum) != nil {
+                continue
+            }
+            // The earliest cutoff wins if a key is revoked twice
+            if after, revoked := hg.revoked[revocation.Creator]; !revoked || revocation.AfterRound < after {
+                hg.revoked[revocation.Creator] = revocation.AfterRound
+                // Signatures verified under the key no longer vouch for anything it sends
+                if hg.signatures != nil {
+                    hg.signatures.InvalidateCreator(revocation.Creator)
+                }
+            }
+        }
+    }
+    return kept
+}
+
+// Refuse to create an event once the local key is revoked past its round, caller holds the lock
+func (hg *Hashgraph) checkRevoked(event *Event) error {
+    after, ok := hg.revoked[event.Creator]
+    if !ok {
+        return nil
+    }
+    if hg.createdRound(event) > after {
+        return errCreatorRevoked
+    }
+    return nil
+}
+
+// Creators whose keys are revoked, with their cutoff rounds
+func (hg *Hashgraph) Revoked() map[string]int {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    revoked := make(map[string]int, len(hg.revoked))
+    for creator, after := range hg.revoked {
+        revoked[creator] = after
+    }
+    return revoked
+}
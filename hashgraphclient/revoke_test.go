@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCreatedRoundLeavesEventUntouched(t *testing.T) {
+    graph := buildTestGraph(t, 71, 4, 120)
+    hg := testHashgraph(t, graph[:119], testCreators(graph))
+    event := copyTestEvents(graph[119:])[0]
+    if round := hg.createdRound(event); round < 2 {
+        t.Fatalf("created round %d", round)
+    }
+    if event.RoundCreated != 0 {
+        t.Fatal("looking up the created round changed the event")
+    }
+}
+
+func TestRevocationAppliedInConsensusOrder(t *testing.T) {
+    admins := seededKeys(72, 2)
+    hg := NewHashgraph(nil, nil)
+    hg.SetRevocationAdmins([]string{PublicKeyHex(&admins[0].PublicKey), PublicKeyHex(&admins[1].PublicKey)}, 2)
+
+    revocation := &Revocation{Type: chatRevoke, Creator: "leaked", AfterRound: 3}
+    for _, admin := range admins {
+        signature, err := SignRevocation(revocation.Creator, revocation.AfterRound, admin)
+        if err != nil {
+            t.Fatal(err)
+        }
+        revocation.Signatures = append(revocation.Signatures, signature)
+    }
+    received := []*Event{
+        {Hash: "before", Creator: "leaked", RoundCreated: 5},
+        {Hash: "revoke", Creator: "admin", RoundCreated: 4, Transactions: [][]byte{revocation.Transaction()}},
+        {Hash: "early", Creator: "leaked", RoundCreated: 3},
+        {Hash: "late", Creator: "leaked", RoundCreated: 4},
+        {Hash: "other", Creator: "honest", RoundCreated: 6},
+    }
+
+    var kept []string
+    for _, event := range hg.applyRevocations(received) {
+        kept = append(kept, event.Hash)
+    }
+    // Events ordered before the revocation stand; after it, only those up to the cutoff round do
+    want := []string{"before", "revoke", "early", "other"}
+    if len(kept) != len(want) {
+        t.Fatalf("kept %v, want %v", kept, want)
+    }
+    for i := range want {
+        if kept[i] != want[i] {
+            t.Fatalf("kept %v, want %v", kept, want)
+        }
+    }
+    if hg.Revoked()["leaked"] != 3 {
+        t.Fatal("revocation not recorded")
+    }
+
+    // Too few admin signatures revoke nothing
+    hg = NewHashgraph(nil, nil)
+    hg.SetRevocationAdmins([]string{PublicKeyHex(&admins[0].PublicKey), PublicKeyHex(&admins[1].PublicKey)}, 2)
+    revocation.Signatures = revocation.Signatures[:1]
+    hg.applyRevocations([]*Event{{Hash: "revoke", Transactions: [][]byte{revocation.Transaction()}}})
+    if len(hg.Revoked()) != 0 {
+        t.Fatal("revocation below the admin quorum applied")
+    }
+}
+
+// Whether a revoked creator's events are inserted does not depend on when the revocation arrived:
+// all are inserted, and those after the cutoff are left out of the consensus order
+func TestRevokedCreatorEventsLeftOutOfOrder(t *testing.T) {
+    graph := buildTestGraph(t, 73, 4, 300)
+    members := testCreators(graph)
+    leaked := graph[0].Creator
+
+    hg := NewHashgraph(nil, nil)
+    hg.SetMembers(members)
+    hg.revoked[leaked] = 2
+    addTestEvents(t, hg, graph)
+
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+    kept := 0
+    for _, event := range hg.ConsensusOrder {
+        if event.Creator != leaked {
+            continue
+        }
+        if event.RoundCreated > 2 {
+            t.Fatalf("event %s of round %d after the cutoff ordered", shortID(event.Hash), event.RoundCreated)
+        }
+        kept++
+    }
+    if kept == 0 {
+        t.Fatal("history before the cutoff left out")
+    }
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"log"
+	"sync"
+)
+
+// Room the client joins on startup
+const defaultRoom = "lobby"
+
+// Event for a room the node has not joined
+var errUnknownRoom = errors.New("unknown room")
+
+// Unknown room policy name not recognized
+var errUnknownRoomPolicy = errors.New("unknown room policy")
+
+// Peers sent events for more unjoined rooms than the node will buffer or auto-join
+var errTooManyRooms = errors.New("too many unjoined rooms")
+
+// Posting would create a room the create policy does not allow
+var errRoomCreateDenied = errors.New("room does not exist and may not be created")
+
+// What to do with events for rooms the node has not joined
+type UnknownRoomPolicy string
+
+const (
+    UnknownRoomIgnore   UnknownRoomPolicy = "ignore"    // reject the event
+    UnknownRoomBuffer   UnknownRoomPolicy = "buffer"    // hold it until the room is joined
+    UnknownRoomAutoJoin UnknownRoomPolicy = "auto-join" // join the room and add it
+)
+
+// Events held per unjoined room under the buffer policy, oldest dropped first
+const defaultRoomBufferSize = 256
+
+// Default number of rooms peers may have buffered or auto-joined, as any peer can name new ones
+const defaultMaxUnknownRooms = 16
+
+// Parse an unknown room policy name
+func parseUnknownRoomPolicy(name string) (UnknownRoomPolicy, error) {
+    switch policy := UnknownRoomPolicy(name); policy {
+    case UnknownRoomIgnore, UnknownRoomBuffer, UnknownRoomAutoJoin:
+        return policy, nil
+    }
+    return "", errUnknownRoomPolicy
+}
+
+// Which rooms posting may create when they do not exist yet
+type RoomCreatePolicy struct {
+    Permissive bool            // create any room that is not denied, otherwise only allowed ones
+    Allow      map[string]bool
+    Deny       map[string]bool
+}
+
+// Build a room create policy from its mode name, strict or permissive, and room lists
+func NewRoomCreatePolicy(mode string, allow, deny []string) (*RoomCreatePolicy, error) {
+    policy := &RoomCreatePolicy{Allow: make(map[string]bool), Deny: make(map[string]bool)}
+    switch mode {
+    case "strict":
+    case "permissive":
+        policy.Permissive = true
+    default:
+        return nil, errUnknownRoomPolicy
+    }
+    for _, roomID := range allow {
+        policy.Allow[roomID] = true
+    }
+    for _, roomID := range deny {
+        policy.Deny[roomID] = true
+    }
+    return policy, nil
+}
+
+// Check whether posting may create a room
+func (p *RoomCreatePolicy) Allowed(roomID string) bool {
+    if p == nil || roomID == "" || p.Deny[roomID] {
+        return false
+    }
+    return p.Permissive || p.Allow[roomID]
+}
+
+// Room manager, one Hashgraph consensus instance per room
+type RoomManager struct {
+    rooms       map[string]*Hashgraph
+    subscribers map[string][]func(*Event)
+    configure   []func(*Hashgraph)
+    unknownRoom UnknownRoomPolicy
+    createRoom  *RoomCreatePolicy
+    buffered    map[string][]*Event
+    autoJoined  map[string]bool
+    maxUnknown  int
+    privateKey  *ecdsa.PrivateKey
+    publicKey   *ecdsa.PublicKey
+    mutex       sync.RWMutex
+}
+
+// create new room manager
+func NewRoomManager(privateKey *ecdsa.PrivateKey, publicKey *ecdsa.PublicKey) *RoomManager {
+    return &RoomManager{
+        rooms:       make(map[string]*Hashgraph),
+        subscribers: make(map[string][]func(*Event)),
+        unknownRoom: UnknownRoomIgnore,
+        buffered:    make(map[string][]*Event),
+        autoJoined:  make(map[string]bool),
+        maxUnknown:  defaultMaxUnknownRooms,
+        privateKey:  privateKey,
+        publicKey:   publicKey,
+    }
+}
+
+// Join a room, creating its Hashgraph if needed and adding any events buffered for it
+func (rm *RoomManager) Join(roomID string) *Hashgraph {
+    rm.mutex.Lock()
+    if hg, ok := rm.rooms[roomID]; ok {
+        rm.mutex.Unlock()
+        return hg
+    }
+    hg := NewHashgraph(rm.privateKey, rm.publicKey)
+    hg.roomID = roomID
+    for _, fn := range rm.configure {
+        fn(hg)
+    }
+    hg.OnFinalized(func(event *Event) {
+        rm.publish(roomID, event)
+    })
+    rm.rooms[roomID] = hg
+    buffered := rm.buffered[roomID]
+    delete(rm.buffered, roomID)
+    rm.mutex.Unlock()
+
+    // Added without the manager lock, as finalized events are published through it
+    for _, event := range buffered {
+        if _, err := hg.AddRemoteEvent(event); err != nil {
+            log.Printf("[%s] Failed to add buffered event: %v", roomID, err)
+        }
+    }
+    return hg
+}
+
+// Set the policy for events from rooms the node has not joined
+func (rm *RoomManager) SetUnknownRoomPolicy(policy UnknownRoomPolicy) {
+    rm.mutex.Lock()
+    defer rm.mutex.Unlock()
+    rm.unknownRoom = policy
+}
+
+// Set how many rooms events from peers may have buffered, or auto-joined, at once
+func (rm *RoomManager) SetMaxUnknownRooms(max int) {
+    rm.mutex.Lock()
+    defer rm.mutex.Unlock()
+    rm.maxUnknown = max
+}
+
+// Set which rooms posting creates, nil to require joining first
+func (rm *RoomManager) SetRoomCreatePolicy(policy *RoomCreatePolicy) {
+    rm.mutex.Lock()
+    defer rm.mutex.Unlock()
+    rm.createRoom = policy
+}
+
+// Post a transaction to a room, creating the room first if it does not exist and the create policy allows it
+func (rm *RoomManager) Post(roomID string, tx []byte) (*Event, error) {
+    rm.mutex.RLock()
+    hg, ok := rm.rooms[roomID]
+    policy := rm.createRoom
+    rm.mutex.RUnlock()
+
+    if !ok {
+        if !policy.Allowed(roomID) {
+            return nil, errRoomCreateDenied
+        }
+        hg = rm.Join(roomID)
+        log.Printf("[%s] Created room on first message", roomID)
+    }
+    return hg.SubmitTransaction(tx, "")
+}
+
+// Hold an event for an unjoined room, refusing a new room once the cap is reached; caller holds the lock
+func (rm *RoomManager) buffer(event *Event) error {
+    if _, ok := rm.buffered[event.RoomID]; !ok && len(rm.buffered) >= rm.maxUnknown {
+        return errTooManyRooms
+    }
+    events := append(rm.buffered[event.RoomID], event)
+    if len(events) > defaultRoomBufferSize {
+        events = events[len(events)-defaultRoomBufferSize:]
+    }
+    rm.buffered[event.RoomID] = events
+    return nil
+}
+
+// Apply a setting to every joined room and to rooms joined later
+func (rm *RoomManager) Configure(fn func(*Hashgraph)) {
+    rm.mutex.Lock()
+    defer rm.mutex.Unlock()
+    rm.configure = append(rm.configure, fn)
+    for _, hg := range rm.rooms {
+        fn(hg)
+    }
+}
+
+// Get the Hashgraph of a joined room
+func (rm *RoomManager) Room(roomID string) (*Hashgraph, bool) {
+    rm.mutex.RLock()
+    defer rm.mutex.RUnlock()
+    hg, ok := rm.rooms[roomID]
+    return hg, ok
+}
+
+// Subscribe to the finalized events of a room
+func (rm *RoomManager) SubscribeRoom(roomID string, cb func(*Event)) {
+    rm.mutex.Lock()
+    defer rm.mutex.Unlock()
+    rm.subscribers[roomID] = append(rm.subscribers[roomID], cb)
+}
+
+// Pass a finalized event to the room's subscribers
+func (rm *RoomManager) publish(roomID string, event *Event) {
+    rm.mutex.RLock()
+    subscribers := rm.subscribers[roomID]
+    rm.mutex.RUnlock()
+    for _, cb := range subscribers {
+        cb(event)
+    }
+}
+
+// Route an event received from a peer to its room, applying the unknown room policy if not joined
+func (rm *RoomManager) AddRemoteEvent(event *Event) (AddResult, error) {
+    rm.mutex.Lock()
+    hg, ok := rm.rooms[event.RoomID]
+    policy := rm.unknownRoom
+    var err error
+    if !ok {
+        switch policy {
+        case UnknownRoomBuffer:
+            err = rm.buffer(event)
+        case UnknownRoomAutoJoin:
+            // Rooms are claimed under the lock, so concurrent events cannot overshoot the cap
+            if !rm.autoJoined[event.RoomID] && len(rm.autoJoined) >= rm.maxUnknown {
+                err = errTooManyRooms
+            } else {
+                rm.autoJoined[event.RoomID] = true
+            }
+        default:
+            err = errUnknownRoom
+        }
+    }
+    rm.mutex.Unlock()
+
+    if err != nil {
+        return AddRejected, err
+    }
+    if !ok {
+        if policy == UnknownRoomBuffer {
+            return AddBuffered, nil
+        }
+        hg = rm.Join(event.RoomID)
+    }
+    return hg.AddRemoteEvent(event)
+}
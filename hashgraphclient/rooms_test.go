@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// A room manager whose rooms all take the members of a test graph
+func testRoomManager(members []string) *RoomManager {
+    rm := NewRoomManager(nil, nil)
+    rm.Configure(func(hg *Hashgraph) {
+        hg.SetMembers(members)
+    })
+    return rm
+}
+
+func TestSubscribeRoomGetsOnlyItsRoom(t *testing.T) {
+    graph := buildTestGraph(t, 91, 4, 150)
+    members := testCreators(graph)
+    other := rewriteTestEvents(t, graph, func(event *Event) { event.RoomID = "other" })
+
+    rm := testRoomManager(members)
+    rm.Join(defaultRoom)
+    rm.Join("other")
+    var lobbyEvents, otherEvents []string
+    rm.SubscribeRoom(defaultRoom, func(event *Event) { lobbyEvents = append(lobbyEvents, event.Hash) })
+    rm.SubscribeRoom("other", func(event *Event) { otherEvents = append(otherEvents, event.Hash) })
+
+    for _, event := range copyTestEvents(graph) {
+        if result, err := rm.AddRemoteEvent(event); result != AddInserted {
+            t.Fatalf("lobby event: %v %v", result, err)
+        }
+    }
+    if len(lobbyEvents) == 0 || len(otherEvents) != 0 {
+        t.Fatalf("lobby subscriber got %d events, other room's got %d", len(lobbyEvents), len(otherEvents))
+    }
+    lobby, _ := rm.Room(defaultRoom)
+    if !reflect.DeepEqual(lobbyEvents, orderHashes(lobby)) {
+        t.Fatal("subscriber did not get the room's consensus order")
+    }
+
+    for _, event := range other {
+        rm.AddRemoteEvent(event)
+    }
+    room, _ := rm.Room("other")
+    if len(otherEvents) == 0 || !reflect.DeepEqual(otherEvents, orderHashes(room)) {
+        t.Fatal("other room's subscriber did not get its consensus order")
+    }
+    if lobby.EventCount() != len(graph) || room.EventCount() != len(graph) {
+        t.Fatal("events crossed between rooms")
+    }
+}
+
+func TestConfigureAppliesToLaterRooms(t *testing.T) {
+    rm := NewRoomManager(nil, nil)
+    rm.Join(defaultRoom)
+    rm.Configure(func(hg *Hashgraph) { hg.SetLamportSkew(7) })
+    later := rm.Join("later")
+    lobby, _ := rm.Room(defaultRoom)
+    if lobby.lamportSkew != 7 || later.lamportSkew != 7 {
+        t.Fatal("setting not applied to every room")
+    }
+}
+
+func TestEventForUnjoinedRoomRejected(t *testing.T) {
+    rm := NewRoomManager(nil, nil)
+    graph := buildTestGraph(t, 92, 2, 1)
+    if result, err := rm.AddRemoteEvent(copyTestEvents(graph)[0]); result != AddRejected || !errors.Is(err, errUnknownRoom) {
+        t.Fatalf("got %v %v", result, err)
+    }
+    if _, ok := rm.Room(defaultRoom); ok {
+        t.Fatal("room joined by an event")
+    }
+}
+
+func TestUnknownRoomPolicies(t *testing.T) {
+    graph := buildTestGraph(t, 93, 4, 60)
+    members := testCreators(graph)
+    other := rewriteTestEvents(t, graph, func(event *Event) { event.RoomID = "other" })
+
+    rm := testRoomManager(members)
+    rm.SetUnknownRoomPolicy(UnknownRoomIgnore)
+    if result, err := rm.AddRemoteEvent(copyTestEvents(other)[0]); result != AddRejected || !errors.Is(err, errUnknownRoom) {
+        t.Fatalf("ignore: got %v %v", result, err)
+    }
+    if _, ok := rm.Room("other"); ok || len(rm.buffered) != 0 {
+        t.Fatal("ignored event joined or buffered the room")
+    }
+
+    rm = testRoomManager(members)
+    rm.SetUnknownRoomPolicy(UnknownRoomBuffer)
+    for _, event := range copyTestEvents(other) {
+        if result, err := rm.AddRemoteEvent(event); result != AddBuffered || err != nil {
+            t.Fatalf("buffer: got %v %v", result, err)
+        }
+    }
+    if _, ok := rm.Room("other"); ok {
+        t.Fatal("buffered event joined the room")
+    }
+    if room := rm.Join("other"); room.EventCount() != len(other) {
+        t.Fatalf("joining added %d of %d buffered events", room.EventCount(), len(other))
+    }
+    if len(rm.buffered) != 0 {
+        t.Fatal("buffered events kept after joining")
+    }
+
+    rm = testRoomManager(members)
+    rm.SetUnknownRoomPolicy(UnknownRoomAutoJoin)
+    for _, event := range copyTestEvents(other) {
+        if result, err := rm.AddRemoteEvent(event); result != AddInserted {
+            t.Fatalf("auto-join: got %v %v", result, err)
+        }
+    }
+    if room, ok := rm.Room("other"); !ok || room.EventCount() != len(other) {
+        t.Fatal("auto-join did not add the events to the joined room")
+    }
+
+    for _, name := range []string{"ignore", "buffer", "auto-join"} {
+        if policy, err := parseUnknownRoomPolicy(name); err != nil || string(policy) != name {
+            t.Fatalf("%s: got %v %v", name, policy, err)
+        }
+    }
+    if _, err := parseUnknownRoomPolicy("join"); !errors.Is(err, errUnknownRoomPolicy) {
+        t.Fatalf("unknown policy: %v", err)
+    }
+}
+
+func TestRoomBufferDropsOldest(t *testing.T) {
+    rm := NewRoomManager(nil, nil)
+    rm.SetUnknownRoomPolicy(UnknownRoomBuffer)
+    event := copyTestEvents(buildTestGraph(t, 94, 2, 1))[0]
+    event.RoomID = "other"
+    for i := 0; i < defaultRoomBufferSize+10; i++ {
+        c := *event
+        c.LamportTime = i
+        rm.AddRemoteEvent(&c)
+    }
+    buffered := rm.buffered["other"]
+    if len(buffered) != defaultRoomBufferSize || buffered[0].LamportTime != 10 {
+        t.Fatalf("buffer holds %d events starting at %d", len(buffered), buffered[0].LamportTime)
+    }
+}
+
+func TestUnknownRoomFloodCapped(t *testing.T) {
+    event := copyTestEvents(buildTestGraph(t, 95, 2, 1))[0]
+    for _, policy := range []UnknownRoomPolicy{UnknownRoomBuffer, UnknownRoomAutoJoin} {
+        rm := NewRoomManager(nil, nil)
+        rm.SetUnknownRoomPolicy(policy)
+        rm.SetMaxUnknownRooms(4)
+        rm.Join(defaultRoom)
+
+        // A peer naming a fresh room in every event gets no more than the cap
+        var refused int
+        for i := 0; i < 1000; i++ {
+            flood := *event
+            flood.RoomID = fmt.Sprintf("flood-%d", i)
+            if _, err := rm.AddRemoteEvent(&flood); errors.Is(err, errTooManyRooms) {
+                refused++
+            }
+        }
+        rm.mutex.RLock()
+        rooms, buffered := len(rm.rooms), len(rm.buffered)
+        rm.mutex.RUnlock()
+        if rooms+buffered != 1+4 || refused != 1000-4 {
+            t.Fatalf("%s: %d rooms and %d buffered after the flood, %d events refused", policy, rooms, buffered, refused)
+        }
+
+        // Rooms already taken keep receiving events
+        again := *event
+        again.RoomID = "flood-0"
+        if _, err := rm.AddRemoteEvent(&again); errors.Is(err, errTooManyRooms) {
+            t.Fatalf("%s: event for a room within the cap refused", policy)
+        }
+    }
+}
+
+func TestPostCreatesRoomUnderPolicy(t *testing.T) {
+    key := seededKeys(113, 1)[0]
+    rm := NewRoomManager(key, &key.PublicKey)
+    if _, err := rm.Post("new", []byte("hello")); !errors.Is(err, errRoomCreateDenied) {
+        t.Fatalf("post without a create policy: %v", err)
+    }
+
+    permissive, err := NewRoomCreatePolicy("permissive", nil, []string{"closed"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    rm.SetRoomCreatePolicy(permissive)
+    event, err := rm.Post("new", []byte("hello"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    hg, ok := rm.Room("new")
+    if !ok || event.RoomID != "new" {
+        t.Fatalf("post created room %v, event in %q", ok, event.RoomID)
+    }
+    if _, ok := hg.GetEvent(event.Hash); !ok {
+        t.Fatal("posted event not in the new room")
+    }
+    // Posting again goes to the room already made
+    if _, err := rm.Post("new", []byte("again")); err != nil || hg.EventCount() != 2 {
+        t.Fatalf("second post: %v, %d events", err, hg.EventCount())
+    }
+    if _, err := rm.Post("closed", []byte("hello")); !errors.Is(err, errRoomCreateDenied) {
+        t.Fatalf("post to a denied room: %v", err)
+    }
+
+    strict, err := NewRoomCreatePolicy("strict", []string{"allowed"}, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    rm.SetRoomCreatePolicy(strict)
+    if _, err := rm.Post("other", []byte("hello")); !errors.Is(err, errRoomCreateDenied) {
+        t.Fatalf("strict post to an unlisted room: %v", err)
+    }
+    if _, err := rm.Post("allowed", []byte("hello")); err != nil {
+        t.Fatalf("strict post to an allowed room: %v", err)
+    }
+    for _, roomID := range []string{"other", "closed"} {
+        if _, ok := rm.Room(roomID); ok {
+            t.Fatalf("denied room %s created", roomID)
+        }
+    }
+    if _, err := NewRoomCreatePolicy("open", nil, nil); !errors.Is(err, errUnknownRoomPolicy) {
+        t.Fatalf("unknown mode: %v", err)
+    }
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"container/list"
+	"crypto/ecdsa"
+	"sync"
+)
+
+// Default number of verified signatures remembered
+const defaultSignatureCacheSize = 4096
+
+// Verified signature entry
+type signatureEntry struct {
+    key     string
+    creator string
+}
+
+// LRU cache of verified event signatures, so an event seen again is not re-verified
+type SignatureCache struct {
+    size      int
+    entries   map[string]*list.Element
+    order     *list.List // most recently used at the front
+    byCreator map[string]map[string]bool
+    mutex     sync.Mutex
+}
+
+// create new signature cache
+func NewSignatureCache(size int) *SignatureCache {
+    return &SignatureCache{
+        size:      size,
+        entries:   make(map[string]*list.Element),
+        order:     list.New(),
+        byCreator: make(map[string]map[string]bool),
+    }
+}
+
+// Cache key covering the hash, creator, signature and its format, so a different signature,
+// or the same bytes read in another encoding, is never trusted
+func signatureKey(event *Event) string {
+    return event.Hash + "/" + event.Creator + "/" + event.SignatureFormat + "/" + event.Signature
+}
+
+// Verify an event signature, answering from the cache when it was verified before. The key
+// must be the creator's own, checked on every call since a cached entry says nothing of it.
+func (sc *SignatureCache) Verify(event *Event, publicKey *ecdsa.PublicKey) bool {
+    if checkCreatorKey(event, publicKey) != nil {
+        return false
+    }
+    key := signatureKey(event)
+    sc.mutex.Lock()
+    if element, ok := sc.entries[key]; ok {
+        sc.order.MoveToFront(element)
+        sc.mutex.Unlock()
+        return true
+    }
+    sc.mutex.Unlock()
+
+    if !verifyEventSignature(event, publicKey) {
+        return false
+    }
+
+    sc.mutex.Lock()
+    defer sc.mutex.Unlock()
+    if _, ok := sc.entries[key]; ok {
+        return true
+    }
+    sc.entries[key] = sc.order.PushFront(&signatureEntry{key: key, creator: event.Creator})
+    if sc.byCreator[event.Creator] == nil {
+        sc.byCreator[event.Creator] = make(map[string]bool)
+    }
+    sc.byCreator[event.Creator][key] = true
+    if sc.order.Len() > sc.size {
+        sc.remove(sc.order.Back())
+    }
+    return true
+}
+
+// Drop an entry, caller holds the lock
+func (sc *SignatureCache) remove(element *list.Element) {
+    entry := element.Value.(*signatureEntry)
+    sc.order.Remove(element)
+    delete(sc.entries, entry.key)
+    delete(sc.byCreator[entry.creator], entry.key)
+    if len(sc.byCreator[entry.creator]) == 0 {
+        delete(sc.byCreator, entry.creator)
+    }
+}
+
+// Forget every verified signature of a creator, for when its key is revoked or discarded
+func (sc *SignatureCache) InvalidateCreator(creator string) {
+    sc.mutex.Lock()
+    defer sc.mutex.Unlock()
+    for key := range sc.byCreator[creator] {
+        sc.remove(sc.entries[key])
+    }
+}
+
+// set the cache to forget a creator's signatures in once a revocation of it applies
+func (hg *Hashgraph) SetSignatureCache(signatures *SignatureCache) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+    hg.signatures = signatures
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"testing"
+)
+
+func TestSignatureCacheRemembersValidSignatures(t *testing.T) {
+    graph, keys, err := BuildTestGraph(31, 2, 4)
+    if err != nil {
+        t.Fatal(err)
+    }
+    event := graph[0]
+    publicKey, _ := publicKeyFromHex(event.Creator)
+    cache := NewSignatureCache(8)
+    if !cache.Verify(event, publicKey) || !cache.Verify(event, publicKey) {
+        t.Fatal("valid signature rejected")
+    }
+    if len(cache.entries) != 1 {
+        t.Fatalf("%d entries after verifying one event twice", len(cache.entries))
+    }
+
+    // Another signature over the same hash is verified afresh, never answered from the cache
+    forged := *event
+    forged.Signature = graph[1].Signature
+    if cache.Verify(&forged, publicKey) {
+        t.Fatal("signature from another event accepted")
+    }
+    other := keys[0]
+    if PublicKeyHex(&other.PublicKey) == event.Creator {
+        other = keys[1]
+    }
+    if cache.Verify(event, &other.PublicKey) {
+        t.Fatal("event accepted under another creator's key")
+    }
+
+    // The same signature bytes read in another format are not answered from the cache
+    reformatted := *event
+    reformatted.SignatureFormat = signatureFormatDER
+    if signatureKey(&reformatted) == signatureKey(event) || cache.Verify(&reformatted, publicKey) {
+        t.Fatal("raw signature accepted as DER from the cache")
+    }
+}
+
+func TestSignatureCacheEvictsAndInvalidates(t *testing.T) {
+    graph := buildTestGraph(t, 32, 2, 6)
+    cache := NewSignatureCache(4)
+    for _, event := range graph {
+        publicKey, _ := publicKeyFromHex(event.Creator)
+        cache.Verify(event, publicKey)
+    }
+    if cache.order.Len() != 4 || len(cache.entries) != 4 {
+        t.Fatalf("cache holds %d entries, want its size of 4", len(cache.entries))
+    }
+    if _, ok := cache.entries[signatureKey(graph[0])]; ok {
+        t.Fatal("least recently used entry kept")
+    }
+
+    // A revocation reaching consensus forgets the revoked creator's verified signatures
+    admins := seededKeys(35, 2)
+    hg := NewHashgraph(nil, nil)
+    hg.SetSignatureCache(cache)
+    hg.SetRevocationAdmins([]string{PublicKeyHex(&admins[0].PublicKey), PublicKeyHex(&admins[1].PublicKey)}, 2)
+    creator := graph[len(graph)-1].Creator
+    revocation := &Revocation{Type: chatRevoke, Creator: creator, AfterRound: 1}
+    for _, admin := range admins {
+        signature, err := SignRevocation(revocation.Creator, revocation.AfterRound, admin)
+        if err != nil {
+            t.Fatal(err)
+        }
+        revocation.Signatures = append(revocation.Signatures, signature)
+    }
+    kept := len(cache.entries)
+    hg.mutex.Lock()
+    hg.applyRevocations([]*Event{{Hash: "revoke", Creator: "admin", RoundCreated: 2, Transactions: [][]byte{revocation.Transaction()}}})
+    hg.mutex.Unlock()
+    if len(cache.entries) >= kept {
+        t.Fatal("revocation left the creator's signatures cached")
+    }
+    for key, element := range cache.entries {
+        if element.Value.(*signatureEntry).creator == creator {
+            t.Fatalf("entry %s of an invalidated creator kept", key)
+        }
+    }
+    if len(cache.byCreator[creator]) != 0 {
+        t.Fatal("creator index not cleared")
+    }
+}
+
+// Verifying the same events again, as gossip redelivers them, answered by the cache
+func BenchmarkSignatureCacheHit(b *testing.B) {
+    graph := buildTestGraph(b, 33, 4, 64)
+    keys := make([]*ecdsa.PublicKey, len(graph))
+    for i, event := range graph {
+        keys[i], _ = publicKeyFromHex(event.Creator)
+    }
+    cache := NewSignatureCache(defaultSignatureCacheSize)
+    for i, event := range graph {
+        cache.Verify(event, keys[i])
+    }
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        j := i % len(graph)
+        cache.Verify(graph[j], keys[j])
+    }
+}
+
+// The same verifications without the cache
+func BenchmarkSignatureVerifyUncached(b *testing.B) {
+    graph := buildTestGraph(b, 33, 4, 64)
+    keys := make([]*ecdsa.PublicKey, len(graph))
+    for i, event := range graph {
+        keys[i], _ = publicKeyFromHex(event.Creator)
+    }
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        j := i % len(graph)
+        verifyEventSignature(graph[j], keys[j])
+    }
+}
+
+// A signature valid for one key does not vouch for an event claiming another creator
+func TestSignatureCacheRejectsMismatchedCreator(t *testing.T) {
+    graph, keys, err := BuildTestGraph(34, 2, 2)
+    if err != nil {
+        t.Fatal(err)
+    }
+    event := *graph[0]
+    signer := keys[0]
+    if PublicKeyHex(&signer.PublicKey) == event.Creator {
+        signer = keys[1]
+    }
+    if err := signEvent(&event, signer); err != nil {
+        t.Fatal(err)
+    }
+    if !verifyEventSignature(&event, &signer.PublicKey) {
+        t.Fatal("re-signed event does not verify")
+    }
+    if NewSignatureCache(8).Verify(&event, &signer.PublicKey) {
+        t.Fatal("event accepted under a key that is not its creator's")
+    }
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// First delay before redialing the signaling server, doubled up to the maximum
+const (
+    reconnectInitialBackoff = 500 * time.Millisecond
+    reconnectMaxBackoff     = 30 * time.Second
+)
+
+// Connection was closed locally
+var errSignalClosed = errors.New("signaling connection closed")
+
+// Signaling connection safe for concurrent writers; the websocket allows only one writer at a time.
+// When dialed with reconnection enabled, a failed read redials and runs the reconnect hooks.
+type SignalConn struct {
+    conn      *websocket.Conn
+    url       string
+    reconnect bool
+    token     string // session token presented on redial to reclaim the session
+    closed    bool
+    hooks     []func()
+    mutex     sync.Mutex
+}
+
+// Dial the signaling server, optionally redialing whenever the connection drops
+func DialSignal(url string, reconnect bool) (*SignalConn, error) {
+    conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+    if err != nil {
+        return nil, fmt.Errorf("dial signaling server: %w", err)
+    }
+    return &SignalConn{conn: conn, url: url, reconnect: reconnect}, nil
+}
+
+// Register a hook run after every successful reconnect, such as re-announcing the node
+func (sc *SignalConn) OnReconnect(hook func()) {
+    sc.mutex.Lock()
+    defer sc.mutex.Unlock()
+    sc.hooks = append(sc.hooks, hook)
+}
+
+// Set the session token the server issued, presented when redialing
+func (sc *SignalConn) SetSessionToken(token string) {
+    sc.mutex.Lock()
+    defer sc.mutex.Unlock()
+    sc.token = token
+}
+
+// URL to redial, carrying the session token if there is one, caller holds the lock
+func (sc *SignalConn) redialURL() string {
+    if sc.token == "" {
+        return sc.url
+    }
+    u, err := url.Parse(sc.url)
+    if err != nil {
+        return sc.url
+    }
+    query := u.Query()
+    query.Set("token", sc.token)
+    u.RawQuery = query.Encode()
+    return u.String()
+}
+
+// Send a message to the signaling server
+func (sc *SignalConn) WriteJSON(v interface{}) error {
+    sc.mutex.Lock()
+    defer sc.mutex.Unlock()
+    return sc.conn.WriteJSON(v)
+}
+
+// Read the next message, only called from the reader goroutine
+func (sc *SignalConn) ReadMessage() (int, []byte, error) {
+    for {
+        sc.mutex.Lock()
+        conn := sc.conn
+        sc.mutex.Unlock()
+
+        messageType, data, err := conn.ReadMessage()
+        if err == nil || !sc.reconnect {
+            return messageType, data, err
+        }
+        log.Println("Signaling connection lost, reconnecting:", err)
+        if err := sc.redial(); err != nil {
+            return 0, nil, err
+        }
+    }
+}
+
+// Redial with exponential backoff until connected or closed, then run the reconnect hooks
+func (sc *SignalConn) redial() error {
+    backoff := reconnectInitialBackoff
+    for {
+        sc.mutex.Lock()
+        closed := sc.closed
+        redialURL := sc.redialURL()
+        sc.mutex.Unlock()
+        if closed {
+            return errSignalClosed
+        }
+
+        conn, _, err := websocket.DefaultDialer.Dial(redialURL, nil)
+        if err == nil {
+            sc.mutex.Lock()
+            sc.conn.Close()
+            sc.conn = conn
+            hooks := append([]func(){}, sc.hooks...)
+            sc.mutex.Unlock()
+
+            log.Println("Reconnected to signaling server")
+            for _, hook := range hooks {
+                hook()
+            }
+            return nil
+        }
+        log.Printf("Failed to reconnect, retrying in %s: %v", backoff, err)
+        time.Sleep(backoff)
+        if backoff *= 2; backoff > reconnectMaxBackoff {
+            backoff = reconnectMaxBackoff
+        }
+    }
+}
+
+// Close the connection
+func (sc *SignalConn) Close() error {
+    sc.mutex.Lock()
+    defer sc.mutex.Unlock()
+    sc.closed = true
+    return sc.conn.Close()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Message read by the test server, with the connection it arrived on
+type connMessage struct {
+    conn  int32
+    token string
+    msg   Message
+}
+
+func TestReconnectReannouncesIdentityAndFrontier(t *testing.T) {
+    upgrader := websocket.Upgrader{}
+    received := make(chan connMessage, 64)
+    var conns int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        conn, err := upgrader.Upgrade(w, r, nil)
+        if err != nil {
+            return
+        }
+        defer conn.Close()
+        n := atomic.AddInt32(&conns, 1)
+        for i := 0; ; i++ {
+            var msg Message
+            if err := conn.ReadJSON(&msg); err != nil {
+                return
+            }
+            received <- connMessage{conn: n, token: r.URL.Query().Get("token"), msg: msg}
+            // Drop the first connection once the node has announced itself
+            if n == 1 && i == 2 {
+                return
+            }
+        }
+    }))
+    defer srv.Close()
+
+    hg := testLocalHashgraph(t, 90)
+    if _, err := hg.SubmitTransaction([]byte("hello"), ""); err != nil {
+        t.Fatal(err)
+    }
+    c, err := DialSignal("ws"+strings.TrimPrefix(srv.URL, "http"), true)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer c.Close()
+    c.SetSessionToken("session-token")
+    c.OnReconnect(func() { announceNode(c, hg, false) })
+    announceNode(c, hg, false)
+    go func() {
+        for {
+            if _, _, err := c.ReadMessage(); err != nil {
+                return
+            }
+        }
+    }()
+
+    want := []string{"hello", "frontier", "presence", "hello", "frontier", "presence"}
+    for i, kind := range want {
+        var got connMessage
+        select {
+        case got = <-received:
+        case <-time.After(2 * time.Second):
+            t.Fatalf("message %d (%s) not sent", i, kind)
+        }
+        if got.msg.Type != kind || got.conn != int32(i/3+1) {
+            t.Fatalf("message %d: %s on connection %d, want %s on %d", i, got.msg.Type, got.conn, kind, i/3+1)
+        }
+        switch kind {
+        case "hello":
+            if got.msg.Creator != hg.CreatorID() {
+                t.Fatal("hello without the node's key")
+            }
+        case "frontier":
+            if !reflect.DeepEqual(got.msg.Frontier, hg.Frontier()) {
+                t.Fatalf("frontier %v, want %v", got.msg.Frontier, hg.Frontier())
+            }
+        }
+        if got.conn == 2 && got.token != "session-token" {
+            t.Fatal("redial did not present the session token")
+        }
+    }
+}
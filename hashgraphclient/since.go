@@ -0,0 +1,40 @@
+package main
+
+import "errors"
+
+// Since-hash not found on the creator's chain
+var errUnknownSinceHash = errors.New("since hash not on creator's chain")
+
+// Events of a creator after the given hash, oldest first, found by walking self-parents back from the head.
+// An empty since returns the creator's whole chain.
+func (hg *Hashgraph) EventsSince(creator, since string) ([]*Event, error) {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+
+    var chain []*Event
+    hash := hg.heads[creator]
+    for hash != since {
+        event, ok := hg.Events[hash]
+        if !ok {
+            if since == "" {
+                break
+            }
+            return nil, errUnknownSinceHash
+        }
+        chain = append(chain, event)
+        hash = event.SelfParent
+    }
+    for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+        chain[i], chain[j] = chain[j], chain[i]
+    }
+    return chain, nil
+}
+
+// Route an events-since request to its room
+func (rm *RoomManager) EventsSince(roomID, creator, since string) ([]*Event, error) {
+    hg, ok := rm.Room(roomID)
+    if !ok {
+        return nil, errUnknownRoom
+    }
+    return hg.EventsSince(creator, since)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEventsSinceRebuildsCreatorTail(t *testing.T) {
+    graph := buildTestGraph(t, 9, 4, 120)
+    full := testHashgraph(t, graph, testCreators(graph))
+
+    // The behind node misses one creator's events from the middle of the graph on
+    creator := graph[60].Creator
+    var tail []*Event
+    behind := NewHashgraph(nil, nil)
+    behind.SetMembers(testCreators(graph))
+    for i, event := range copyTestEvents(graph) {
+        if i >= 60 && event.Creator == creator {
+            tail = append(tail, event)
+            continue
+        }
+        if result, err := behind.AddRemoteEvent(event); result == AddRejected {
+            t.Fatalf("event %s: %v", shortID(event.Hash), err)
+        }
+    }
+    known, err := behind.EventsSince(creator, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    since := known[len(known)-1].Hash
+
+    chain, err := full.EventsSince(creator, since)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(chain) != len(tail) {
+        t.Fatalf("chain of %d events, want %d", len(chain), len(tail))
+    }
+    for i, event := range chain {
+        if event.Hash != tail[i].Hash {
+            t.Fatalf("chain event %d is %s, want %s", i, shortID(event.Hash), shortID(tail[i].Hash))
+        }
+    }
+
+    for _, event := range copyTestEvents(chain) {
+        if result, err := behind.AddRemoteEvent(event); result != AddInserted {
+            t.Fatalf("chain event %s: %v %v", shortID(event.Hash), result, err)
+        }
+    }
+    if count := behind.EventCount(); count != len(graph) {
+        t.Fatalf("%d of %d events after the chain arrived", count, len(graph))
+    }
+
+    if _, err := full.EventsSince(creator, "unknown"); !errors.Is(err, errUnknownSinceHash) {
+        t.Fatalf("unknown since hash: %v", err)
+    }
+    if chain, err := full.EventsSince(creator, chain[len(chain)-1].Hash); err != nil || len(chain) != 0 {
+        t.Fatalf("since the head: %d events, %v", len(chain), err)
+    }
+}
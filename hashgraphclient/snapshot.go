@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// Default interval between snapshot saves
+const defaultSnapshotInterval = 30 * time.Second
+
+// Persisted events and consensus state, so a restart neither re-inserts events nor recomputes decided rounds.
+// Each event carries its round created, witness flag, fame, round received and consensus timestamp.
+type Snapshot struct {
+    Events            []*Event        `json:"events"` // parents before children
+    Famous            map[string]bool `json:"famous"`
+    ConsensusOrder    []string        `json:"consensusOrder"`
+    LastReceivedRound int             `json:"lastReceivedRound"`
+    Votes             map[string]map[string]bool      `json:"votes,omitempty"` // virtual votes by voter, for witnesses still undecided
+    Local             map[string]SnapshotLocal        `json:"local,omitempty"` // by event hash
+    Forked            map[string]time.Time            `json:"forked,omitempty"`
+    Revoked           map[string]int                  `json:"revoked,omitempty"`
+    Epochs            []memberEpoch                   `json:"epochs,omitempty"`
+}
+
+// Local event fields that are not sent to peers
+type SnapshotLocal struct {
+    ReceivedFrom     string            `json:"receivedFrom,omitempty"`
+    IdempotencyKey   string            `json:"idempotencyKey,omitempty"`
+    TimestampSources []TimestampSource `json:"timestampSources,omitempty"`
+}
+
+// Take a snapshot of the graph
+func (hg *Hashgraph) Snapshot() *Snapshot {
+    hg.mutex.RLock()
+    snapshot := &Snapshot{
+        Events:            hg.copyEvents(),
+        Famous:            make(map[string]bool),
+        ConsensusOrder:    make([]string, 0, len(hg.ConsensusOrder)),
+        LastReceivedRound: hg.lastReceivedRound,
+        Votes:             make(map[string]map[string]bool, len(hg.votes)),
+        Local:             make(map[string]SnapshotLocal),
+        Forked:            make(map[string]time.Time, len(hg.forked)),
+        Revoked:           make(map[string]int, len(hg.revoked)),
+        Epochs:            append([]memberEpoch(nil), hg.epochs...),
+    }
+    for _, event := range hg.ConsensusOrder {
+        snapshot.ConsensusOrder = append(snapshot.ConsensusOrder, event.Hash)
+    }
+    for voter, votes := range hg.votes {
+        snapshot.Votes[voter] = make(map[string]bool, len(votes))
+        for hash, vote := range votes {
+            snapshot.Votes[voter][hash] = vote
+        }
+    }
+    for creator, at := range hg.forked {
+        snapshot.Forked[creator] = at
+    }
+    for creator, round := range hg.revoked {
+        snapshot.Revoked[creator] = round
+    }
+    hg.mutex.RUnlock()
+
+    sortTopological(snapshot.Events)
+    for _, event := range snapshot.Events {
+        if event.Famous != nil {
+            snapshot.Famous[event.Hash] = *event.Famous
+        }
+        if event.ReceivedFrom != "" || event.IdempotencyKey != "" || len(event.TimestampSources) > 0 {
+            snapshot.Local[event.Hash] = SnapshotLocal{
+                ReceivedFrom:     event.ReceivedFrom,
+                IdempotencyKey:   event.IdempotencyKey,
+                TimestampSources: event.TimestampSources,
+            }
+        }
+    }
+    return snapshot
+}
+
+// Restore a snapshot into an empty graph. Events are placed with the rounds, fame and order
+// they were saved with, so nothing decided is recomputed; only snapshots saved without rounds
+// have them worked out again.
+func (hg *Hashgraph) RestoreSnapshot(snapshot *Snapshot) {
+    hg.mutex.Lock()
+    defer hg.mutex.Unlock()
+
+    if snapshot.Epochs != nil {
+        hg.epochs = snapshot.Epochs
+    }
+    for creator, at := range snapshot.Forked {
+        hg.forked[creator] = at
+    }
+    for creator, round := range snapshot.Revoked {
+        hg.revoked[creator] = round
+    }
+    now := time.Now()
+    for _, event := range snapshot.Events {
+        if famous, ok := snapshot.Famous[event.Hash]; ok {
+            event.Famous = &famous
+        }
+        if local, ok := snapshot.Local[event.Hash]; ok {
+            event.ReceivedFrom = local.ReceivedFrom
+            event.IdempotencyKey = local.IdempotencyKey
+            event.TimestampSources = local.TimestampSources
+            if local.IdempotencyKey != "" {
+                hg.idempotencyKeys[local.IdempotencyKey] = event.Hash
+            }
+        }
+        hg.Events[event.Hash] = event
+        hg.heads[event.Creator] = event.Hash
+        hg.lastContact[event.Creator] = now
+        hg.detectFork(event)
+        if event.RoundCreated == 0 {
+            hg.divideRounds(event)
+        }
+        hg.Rounds[event.RoundCreated] = append(hg.Rounds[event.RoundCreated], event)
+        if hg.roundCounts[event.RoundCreated] == nil {
+            hg.roundCounts[event.RoundCreated] = make(map[string]int)
+        }
+        hg.roundCounts[event.RoundCreated][event.Creator]++
+        if event.LamportTime > hg.maxLamport {
+            hg.maxLamport = event.LamportTime
+        }
+    }
+    for _, events := range hg.Rounds {
+        sort.Slice(events, func(i, j int) bool { return roundLess(events[i], events[j]) })
+    }
+    for voter, votes := range snapshot.Votes {
+        hg.votes[voter] = votes
+    }
+    for _, hash := range snapshot.ConsensusOrder {
+        if event, ok := hg.Events[hash]; ok {
+            hg.ConsensusOrder = append(hg.ConsensusOrder, event)
+        }
+    }
+    hg.lastReceivedRound = snapshot.LastReceivedRound
+}
+
+// Write a snapshot atomically
+func SaveSnapshot(path string, snapshot *Snapshot) error {
+    data, err := json.Marshal(snapshot)
+    if err != nil {
+        return fmt.Errorf("encode snapshot: %w", err)
+    }
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0600); err != nil {
+        return fmt.Errorf("write snapshot: %w", err)
+    }
+    if err := os.Rename(tmp, path); err != nil {
+        return fmt.Errorf("write snapshot: %w", err)
+    }
+    return nil
+}
+
+// Read a snapshot, returning nil if none has been saved yet
+func LoadSnapshot(path string) (*Snapshot, error) {
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("read snapshot: %w", err)
+    }
+    var snapshot Snapshot
+    if err := json.Unmarshal(data, &snapshot); err != nil {
+        return nil, fmt.Errorf("decode snapshot %s: %w", path, err)
+    }
+    return &snapshot, nil
+}
+
+// Save snapshots of the graph periodically
+func runSnapshots(hg *Hashgraph, path string, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for range ticker.C {
+        if err := SaveSnapshot(path, hg.Snapshot()); err != nil {
+            log.Println("Failed to save snapshot:", err)
+        }
+    }
+}
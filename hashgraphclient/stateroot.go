@@ -0,0 +1,23 @@
+package main
+
+import (
+	"encoding/hex"
+)
+
+// Running hash over the consensus order up to and including a round received (all rounds if round <= 0)
+func (hg *Hashgraph) StateRoot(round int) string {
+    hg.mutex.RLock()
+    defer hg.mutex.RUnlock()
+
+    var root []byte
+    for _, event := range hg.ConsensusOrder {
+        if round > 0 && event.RoundReceived > round {
+            break
+        }
+        hash := newDomainHash(hg.hasher, stateRootDomain)
+        hash.Write(root)
+        hash.Write([]byte(event.Hash))
+        root = hash.Sum(nil)
+    }
+    return hex.EncodeToString(root)
+}
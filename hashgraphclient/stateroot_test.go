@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStateRootAgreesAcrossNodes(t *testing.T) {
+    graph := buildTestGraph(t, 111, 4, 250)
+    members := testCreators(graph)
+    first := testHashgraph(t, graph, members)
+    second := testHashgraph(t, shuffledTopological(graph, 3), members)
+
+    if NewHashgraph(nil, nil).StateRoot(0) != "" {
+        t.Fatal("empty consensus order has a state root")
+    }
+    root := first.StateRoot(0)
+    if root == "" || root != second.StateRoot(0) {
+        t.Fatalf("state roots %q and %q", root, second.StateRoot(0))
+    }
+    round := first.LastFinalizedRound() - 1
+    if first.StateRoot(round) == root || first.StateRoot(round) != second.StateRoot(round) {
+        t.Fatal("state root up to an earlier round does not cover only its prefix of the order")
+    }
+
+    rooms := NewRoomManager(nil, nil)
+    rooms.rooms[defaultRoom] = first
+    recorder := httptest.NewRecorder()
+    stateRootHandler(recorder, httptest.NewRequest(http.MethodGet, "/stateroot", nil), rooms)
+    var body struct {
+        StateRoot string `json:"stateRoot"`
+    }
+    if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil || body.StateRoot != root {
+        t.Fatalf("handler returned %q, %v", body.StateRoot, err)
+    }
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Event not in the store
+var errStoreNotFound = errors.New("event not in store")
+
+// Store name not recognized
+var errUnknownStore = errors.New("unknown store, want memory, file:<dir> or mongodb://<host>/<db>")
+
+// Page cursor not issued by the store
+var errInvalidStoreCursor = errors.New("invalid store cursor")
+
+// Events read from a store per page when reloading or serving history
+const defaultStorePageSize = 500
+
+// Store verification mode not recognized
+var errUnknownStoreVerify = errors.New("unknown store verification, want off, lenient or strict")
+
+// How events reloaded from a store are checked before they enter the graph
+type StoreVerify string
+
+const (
+    StoreVerifyOff     StoreVerify = "off"     // trust the store
+    StoreVerifyLenient StoreVerify = "lenient" // check hashes and signatures, quarantining corrupt events
+    StoreVerifyStrict  StoreVerify = "strict"  // check hashes and signatures, refusing to load on corruption
+)
+
+// Look up a store verification mode by flag name
+func storeVerifyByName(name string) (StoreVerify, error) {
+    switch mode := StoreVerify(name); mode {
+    case StoreVerifyOff, StoreVerifyLenient, StoreVerifyStrict:
+        return mode, nil
+    }
+    return "", errUnknownStoreVerify
+}
+
+// Event in a store failing its hash or signature check
+type CorruptEventError struct {
+    Hash string
+    Err  error
+}
+
+func (e *CorruptEventError) Error() string {
+    return fmt.Sprintf("corrupt event %s in store: %v", e.Hash, e.Err)
+}
+
+func (e *CorruptEventError) Unwrap() error {
+    return e.Err
+}
+
+// Persistence backend for events. The pipeline's persist stage and locally created events
+// both write through it, and a restarted node reloads its graph from it.
+type Store interface {
+    EnsureIndexes() error
+    Put(event *Event) error
+    Get(hash string) (*Event, error)
+    List() ([]*Event, error)
+    // Up to limit events in insertion order, starting at cursor ("" for the first page), and
+    // the cursor of the next page. An empty page marks the end; its cursor picks up later events.
+    LoadEvents(cursor string, limit int) ([]*Event, string, error)
+    Delete(hash string) error
+}
+
+// Open a store from its flag value: "memory", "file:<dir>" for one JSON file per event, or a
+// mongodb:// URI whose path names the database
+func openStore(spec string) (Store, error) {
+    var store Store
+    switch {
+    case spec == "memory":
+        store = NewMemoryStore()
+    case strings.HasPrefix(spec, "file:") && len(spec) > len("file:"):
+        store = NewFileEventStore(strings.TrimPrefix(spec, "file:"))
+    case isMongoURI(spec):
+        mongoStore, err := OpenMongoStore(spec)
+        if err != nil {
+            return nil, fmt.Errorf("open store: %w", err)
+        }
+        store = mongoStore
+    default:
+        return nil, errUnknownStore
+    }
+    if err := store.EnsureIndexes(); err != nil {
+        return nil, fmt.Errorf("open store %s: %w", spec, err)
+    }
+    return store, nil
+}
+
+// Store kept in memory, lost on exit
+type MemoryStore struct {
+    events map[string]*Event
+    order  []string // hashes in insertion order, including deleted ones
+    mutex  sync.RWMutex
+}
+
+// create new in-memory store
+func NewMemoryStore() *MemoryStore {
+    return &MemoryStore{events: make(map[string]*Event)}
+}
+
+func (ms *MemoryStore) EnsureIndexes() error {
+    return nil
+}
+
+func (ms *MemoryStore) Put(event *Event) error {
+    copied := *event
+    ms.mutex.Lock()
+    defer ms.mutex.Unlock()
+    if _, ok := ms.events[event.Hash]; !ok {
+        ms.order = append(ms.order, event.Hash)
+    }
+    ms.events[event.Hash] = &copied
+    return nil
+}
+
+func (ms *MemoryStore) Get(hash string) (*Event, error) {
+    ms.mutex.RLock()
+    defer ms.mutex.RUnlock()
+    event, ok := ms.events[hash]
+    if !ok {
+        return nil, errStoreNotFound
+    }
+    copied := *event
+    return &copied, nil
+}
+
+func (ms *MemoryStore) List() ([]*Event, error) {
+    ms.mutex.RLock()
+    defer ms.mutex.RUnlock()
+    events := make([]*Event, 0, len(ms.events))
+    for _, event := range ms.events {
+        copied := *event
+        events = append(events, &copied)
+    }
+    sortTopological(events)
+    return events, nil
+}
+
+// The cursor is a position in the insertion order
+func (ms *MemoryStore) LoadEvents(cursor string, limit int) ([]*Event, string, error) {
+    if limit <= 0 {
+        limit = defaultStorePageSize
+    }
+    ms.mutex.RLock()
+    defer ms.mutex.RUnlock()
+    position := 0
+    if cursor != "" {
+        var err error
+        if position, err = strconv.Atoi(cursor); err != nil || position < 0 || position > len(ms.order) {
+            return nil, "", errInvalidStoreCursor
+        }
+    }
+    var events []*Event
+    for ; position < len(ms.order) && len(events) < limit; position++ {
+        event, ok := ms.events[ms.order[position]]
+        if !ok {
+            continue
+        }
+        copied := *event
+        events = append(events, &copied)
+    }
+    return events, strconv.Itoa(position), nil
+}
+
+func (ms *MemoryStore) Delete(hash string) error {
+    ms.mutex.Lock()
+    defer ms.mutex.Unlock()
+    delete(ms.events, hash)
+    return nil
+}
+
+// File listing a file store's hashes in insertion order, one per line
+const fileStoreIndex = "events.idx"
+
+// Store keeping each event in its own JSON file, named by hash, in a directory
+type FileEventStore struct {
+    dir   string
+    mutex sync.Mutex // serializes appends to the index
+}
+
+// create new file-backed store in a directory
+func NewFileEventStore(dir string) *FileEventStore {
+    return &FileEventStore{dir: dir}
+}
+
+// Path of an event's file, rejecting hashes that are not hex so they cannot leave the directory
+func (fs *FileEventStore) path(hash string) (string, error) {
+    if _, err := hex.DecodeString(hash); err != nil || hash == "" {
+        return "", errStoreNotFound
+    }
+    return filepath.Join(fs.dir, hash+".json"), nil
+}
+
+// Create the directory, and the insertion-order index from the events already in it,
+// parents first, when the directory predates the index
+func (fs *FileEventStore) EnsureIndexes() error {
+    if err := os.MkdirAll(fs.dir, 0700); err != nil {
+        return fmt.Errorf("create store directory: %w", err)
+    }
+    index := filepath.Join(fs.dir, fileStoreIndex)
+    if _, err := os.Stat(index); err == nil {
+        return nil
+    }
+    events, err := fs.List()
+    if err != nil {
+        return err
+    }
+    var lines strings.Builder
+    for _, event := range events {
+        lines.WriteString(event.Hash + "\n")
+    }
+    tmp := index + ".tmp"
+    if err := os.WriteFile(tmp, []byte(lines.String()), 0600); err != nil {
+        return fmt.Errorf("write store index: %w", err)
+    }
+    if err := os.Rename(tmp, index); err != nil {
+        return fmt.Errorf("write store index: %w", err)
+    }
+    return nil
+}
+
+// Write an event atomically, indexing it the first time it is stored
+func (fs *FileEventStore) Put(event *Event) error {
+    path, err := fs.path(event.Hash)
+    if err != nil {
+        return err
+    }
+    data, err := json.Marshal(event)
+    if err != nil {
+        return fmt.Errorf("encode event %s: %w", shortID(event.Hash), err)
+    }
+    fs.mutex.Lock()
+    defer fs.mutex.Unlock()
+    _, err = os.Stat(path)
+    isNew := os.IsNotExist(err)
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0600); err != nil {
+        return fmt.Errorf("write event: %w", err)
+    }
+    if err := os.Rename(tmp, path); err != nil {
+        return fmt.Errorf("write event: %w", err)
+    }
+    if isNew {
+        return fs.appendIndex(event.Hash)
+    }
+    return nil
+}
+
+// Append a hash to the index, caller holds the lock
+func (fs *FileEventStore) appendIndex(hash string) error {
+    index, err := os.OpenFile(filepath.Join(fs.dir, fileStoreIndex), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+    if err != nil {
+        return fmt.Errorf("open store index: %w", err)
+    }
+    defer index.Close()
+    if _, err := index.WriteString(hash + "\n"); err != nil {
+        return fmt.Errorf("append store index: %w", err)
+    }
+    return nil
+}
+
+func (fs *FileEventStore) Get(hash string) (*Event, error) {
+    path, err := fs.path(hash)
+    if err != nil {
+        return nil, err
+    }
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return nil, errStoreNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("read event: %w", err)
+    }
+    var event Event
+    if err := json.Unmarshal(data, &event); err != nil {
+        return nil, fmt.Errorf("decode event %s: %w", shortID(hash), err)
+    }
+    return &event, nil
+}
+
+func (fs *FileEventStore) List() ([]*Event, error) {
+    paths, err := filepath.Glob(filepath.Join(fs.dir, "*.json"))
+    if err != nil {
+        return nil, err
+    }
+    events := make([]*Event, 0, len(paths))
+    for _, path := range paths {
+        event, err := fs.Get(strings.TrimSuffix(filepath.Base(path), ".json"))
+        if err != nil {
+            return nil, err
+        }
+        events = append(events, event)
+    }
+    sortTopological(events)
+    return events, nil
+}
+
+// The cursor is a byte offset into the index, so a page reads only its own lines.
+// Deleted events are skipped, and a line still being written is left for the next page.
+func (fs *FileEventStore) LoadEvents(cursor string, limit int) ([]*Event, string, error) {
+    if limit <= 0 {
+        limit = defaultStorePageSize
+    }
+    var offset int64
+    if cursor != "" {
+        var err error
+        if offset, err = strconv.ParseInt(cursor, 10, 64); err != nil || offset < 0 {
+            return nil, "", errInvalidStoreCursor
+        }
+    }
+    index, err := os.Open(filepath.Join(fs.dir, fileStoreIndex))
+    if os.IsNotExist(err) {
+        return nil, cursor, nil
+    }
+    if err != nil {
+        return nil, "", fmt.Errorf("open store index: %w", err)
+    }
+    defer index.Close()
+    if _, err := index.Seek(offset, io.SeekStart); err != nil {
+        return nil, "", fmt.Errorf("seek store index: %w", err)
+    }
+
+    reader := bufio.NewReader(index)
+    var events []*Event
+    for len(events) < limit {
+        line, err := reader.ReadString('\n')
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, "", fmt.Errorf("read store index: %w", err)
+        }
+        offset += int64(len(line))
+        event, err := fs.Get(strings.TrimSuffix(line, "\n"))
+        if errors.Is(err, errStoreNotFound) {
+            continue
+        }
+        if err != nil {
+            return nil, "", err
+        }
+        events = append(events, event)
+    }
+    return events, strconv.FormatInt(offset, 10), nil
+}
+
+func (fs *FileEventStore) Delete(hash string) error {
+    path, err := fs.path(hash)
+    if err != nil {
+        return err
+    }
+    if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+        return fmt.Errorf("delete event: %w", err)
+    }
+    return nil
+}
+
+// Persist events through a store
+func (hg *Hashgraph) SetStore(store Store) {
+    hg.SetPersister(store.Put)
+}
+
+// Reload a room's events from a store into an empty graph, a page at a time in insertion order,
+// which puts parents first. Unless verify is off, every event's hash and signature are checked
+// first: strict mode fails on the first corrupt event, lenient mode hands it to quarantine and
+// skips it, so its descendants stay buffered.
+func (hg *Hashgraph) LoadStore(store Store, verify StoreVerify, quarantine func(*Event, error)) (int, error) {
+    loaded := 0
+    cursor := ""
+    for {
+        events, next, err := store.LoadEvents(cursor, defaultStorePageSize)
+        if err != nil {
+            return loaded, fmt.Errorf("load stored events: %w", err)
+        }
+        if len(events) == 0 {
+            return loaded, nil
+        }
+        cursor = next
+        n, err := hg.loadStoredEvents(events, verify, quarantine)
+        loaded += n
+        if err != nil {
+            return loaded, err
+        }
+    }
+}
+
+// Add one page of stored events, returning how many belonged to the room
+func (hg *Hashgraph) loadStoredEvents(events []*Event, verify StoreVerify, quarantine func(*Event, error)) (int, error) {
+    loaded := 0
+    for _, event := range events {
+        if event.RoomID != hg.roomID {
+            continue
+        }
+        if verify != StoreVerifyOff {
+            if err := verifyEventIntegrity(event); err != nil {
+                if verify == StoreVerifyStrict {
+                    return loaded, &CorruptEventError{Hash: event.Hash, Err: err}
+                }
+                quarantine(event, err)
+                continue
+            }
+        }
+        if _, err := hg.AddRemoteEvent(event); err != nil {
+            return loaded, fmt.Errorf("load event %s: %w", shortID(event.Hash), err)
+        }
+        loaded++
+    }
+    return loaded, nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Contract every Store implementation must meet
+func testStoreContract(t *testing.T, store Store) {
+    t.Helper()
+    if err := store.EnsureIndexes(); err != nil {
+        t.Fatal(err)
+    }
+    graph := buildTestGraph(t, 87, 4, 60)
+    for _, event := range graph {
+        if err := store.Put(event); err != nil {
+            t.Fatal(err)
+        }
+    }
+    // Putting an event again stores it once
+    if err := store.Put(graph[0]); err != nil {
+        t.Fatal(err)
+    }
+
+    got, err := store.Get(graph[10].Hash)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got.Hash != graph[10].Hash || got.Signature != graph[10].Signature || !reflect.DeepEqual(got.Transactions, graph[10].Transactions) {
+        t.Fatal("stored event differs")
+    }
+    got.Signature = "changed"
+    if again, _ := store.Get(graph[10].Hash); again.Signature != graph[10].Signature {
+        t.Fatal("store shares events with callers")
+    }
+    if _, err := store.Get(fileHash([]byte("missing"))); !errors.Is(err, errStoreNotFound) {
+        t.Fatalf("missing event: %v", err)
+    }
+
+    listed, err := store.List()
+    if err != nil {
+        t.Fatal(err)
+    }
+    position := make(map[string]int, len(listed))
+    for i, event := range listed {
+        position[event.Hash] = i
+    }
+    if len(listed) != len(graph) {
+        t.Fatalf("listed %d of %d events", len(listed), len(graph))
+    }
+    for _, event := range listed {
+        if parent, ok := position[event.SelfParent]; event.SelfParent != "" && (!ok || parent > position[event.Hash]) {
+            t.Fatal("listed an event before its self-parent")
+        }
+    }
+
+    // Pages cover the insertion order once, and the last cursor picks up later events
+    var paged []string
+    cursor := ""
+    for {
+        page, next, err := store.LoadEvents(cursor, 7)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if len(page) > 7 {
+            t.Fatalf("page of %d events", len(page))
+        }
+        if len(page) == 0 {
+            break
+        }
+        for _, event := range page {
+            paged = append(paged, event.Hash)
+        }
+        cursor = next
+    }
+    want := make([]string, len(graph))
+    for i, event := range graph {
+        want[i] = event.Hash
+    }
+    if !reflect.DeepEqual(paged, want) {
+        t.Fatal("pages differ from the insertion order")
+    }
+    later := buildTestGraph(t, 88, 2, 1)[0]
+    store.Put(later)
+    if page, _, err := store.LoadEvents(cursor, 7); err != nil || len(page) != 1 || page[0].Hash != later.Hash {
+        t.Fatalf("page after the end: %d events, %v", len(page), err)
+    }
+    if _, _, err := store.LoadEvents("not a cursor", 7); !errors.Is(err, errInvalidStoreCursor) {
+        t.Fatalf("invalid cursor: %v", err)
+    }
+
+    if err := store.Delete(later.Hash); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := store.Get(later.Hash); !errors.Is(err, errStoreNotFound) {
+        t.Fatalf("deleted event: %v", err)
+    }
+    if page, _, _ := store.LoadEvents(cursor, 7); len(page) != 0 {
+        t.Fatal("deleted event still paged")
+    }
+
+    // A node reloads the same graph from the store
+    members := testCreators(graph)
+    reloaded := NewHashgraph(nil, nil)
+    reloaded.roomID = defaultRoom
+    reloaded.SetMembers(members)
+    if n, err := reloaded.LoadStore(store, StoreVerifyStrict, nil); err != nil || n != len(graph) {
+        t.Fatalf("reloaded %d events: %v", n, err)
+    }
+    if !reflect.DeepEqual(orderHashes(reloaded), orderHashes(testHashgraph(t, graph, members))) {
+        t.Fatal("reloaded graph reached a different order")
+    }
+}
+
+func TestMemoryStoreContract(t *testing.T) {
+    testStoreContract(t, NewMemoryStore())
+}
+
+func TestFileEventStoreContract(t *testing.T) {
+    testStoreContract(t, NewFileEventStore(t.TempDir()))
+}
+
+// MongoDB store in a database of its own, dropped after the test; skips unless MONGODB_URI names a server
+func testMongoStore(t *testing.T) *MongoStore {
+    t.Helper()
+    uri := os.Getenv("MONGODB_URI")
+    if uri == "" {
+        t.Skip("MONGODB_URI not set")
+    }
+    store, err := OpenMongoStore(uri)
+    if err != nil {
+        t.Fatal(err)
+    }
+    database := store.client.Database(fmt.Sprintf("hashgraph_test_%d", time.Now().UnixNano()))
+    store.events = database.Collection(mongoEventsCollection)
+    t.Cleanup(func() {
+        database.Drop(context.Background())
+        store.Close()
+    })
+    return store
+}
+
+func TestMongoStoreContract(t *testing.T) {
+    testStoreContract(t, testMongoStore(t))
+}
+
+func TestOpenStore(t *testing.T) {
+    for _, spec := range []string{"memory", "file:" + t.TempDir()} {
+        store, err := openStore(spec)
+        if err != nil {
+            t.Fatalf("%s: %v", spec, err)
+        }
+        testStoreContract(t, store)
+    }
+    for _, spec := range []string{"mongodb", "file:", ""} {
+        if _, err := openStore(spec); !errors.Is(err, errUnknownStore) {
+            t.Fatalf("%q: %v", spec, err)
+        }
+    }
+    // A MongoDB URI opens a MongoDB store, failing here as nothing listens on the port
+    if _, err := openStore("mongodb://127.0.0.1:1/hashgraphDB?serverSelectionTimeoutMS=100"); err == nil || errors.Is(err, errUnknownStore) {
+        t.Fatalf("unreachable MongoDB store: %v", err)
+    }
+}
+
+func TestLoadStoreVerifiesEvents(t *testing.T) {
+    graph := buildTestGraph(t, 131, 4, 60)
+    members := testCreators(graph)
+    store := NewFileEventStore(t.TempDir())
+    corrupt := copyTestEvents(graph[30:31])[0]
+    corrupt.Transactions = [][]byte{[]byte("tampered on disk")}
+    for _, event := range graph {
+        if event.Hash == corrupt.Hash {
+            event = corrupt
+        }
+        if err := store.Put(event); err != nil {
+            t.Fatal(err)
+        }
+    }
+    load := func(verify StoreVerify, quarantine func(*Event, error)) (*Hashgraph, error) {
+        hg := NewHashgraph(nil, nil)
+        hg.roomID = defaultRoom
+        hg.SetMembers(members)
+        _, err := hg.LoadStore(store, verify, quarantine)
+        return hg, err
+    }
+
+    // Strict mode refuses to start on corruption
+    _, err := load(StoreVerifyStrict, nil)
+    var corruptErr *CorruptEventError
+    if !errors.As(err, &corruptErr) || corruptErr.Hash != corrupt.Hash || !errors.Is(err, errHashMismatch) {
+        t.Fatalf("strict load: %v", err)
+    }
+
+    // Lenient mode quarantines the event and loads everything that does not depend on it
+    var quarantined []string
+    hg, err := load(StoreVerifyLenient, func(event *Event, reason error) {
+        if !errors.Is(reason, errHashMismatch) {
+            t.Errorf("quarantined for %v", reason)
+        }
+        quarantined = append(quarantined, event.Hash)
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(quarantined, []string{corrupt.Hash}) {
+        t.Fatalf("quarantined %d events", len(quarantined))
+    }
+    byHash := make(map[string]*Event, len(graph))
+    for _, event := range graph {
+        byHash[event.Hash] = event
+    }
+    for _, event := range graph {
+        _, loaded := hg.GetEvent(event.Hash)
+        if loaded == walksTo(byHash, event, corrupt) {
+            t.Fatalf("event %s loaded %v", shortID(event.Hash), loaded)
+        }
+    }
+
+    if _, err := storeVerifyByName("paranoid"); !errors.Is(err, errUnknownStoreVerify) {
+        t.Fatalf("unknown mode: %v", err)
+    }
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Stored event names a parent the store does not hold
+var errParentNotStored = errors.New("parent not in store")
+
+// Stored event failing a consistency check
+type StoreProblem struct {
+    Hash  string `json:"hash"`
+    Error string `json:"error"`
+}
+
+// Result of checking a store offline
+type StoreReport struct {
+    Events  int            `json:"events"`
+    Corrupt []StoreProblem `json:"corrupt"` // hash or signature does not verify
+    Orphans []StoreProblem `json:"orphans"` // a parent is not in the store
+}
+
+// Whether the store passed every check
+func (r *StoreReport) OK() bool {
+    return len(r.Corrupt) == 0 && len(r.Orphans) == 0
+}
+
+// Check every event in a store, a page at a time: its hash must match its contents, its signature
+// must verify against its creator's key, and its parents must be in the store. Parents are
+// resolved once all pages are read, so the check does not rely on the store's order.
+func CheckStore(store Store) (*StoreReport, error) {
+    report := &StoreReport{Corrupt: []StoreProblem{}, Orphans: []StoreProblem{}}
+    stored := make(map[string]bool)
+    parents := make(map[string][]string)
+    cursor := ""
+    for {
+        events, next, err := store.LoadEvents(cursor, defaultStorePageSize)
+        if err != nil {
+            return nil, fmt.Errorf("load stored events: %w", err)
+        }
+        if len(events) == 0 {
+            break
+        }
+        cursor = next
+        for _, event := range events {
+            report.Events++
+            stored[event.Hash] = true
+            if err := verifyEventIntegrity(event); err != nil {
+                report.Corrupt = append(report.Corrupt, StoreProblem{Hash: event.Hash, Error: err.Error()})
+            }
+            for _, parent := range append([]string{event.SelfParent}, event.OtherParents()...) {
+                if parent != "" {
+                    parents[event.Hash] = append(parents[event.Hash], parent)
+                }
+            }
+        }
+    }
+    for hash, eventParents := range parents {
+        for _, parent := range eventParents {
+            if !stored[parent] {
+                report.Orphans = append(report.Orphans, StoreProblem{Hash: hash, Error: fmt.Sprintf("%v: %s", errParentNotStored, parent)})
+                break
+            }
+        }
+    }
+    sort.Slice(report.Orphans, func(i, j int) bool { return report.Orphans[i].Hash < report.Orphans[j].Hash })
+    return report, nil
+}
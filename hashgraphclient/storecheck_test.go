@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+)
+
+// Seed a store with one tampered event and one event whose self-parent is missing, and check
+// the report names both
+func testCheckStoreFindsProblems(t *testing.T, store Store) {
+    graph := buildTestGraph(t, 81, 3, 30)
+    if err := store.EnsureIndexes(); err != nil {
+        t.Fatal(err)
+    }
+    events := copyTestEvents(graph)
+    corrupt := events[10]
+    corrupt.Transactions = [][]byte{[]byte("tampered")}
+    for _, event := range events {
+        if err := store.Put(event); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    // Drop an event whose hash is some other event's self-parent
+    var orphan *Event
+    for _, event := range events[20:] {
+        if event.SelfParent != "" && event.SelfParent != corrupt.Hash {
+            orphan = event
+            break
+        }
+    }
+    if err := store.Delete(orphan.SelfParent); err != nil {
+        t.Fatal(err)
+    }
+
+    report, err := CheckStore(store)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if report.OK() || report.Events != len(events)-1 {
+        t.Fatalf("report %+v", report)
+    }
+    if len(report.Corrupt) != 1 || report.Corrupt[0].Hash != corrupt.Hash {
+        t.Fatalf("corrupt events %+v, want %s", report.Corrupt, shortID(corrupt.Hash))
+    }
+    found := false
+    for _, problem := range report.Orphans {
+        if problem.Hash == orphan.Hash {
+            found = true
+        }
+    }
+    if !found {
+        t.Fatalf("orphans %+v do not include %s", report.Orphans, shortID(orphan.Hash))
+    }
+}
+
+func TestCheckStoreReportsCorruptAndOrphanEvents(t *testing.T) {
+    testCheckStoreFindsProblems(t, NewFileEventStore(t.TempDir()))
+}
+
+func TestCheckMongoStore(t *testing.T) {
+    testCheckStoreFindsProblems(t, testMongoStore(t))
+}
+
+func TestCheckStoreCleanStore(t *testing.T) {
+    store := NewMemoryStore()
+    for _, event := range copyTestEvents(buildTestGraph(t, 82, 3, 20)) {
+        if err := store.Put(event); err != nil {
+            t.Fatal(err)
+        }
+    }
+    report, err := CheckStore(store)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !report.OK() || report.Events != 20 {
+        t.Fatalf("report %+v", report)
+    }
+}
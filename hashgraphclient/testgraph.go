@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+	"math/rand"
+	"time"
+)
+
+// Start of the timestamps in generated graphs
+var testGraphEpoch = time.Unix(1700000000, 0).UTC()
+
+// Builder arguments out of range
+var errTestGraphSize = errors.New("test graph needs at least two members and one event")
+
+// Member keys derived from a seed. Scalars come from a seeded PRNG, so the same seed always
+// yields the same keys; these keys are for generated graphs only, never for real nodes.
+func seededKeys(seed int64, members int) []*ecdsa.PrivateKey {
+    curve := elliptic.P256()
+    n := curve.Params().N
+    random := rand.New(rand.NewSource(seed))
+    keys := make([]*ecdsa.PrivateKey, members)
+    for i := range keys {
+        scalar := make([]byte, scalarSize(curve))
+        random.Read(scalar)
+        d := new(big.Int).SetBytes(scalar)
+        d.Mod(d, new(big.Int).Sub(n, big.NewInt(1)))
+        d.Add(d, big.NewInt(1))
+        x, y := curve.ScalarBaseMult(d.FillBytes(make([]byte, scalarSize(curve))))
+        keys[i] = &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y}, D: d}
+    }
+    return keys
+}
+
+// Build a valid random graph for benchmarks and regression tests: each event is made by a
+// random member on top of its own latest event and another member's latest event, one second
+// after the previous one. The same seed, member count and event count give the same keys,
+// parents, timestamps and hashes. Signatures are fresh each time, since ECDSA signing draws
+// its own randomness, so only coin-round fame decisions can differ between builds.
+// Events are returned parents first, ready for Replay.
+func BuildTestGraph(seed int64, members, events int) ([]*Event, []*ecdsa.PrivateKey, error) {
+    if members < 2 || events < 1 {
+        return nil, nil, errTestGraphSize
+    }
+    keys := seededKeys(seed, members)
+    creators := make([]string, members)
+    for i, key := range keys {
+        creators[i] = PublicKeyHex(&key.PublicKey)
+    }
+    random := rand.New(rand.NewSource(seed))
+
+    heads := make([]*Event, members)
+    graph := make([]*Event, 0, events)
+    for i := 0; i < events; i++ {
+        creator := random.Intn(members)
+        other := random.Intn(members - 1)
+        if other >= creator {
+            other++
+        }
+
+        event := &Event{
+            Transactions:  [][]byte{[]byte("tx " + creators[creator][:8])},
+            Creator:       creators[creator],
+            Timestamp:     testGraphEpoch.Add(time.Duration(i) * time.Second),
+            HashAlgorithm: defaultHashAlgorithm,
+            RoomID:        defaultRoom,
+            LamportTime:   1,
+        }
+        for _, parent := range []*Event{heads[creator], heads[other]} {
+            if parent != nil && parent.LamportTime >= event.LamportTime {
+                event.LamportTime = parent.LamportTime + 1
+            }
+        }
+        if heads[creator] != nil {
+            event.SelfParent = heads[creator].Hash
+        }
+        if heads[other] != nil {
+            event.OtherParent = heads[other].Hash
+        }
+
+        hash, err := hashEvent(event)
+        if err != nil {
+            return nil, nil, err
+        }
+        event.Hash = hash
+        if err := signEvent(event, keys[creator]); err != nil {
+            return nil, nil, err
+        }
+        heads[creator] = event
+        graph = append(graph, event)
+    }
+    return graph, keys, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBuildTestGraphDeterministic(t *testing.T) {
+    first, firstKeys, err := BuildTestGraph(51, 4, 100)
+    if err != nil {
+        t.Fatal(err)
+    }
+    second, secondKeys, err := BuildTestGraph(51, 4, 100)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i, key := range firstKeys {
+        if key.D.Cmp(secondKeys[i].D) != 0 {
+            t.Fatalf("key %d differs between builds with the same seed", i)
+        }
+    }
+    for i, a := range first {
+        b := second[i]
+        if a.Hash != b.Hash || a.Creator != b.Creator || a.SelfParent != b.SelfParent ||
+            a.OtherParent != b.OtherParent || !a.Timestamp.Equal(b.Timestamp) || a.LamportTime != b.LamportTime {
+            t.Fatalf("event %d differs between builds with the same seed", i)
+        }
+    }
+
+    other, _, err := BuildTestGraph(52, 4, 100)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if other[0].Hash == first[0].Hash {
+        t.Fatal("different seeds gave the same graph")
+    }
+}
+
+func TestBuildTestGraphValid(t *testing.T) {
+    graph, keys, err := BuildTestGraph(53, 3, 60)
+    if err != nil {
+        t.Fatal(err)
+    }
+    members := make(map[string]bool)
+    for _, key := range keys {
+        members[PublicKeyHex(&key.PublicKey)] = true
+    }
+    seen := make(map[string]bool)
+    for _, event := range graph {
+        if !members[event.Creator] {
+            t.Fatalf("event %s by a creator outside the keys", shortID(event.Hash))
+        }
+        if err := checkEventHash(event); err != nil {
+            t.Fatalf("event %s: %v", shortID(event.Hash), err)
+        }
+        publicKey, _ := publicKeyFromHex(event.Creator)
+        if !verifyEventSignature(event, publicKey) {
+            t.Fatalf("event %s: signature does not verify", shortID(event.Hash))
+        }
+        for _, parent := range []string{event.SelfParent, event.OtherParent} {
+            if parent != "" && !seen[parent] {
+                t.Fatalf("event %s comes before its parent", shortID(event.Hash))
+            }
+        }
+        seen[event.Hash] = true
+    }
+
+    if _, _, err := BuildTestGraph(1, 1, 10); !errors.Is(err, errTestGraphSize) {
+        t.Fatalf("one member: %v", err)
+    }
+}
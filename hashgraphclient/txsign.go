@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+)
+
+// Transaction signatures do not match the transactions they cover
+var errInvalidTransactionSignature = errors.New("invalid transaction signature")
+
+// Signature binding one transaction to its author, so batched events stay attributable
+type TransactionSignature struct {
+    Author    string // creator ID of the author
+    Signature string
+}
+
+// signing input for a transaction
+func transactionDigest(tx []byte) []byte {
+    hash := newDomainHash(defaultHasher(), txSignDomain)
+    writeField(hash, tx)
+    return hash.Sum(nil)
+}
+
+// Sign a transaction as its author
+func SignTransaction(tx []byte, privateKey *ecdsa.PrivateKey) (TransactionSignature, error) {
+    r, s, err := ecdsa.Sign(rand.Reader, privateKey, transactionDigest(tx))
+    if err != nil {
+        return TransactionSignature{}, err
+    }
+    return TransactionSignature{
+        Author:    PublicKeyHex(&privateKey.PublicKey),
+        Signature: hex.EncodeToString(encodeSignature(privateKey.Curve, r, s)),
+    }, nil
+}
+
+// Verify the per-transaction signatures of an event, if it carries any
+func verifyTransactionSignatures(event *Event) error {
+    if len(event.TransactionSignatures) == 0 {
+        return nil
+    }
+    if len(event.TransactionSignatures) != len(event.Transactions) {
+        return errInvalidTransactionSignature
+    }
+    for i, tx := range event.Transactions {
+        txSignature := event.TransactionSignatures[i]
+        publicKey, err := publicKeyFromHex(txSignature.Author)
+        if err != nil {
+            return err
+        }
+        signature, err := hex.DecodeString(txSignature.Signature)
+        if err != nil {
+            return errInvalidTransactionSignature
+        }
+        r, s, ok := decodeSignature(publicKey.Curve, signature)
+        if !ok || !ecdsa.Verify(publicKey, transactionDigest(tx), r, s) {
+            return errInvalidTransactionSignature
+        }
+    }
+    return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransactionSignaturesAttributeBatchedTransactions(t *testing.T) {
+    authors := seededKeys(201, 2)
+    event := testEvent()
+    event.Transactions = [][]byte{[]byte("from alice"), []byte("from bob")}
+    for i, tx := range event.Transactions {
+        signature, err := SignTransaction(tx, authors[i])
+        if err != nil {
+            t.Fatal(err)
+        }
+        event.TransactionSignatures = append(event.TransactionSignatures, signature)
+    }
+    if err := verifyTransactionSignatures(event); err != nil {
+        t.Fatal(err)
+    }
+    if transactionAuthor(event, 1) != PublicKeyHex(&authors[1].PublicKey) {
+        t.Fatal("batched transaction not attributed to its signer")
+    }
+
+    swapped := *event
+    swapped.TransactionSignatures = []TransactionSignature{event.TransactionSignatures[1], event.TransactionSignatures[0]}
+    if err := verifyTransactionSignatures(&swapped); !errors.Is(err, errInvalidTransactionSignature) {
+        t.Fatalf("swapped signatures: %v", err)
+    }
+    short := *event
+    short.TransactionSignatures = event.TransactionSignatures[:1]
+    if err := verifyTransactionSignatures(&short); !errors.Is(err, errInvalidTransactionSignature) {
+        t.Fatalf("missing signature: %v", err)
+    }
+}
+
+func TestValidateRejectsForgedTransactionSignature(t *testing.T) {
+    author := seededKeys(202, 1)[0]
+    signature, err := SignTransaction([]byte("signed text"), author)
+    if err != nil {
+        t.Fatal(err)
+    }
+    forged := rewriteTestEvents(t, buildTestGraph(t, 202, 2, 1), func(event *Event) {
+        event.TransactionSignatures = []TransactionSignature{signature}
+    })[0]
+    hg := NewHashgraph(nil, nil)
+    result, err := hg.AddRemoteEvent(forged)
+    expectRejected(t, result, err, "validate", errInvalidTransactionSignature)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Largest verify request body accepted
+const maxVerifyBody = 1 << 20
+
+// Public key that is neither a creator ID nor a PEM EC public key
+var errInvalidPublicKey = errors.New("public key must be a hex creator ID or a PEM EC public key")
+
+// Event and the key it should be checked against
+type verifyRequest struct {
+    Event     *Event `json:"event"`
+    PublicKey string `json:"publicKey"` // hex creator ID, or PEM
+}
+
+// Outcome of checking an event
+type verifyResponse struct {
+    HashValid      bool   `json:"hashValid"`
+    SignatureValid bool   `json:"signatureValid"`
+    CreatorMatches bool   `json:"creatorMatches"` // the key is the one the event's creator ID encodes
+    Error          string `json:"error,omitempty"`
+}
+
+// Parse a public key given as a creator ID or as PEM
+func parsePublicKey(value string) (*ecdsa.PublicKey, error) {
+    value = strings.TrimSpace(value)
+    if !strings.HasPrefix(value, "-----BEGIN") {
+        return publicKeyFromHex(value)
+    }
+    block, _ := pem.Decode([]byte(value))
+    if block == nil {
+        return nil, errInvalidPublicKey
+    }
+    key, err := x509.ParsePKIXPublicKey(block.Bytes)
+    if err != nil {
+        return nil, err
+    }
+    publicKey, ok := key.(*ecdsa.PublicKey)
+    if !ok {
+        return nil, errInvalidPublicKey
+    }
+    return publicKey, nil
+}
+
+// Check a single event's hash and signature against a supplied key, so auditors can verify events without running a node
+func verifyHandler(w http.ResponseWriter, r *http.Request) {
+    var request verifyRequest
+    if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxVerifyBody)).Decode(&request); err != nil || request.Event == nil {
+        http.Error(w, "invalid request", http.StatusBadRequest)
+        return
+    }
+    publicKey, err := parsePublicKey(request.PublicKey)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    var response verifyResponse
+    hash, err := hashEvent(request.Event)
+    if err != nil {
+        response.Error = err.Error()
+    } else {
+        response.HashValid = hash == request.Event.Hash
+    }
+    response.SignatureValid = response.HashValid && verifyEventSignature(request.Event, publicKey)
+    response.CreatorMatches = PublicKeyHex(publicKey) == request.Event.Creator
+    json.NewEncoder(w).Encode(response)
+}
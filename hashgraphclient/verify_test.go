@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Post an event and key to the verify handler
+func postVerify(t *testing.T, event *Event, publicKey string) (int, verifyResponse) {
+    t.Helper()
+    body, err := json.Marshal(verifyRequest{Event: event, PublicKey: publicKey})
+    if err != nil {
+        t.Fatal(err)
+    }
+    recorder := httptest.NewRecorder()
+    verifyHandler(recorder, httptest.NewRequest(http.MethodPost, "/verify", bytes.NewReader(body)))
+    var response verifyResponse
+    if recorder.Code == http.StatusOK {
+        if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return recorder.Code, response
+}
+
+func TestVerifyHandler(t *testing.T) {
+    graph, keys, err := BuildTestGraph(89, 2, 4)
+    if err != nil {
+        t.Fatal(err)
+    }
+    event := copyTestEvents(graph)[0]
+    signer, other := keys[0], keys[1]
+    if PublicKeyHex(&signer.PublicKey) != event.Creator {
+        signer, other = other, signer
+    }
+    der, err := x509.MarshalPKIXPublicKey(&signer.PublicKey)
+    if err != nil {
+        t.Fatal(err)
+    }
+    pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+    for _, key := range []string{event.Creator, pemKey} {
+        status, response := postVerify(t, event, key)
+        if status != http.StatusOK || !response.HashValid || !response.SignatureValid || !response.CreatorMatches {
+            t.Fatalf("valid event: %d %+v", status, response)
+        }
+    }
+
+    // Another member's key does not verify the signature
+    status, response := postVerify(t, event, PublicKeyHex(&other.PublicKey))
+    if status != http.StatusOK || !response.HashValid || response.SignatureValid || response.CreatorMatches {
+        t.Fatalf("other key: %d %+v", status, response)
+    }
+
+    tampered := *event
+    tampered.Transactions = [][]byte{[]byte("forged")}
+    status, response = postVerify(t, &tampered, event.Creator)
+    if status != http.StatusOK || response.HashValid || response.SignatureValid {
+        t.Fatalf("tampered event: %d %+v", status, response)
+    }
+    // Re-hashing the tampered event does not make its signature valid
+    tampered.Hash, _ = hashEvent(&tampered)
+    status, response = postVerify(t, &tampered, event.Creator)
+    if status != http.StatusOK || !response.HashValid || response.SignatureValid {
+        t.Fatalf("re-hashed tampered event: %d %+v", status, response)
+    }
+
+    if status, _ := postVerify(t, event, "not a key"); status != http.StatusBadRequest {
+        t.Fatalf("invalid key: status %d", status)
+    }
+    if status, _ := postVerify(t, nil, event.Creator); status != http.StatusBadRequest {
+        t.Fatalf("missing event: status %d", status)
+    }
+}
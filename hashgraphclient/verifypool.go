@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// Default inbound verification workers and queued events waiting for one
+const (
+    defaultVerifyWorkers = 4
+    defaultVerifyQueue   = 256
+)
+
+// Event shed because every verification worker is busy and the queue is full
+var errVerifyQueueFull = errors.New("verification queue full")
+
+// Verification pool counters
+type VerifyPoolStats struct {
+    Workers   int `json:"workers"`
+    Queued    int `json:"queued"`
+    Active    int `json:"active"`
+    MaxActive int `json:"maxActive"` // most verifications ever running at once
+    Verified  int `json:"verified"`
+    Shed      int `json:"shed"`
+}
+
+// Fixed set of workers verifying inbound events, so a flood of events costs at most
+// workers concurrent verifications and a bounded queue rather than a goroutine each
+type VerifyPool struct {
+    workers   int
+    jobs      chan func()
+    active    int
+    maxActive int
+    verified  int
+    shed      int
+    mutex     sync.Mutex
+}
+
+// create new verification pool and start its workers
+func NewVerifyPool(workers, queue int) *VerifyPool {
+    if workers < 1 {
+        workers = 1
+    }
+    if queue < 0 {
+        queue = 0
+    }
+    vp := &VerifyPool{workers: workers, jobs: make(chan func(), queue)}
+    for i := 0; i < workers; i++ {
+        go vp.work()
+    }
+    return vp
+}
+
+// Queue a verification without blocking, failing with errVerifyQueueFull when saturated
+func (vp *VerifyPool) Submit(job func()) error {
+    select {
+    case vp.jobs <- job:
+        return nil
+    default:
+        vp.mutex.Lock()
+        vp.shed++
+        vp.mutex.Unlock()
+        return errVerifyQueueFull
+    }
+}
+
+// Run queued verifications until the pool is closed
+func (vp *VerifyPool) work() {
+    for job := range vp.jobs {
+        vp.mutex.Lock()
+        vp.active++
+        if vp.active > vp.maxActive {
+            vp.maxActive = vp.active
+        }
+        vp.mutex.Unlock()
+
+        job()
+
+        vp.mutex.Lock()
+        vp.active--
+        vp.verified++
+        vp.mutex.Unlock()
+    }
+}
+
+// Stop the workers once the queue drains; Submit must not be called afterwards
+func (vp *VerifyPool) Close() {
+    close(vp.jobs)
+}
+
+// Get the pool counters
+func (vp *VerifyPool) Stats() VerifyPoolStats {
+    vp.mutex.Lock()
+    defer vp.mutex.Unlock()
+    return VerifyPoolStats{
+        Workers:   vp.workers,
+        Queued:    len(vp.jobs),
+        Active:    vp.active,
+        MaxActive: vp.maxActive,
+        Verified:  vp.verified,
+        Shed:      vp.shed,
+    }
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVerifyPoolBoundsFlood(t *testing.T) {
+    const workers, queue, flood = 3, 5, 200
+    pool := NewVerifyPool(workers, queue)
+    defer pool.Close()
+
+    graph := buildTestGraph(t, 137, 4, flood)
+    release := make(chan struct{})
+    var mutex sync.Mutex
+    running, peak, verified := 0, 0, 0
+    accepted, shed := 0, 0
+    for _, event := range graph {
+        event := event
+        err := pool.Submit(func() {
+            mutex.Lock()
+            running++
+            if running > peak {
+                peak = running
+            }
+            mutex.Unlock()
+            <-release
+            if err := verifyEventIntegrity(event); err != nil {
+                t.Errorf("event %s: %v", shortID(event.Hash), err)
+            }
+            mutex.Lock()
+            running--
+            verified++
+            mutex.Unlock()
+        })
+        switch {
+        case err == nil:
+            accepted++
+        case errors.Is(err, errVerifyQueueFull):
+            shed++
+        default:
+            t.Fatal(err)
+        }
+    }
+    // Only the running verifications and the queue are ever held
+    if accepted < queue || accepted > workers+queue || shed != flood-accepted {
+        t.Fatalf("accepted %d and shed %d of %d", accepted, shed, flood)
+    }
+
+    close(release)
+    deadline := time.Now().Add(2 * time.Second)
+    for pool.Stats().Verified != accepted {
+        if time.Now().After(deadline) {
+            t.Fatalf("verified %d of %d accepted", pool.Stats().Verified, accepted)
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    stats := pool.Stats()
+    mutex.Lock()
+    defer mutex.Unlock()
+    if peak > workers || stats.MaxActive > workers || verified != accepted {
+        t.Fatalf("peak %d concurrent verifications, pool saw %d, limit %d", peak, stats.MaxActive, workers)
+    }
+    if stats.Shed != shed || stats.Workers != workers || stats.Active != 0 || stats.Queued != 0 {
+        t.Fatalf("stats %+v", stats)
+    }
+}
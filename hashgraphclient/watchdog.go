@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Default time consensus may go without finalizing a round
+const defaultWatchdogInterval = time.Minute
+
+// Consensus progress as seen by the watchdog
+type WatchdogState string
+
+const (
+    WatchdogProgressing WatchdogState = "progressing"
+    WatchdogIdle        WatchdogState = "idle"    // no new events, nothing to finalize
+    WatchdogStalled     WatchdogState = "stalled" // new events arrived but no round was finalized
+)
+
+// Watchdog over consensus progress
+type Watchdog struct {
+    hg               *Hashgraph
+    interval         time.Duration
+    lastRound        int
+    eventsAtProgress int
+    lastProgress     time.Time
+    state            WatchdogState
+    stalls           int
+    mutex            sync.Mutex
+}
+
+// create new watchdog
+func NewWatchdog(hg *Hashgraph, interval time.Duration) *Watchdog {
+    return &Watchdog{
+        hg:               hg,
+        interval:         interval,
+        lastRound:        hg.LastFinalizedRound(),
+        eventsAtProgress: hg.EventCount(),
+        lastProgress:     time.Now(),
+        state:            WatchdogProgressing,
+    }
+}
+
+// Check progress, logging when consensus has stalled despite new events
+func (w *Watchdog) Check(now time.Time) WatchdogState {
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+
+    round, events := w.hg.LastFinalizedRound(), w.hg.EventCount()
+    switch {
+    case round > w.lastRound:
+        w.lastRound, w.eventsAtProgress, w.lastProgress = round, events, now
+        w.state = WatchdogProgressing
+    case now.Sub(w.lastProgress) < w.interval:
+    case events > w.eventsAtProgress:
+        if w.state != WatchdogStalled {
+            w.stalls++
+            log.Printf("Consensus stalled: round %d not advanced for %s despite %d new events",
+                round, now.Sub(w.lastProgress).Round(time.Second), events-w.eventsAtProgress)
+        }
+        w.state = WatchdogStalled
+    default:
+        w.state = WatchdogIdle
+    }
+    return w.state
+}
+
+// Number of stalls detected so far
+func (w *Watchdog) Stalls() int {
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+    return w.stalls
+}
+
+// Check progress periodically
+func (w *Watchdog) Run() {
+    ticker := time.NewTicker(w.interval / 2)
+    defer ticker.Stop()
+    for now := range ticker.C {
+        w.Check(now)
+    }
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchdogTellsStallFromIdle(t *testing.T) {
+    graph := buildTestGraph(t, 181, 4, 200)
+    hg := NewHashgraph(nil, nil)
+    hg.SetMembers(testCreators(graph))
+    // Consensus is held back, so events arrive without any round being finalized
+    hg.SetMinMembers(5)
+    watchdog := NewWatchdog(hg, time.Minute)
+    start := time.Now()
+
+    if state := watchdog.Check(start.Add(2 * time.Minute)); state != WatchdogIdle {
+        t.Fatalf("no events: %s", state)
+    }
+    addTestEvents(t, hg, graph[:150])
+    if state := watchdog.Check(start.Add(30 * time.Second)); state == WatchdogStalled {
+        t.Fatal("stall reported within the interval")
+    }
+    for i := 0; i < 3; i++ {
+        if state := watchdog.Check(start.Add(2 * time.Minute)); state != WatchdogStalled {
+            t.Fatalf("events without progress: %s", state)
+        }
+    }
+    if watchdog.Stalls() != 1 {
+        t.Fatalf("one stall counted %d times", watchdog.Stalls())
+    }
+
+    hg.SetMinMembers(4)
+    addTestEvents(t, hg, graph[150:])
+    if state := watchdog.Check(start.Add(3 * time.Minute)); state != WatchdogProgressing {
+        t.Fatalf("rounds finalized: %s", state)
+    }
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Send events without the consensus fields receivers recompute anyway
+var compactWire = true
+
+// Encode an event, leaving out the consensus timestamp until it is set;
+// every other zero field is left out through its omitempty tag
+func (e Event) MarshalJSON() ([]byte, error) {
+    type plainEvent Event
+    out := struct {
+        plainEvent
+        ConsensusTimestamp *time.Time `json:",omitempty"`
+    }{plainEvent: plainEvent(e)}
+    if !e.ConsensusTimestamp.IsZero() {
+        out.ConsensusTimestamp = &e.ConsensusTimestamp
+    }
+    return json.Marshal(out)
+}
+
+// Event as sent to peers. In the compact form only what the creator hashed and signed
+// is kept; rounds, witness, fame and consensus time are derived locally by every receiver
+// and never trusted from the wire, so they are not worth the bandwidth.
+func wireEvent(event *Event) *Event {
+    if !compactWire {
+        return event
+    }
+    return &Event{
+        Transactions:          event.Transactions,
+        TransactionSignatures: event.TransactionSignatures,
+        SelfParent:            event.SelfParent,
+        OtherParent:           event.OtherParent,
+        ExtraParents:          event.ExtraParents,
+        Creator:               event.Creator,
+        Timestamp:             event.Timestamp,
+        Signature:             event.Signature,
+        Hash:                  event.Hash,
+        LamportTime:           event.LamportTime,
+        HashAlgorithm:         event.HashAlgorithm,
+        RoomID:                event.RoomID,
+        Ephemeral:             event.Ephemeral,
+        SignatureFormat:       event.SignatureFormat,
+    }
+}
+
+// Wire form of several events
+func wireEvents(events []*Event) []*Event {
+    wire := make([]*Event, len(events))
+    for i, event := range events {
+        wire[i] = wireEvent(event)
+    }
+    return wire
+}
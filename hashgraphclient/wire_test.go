@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompactFramesKeepConsensus(t *testing.T) {
+    graph := buildTestGraph(t, 103, 4, 200)
+    members := testCreators(graph)
+    sender := testHashgraph(t, graph, members)
+
+    receiver := NewHashgraph(nil, nil)
+    receiver.SetMembers(members)
+    full, compact := 0, 0
+    for _, event := range sender.SnapshotEvents() {
+        frame, err := json.Marshal(wireEvent(event))
+        if err != nil {
+            t.Fatal(err)
+        }
+        verbose, err := json.Marshal(event)
+        if err != nil {
+            t.Fatal(err)
+        }
+        full += len(verbose)
+        compact += len(frame)
+        for _, field := range []string{"RoundCreated", "Famous", "Witness", "RoundReceived", "ConsensusTimestamp"} {
+            if strings.Contains(string(frame), `"`+field+`"`) {
+                t.Fatalf("compact frame carries %s: %s", field, frame)
+            }
+        }
+
+        var received Event
+        if err := json.Unmarshal(frame, &received); err != nil {
+            t.Fatal(err)
+        }
+        // The hash input survives the compact form
+        if hash, err := hashEvent(&received); err != nil || hash != event.Hash {
+            t.Fatalf("event %s rehashed to %s: %v", shortID(event.Hash), shortID(hash), err)
+        }
+        if result, err := receiver.AddRemoteEvent(&received); result != AddInserted {
+            t.Fatalf("event %s: %v %v", shortID(event.Hash), result, err)
+        }
+    }
+    if compact >= full {
+        t.Fatalf("compact frames %d bytes, full %d", compact, full)
+    }
+    if len(receiver.ConsensusOrder) == 0 || !reflect.DeepEqual(orderHashes(receiver), orderHashes(sender)) {
+        t.Fatal("receiver of compact frames reached a different order")
+    }
+}
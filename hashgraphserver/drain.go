@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default time a draining session gets to flush its queued messages before it is closed
+const defaultDrainTimeout = 5 * time.Second
+
+// Time a draining session gets, set in main
+var drainTimeout = defaultDrainTimeout
+
+// Session to drain is not connected
+var errUnknownSession = errors.New("unknown session")
+
+// Drain state of one connected session
+type sessionDrain struct {
+    conn      *sessionConn
+    requested chan struct{}
+    once      sync.Once
+}
+
+// Ask the session to drain: its reader stops, so nothing more is queued
+func (d *sessionDrain) Request() {
+    d.once.Do(func() {
+        close(d.requested)
+        d.conn.SetReadDeadline(time.Now())
+    })
+}
+
+// Whether the session has been asked to drain
+func (d *sessionDrain) Requested() bool {
+    select {
+    case <-d.requested:
+        return true
+    default:
+        return false
+    }
+}
+
+// Finish draining once the reader has stopped: wait for the session's queued messages to be
+// forwarded, then send a draining notice so the peer resyncs elsewhere, and close
+func (d *sessionDrain) Finish(nodeID string, flushed <-chan struct{}) {
+    timer := time.NewTimer(drainTimeout)
+    defer timer.Stop()
+    select {
+    case <-flushed:
+    case <-timer.C:
+        log.Printf("Drain of %s timed out with messages still queued", nodeID)
+    }
+    notice := Message{Type: "draining", NodeID: nodeID}
+    if err := d.conn.WriteJSONBefore(notice, time.Now().Add(drainTimeout)); err != nil {
+        log.Println("Failed to send draining notice:", err)
+    }
+    d.conn.Close()
+    log.Println("Session drained:", nodeID)
+}
+
+// Drain state of connected sessions
+type SessionDrains struct {
+    drains map[string]*sessionDrain
+    mutex  sync.Mutex
+}
+
+var sessionDrains = SessionDrains{
+    drains: make(map[string]*sessionDrain),
+}
+
+// Track a newly connected session
+func (sd *SessionDrains) Open(nodeID string, conn *sessionConn) *sessionDrain {
+    sd.mutex.Lock()
+    defer sd.mutex.Unlock()
+    d := &sessionDrain{conn: conn, requested: make(chan struct{})}
+    sd.drains[nodeID] = d
+    return d
+}
+
+// Stop tracking a session once it has disconnected
+func (sd *SessionDrains) Close(nodeID string) {
+    sd.mutex.Lock()
+    defer sd.mutex.Unlock()
+    delete(sd.drains, nodeID)
+}
+
+// Ask a connected session to drain and disconnect
+func (sd *SessionDrains) Drain(nodeID string) error {
+    sd.mutex.Lock()
+    d, ok := sd.drains[nodeID]
+    sd.mutex.Unlock()
+    if !ok {
+        return fmt.Errorf("%w %s", errUnknownSession, nodeID)
+    }
+    d.Request()
+    return nil
+}
+
+// Require the ADMIN_TOKEN bearer token, disabling the endpoint when it is unset
+func adminHandler(next http.HandlerFunc) http.HandlerFunc {
+    token := os.Getenv("ADMIN_TOKEN")
+    return func(w http.ResponseWriter, r *http.Request) {
+        if token == "" {
+            http.Error(w, "admin endpoints disabled", http.StatusNotFound)
+            return
+        }
+        presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+        if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+            http.Error(w, "unauthorized", http.StatusUnauthorized)
+            return
+        }
+        next(w, r)
+    }
+}
+
+// Drain and disconnect a session
+func drainHandler(w http.ResponseWriter, r *http.Request) {
+    nodeID := r.PathValue("id")
+    if err := sessionDrains.Drain(nodeID); err != nil {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+    log.Println("Draining session:", nodeID)
+    w.WriteHeader(http.StatusAccepted)
+}
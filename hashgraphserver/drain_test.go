@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Drain a session through the admin endpoint
+func requestDrain(t *testing.T, nodeID string) int {
+    t.Helper()
+    mux := http.NewServeMux()
+    mux.HandleFunc("POST /admin/sessions/{id}/drain", adminHandler(drainHandler))
+    request := httptest.NewRequest(http.MethodPost, "/admin/sessions/"+nodeID+"/drain", nil)
+    request.Header.Set("Authorization", "Bearer drain-token")
+    recorder := httptest.NewRecorder()
+    mux.ServeHTTP(recorder, request)
+    return recorder.Code
+}
+
+func TestDrainFlushesQueuedMessages(t *testing.T) {
+    t.Setenv("ADMIN_TOKEN", "drain-token")
+    savedConfig, savedPolicy, savedLog, savedTimeout := inboundConfig, creatorPolicy, eventStore, drainTimeout
+    t.Cleanup(func() { inboundConfig, creatorPolicy, eventStore, drainTimeout = savedConfig, savedPolicy, savedLog, savedTimeout })
+    var err error
+    eventStore, err = OpenRelayLog(filepath.Join(t.TempDir(), "relay.jsonl"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { eventStore.Close() })
+    inboundConfig = InboundConfig{Size: 8, Policy: QueueFullDrop}
+
+    for _, c := range []struct {
+        timeout  time.Duration
+        timesOut bool
+    }{{time.Second, false}, {50 * time.Millisecond, true}} {
+        drainTimeout = c.timeout
+        creatorPolicy, err = LoadCreatorPolicy(writeTestPolicy(t, "", `{}`))
+        if err != nil {
+            t.Fatal(err)
+        }
+        receiver, to := dialSignal(t, "")
+        // The sender's handler stalls on the locked policy, so its events wait in the queue
+        creatorPolicy.mutex.Lock()
+        sender, from := dialSignal(t, "")
+        for i := 0; i < 3; i++ {
+            event, _ := json.Marshal(Message{Type: "event", TargetNode: to.NodeID, Event: json.RawMessage(fmt.Sprintf(`{"Creator":"alice","Hash":"%d"}`, i))})
+            if err := sender.WriteMessage(websocket.TextMessage, event); err != nil {
+                t.Fatal(err)
+            }
+        }
+        if !waitForMetric(fmt.Sprintf(`signal_inbound_queue_depth{session=%q} 2`, from.NodeID), time.Second) {
+            creatorPolicy.mutex.Unlock()
+            t.Fatal("events not queued")
+        }
+        if code := requestDrain(t, from.NodeID); code != http.StatusAccepted {
+            creatorPolicy.mutex.Unlock()
+            t.Fatalf("drain: status %d", code)
+        }
+
+        if c.timesOut {
+            // Past the timeout the session is closed with its messages still queued
+            notice := readUntil(sender, time.Second)
+            creatorPolicy.mutex.Unlock()
+            if notice == nil || notice.Type != "draining" || notice.NodeID != from.NodeID {
+                t.Fatalf("timed out drain sent %+v", notice)
+            }
+        } else {
+            time.Sleep(50 * time.Millisecond)
+            creatorPolicy.mutex.Unlock()
+        }
+        // Every queued event is forwarded, before the draining notice unless the drain timed out
+        for i := 0; i < 3; i++ {
+            msg := readUntil(receiver, time.Second)
+            if msg == nil || msg.Type != "event" || msg.SourceNode != from.NodeID {
+                t.Fatalf("queued event %d: %+v", i, msg)
+            }
+        }
+        if !c.timesOut {
+            if notice := readUntil(sender, time.Second); notice == nil || notice.Type != "draining" || notice.NodeID != from.NodeID {
+                t.Fatalf("drained session sent %+v", notice)
+            }
+        }
+        if msg := readTestMessage(sender); msg != nil {
+            t.Fatalf("drained connection still open, sent %+v", msg)
+        }
+        waitForSession(t, from.NodeID, false)
+        receiver.Close()
+        waitForSession(t, to.NodeID, false)
+    }
+
+    if code := requestDrain(t, "no-such-session"); code != http.StatusNotFound {
+        t.Fatalf("unknown session: status %d", code)
+    }
+}
+
+// Read the next message, waiting up to timeout
+func readUntil(conn *websocket.Conn, timeout time.Duration) *Message {
+    conn.SetReadDeadline(time.Now().Add(timeout))
+    var msg Message
+    if err := conn.ReadJSON(&msg); err != nil {
+        return nil
+    }
+    return &msg
+}
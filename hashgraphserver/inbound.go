@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Default number of inbound messages buffered per session
+const defaultInboundQueueSize = 64
+
+// What to do with a message arriving at a full inbound queue
+type QueueFullPolicy string
+
+const (
+    QueueFullDrop   QueueFullPolicy = "drop"   // discard the message silently
+    QueueFullReject QueueFullPolicy = "reject" // discard it and tell the sender with a queue_full message
+)
+
+// Policy name not recognized
+var errUnknownQueueFullPolicy = errors.New("unknown queue full policy")
+
+// Parse a full-queue policy from its flag value
+func parseQueueFullPolicy(name string) (QueueFullPolicy, error) {
+    switch policy := QueueFullPolicy(name); policy {
+    case QueueFullDrop, QueueFullReject:
+        return policy, nil
+    }
+    return "", fmt.Errorf("%w %q", errUnknownQueueFullPolicy, name)
+}
+
+// Inbound queue settings, shared by every session
+type InboundConfig struct {
+    Size   int
+    Policy QueueFullPolicy
+}
+
+var inboundConfig = InboundConfig{Size: defaultInboundQueueSize, Policy: QueueFullDrop}
+
+// Bounded buffer of raw messages read from one session, drained by its handler
+type InboundQueue struct {
+    messages chan []byte
+}
+
+// Queue a message without blocking, reporting false when the queue is full
+func (q *InboundQueue) Offer(message []byte) bool {
+    select {
+    case q.messages <- message:
+        return true
+    default:
+        return false
+    }
+}
+
+// Messages in arrival order, closed once the reader stops
+func (q *InboundQueue) Messages() <-chan []byte {
+    return q.messages
+}
+
+// Number of messages waiting to be handled
+func (q *InboundQueue) Depth() int {
+    return len(q.messages)
+}
+
+// Inbound queues of connected sessions, with counters of messages lost to full queues
+type QueueMetrics struct {
+    queues   map[string]*InboundQueue
+    dropped  int
+    rejected int
+    mutex    sync.Mutex
+}
+
+var inboundQueues = QueueMetrics{
+    queues: make(map[string]*InboundQueue),
+}
+
+// Create the inbound queue of a session
+func (qm *QueueMetrics) Open(nodeID string, size int) *InboundQueue {
+    qm.mutex.Lock()
+    defer qm.mutex.Unlock()
+    queue := &InboundQueue{messages: make(chan []byte, size)}
+    qm.queues[nodeID] = queue
+    return queue
+}
+
+// Close a session's queue so its handler finishes, and stop reporting it
+func (qm *QueueMetrics) Close(nodeID string) {
+    qm.mutex.Lock()
+    defer qm.mutex.Unlock()
+    if queue, ok := qm.queues[nodeID]; ok {
+        close(queue.messages)
+        delete(qm.queues, nodeID)
+    }
+}
+
+// Count a message lost to a full queue under the given policy
+func (qm *QueueMetrics) RecordFull(policy QueueFullPolicy) {
+    qm.mutex.Lock()
+    defer qm.mutex.Unlock()
+    if policy == QueueFullReject {
+        qm.rejected++
+    } else {
+        qm.dropped++
+    }
+}
+
+// Write the queue metrics in the Prometheus text format
+func (qm *QueueMetrics) Write(w io.Writer) {
+    qm.mutex.Lock()
+    defer qm.mutex.Unlock()
+
+    ids := make([]string, 0, len(qm.queues))
+    for id := range qm.queues {
+        ids = append(ids, id)
+    }
+    sort.Strings(ids)
+
+    fmt.Fprintln(w, "# HELP signal_inbound_queue_depth Inbound messages waiting to be handled, by session.")
+    fmt.Fprintln(w, "# TYPE signal_inbound_queue_depth gauge")
+    for _, id := range ids {
+        fmt.Fprintf(w, "signal_inbound_queue_depth{session=%q} %d\n", id, qm.queues[id].Depth())
+    }
+    fmt.Fprintln(w, "# HELP signal_inbound_full_total Inbound messages discarded because the session queue was full, by policy.")
+    fmt.Fprintln(w, "# TYPE signal_inbound_full_total counter")
+    fmt.Fprintf(w, "signal_inbound_full_total{policy=%q} %d\n", QueueFullDrop, qm.dropped)
+    fmt.Fprintf(w, "signal_inbound_full_total{policy=%q} %d\n", QueueFullReject, qm.rejected)
+}
+
+// Serve the queue metrics
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+    inboundQueues.Write(w)
+}
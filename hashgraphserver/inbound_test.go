@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Queue metrics as served on /metrics
+func servedQueueMetrics() string {
+    recorder := httptest.NewRecorder()
+    metricsHandler(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+    return recorder.Body.String()
+}
+
+// Wait until the served metrics contain a line
+func waitForMetric(line string, timeout time.Duration) bool {
+    deadline := time.Now().Add(timeout)
+    for time.Now().Before(deadline) {
+        if strings.Contains(servedQueueMetrics(), line+"\n") {
+            return true
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    return false
+}
+
+func TestInboundQueueBounded(t *testing.T) {
+    queue := inboundQueues.Open("queue-unit", 3)
+    defer inboundQueues.Close("queue-unit")
+    for i := 1; i <= 3; i++ {
+        if !queue.Offer([]byte("message")) || queue.Depth() != i {
+            t.Fatalf("offer %d: depth %d", i, queue.Depth())
+        }
+    }
+    if queue.Offer([]byte("message")) || queue.Depth() != 3 {
+        t.Fatal("full queue accepted a message")
+    }
+    if !strings.Contains(servedQueueMetrics(), `signal_inbound_queue_depth{session="queue-unit"} 3`+"\n") {
+        t.Fatal("queue depth not exported")
+    }
+
+    for name, want := range map[string]QueueFullPolicy{"drop": QueueFullDrop, "reject": QueueFullReject} {
+        if policy, err := parseQueueFullPolicy(name); err != nil || policy != want {
+            t.Fatalf("%s: got %q %v", name, policy, err)
+        }
+    }
+    if _, err := parseQueueFullPolicy("block"); err == nil {
+        t.Fatal("unknown policy parsed")
+    }
+}
+
+func TestInboundBurstFillsQueue(t *testing.T) {
+    savedConfig, savedPolicy, savedLog := inboundConfig, creatorPolicy, eventStore
+    t.Cleanup(func() { inboundConfig, creatorPolicy, eventStore = savedConfig, savedPolicy, savedLog })
+    var err error
+    eventStore, err = OpenRelayLog(filepath.Join(t.TempDir(), "relay.jsonl"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { eventStore.Close() })
+
+    for _, policy := range []QueueFullPolicy{QueueFullDrop, QueueFullReject} {
+        inboundConfig = InboundConfig{Size: 2, Policy: policy}
+        creatorPolicy, err = LoadCreatorPolicy(writeTestPolicy(t, "", `{}`))
+        if err != nil {
+            t.Fatal(err)
+        }
+        // The handler stalls on the policy while it is locked, so the burst piles up in the queue
+        creatorPolicy.mutex.Lock()
+        client, welcome := dialSignal(t, "")
+        event, _ := json.Marshal(Message{Type: "event", TargetNode: "nobody", Event: json.RawMessage(`{"Creator":"alice"}`)})
+        send := func() {
+            if err := client.WriteMessage(websocket.TextMessage, event); err != nil {
+                t.Fatal(err)
+            }
+        }
+        send()
+        time.Sleep(50 * time.Millisecond)
+        send()
+        send()
+        depth := fmt.Sprintf(`signal_inbound_queue_depth{session=%q} 2`, welcome.NodeID)
+        if !waitForMetric(depth, time.Second) {
+            creatorPolicy.mutex.Unlock()
+            t.Fatalf("%s: burst did not fill the queue:\n%s", policy, servedQueueMetrics())
+        }
+
+        inboundQueues.mutex.Lock()
+        before := map[QueueFullPolicy]int{QueueFullDrop: inboundQueues.dropped, QueueFullReject: inboundQueues.rejected}
+        inboundQueues.mutex.Unlock()
+        send()
+        full := fmt.Sprintf(`signal_inbound_full_total{policy=%q} %d`, policy, before[policy]+1)
+        if !waitForMetric(full, time.Second) {
+            creatorPolicy.mutex.Unlock()
+            t.Fatalf("%s: message over the bound not counted:\n%s", policy, servedQueueMetrics())
+        }
+        reply := readTestMessage(client)
+        creatorPolicy.mutex.Unlock()
+        if policy == QueueFullReject && (reply == nil || reply.Type != "queue_full") {
+            t.Fatalf("reject: reply %+v, want queue_full", reply)
+        }
+        if policy == QueueFullDrop && reply != nil {
+            t.Fatalf("drop: reply %+v", reply)
+        }
+        if !waitForMetric(fmt.Sprintf(`signal_inbound_queue_depth{session=%q} 0`, welcome.NodeID), time.Second) {
+            t.Fatalf("%s: queue not drained once the handler resumed", policy)
+        }
+
+        // End the session before the settings it reads change
+        client.Close()
+        for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+            if _, ok := sessionConnOf(welcome.NodeID); !ok {
+                break
+            }
+        }
+        if _, ok := sessionConnOf(welcome.NodeID); ok {
+            t.Fatalf("%s: session not closed", policy)
+        }
+    }
+}
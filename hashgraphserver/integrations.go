@@ -0,0 +1,400 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers carrying the request time, a one-time nonce and the hex HMAC-SHA256 over both and the body
+const (
+    integrationSignatureHeader = "X-Signature"
+    integrationTimestampHeader = "X-Timestamp"
+    integrationNonceHeader     = "X-Nonce"
+)
+
+// Default files holding the integration key and the head of its event chain in each room
+const (
+    defaultIntegrationKeyPath   = "integration.key"
+    defaultIntegrationStatePath = "integration-head.json"
+)
+
+// How far a request's timestamp may be from the server clock; nonces are remembered this long
+const integrationWindow = 5 * time.Minute
+
+// Longest nonce accepted
+const maxIntegrationNonce = 128
+
+// Room integration messages go to when they name none, the room clients join on startup
+const defaultIntegrationRoom = "lobby"
+
+// Source node integration events are relayed from; acknowledgments sent back to it are dropped
+const integrationSourceNode = "integration"
+
+// Hash algorithm and domain tags of the client event format, which integration events must match
+const (
+    integrationHashAlgorithm = "sha256"
+    eventHashDomain          = "hashgraph/event-hash/v1\x00"
+    eventSignDomain          = "hashgraph/event-sign/v1\x00"
+)
+
+// Key file does not hold a PEM-encoded key
+var errNoPEMBlock = errors.New("no PEM block in key file")
+
+// Integration key is not on a curve clients accept
+var errIntegrationCurve = errors.New("integration key curve not supported")
+
+// Request timestamp missing or outside the window
+var errStaleIntegrationRequest = errors.New("integration request timestamp outside window")
+
+// Nonce missing, too long or already used
+var errReplayedIntegrationRequest = errors.New("integration request nonce missing or reused")
+
+// Integration configuration
+type IntegrationConfig struct {
+    Secret    []byte            // shared HMAC secret, the endpoint is disabled when empty
+    ServiceID string            // creator ID events are attributed to, derived from Key
+    Key       *ecdsa.PrivateKey // signs events on behalf of the service
+    chain     *integrationChain
+    nonces    *nonceCache
+}
+
+// Message posted by an integration
+type IntegrationMessage struct {
+    Text   string `json:"text"`
+    RoomID string `json:"roomId,omitempty"`
+}
+
+// Event in the client wire format
+type integrationEvent struct {
+    Transactions  [][]byte `json:",omitempty"`
+    SelfParent    string   `json:",omitempty"`
+    Creator       string
+    Timestamp     time.Time
+    Signature     string
+    Hash          string
+    LamportTime   int
+    HashAlgorithm string `json:",omitempty"`
+    RoomID        string `json:",omitempty"`
+}
+
+// Latest integration event of a room, which the next one builds on
+type integrationHead struct {
+    Hash        string    `json:"hash"`
+    LamportTime int       `json:"lamportTime"`
+    Timestamp   time.Time `json:"timestamp"`
+}
+
+// Heads of the integration's chain in every room, persisted so a restart does not fork it
+type integrationChain struct {
+    path  string
+    heads map[string]integrationHead
+    mutex sync.Mutex
+}
+
+// Nonces seen within the window, with the time each may be forgotten
+type nonceCache struct {
+    seen  map[string]time.Time
+    mutex sync.Mutex
+}
+
+// Load integration configuration from the environment
+func loadIntegrationConfig() (*IntegrationConfig, error) {
+    config := &IntegrationConfig{
+        Secret: []byte(os.Getenv("INTEGRATION_SECRET")),
+        nonces: &nonceCache{seen: make(map[string]time.Time)},
+    }
+    if len(config.Secret) == 0 {
+        return config, nil
+    }
+
+    keyPath := os.Getenv("INTEGRATION_KEY_PATH")
+    if keyPath == "" {
+        keyPath = defaultIntegrationKeyPath
+    }
+    key, err := loadOrCreateIntegrationKey(keyPath)
+    if err != nil {
+        return nil, err
+    }
+    config.Key = key
+    if config.ServiceID, err = integrationCreatorID(&key.PublicKey); err != nil {
+        return nil, err
+    }
+
+    statePath := os.Getenv("INTEGRATION_STATE_PATH")
+    if statePath == "" {
+        statePath = defaultIntegrationStatePath
+    }
+    if config.chain, err = loadIntegrationChain(statePath); err != nil {
+        return nil, err
+    }
+    log.Println("Integration events are created by", config.ServiceID)
+    return config, nil
+}
+
+// Load the integration key, generating and saving one on first run so the service keeps its identity
+func loadOrCreateIntegrationKey(path string) (*ecdsa.PrivateKey, error) {
+    data, err := os.ReadFile(path)
+    if err == nil {
+        block, _ := pem.Decode(data)
+        if block == nil {
+            return nil, fmt.Errorf("%w: %s", errNoPEMBlock, path)
+        }
+        key, err := x509.ParseECPrivateKey(block.Bytes)
+        if err != nil {
+            return nil, fmt.Errorf("parse integration key %s: %w", path, err)
+        }
+        return key, nil
+    }
+    if !os.IsNotExist(err) {
+        return nil, fmt.Errorf("read integration key: %w", err)
+    }
+
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil {
+        return nil, fmt.Errorf("generate integration key: %w", err)
+    }
+    der, err := x509.MarshalECPrivateKey(key)
+    if err != nil {
+        return nil, fmt.Errorf("encode integration key: %w", err)
+    }
+    if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+        return nil, fmt.Errorf("write integration key: %w", err)
+    }
+    return key, nil
+}
+
+// Creator ID of a public key as clients derive it: the curve name and the hex point
+func integrationCreatorID(publicKey *ecdsa.PublicKey) (string, error) {
+    var name string
+    switch publicKey.Curve {
+    case elliptic.P256():
+        name = "P256"
+    case elliptic.P384():
+        name = "P384"
+    case elliptic.P521():
+        name = "P521"
+    default:
+        return "", errIntegrationCurve
+    }
+    return name + ":" + hex.EncodeToString(elliptic.Marshal(publicKey.Curve, publicKey.X, publicKey.Y)), nil
+}
+
+// Load the chain heads, starting a new chain when none were saved
+func loadIntegrationChain(path string) (*integrationChain, error) {
+    chain := &integrationChain{path: path, heads: make(map[string]integrationHead)}
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return chain, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("read integration state: %w", err)
+    }
+    if err := json.Unmarshal(data, &chain.heads); err != nil {
+        return nil, fmt.Errorf("decode integration state: %w", err)
+    }
+    return chain, nil
+}
+
+// Create and sign the next integration event in a room, saving the new head before the event is
+// released, so an event that was relayed is never followed by a second one on the same self-parent
+func (chain *integrationChain) next(config *IntegrationConfig, roomID string, transactions [][]byte, now time.Time) (*integrationEvent, error) {
+    chain.mutex.Lock()
+    defer chain.mutex.Unlock()
+
+    head := chain.heads[roomID]
+    event := &integrationEvent{
+        Transactions:  transactions,
+        SelfParent:    head.Hash,
+        Creator:       config.ServiceID,
+        Timestamp:     now.UTC(),
+        LamportTime:   head.LamportTime + 1,
+        HashAlgorithm: integrationHashAlgorithm,
+        RoomID:        roomID,
+    }
+    // Timestamps must increase along the chain even if the clock steps back
+    if !event.Timestamp.After(head.Timestamp) {
+        event.Timestamp = head.Timestamp.Add(time.Nanosecond)
+    }
+    event.Hash = hashIntegrationEvent(event)
+    digest := sha256.Sum256([]byte(eventSignDomain + event.Hash))
+    r, s, err := ecdsa.Sign(rand.Reader, config.Key, digest[:])
+    if err != nil {
+        return nil, fmt.Errorf("sign integration event: %w", err)
+    }
+    size := (config.Key.Curve.Params().BitSize + 7) / 8
+    signature := make([]byte, 2*size)
+    r.FillBytes(signature[:size])
+    s.FillBytes(signature[size:])
+    event.Signature = hex.EncodeToString(signature)
+
+    chain.heads[roomID] = integrationHead{Hash: event.Hash, LamportTime: event.LamportTime, Timestamp: event.Timestamp}
+    data, err := json.Marshal(chain.heads)
+    if err == nil {
+        err = os.WriteFile(chain.path, data, 0600)
+    }
+    if err != nil {
+        chain.heads[roomID] = head
+        return nil, fmt.Errorf("save integration state: %w", err)
+    }
+    return event, nil
+}
+
+// Event hash exactly as clients compute it: the domain tag, then each field length-prefixed in order
+func hashIntegrationEvent(event *integrationEvent) string {
+    buf := []byte(eventHashDomain)
+    // Raw signatures have an empty format name, and integration events have no other-parent
+    for _, field := range []string{event.HashAlgorithm, "", event.RoomID, event.Creator, event.SelfParent, ""} {
+        buf = binary.BigEndian.AppendUint32(buf, uint32(len(field)))
+        buf = append(buf, field...)
+    }
+    buf = binary.BigEndian.AppendUint64(buf, uint64(event.Timestamp.UnixNano()))
+    buf = binary.BigEndian.AppendUint64(buf, uint64(event.LamportTime))
+    buf = append(buf, 0) // not ephemeral
+    buf = binary.BigEndian.AppendUint32(buf, uint32(len(event.Transactions)))
+    for _, tx := range event.Transactions {
+        buf = binary.BigEndian.AppendUint32(buf, uint32(len(tx)))
+        buf = append(buf, tx...)
+    }
+    sum := sha256.Sum256(buf)
+    return hex.EncodeToString(sum[:])
+}
+
+// Check the HMAC over the timestamp, nonce and body against the shared secret
+func validIntegrationHMAC(secret []byte, timestamp, nonce string, body []byte, signature string) bool {
+    expected, err := hex.DecodeString(signature)
+    if err != nil {
+        return false
+    }
+    mac := hmac.New(sha256.New, secret)
+    mac.Write([]byte(timestamp + "\n" + nonce + "\n"))
+    mac.Write(body)
+    return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// Check that a request's timestamp is within the window of now
+func checkIntegrationTimestamp(timestamp string, now time.Time) error {
+    seconds, err := strconv.ParseInt(timestamp, 10, 64)
+    if err != nil {
+        return errStaleIntegrationRequest
+    }
+    skew := now.Sub(time.Unix(seconds, 0))
+    if skew > integrationWindow || skew < -integrationWindow {
+        return errStaleIntegrationRequest
+    }
+    return nil
+}
+
+// Record a nonce, failing if it was seen within the window; expired nonces are forgotten on the way
+func (nc *nonceCache) Use(nonce string, now time.Time) error {
+    if nonce == "" || len(nonce) > maxIntegrationNonce {
+        return errReplayedIntegrationRequest
+    }
+    nc.mutex.Lock()
+    defer nc.mutex.Unlock()
+    for seen, expires := range nc.seen {
+        if now.After(expires) {
+            delete(nc.seen, seen)
+        }
+    }
+    if _, ok := nc.seen[nonce]; ok {
+        return errReplayedIntegrationRequest
+    }
+    // A nonce outlives every timestamp it could be replayed with
+    nc.seen[nonce] = now.Add(2 * integrationWindow)
+    return nil
+}
+
+// Relay an integration event to every session in its room, and to the event store
+func relayIntegrationEvent(event *integrationEvent) error {
+    data, err := json.Marshal(event)
+    if err != nil {
+        return fmt.Errorf("encode integration event: %w", err)
+    }
+    msg := Message{Type: "event", Event: data, RoomID: event.RoomID, SourceNode: integrationSourceNode}
+    if eventStore != nil {
+        if err := eventStore.Append(msg); err != nil {
+            log.Println("Failed to store relayed event:", err)
+        }
+    }
+    recipients := sessionConns(func(id string) bool {
+        return sessionRooms.In(id, event.RoomID)
+    })
+    for id, conn := range recipients {
+        if err := conn.WriteJSON(msg); err != nil {
+            log.Printf("Failed to relay integration event to %s: %v", id, err)
+        }
+    }
+    return nil
+}
+
+// Post a chat message on behalf of an external service
+func integrationMessageHandler(config *IntegrationConfig) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        if len(config.Secret) == 0 {
+            http.Error(w, "integrations disabled", http.StatusNotFound)
+            return
+        }
+
+        body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+        if err != nil {
+            http.Error(w, "failed to read body", http.StatusBadRequest)
+            return
+        }
+        timestamp, nonce := r.Header.Get(integrationTimestampHeader), r.Header.Get(integrationNonceHeader)
+        if !validIntegrationHMAC(config.Secret, timestamp, nonce, body, r.Header.Get(integrationSignatureHeader)) {
+            http.Error(w, "invalid signature", http.StatusUnauthorized)
+            return
+        }
+        now := time.Now()
+        if err := checkIntegrationTimestamp(timestamp, now); err != nil {
+            http.Error(w, err.Error(), http.StatusUnauthorized)
+            return
+        }
+        if err := config.nonces.Use(nonce, now); err != nil {
+            http.Error(w, err.Error(), http.StatusUnauthorized)
+            return
+        }
+
+        var msg IntegrationMessage
+        if err := json.Unmarshal(body, &msg); err != nil || msg.Text == "" {
+            http.Error(w, "invalid message", http.StatusBadRequest)
+            return
+        }
+        if msg.RoomID == "" {
+            msg.RoomID = defaultIntegrationRoom
+        }
+
+        event, err := config.chain.next(config, msg.RoomID, [][]byte{[]byte(msg.Text)}, now)
+        if err == nil {
+            err = relayIntegrationEvent(event)
+        }
+        if err != nil {
+            log.Println("Failed to add integration event:", err)
+            http.Error(w, "failed to add event", http.StatusInternalServerError)
+            return
+        }
+        log.Printf("Integration message in %s from %s", msg.RoomID, config.ServiceID)
+        w.WriteHeader(http.StatusAccepted)
+    }
+}
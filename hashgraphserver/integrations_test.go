@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Hash the client computes for the same event, see the client's hasher tests
+const integrationHashVector = "73713eda8cf306d6f3d5e879768062e4b8f088b2ae416ef648b614277e09e299"
+
+func testIntegrationConfig(t *testing.T) *IntegrationConfig {
+    t.Helper()
+    dir := t.TempDir()
+    t.Setenv("INTEGRATION_SECRET", "secret")
+    t.Setenv("INTEGRATION_KEY_PATH", filepath.Join(dir, "integration.key"))
+    t.Setenv("INTEGRATION_STATE_PATH", filepath.Join(dir, "integration-head.json"))
+    config, err := loadIntegrationConfig()
+    if err != nil {
+        t.Fatal(err)
+    }
+    return config
+}
+
+// Post a message signed the way an integration would
+func postIntegration(config *IntegrationConfig, body, timestamp, nonce string) int {
+    mac := hmac.New(sha256.New, config.Secret)
+    mac.Write([]byte(timestamp + "\n" + nonce + "\n" + body))
+    request := httptest.NewRequest(http.MethodPost, "/integrations/message", strings.NewReader(body))
+    request.Header.Set(integrationTimestampHeader, timestamp)
+    request.Header.Set(integrationNonceHeader, nonce)
+    request.Header.Set(integrationSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+    recorder := httptest.NewRecorder()
+    integrationMessageHandler(config)(recorder, request)
+    return recorder.Code
+}
+
+func TestIntegrationRejectsReplays(t *testing.T) {
+    config := testIntegrationConfig(t)
+    now := strconv.FormatInt(time.Now().Unix(), 10)
+    body := `{"text":"deploy finished"}`
+
+    if code := postIntegration(config, body, now, "nonce-1"); code != http.StatusAccepted {
+        t.Fatalf("first request: status %d", code)
+    }
+    if code := postIntegration(config, body, now, "nonce-1"); code != http.StatusUnauthorized {
+        t.Fatalf("replayed nonce: status %d", code)
+    }
+    stale := strconv.FormatInt(time.Now().Add(-2*integrationWindow).Unix(), 10)
+    if code := postIntegration(config, body, stale, "nonce-2"); code != http.StatusUnauthorized {
+        t.Fatalf("stale timestamp: status %d", code)
+    }
+    if code := postIntegration(config, body, now, ""); code != http.StatusUnauthorized {
+        t.Fatalf("missing nonce: status %d", code)
+    }
+
+    request := httptest.NewRequest(http.MethodPost, "/integrations/message", strings.NewReader(body))
+    request.Header.Set(integrationTimestampHeader, now)
+    request.Header.Set(integrationNonceHeader, "nonce-3")
+    request.Header.Set(integrationSignatureHeader, "00")
+    recorder := httptest.NewRecorder()
+    integrationMessageHandler(config)(recorder, request)
+    if recorder.Code != http.StatusUnauthorized {
+        t.Fatalf("bad signature: status %d", recorder.Code)
+    }
+}
+
+func TestIntegrationIdentityIsKeyDerivedAndPersistent(t *testing.T) {
+    config := testIntegrationConfig(t)
+    if !strings.HasPrefix(config.ServiceID, "P256:04") {
+        t.Fatalf("service ID %q is not derived from the key", config.ServiceID)
+    }
+    again, err := loadIntegrationConfig()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if again.ServiceID != config.ServiceID {
+        t.Fatal("integration key changed across restarts")
+    }
+}
+
+func TestIntegrationEventsChain(t *testing.T) {
+    config := testIntegrationConfig(t)
+    now := time.Now()
+    first, err := config.chain.next(config, "lobby", [][]byte{[]byte("one")}, now)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // A restart reloads the head, so the next event extends the chain instead of forking it
+    chain, err := loadIntegrationChain(config.chain.path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    second, err := chain.next(config, "lobby", [][]byte{[]byte("two")}, now.Add(-time.Second))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if second.SelfParent != first.Hash || second.LamportTime != first.LamportTime+1 {
+        t.Fatalf("second event does not extend the chain: %+v", second)
+    }
+    if !second.Timestamp.After(first.Timestamp) {
+        t.Fatal("timestamp did not increase along the chain")
+    }
+
+    signature, _ := hex.DecodeString(second.Signature)
+    digest := sha256.Sum256([]byte(eventSignDomain + second.Hash))
+    size := len(signature) / 2
+    r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+    if !ecdsa.Verify(&config.Key.PublicKey, digest[:], r, s) {
+        t.Fatal("integration event signature does not verify")
+    }
+}
+
+func TestIntegrationHashMatchesClient(t *testing.T) {
+    event := &integrationEvent{
+        Transactions:  [][]byte{[]byte("hello")},
+        SelfParent:    "ab",
+        Creator:       "P256:04cd",
+        Timestamp:     time.Unix(1700000000, 5).UTC(),
+        LamportTime:   2,
+        HashAlgorithm: integrationHashAlgorithm,
+        RoomID:        "lobby",
+    }
+    if hash := hashIntegrationEvent(event); hash != integrationHashVector {
+        t.Fatalf("got %s, want %s", hash, integrationHashVector)
+    }
+}
@@ -3,10 +3,13 @@ package main
 import (
 	"crypto/ecdsa"
 	"encoding/json"
+	"flag"
 	"hashgraphserver/server" // Updated import path
 	"log"
 	"net/http"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -19,10 +22,65 @@ type Message struct {
     Candidate  string `json:"candidate,omitempty"`
     SelfParent string `json:"selfParent,omitempty"`
     OtherParent string `json:"otherParent,omitempty"`
-    Event      *server.Event `json:"event,omitempty"`
+    Event      json.RawMessage `json:"event,omitempty"` // client event, relayed as is so no field is dropped
     TargetNode string `json:"targetNode,omitempty"` // New target node field
+    SourceNode string `json:"sourceNode,omitempty"`
+    EventHash  string `json:"eventHash,omitempty"`
+    Nodes      []string `json:"nodes,omitempty"`
+    Error      string   `json:"error,omitempty"`
+    RoomID     string   `json:"roomId,omitempty"`
+    Status     string   `json:"status,omitempty"`
+    Creator    string   `json:"creator,omitempty"`
+    Events     json.RawMessage `json:"events,omitempty"`
+    NodeID     string   `json:"nodeId,omitempty"`
+    Round      int      `json:"round,omitempty"`
+    Seq        int      `json:"seq,omitempty"`
+    Frontier   map[string]string `json:"frontier,omitempty"`
+    Token      string   `json:"token,omitempty"`
+    Rooms      []string `json:"rooms,omitempty"`
+    Receipt    json.RawMessage `json:"receipt,omitempty"` // consensus receipt, relayed as is
 }
 
+// Protocol handling options
+type ProtocolConfig struct {
+    Strict            bool // answer unknown message types with a protocol_error
+    DisconnectOnError bool // in strict mode, also close the connection
+}
+
+var protocolConfig ProtocolConfig
+
+// Creator allowlist and denylist for forwarded events, nil allows all
+var creatorPolicy *CreatorPolicy
+
+// Handle a message of unknown type, reporting whether to keep the connection
+func handleUnknownType(conn *sessionConn, msgType string) bool {
+    if !protocolConfig.Strict {
+        log.Println("Ignoring unknown message type:", msgType)
+        return true
+    }
+    log.Println("Rejecting unknown message type:", msgType)
+    reply := Message{Type: "protocol_error", Error: "unknown message type: " + msgType}
+    if err := conn.WriteJSON(reply); err != nil {
+        log.Println("Failed to send protocol error:", err)
+    }
+    return !protocolConfig.DisconnectOnError
+}
+
+// Creator of a relayed event, decoding only that field
+func eventCreator(event json.RawMessage) string {
+    var header struct {
+        Creator string
+    }
+    json.Unmarshal(event, &header)
+    return header.Creator
+}
+
+// Store every relayed event is persisted to, nil to keep none
+var eventStore EventStore
+
+// Set in relay-only mode, where the server forwards and stores events without running consensus
+var relayOnly bool
+
 // Upgrade HTTP connection to WebSocket connection
 var upgrader = websocket.Upgrader{
     CheckOrigin: func(r *http.Request) bool {
@@ -30,18 +88,61 @@ var upgrader = websocket.Upgrader{
     },
 }
 
+// Connection of a session. A websocket allows one writer at a time, while the session's own
+// handler, other sessions' handlers, integrations and drains all write to it, so writes take turns.
+type sessionConn struct {
+    *websocket.Conn
+    writeMutex sync.Mutex
+}
+
+// Write a message, waiting for any write in progress
+func (sc *sessionConn) WriteJSON(v any) error {
+    sc.writeMutex.Lock()
+    defer sc.writeMutex.Unlock()
+    return sc.Conn.WriteJSON(v)
+}
+
+// Write a message that must go out before deadline
+func (sc *sessionConn) WriteJSONBefore(v any, deadline time.Time) error {
+    sc.writeMutex.Lock()
+    defer sc.writeMutex.Unlock()
+    sc.Conn.SetWriteDeadline(deadline)
+    return sc.Conn.WriteJSON(v)
+}
+
 // Session manager structure
 type SessionManager struct {
-    sessions map[string]*websocket.Conn
+    sessions map[string]*sessionConn
     mutex    sync.Mutex
 }
 
 var sessionManager = SessionManager{
-    sessions: make(map[string]*websocket.Conn),
+    sessions: make(map[string]*sessionConn),
+}
+
+// Connection of a connected session
+func sessionConnOf(id string) (*sessionConn, bool) {
+    sessionManager.mutex.Lock()
+    defer sessionManager.mutex.Unlock()
+    conn, ok := sessionManager.sessions[id]
+    return conn, ok
+}
+
+// Connections of the sessions keep accepts, so they can be written to without holding the session lock
+func sessionConns(keep func(id string) bool) map[string]*sessionConn {
+    sessionManager.mutex.Lock()
+    defer sessionManager.mutex.Unlock()
+    conns := make(map[string]*sessionConn)
+    for id, conn := range sessionManager.sessions {
+        if keep(id) {
+            conns[id] = conn
+        }
+    }
+    return conns
 }
 
 // Register new node
-func registerNode(conn *websocket.Conn) string {
+func registerNode(conn *sessionConn) string {
     sessionManager.mutex.Lock()
     defer sessionManager.mutex.Unlock()
     id := uuid.New().String()
@@ -49,6 +150,21 @@ func registerNode(conn *websocket.Conn) string {
     return id
 }
 
+// Register a node under the session its token names, if that session is not connected
+func reclaimNode(conn *sessionConn, token string) (string, error) {
+    id, err := sessionTokens.Validate(token, time.Now())
+    if err != nil {
+        return "", err
+    }
+    sessionManager.mutex.Lock()
+    defer sessionManager.mutex.Unlock()
+    if _, ok := sessionManager.sessions[id]; ok {
+        return "", errSessionInUse
+    }
+    sessionManager.sessions[id] = conn
+    return id, nil
+}
+
 // Unregister node
 func unregisterNode(id string) {
     sessionManager.mutex.Lock()
@@ -56,6 +172,18 @@ func unregisterNode(id string) {
     delete(sessionManager.sessions, id)
 }
 
+// List the IDs of connected sessions
+func listSessions() []string {
+    sessionManager.mutex.Lock()
+    defer sessionManager.mutex.Unlock()
+    nodes := make([]string, 0, len(sessionManager.sessions))
+    for id := range sessionManager.sessions {
+        nodes = append(nodes, id)
+    }
+    sort.Strings(nodes)
+    return nodes
+}
+
 // Get online nodes list
 func getNodesHandler(w http.ResponseWriter, r *http.Request) {
     nodes := server.HashgraphManagerInstance.GetNodes()
@@ -65,47 +193,110 @@ func getNodesHandler(w http.ResponseWriter, r *http.Request) {
 // WebSocket connection handler
 func signalHandler(w http.ResponseWriter, r *http.Request) {
     // Upgrade HTTP connection to WebSocket
-    conn, err := upgrader.Upgrade(w, r, nil)
+    wsConn, err := upgrader.Upgrade(w, r, nil)
     if err != nil {
         log.Println("Failed to upgrade to WebSocket:", err)
         return
     }
-    defer conn.Close()
+    defer wsConn.Close()
+    conn := &sessionConn{Conn: wsConn}
 
-    // Register node and get unique ID
-    nodeID := registerNode(conn)
+    // Reclaim the session a token was issued for, or register a new one
+    var nodeID string
+    welcome := Message{Type: "welcome"}
+    if token := r.URL.Query().Get("token"); token != "" {
+        id, err := reclaimNode(conn, token)
+        if err != nil {
+            log.Println("Rejected session token:", err)
+            welcome.Error = err.Error()
+        } else {
+            nodeID = id
+            welcome.Rooms = sessionRooms.List(nodeID)
+            log.Println("Session reclaimed:", nodeID)
+        }
+    }
+    if nodeID == "" {
+        nodeID = registerNode(conn)
+    }
+    sessionRooms.Resume(nodeID, time.Now(), sessionTokens.ttl)
     // Register node to Hashgraph manager
     server.HashgraphManagerInstance.RegisterNode(nodeID)
     defer unregisterNode(nodeID)
+    defer sessionRooms.Drop(nodeID, time.Now())
+
+    // Tell the node its session ID so it can leave itself out of its peer list, and a token to reclaim it with
+    welcome.NodeID = nodeID
+    welcome.Token = sessionTokens.Issue(nodeID, time.Now())
+    if err := conn.WriteJSON(welcome); err != nil {
+        log.Println("Failed to send welcome:", err)
+    }
+
+    // Handle messages from the session's queue, so bursts are absorbed instead of stalling the reader
+    queue := inboundQueues.Open(nodeID, inboundConfig.Size)
+    drain := sessionDrains.Open(nodeID, conn)
+    defer sessionDrains.Close(nodeID)
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        for message := range queue.Messages() {
+            if !handleMessage(conn, nodeID, message) {
+                // Evict the session, draining it so the messages it already queued are still forwarded
+                drain.Request()
+            }
+        }
+    }()
+    defer func() {
+        inboundQueues.Close(nodeID)
+        if drain.Requested() {
+            drain.Finish(nodeID, done)
+            return
+        }
+        <-done
+    }()
 
     for {
         // Read message
         _, message, err := conn.ReadMessage()
         if err != nil {
-            log.Println("Failed to read message:", err)
+            if !drain.Requested() {
+                log.Println("Failed to read message:", err)
+            }
             break
         }
-
-        // Parse message
-        var msg Message
-        err = json.Unmarshal(message, &msg)
-        if err != nil {
-            log.Println("Failed to parse message:", err)
+        if queue.Offer(message) {
             continue
         }
+        inboundQueues.RecordFull(inboundConfig.Policy)
+        log.Printf("Inbound queue of %s is full, message discarded", nodeID)
+        if inboundConfig.Policy == QueueFullReject {
+            reply := Message{Type: "queue_full", Error: "inbound queue full, message discarded"}
+            if err := conn.WriteJSON(reply); err != nil {
+                log.Println("Failed to send queue_full:", err)
+            }
+        }
+    }
+}
+
+// Handle one message from a session, reporting whether to keep the connection
+func handleMessage(conn *sessionConn, nodeID string, message []byte) bool {
+    // Parse message
+    var msg Message
+    err := json.Unmarshal(message, &msg)
+    if err != nil {
+        log.Println("Failed to parse message:", err)
+        return true
+    }
 
-        switch msg.Type {
-        case "offer":
-            log.Println("Received offer")
-            // Handle offer forwarding logic here
-        case "answer":
-            log.Println("Received answer")
-            // Handle answer forwarding logic here
-        case "candidate":
-            log.Println("Received ICE candidate")
-            // Handle ICE candidate forwarding logic here
-        case "event":
-            log.Println("Received event")
+    switch msg.Type {
+    case "offer":
+        log.Println("Received offer")
+        // Handle offer forwarding logic here
+    case "answer":
+        log.Println("Received answer")
+        // Handle answer forwarding logic here
+    case "event":
+        log.Println("Received event")
+        if !relayOnly {
             // Handle event information and update Hashgraph
             transactions := [][]byte{} // Example transactions
             privateKey := &ecdsa.PrivateKey{} // Example private key
@@ -114,32 +305,121 @@ func signalHandler(w http.ResponseWriter, r *http.Request) {
             if err != nil {
                 log.Println("Failed to add event to Hashgraph:", err)
             }
+        }
+
+        // If the target node is itself, handle the event directly
+        if msg.TargetNode == nodeID {
+            log.Println("Target node is itself, handling event directly")
+            return true
+        }
 
-            // If the target node is itself, handle the event directly
-            if msg.TargetNode == nodeID {
-                log.Println("Target node is itself, handling event directly")
-                continue
+        // Drop events from creators the policy does not allow
+        if creatorPolicy != nil && msg.Event != nil {
+            if creator := eventCreator(msg.Event); !creatorPolicy.Allowed(creator) {
+                log.Println("Rejected event from disallowed creator:", creator)
+                return true
             }
+        }
 
-            // Forward event to target node
-            if targetConn, ok := sessionManager.sessions[msg.TargetNode]; ok {
-                if err := targetConn.WriteJSON(msg); err != nil {
-                    log.Println("Failed to forward event:", err)
-                }
-            } else {
-                log.Println("Target node does not exist or has disconnected")
+        // Forward event to target node, noting the sender so it can acknowledge
+        msg.SourceNode = nodeID
+        if eventStore != nil {
+            if err := eventStore.Append(msg); err != nil {
+                log.Println("Failed to store relayed event:", err)
             }
         }
+        if targetConn, ok := sessionConnOf(msg.TargetNode); ok {
+            if err := targetConn.WriteJSON(msg); err != nil {
+                log.Println("Failed to forward event:", err)
+            }
+        } else {
+            log.Println("Target node does not exist or has disconnected")
+        }
+    case "presence", "typing", "hello", "frontier", "receipt":
+        sessionRooms.Join(nodeID, msg.RoomID)
+        forwardPresence(msg, nodeID)
+    case "list_nodes":
+        // Answer with the connected sessions on the signaling connection
+        reply := Message{Type: "nodes", Nodes: listSessions()}
+        if err := conn.WriteJSON(reply); err != nil {
+            log.Println("Failed to send node list:", err)
+        }
+    case "candidate", "ack", "event_rejected", "events_since", "events_chain", "shuffle", "shuffle_reply", "watermark", "ping", "pong":
+        // Forward ICE candidates, acknowledgments and rejections, since-hash gossip, peer shuffles, watermarks and latency probes
+        // to the target node, noting the sender so the far side can match candidates to its peer
+        if msg.TargetNode == integrationSourceNode {
+            return true
+        }
+        msg.SourceNode = nodeID
+        if targetConn, ok := sessionConnOf(msg.TargetNode); ok {
+            if err := targetConn.WriteJSON(msg); err != nil {
+                log.Printf("Failed to forward %s: %v", msg.Type, err)
+            }
+        } else {
+            log.Printf("%s target node does not exist or has disconnected", msg.Type)
+        }
+    default:
+        return handleUnknownType(conn, msg.Type)
     }
+    return true
 }
 
 func main() {
-    // Initialize MongoDB connection
-    server.HashgraphManagerInstance.InitMongoDB("mongodb://localhost:27017", "hashgraphDB")
-    defer server.HashgraphManagerInstance.CloseMongoDB()
+    flag.BoolVar(&protocolConfig.Strict, "strict", false, "reply to unknown message types with a protocol_error")
+    flag.BoolVar(&protocolConfig.DisconnectOnError, "strict-disconnect", false, "in strict mode, also disconnect on unknown message types")
+    creatorPolicyPath := flag.String("creator-policy", "", "JSON file with creator allow and deny lists, reloaded on SIGHUP")
+    flag.BoolVar(&relayOnly, "relay-only", false, "only forward and store events, leaving consensus to the clients")
+    storeSpec := flag.String("store", defaultEventStore, "store relayed events are persisted to: file:<path> for a JSON lines log, or a mongodb://<host>/<db> URI")
+    turnURLs := flag.String("turn-urls", "", "comma-separated TURN URLs served on /ice, credentials are minted from TURN_SECRET")
+    turnTTL := flag.Duration("turn-ttl", defaultTURNTTL, "lifetime of minted TURN credentials")
+    flag.IntVar(&inboundConfig.Size, "inbound-queue", defaultInboundQueueSize, "inbound messages buffered per session")
+    queueFull := flag.String("inbound-full", string(QueueFullDrop), "when a session's inbound queue is full: drop, or reject with a queue_full message")
+    sessionTTL := flag.Duration("session-ttl", defaultSessionTokenTTL, "lifetime of session tokens, and so how long a dropped session can be reclaimed")
+    flag.DurationVar(&drainTimeout, "drain-timeout", defaultDrainTimeout, "time a drained session gets to flush its queued messages before it is closed")
+    flag.Parse()
+
+    tokens, err := loadSessionTokens(*sessionTTL)
+    if err != nil {
+        log.Fatal("Failed to set up session tokens:", err)
+    }
+    sessionTokens = tokens
+
+    policy, err := parseQueueFullPolicy(*queueFull)
+    if err != nil {
+        log.Fatal("Invalid inbound queue policy:", err)
+    }
+    inboundConfig.Policy = policy
+
+    store, err := openEventStore(*storeSpec)
+    if err != nil {
+        log.Fatal("Failed to open event store:", err)
+    }
+    defer store.Close()
+    eventStore = store
+    if relayOnly {
+        log.Println("Relay-only mode, consensus is left to the clients")
+    }
+
+    if *creatorPolicyPath != "" {
+        policy, err := LoadCreatorPolicy(*creatorPolicyPath)
+        if err != nil {
+            log.Fatal("Failed to load creator policy:", err)
+        }
+        creatorPolicy = policy
+        go reloadOnHangup(policy)
+    }
+
+    integrationConfig, err := loadIntegrationConfig()
+    if err != nil {
+        log.Fatal("Failed to load integration config:", err)
+    }
 
     http.HandleFunc("/signal", signalHandler)
     http.HandleFunc("/nodes", getNodesHandler)
+    http.HandleFunc("/integrations/message", integrationMessageHandler(integrationConfig))
+    http.HandleFunc("/metrics", metricsHandler)
+    http.HandleFunc("/ice", iceHandler(loadTURNConfig(*turnURLs, *turnTTL)))
+    http.HandleFunc("POST /admin/sessions/{id}/drain", adminHandler(drainHandler))
     log.Println("Signal server started, listening on port: 8080")
     log.Fatal(http.ListenAndServe(":8080", nil))
 }
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Presence from other sessions and forwarded acks reach one session from many goroutines at once,
+// while other sessions come and go; every message must arrive whole
+func TestConcurrentWritesToOneSession(t *testing.T) {
+    client := testSession(t, "busy")
+    sessionRooms.Join("busy", "lobby")
+    sessionRooms.Join("busy-sender", "lobby")
+
+    const writers, perWriter = 4, 50
+    var wg sync.WaitGroup
+    for w := 0; w < writers; w++ {
+        wg.Add(2)
+        go func() {
+            defer wg.Done()
+            for i := 0; i < perWriter; i++ {
+                forwardPresence(Message{Type: "presence", RoomID: "lobby"}, "busy-sender")
+            }
+        }()
+        go func() {
+            defer wg.Done()
+            ack, _ := json.Marshal(Message{Type: "ack", TargetNode: "busy"})
+            for i := 0; i < perWriter; i++ {
+                handleMessage(nil, "busy-sender", ack)
+            }
+        }()
+    }
+    wg.Add(1)
+    go func() {
+        defer wg.Done()
+        for i := 0; i < perWriter; i++ {
+            id := registerNode(&sessionConn{})
+            unregisterNode(id)
+        }
+    }()
+
+    counts := make(map[string]int)
+    client.SetReadDeadline(time.Now().Add(5 * time.Second))
+    for received := 0; received < 2*writers*perWriter; received++ {
+        var msg Message
+        if err := client.ReadJSON(&msg); err != nil {
+            t.Fatalf("after %d messages: %v", received, err)
+        }
+        counts[msg.Type]++
+    }
+    wg.Wait()
+    if counts["presence"] != writers*perWriter || counts["ack"] != writers*perWriter {
+        t.Fatal(fmt.Sprint("messages received: ", counts))
+    }
+}
+
+func TestListNodesOverWebsocket(t *testing.T) {
+    client := testSession(t, "lister")
+    testSession(t, "listed")
+    conn, _ := sessionConnOf("lister")
+    request, _ := json.Marshal(Message{Type: "list_nodes"})
+    if !handleMessage(conn, "lister", request) {
+        t.Fatal("list_nodes closed the connection")
+    }
+    msg := readTestMessage(client)
+    if msg == nil || msg.Type != "nodes" {
+        t.Fatalf("reply %+v", msg)
+    }
+    found := map[string]bool{}
+    for _, id := range msg.Nodes {
+        found[id] = true
+    }
+    if !found["lister"] || !found["listed"] {
+        t.Fatalf("node list %v", msg.Nodes)
+    }
+}
+
+func TestStrictModeRejectsUnknownTypes(t *testing.T) {
+    saved := protocolConfig
+    t.Cleanup(func() { protocolConfig = saved })
+    client := testSession(t, "strict")
+    conn, _ := sessionConnOf("strict")
+    unknown, _ := json.Marshal(Message{Type: "bogus"})
+
+    protocolConfig = ProtocolConfig{}
+    if !handleMessage(conn, "strict", unknown) {
+        t.Fatal("lenient mode closed the connection")
+    }
+
+    protocolConfig = ProtocolConfig{Strict: true}
+    if !handleMessage(conn, "strict", unknown) {
+        t.Fatal("strict mode without disconnect closed the connection")
+    }
+    // Lenient mode sent nothing, so the first reply is strict mode's
+    if msg := readTestMessage(client); msg == nil || msg.Type != "protocol_error" || !strings.Contains(msg.Error, "bogus") {
+        t.Fatalf("reply %+v", msg)
+    }
+
+    protocolConfig = ProtocolConfig{Strict: true, DisconnectOnError: true}
+    if handleMessage(conn, "strict", unknown) {
+        t.Fatal("strict disconnect kept the connection")
+    }
+}
+
+// Connect a client to the signaling handler, returning its end and the welcome it got
+func dialSignal(t *testing.T, query string) (*websocket.Conn, *Message) {
+    t.Helper()
+    if sessionTokens == nil {
+        tokens, err := loadSessionTokens(time.Minute)
+        if err != nil {
+            t.Fatal(err)
+        }
+        sessionTokens = tokens
+    }
+    srv := httptest.NewServer(http.HandlerFunc(signalHandler))
+    t.Cleanup(srv.Close)
+    client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+query, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { client.Close() })
+    welcome := readTestMessage(client)
+    if welcome == nil || welcome.Type != "welcome" {
+        t.Fatalf("first message %+v, want welcome", welcome)
+    }
+    return client, welcome
+}
+
+func TestWelcomeNamesOwnSession(t *testing.T) {
+    client, welcome := dialSignal(t, "")
+    if welcome.NodeID == "" {
+        t.Fatal("welcome without the session ID")
+    }
+    request, _ := json.Marshal(Message{Type: "list_nodes"})
+    if err := client.WriteMessage(websocket.TextMessage, request); err != nil {
+        t.Fatal(err)
+    }
+    msg := readTestMessage(client)
+    if msg == nil || msg.Type != "nodes" {
+        t.Fatalf("reply %+v", msg)
+    }
+    for _, id := range msg.Nodes {
+        if id == welcome.NodeID {
+            return
+        }
+    }
+    t.Fatalf("own session %s not in node list %v, so the client cannot leave it out", welcome.NodeID, msg.Nodes)
+}
+
+// Log output collected from many goroutines
+type testLog struct {
+    mutex sync.Mutex
+    lines strings.Builder
+}
+
+func (l *testLog) Write(p []byte) (int, error) {
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+    return l.lines.Write(p)
+}
+
+func (l *testLog) String() string {
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+    return l.lines.String()
+}
+
+func TestCandidateForwardedToTarget(t *testing.T) {
+    sender, from := dialSignal(t, "")
+    receiver, to := dialSignal(t, "")
+    candidate, _ := json.Marshal(Message{Type: "candidate", TargetNode: to.NodeID, Candidate: "candidate:1 1 udp 2130706431 192.0.2.2 5000 typ host"})
+    if err := sender.WriteMessage(websocket.TextMessage, candidate); err != nil {
+        t.Fatal(err)
+    }
+    msg := readTestMessage(receiver)
+    if msg == nil || msg.Type != "candidate" || msg.SourceNode != from.NodeID || !strings.Contains(msg.Candidate, "192.0.2.2") {
+        t.Fatalf("target got %+v", msg)
+    }
+
+    // Once the target is gone the candidate is dropped with a log
+    logs := &testLog{}
+    log.SetOutput(logs)
+    defer log.SetOutput(os.Stderr)
+    receiver.Close()
+    deadline := time.Now().Add(2 * time.Second)
+    for {
+        if _, ok := sessionConnOf(to.NodeID); !ok {
+            break
+        }
+        if time.Now().After(deadline) {
+            t.Fatal("target session still open")
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    if err := sender.WriteMessage(websocket.TextMessage, candidate); err != nil {
+        t.Fatal(err)
+    }
+    for !strings.Contains(logs.String(), "candidate target node does not exist") {
+        if time.Now().After(deadline) {
+            t.Fatalf("no log of the dropped candidate in %q", logs.String())
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    if msg := readTestMessage(sender); msg != nil {
+        t.Fatalf("sender got %+v", msg)
+    }
+}
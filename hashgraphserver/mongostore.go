@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// Database used when the MongoDB URI names none
+const defaultMongoDatabase = "hashgraphDB"
+
+// Collection holding one document per relayed event
+const mongoEventsCollection = "events"
+
+// Time one MongoDB operation may take
+const mongoTimeout = 10 * time.Second
+
+// Document holding one relayed event. The event is kept as the client's JSON, so no field is dropped.
+type mongoRelayEntry struct {
+    Event      string    `bson:"event"`
+    SourceNode string    `bson:"sourceNode"`
+    TargetNode string    `bson:"targetNode"`
+    ReceivedAt time.Time `bson:"receivedAt"`
+}
+
+// Event store keeping relayed events in a MongoDB collection
+type MongoEventStore struct {
+    client *mongo.Client
+    events *mongo.Collection
+}
+
+// Connect to the MongoDB store at a mongodb:// URI, whose path names the database
+func OpenMongoEventStore(uri string) (*MongoEventStore, error) {
+    parsed, err := connstring.ParseAndValidate(uri)
+    if err != nil {
+        return nil, fmt.Errorf("parse MongoDB URI: %w", err)
+    }
+    database := parsed.Database
+    if database == "" {
+        database = defaultMongoDatabase
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+    defer cancel()
+    client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+    if err != nil {
+        return nil, fmt.Errorf("connect to MongoDB: %w", err)
+    }
+    if err := client.Ping(ctx, nil); err != nil {
+        client.Disconnect(context.Background())
+        return nil, fmt.Errorf("connect to MongoDB: %w", err)
+    }
+    return &MongoEventStore{client: client, events: client.Database(database).Collection(mongoEventsCollection)}, nil
+}
+
+// Store a relayed event as one document
+func (ms *MongoEventStore) Append(msg Message) error {
+    ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+    defer cancel()
+    entry := mongoRelayEntry{
+        Event:      string(msg.Event),
+        SourceNode: msg.SourceNode,
+        TargetNode: msg.TargetNode,
+        ReceivedAt: time.Now(),
+    }
+    if _, err := ms.events.InsertOne(ctx, entry); err != nil {
+        return fmt.Errorf("store relayed event: %w", err)
+    }
+    return nil
+}
+
+// Disconnect from MongoDB
+func (ms *MongoEventStore) Close() error {
+    ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+    defer cancel()
+    return ms.client.Disconnect(ctx)
+}
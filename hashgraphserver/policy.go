@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Creator allowlist and denylist, reloadable from a JSON file
+type CreatorPolicy struct {
+    path  string
+    allow map[string]bool // empty allows every creator not denied
+    deny  map[string]bool
+    mutex sync.RWMutex
+}
+
+// Creator policy file format
+type creatorPolicyFile struct {
+    Allow []string `json:"allow"`
+    Deny  []string `json:"deny"`
+}
+
+// Load a creator policy from a file
+func LoadCreatorPolicy(path string) (*CreatorPolicy, error) {
+    policy := &CreatorPolicy{path: path}
+    if err := policy.Reload(); err != nil {
+        return nil, err
+    }
+    return policy, nil
+}
+
+// Re-read the policy file
+func (p *CreatorPolicy) Reload() error {
+    data, err := os.ReadFile(p.path)
+    if err != nil {
+        return fmt.Errorf("read creator policy: %w", err)
+    }
+    var file creatorPolicyFile
+    if err := json.Unmarshal(data, &file); err != nil {
+        return fmt.Errorf("decode creator policy %s: %w", p.path, err)
+    }
+    allow, deny := make(map[string]bool), make(map[string]bool)
+    for _, creator := range file.Allow {
+        allow[creator] = true
+    }
+    for _, creator := range file.Deny {
+        deny[creator] = true
+    }
+
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    p.allow, p.deny = allow, deny
+    return nil
+}
+
+// Check whether a creator may post
+func (p *CreatorPolicy) Allowed(creator string) bool {
+    p.mutex.RLock()
+    defer p.mutex.RUnlock()
+    if p.deny[creator] {
+        return false
+    }
+    return len(p.allow) == 0 || p.allow[creator]
+}
+
+// Reload the policy whenever the process receives SIGHUP
+func reloadOnHangup(policy *CreatorPolicy) {
+    hangup := make(chan os.Signal, 1)
+    signal.Notify(hangup, syscall.SIGHUP)
+    for range hangup {
+        if err := policy.Reload(); err != nil {
+            log.Println("Failed to reload creator policy:", err)
+            continue
+        }
+        log.Println("Creator policy reloaded")
+    }
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Write a creator policy file, returning its path
+func writeTestPolicy(t *testing.T, path, contents string) string {
+    t.Helper()
+    if path == "" {
+        path = filepath.Join(t.TempDir(), "policy.json")
+    }
+    if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+func TestCreatorPolicyAllowDenyAndReload(t *testing.T) {
+    path := writeTestPolicy(t, "", `{"deny":["mallory"]}`)
+    policy, err := LoadCreatorPolicy(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !policy.Allowed("alice") || policy.Allowed("mallory") {
+        t.Fatal("denylist alone should allow everyone but mallory")
+    }
+
+    writeTestPolicy(t, path, `{"allow":["alice","mallory"],"deny":["mallory"]}`)
+    if !policy.Allowed("alice") {
+        t.Fatal("policy changed before reload")
+    }
+    if err := policy.Reload(); err != nil {
+        t.Fatal(err)
+    }
+    if !policy.Allowed("alice") || policy.Allowed("bob") || policy.Allowed("mallory") {
+        t.Fatal("allowlist should admit only alice, with the denylist winning for mallory")
+    }
+
+    writeTestPolicy(t, path, `not json`)
+    if err := policy.Reload(); err == nil {
+        t.Fatal("malformed policy reloaded")
+    }
+    if !policy.Allowed("alice") || policy.Allowed("bob") {
+        t.Fatal("failed reload changed the policy")
+    }
+    if _, err := LoadCreatorPolicy(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+        t.Fatal("loaded a missing policy file")
+    }
+}
+
+func TestDisallowedCreatorNotForwarded(t *testing.T) {
+    savedPolicy, savedLog := creatorPolicy, eventStore
+    t.Cleanup(func() { creatorPolicy, eventStore = savedPolicy, savedLog })
+    policy, err := LoadCreatorPolicy(writeTestPolicy(t, "", `{"deny":["mallory"]}`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    creatorPolicy = policy
+    eventStore, err = OpenRelayLog(filepath.Join(t.TempDir(), "relay.jsonl"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { eventStore.Close() })
+
+    target := testSession(t, "policy-target")
+    testSession(t, "policy-sender")
+    conn, _ := sessionConnOf("policy-sender")
+    for _, creator := range []string{"mallory", "alice"} {
+        message, _ := json.Marshal(Message{
+            Type:       "event",
+            TargetNode: "policy-target",
+            Event:      json.RawMessage(`{"Creator":"` + creator + `"}`),
+        })
+        if !handleMessage(conn, "policy-sender", message) {
+            t.Fatal("event closed the connection")
+        }
+    }
+    // The denied event was handled first, so the first one forwarded must be alice's
+    msg := readTestMessage(target)
+    if msg == nil || eventCreator(msg.Event) != "alice" {
+        t.Fatalf("forwarded %+v", msg)
+    }
+}
@@ -0,0 +1,18 @@
+package main
+
+import "log"
+
+// Forward a presence, typing, hello, frontier or receipt message to its target, or to every other session
+// in its room when it has none; sessions outside the room never get it, and a message naming no room reaches no one.
+// These announcements are not consensus transactions, so they are neither added to the Hashgraph nor stored.
+func forwardPresence(msg Message, nodeID string) {
+    msg.SourceNode = nodeID
+    recipients := sessionConns(func(id string) bool {
+        return id != nodeID && (msg.TargetNode == "" || id == msg.TargetNode) && sessionRooms.In(id, msg.RoomID)
+    })
+    for id, conn := range recipients {
+        if err := conn.WriteJSON(msg); err != nil {
+            log.Printf("Failed to forward %s to %s: %v", msg.Type, id, err)
+        }
+    }
+}
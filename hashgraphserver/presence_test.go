@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Connect a websocket and register the server end as a session, returning the client end
+func testSession(t *testing.T, id string) *websocket.Conn {
+    t.Helper()
+    registered := make(chan struct{})
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        conn, err := upgrader.Upgrade(w, r, nil)
+        if err != nil {
+            t.Error(err)
+            return
+        }
+        sessionManager.mutex.Lock()
+        sessionManager.sessions[id] = &sessionConn{Conn: conn}
+        sessionManager.mutex.Unlock()
+        close(registered)
+    }))
+    t.Cleanup(srv.Close)
+    client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() {
+        unregisterNode(id)
+        client.Close()
+    })
+    <-registered
+    return client
+}
+
+// Read the next message a session gets, or nil if none arrives shortly
+func readTestMessage(conn *websocket.Conn) *Message {
+    conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+    var msg Message
+    if err := conn.ReadJSON(&msg); err != nil {
+        return nil
+    }
+    return &msg
+}
+
+func TestPresenceStaysInItsRoom(t *testing.T) {
+    testSession(t, "presence-sender")
+    inRoom := testSession(t, "presence-in-room")
+    elsewhere := testSession(t, "presence-elsewhere")
+    sessionRooms.Join("presence-sender", "lobby")
+    sessionRooms.Join("presence-in-room", "lobby")
+    sessionRooms.Join("presence-elsewhere", "other")
+
+    for _, msgType := range []string{"presence", "typing", "hello", "frontier", "receipt"} {
+        forwardPresence(Message{Type: msgType, RoomID: "lobby"}, "presence-sender")
+        if msg := readTestMessage(inRoom); msg == nil || msg.Type != msgType || msg.SourceNode != "presence-sender" {
+            t.Fatalf("%s not delivered within the room: %+v", msgType, msg)
+        }
+    }
+    forwardPresence(Message{Type: "typing", RoomID: "lobby", TargetNode: "presence-elsewhere"}, "presence-sender")
+
+    // The first message the other room's session gets must be the one sent to its room
+    forwardPresence(Message{Type: "hello", RoomID: "other"}, "presence-sender")
+    if msg := readTestMessage(elsewhere); msg == nil || msg.RoomID != "other" {
+        t.Fatalf("session in another room got %+v", msg)
+    }
+}
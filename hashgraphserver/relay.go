@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Relay log entry
+type RelayEntry struct {
+    Event      json.RawMessage `json:"event"` // exactly as the client sent it
+    SourceNode string          `json:"sourceNode"`
+    TargetNode string          `json:"targetNode"`
+    ReceivedAt time.Time       `json:"receivedAt"`
+}
+
+// Event store appending relayed events to a file as JSON lines
+type RelayLog struct {
+    file  *os.File
+    mutex sync.Mutex
+}
+
+// Open the relay log, creating it if needed
+func OpenRelayLog(path string) (*RelayLog, error) {
+    file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+    if err != nil {
+        return nil, fmt.Errorf("open relay log: %w", err)
+    }
+    return &RelayLog{file: file}, nil
+}
+
+// Store a relayed event as one JSON line
+func (rl *RelayLog) Append(msg Message) error {
+    data, err := json.Marshal(RelayEntry{
+        Event:      msg.Event,
+        SourceNode: msg.SourceNode,
+        TargetNode: msg.TargetNode,
+        ReceivedAt: time.Now(),
+    })
+    if err != nil {
+        return fmt.Errorf("encode relay entry: %w", err)
+    }
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+    if _, err := rl.file.Write(append(data, '\n')); err != nil {
+        return fmt.Errorf("append relay entry: %w", err)
+    }
+    return nil
+}
+
+// Close the relay log
+func (rl *RelayLog) Close() error {
+    return rl.file.Close()
+}
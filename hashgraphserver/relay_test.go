@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Client event with fields a typed server event would not know about
+const relayedEvent = `{"Creator":"P-256:04ab","Hash":"00ff","RoomID":"lobby","HashAlgorithm":"sha256","Ephemeral":true,"ExtraParents":["01","02"]}`
+
+func TestRelayKeepsEventFields(t *testing.T) {
+    var msg Message
+    if err := json.Unmarshal([]byte(`{"type":"event","targetNode":"b","event":`+relayedEvent+`}`), &msg); err != nil {
+        t.Fatal(err)
+    }
+    forwarded, err := json.Marshal(msg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !bytes.Contains(forwarded, []byte(relayedEvent)) {
+        t.Fatalf("forwarded message %s lost event fields", forwarded)
+    }
+
+    path := filepath.Join(t.TempDir(), "relay.jsonl")
+    rl, err := OpenRelayLog(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := rl.Append(msg); err != nil {
+        t.Fatal(err)
+    }
+    rl.Close()
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var entry RelayEntry
+    if err := json.Unmarshal(data, &entry); err != nil {
+        t.Fatal(err)
+    }
+    if string(entry.Event) != relayedEvent {
+        t.Fatalf("relay log stored %s, want %s", entry.Event, relayedEvent)
+    }
+}
+
+func TestEventCreator(t *testing.T) {
+    if creator := eventCreator(json.RawMessage(relayedEvent)); creator != "P-256:04ab" {
+        t.Fatalf("got creator %q", creator)
+    }
+    if creator := eventCreator(json.RawMessage(`not json`)); creator != "" {
+        t.Fatalf("got creator %q from malformed event", creator)
+    }
+}
+
+// In relay-only mode an event is forwarded and logged exactly as the client sent it, with no
+// consensus fields filled in by the server
+func TestRelayOnlyForwardsAndLogsEvents(t *testing.T) {
+    saved := eventStore
+    t.Cleanup(func() { eventStore = saved })
+    path := filepath.Join(t.TempDir(), "relay.jsonl")
+    var err error
+    eventStore, err = OpenRelayLog(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { eventStore.Close() })
+
+    target := testSession(t, "relay-target")
+    testSession(t, "relay-sender")
+    conn, _ := sessionConnOf("relay-sender")
+    message, _ := json.Marshal(Message{Type: "event", TargetNode: "relay-target", Event: json.RawMessage(relayedEvent)})
+    if !handleMessage(conn, "relay-sender", message) {
+        t.Fatal("event closed the connection")
+    }
+
+    msg := readTestMessage(target)
+    if msg == nil || string(msg.Event) != relayedEvent || msg.SourceNode != "relay-sender" {
+        t.Fatalf("forwarded %+v", msg)
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var entry RelayEntry
+    if err := json.Unmarshal(data, &entry); err != nil {
+        t.Fatal(err)
+    }
+    if string(entry.Event) != relayedEvent || entry.SourceNode != "relay-sender" || entry.TargetNode != "relay-target" {
+        t.Fatalf("relay log stored %+v", entry)
+    }
+    for _, field := range []string{"RoundCreated", "RoundReceived", "Famous", "ConsensusTimestamp"} {
+        if bytes.Contains(data, []byte(field)) {
+            t.Fatalf("relay log entry has consensus field %s: %s", field, data)
+        }
+    }
+}
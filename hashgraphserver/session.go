@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default lifetime of a session token, and so how long a dropped session can be reclaimed
+const defaultSessionTokenTTL = 10 * time.Minute
+
+// Token is malformed or its MAC does not match
+var errInvalidSessionToken = errors.New("invalid session token")
+
+// Token is past its expiry
+var errSessionTokenExpired = errors.New("session token expired")
+
+// Session named by the token is still connected
+var errSessionInUse = errors.New("session is still connected")
+
+// Issues and checks the signed tokens a client presents to reclaim its session on reconnect
+type SessionTokens struct {
+    secret []byte
+    ttl    time.Duration
+}
+
+// Sign tokens with SESSION_SECRET, or with a random key when unset, so tokens
+// only survive a server restart when the secret is configured
+func loadSessionTokens(ttl time.Duration) (*SessionTokens, error) {
+    secret := []byte(os.Getenv("SESSION_SECRET"))
+    if len(secret) == 0 {
+        secret = make([]byte, 32)
+        if _, err := rand.Read(secret); err != nil {
+            return nil, fmt.Errorf("generate session secret: %w", err)
+        }
+    }
+    return &SessionTokens{secret: secret, ttl: ttl}, nil
+}
+
+// MAC over a token's claims
+func (st *SessionTokens) sign(claims string) []byte {
+    mac := hmac.New(sha256.New, st.secret)
+    mac.Write([]byte(claims))
+    return mac.Sum(nil)
+}
+
+// Issue a token for a session: "<session>|<expiry unix time>" and its HMAC, both base64url
+func (st *SessionTokens) Issue(nodeID string, now time.Time) string {
+    claims := nodeID + "|" + strconv.FormatInt(now.Add(st.ttl).Unix(), 10)
+    return base64.RawURLEncoding.EncodeToString([]byte(claims)) + "." + base64.RawURLEncoding.EncodeToString(st.sign(claims))
+}
+
+// Check a token, returning the session it was issued for
+func (st *SessionTokens) Validate(token string, now time.Time) (string, error) {
+    encodedClaims, encodedMAC, ok := strings.Cut(token, ".")
+    if !ok {
+        return "", errInvalidSessionToken
+    }
+    claims, err := base64.RawURLEncoding.DecodeString(encodedClaims)
+    if err != nil {
+        return "", errInvalidSessionToken
+    }
+    mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+    if err != nil || !hmac.Equal(mac, st.sign(string(claims))) {
+        return "", errInvalidSessionToken
+    }
+    nodeID, expiry, ok := strings.Cut(string(claims), "|")
+    if !ok || nodeID == "" {
+        return "", errInvalidSessionToken
+    }
+    expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+    if err != nil {
+        return "", errInvalidSessionToken
+    }
+    if now.Unix() > expiresAt {
+        return "", errSessionTokenExpired
+    }
+    return nodeID, nil
+}
+
+// Session tokens, set in main
+var sessionTokens *SessionTokens
+
+// Rooms each session has announced itself in, kept after a disconnect so a reclaimed session gets them back
+type SessionRooms struct {
+    rooms     map[string]map[string]bool
+    droppedAt map[string]time.Time
+    mutex     sync.Mutex
+}
+
+var sessionRooms = SessionRooms{
+    rooms:     make(map[string]map[string]bool),
+    droppedAt: make(map[string]time.Time),
+}
+
+// Note that a session is in a room
+func (sr *SessionRooms) Join(nodeID, roomID string) {
+    if roomID == "" {
+        return
+    }
+    sr.mutex.Lock()
+    defer sr.mutex.Unlock()
+    if sr.rooms[nodeID] == nil {
+        sr.rooms[nodeID] = make(map[string]bool)
+    }
+    sr.rooms[nodeID][roomID] = true
+}
+
+// Rooms of a session, sorted
+func (sr *SessionRooms) List(nodeID string) []string {
+    sr.mutex.Lock()
+    defer sr.mutex.Unlock()
+    rooms := make([]string, 0, len(sr.rooms[nodeID]))
+    for roomID := range sr.rooms[nodeID] {
+        rooms = append(rooms, roomID)
+    }
+    sort.Strings(rooms)
+    return rooms
+}
+
+// Whether a session has announced itself in a room
+func (sr *SessionRooms) In(nodeID, roomID string) bool {
+    sr.mutex.Lock()
+    defer sr.mutex.Unlock()
+    return sr.rooms[nodeID][roomID]
+}
+
+// Keep a disconnected session's rooms until its token could no longer reclaim them
+func (sr *SessionRooms) Drop(nodeID string, now time.Time) {
+    sr.mutex.Lock()
+    defer sr.mutex.Unlock()
+    sr.droppedAt[nodeID] = now
+}
+
+// Mark a session connected again, and forget the rooms of sessions dropped longer than ttl ago
+func (sr *SessionRooms) Resume(nodeID string, now time.Time, ttl time.Duration) {
+    sr.mutex.Lock()
+    defer sr.mutex.Unlock()
+    delete(sr.droppedAt, nodeID)
+    for id, at := range sr.droppedAt {
+        if now.Sub(at) > ttl {
+            delete(sr.rooms, id)
+            delete(sr.droppedAt, id)
+        }
+    }
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Wait for a session to be registered or gone
+func waitForSession(t *testing.T, id string, connected bool) {
+    t.Helper()
+    deadline := time.Now().Add(2 * time.Second)
+    for {
+        if _, ok := sessionConnOf(id); ok == connected {
+            return
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("session %s connected %v, want %v", id, !connected, connected)
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+}
+
+func TestReconnectWithTokenRestoresRooms(t *testing.T) {
+    client, welcome := dialSignal(t, "")
+    if welcome.Token == "" {
+        t.Fatal("welcome without a session token")
+    }
+    hello, _ := json.Marshal(Message{Type: "hello", RoomID: "token-room"})
+    if err := client.WriteMessage(websocket.TextMessage, hello); err != nil {
+        t.Fatal(err)
+    }
+    deadline := time.Now().Add(2 * time.Second)
+    for !sessionRooms.In(welcome.NodeID, "token-room") {
+        if time.Now().After(deadline) {
+            t.Fatal("session never joined its room")
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+
+    // The token cannot take over a session that is still connected
+    _, taken := dialSignal(t, "?token="+url.QueryEscape(welcome.Token))
+    if taken.NodeID == welcome.NodeID || taken.Error != errSessionInUse.Error() {
+        t.Fatalf("connected session reclaimed: %+v", taken)
+    }
+
+    client.Close()
+    waitForSession(t, welcome.NodeID, false)
+    _, reclaimed := dialSignal(t, "?token="+url.QueryEscape(welcome.Token))
+    if reclaimed.NodeID != welcome.NodeID || reclaimed.Error != "" {
+        t.Fatalf("reclaim gave %+v", reclaimed)
+    }
+    if !reflect.DeepEqual(reclaimed.Rooms, []string{"token-room"}) {
+        t.Fatalf("reclaimed rooms %v", reclaimed.Rooms)
+    }
+
+    _, rejected := dialSignal(t, "?token="+url.QueryEscape(welcome.Token+"x"))
+    if rejected.NodeID == welcome.NodeID || rejected.Error != errInvalidSessionToken.Error() || len(rejected.Rooms) != 0 {
+        t.Fatalf("tampered token gave %+v", rejected)
+    }
+}
+
+func TestSessionTokenValidation(t *testing.T) {
+    tokens := &SessionTokens{secret: []byte("secret"), ttl: time.Minute}
+    now := time.Now()
+    token := tokens.Issue("node-1", now)
+    if id, err := tokens.Validate(token, now.Add(time.Minute)); err != nil || id != "node-1" {
+        t.Fatalf("valid token: %q %v", id, err)
+    }
+    if _, err := tokens.Validate(token, now.Add(time.Minute+time.Second)); !errors.Is(err, errSessionTokenExpired) {
+        t.Fatalf("expired token: %v", err)
+    }
+    other := &SessionTokens{secret: []byte("other"), ttl: time.Minute}
+    for _, bad := range []string{"", "nodot", "!!.!!", other.Issue("node-1", now)} {
+        if _, err := tokens.Validate(bad, now); !errors.Is(err, errInvalidSessionToken) {
+            t.Fatalf("token %q: %v", bad, err)
+        }
+    }
+
+    // A dropped session's rooms outlive it only for the token lifetime
+    rooms := SessionRooms{rooms: make(map[string]map[string]bool), droppedAt: make(map[string]time.Time)}
+    rooms.Join("old", "lobby")
+    rooms.Join("recent", "lobby")
+    rooms.Drop("old", now.Add(-2*time.Minute))
+    rooms.Drop("recent", now)
+    rooms.Resume("other", now, time.Minute)
+    if rooms.In("old", "lobby") || !rooms.In("recent", "lobby") {
+        t.Fatal("dropped session rooms not expired by the token lifetime")
+    }
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Store relayed events are persisted to unless -store names another
+const defaultEventStore = "mongodb://localhost:27017/hashgraphDB"
+
+// Store name not recognized
+var errUnknownEventStore = errors.New("unknown event store, want file:<path> or mongodb://<host>/<db>")
+
+// Persistence backend for the events the server relays. The server keeps no graph of its own,
+// so a relayed event is stored exactly as the client sent it.
+type EventStore interface {
+    Append(msg Message) error
+    Close() error
+}
+
+// Open an event store from its flag value: "file:<path>" for a JSON lines log, or a
+// mongodb:// URI whose path names the database
+func openEventStore(spec string) (EventStore, error) {
+    switch {
+    case strings.HasPrefix(spec, "file:") && len(spec) > len("file:"):
+        return OpenRelayLog(strings.TrimPrefix(spec, "file:"))
+    case strings.HasPrefix(spec, "mongodb://") || strings.HasPrefix(spec, "mongodb+srv://"):
+        store, err := OpenMongoEventStore(spec)
+        if err != nil {
+            return nil, fmt.Errorf("open event store: %w", err)
+        }
+        return store, nil
+    }
+    return nil, errUnknownEventStore
+}
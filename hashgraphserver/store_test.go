@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenEventStore(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "relay.jsonl")
+    store, err := openEventStore("file:" + path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    msg := Message{Type: "event", Event: json.RawMessage(relayedEvent), SourceNode: "a", TargetNode: "b"}
+    if err := store.Append(msg); err != nil {
+        t.Fatal(err)
+    }
+    store.Close()
+    data, err := os.ReadFile(path)
+    if err != nil || !strings.Contains(string(data), relayedEvent) {
+        t.Fatalf("file store holds %s: %v", data, err)
+    }
+
+    for _, spec := range []string{"", "file:", "relay.jsonl", "mongodb"} {
+        if _, err := openEventStore(spec); !errors.Is(err, errUnknownEventStore) {
+            t.Fatalf("%q: %v", spec, err)
+        }
+    }
+    // A MongoDB URI opens a MongoDB store, failing here as nothing listens on the port
+    if _, err := openEventStore("mongodb://127.0.0.1:1/hashgraphDB?serverSelectionTimeoutMS=100"); err == nil || errors.Is(err, errUnknownEventStore) {
+        t.Fatalf("unreachable MongoDB store: %v", err)
+    }
+}
+
+// Runs against the server MONGODB_URI names, in a database of its own, and skips without one
+func TestMongoEventStoreKeepsEvents(t *testing.T) {
+    uri := os.Getenv("MONGODB_URI")
+    if uri == "" {
+        t.Skip("MONGODB_URI not set")
+    }
+    store, err := OpenMongoEventStore(uri)
+    if err != nil {
+        t.Fatal(err)
+    }
+    database := store.client.Database(fmt.Sprintf("hashgraph_test_%d", time.Now().UnixNano()))
+    store.events = database.Collection(mongoEventsCollection)
+    t.Cleanup(func() {
+        database.Drop(context.Background())
+        store.Close()
+    })
+
+    msg := Message{Type: "event", Event: json.RawMessage(relayedEvent), SourceNode: "a", TargetNode: "b"}
+    if err := store.Append(msg); err != nil {
+        t.Fatal(err)
+    }
+    var entry mongoRelayEntry
+    if err := store.events.FindOne(context.Background(), map[string]string{"sourceNode": "a"}).Decode(&entry); err != nil {
+        t.Fatal(err)
+    }
+    if entry.Event != relayedEvent || entry.TargetNode != "b" {
+        t.Fatalf("MongoDB store holds %+v", entry)
+    }
+}
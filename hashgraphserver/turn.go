@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default lifetime of minted TURN credentials
+const defaultTURNTTL = time.Hour
+
+// TURN configuration
+type TURNConfig struct {
+    Secret []byte        // shared secret with the TURN server, /ice serves no TURN entry when empty
+    URLs   []string      // turn: and turns: URLs handed to clients
+    TTL    time.Duration // lifetime of minted credentials
+}
+
+// ICE server entry served to clients
+type ICEServer struct {
+    URLs       []string `json:"urls"`
+    Username   string   `json:"username,omitempty"`
+    Credential string   `json:"credential,omitempty"`
+}
+
+// Response of GET /ice
+type ICEResponse struct {
+    ICEServers []ICEServer `json:"iceServers"`
+    TTL        int         `json:"ttl"` // seconds until the credentials expire
+}
+
+// Load the TURN configuration, the secret comes from the environment
+func loadTURNConfig(urls string, ttl time.Duration) *TURNConfig {
+    config := &TURNConfig{
+        Secret: []byte(os.Getenv("TURN_SECRET")),
+        TTL:    ttl,
+    }
+    if urls != "" {
+        config.URLs = strings.Split(urls, ",")
+    }
+    return config
+}
+
+// Mint time-limited TURN credentials as in the TURN REST API: the username is
+// "<expiry unix time>:<user>" and the password the base64 HMAC-SHA1 of the username
+func mintTURNCredentials(secret []byte, user string, ttl time.Duration, now time.Time) (string, string) {
+    username := strconv.FormatInt(now.Add(ttl).Unix(), 10) + ":" + user
+    mac := hmac.New(sha1.New, secret)
+    mac.Write([]byte(username))
+    return username, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Serve ICE servers with freshly minted TURN credentials
+func iceHandler(config *TURNConfig) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        response := ICEResponse{ICEServers: []ICEServer{}}
+        if len(config.Secret) > 0 && len(config.URLs) > 0 {
+            user := r.URL.Query().Get("user")
+            if user == "" {
+                user = "client"
+            }
+            username, credential := mintTURNCredentials(config.Secret, user, config.TTL, time.Now())
+            response.ICEServers = append(response.ICEServers, ICEServer{
+                URLs:       config.URLs,
+                Username:   username,
+                Credential: credential,
+            })
+            response.TTL = int(config.TTL.Seconds())
+        }
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(response)
+    }
+}
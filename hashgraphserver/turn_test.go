@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Check credentials the way a TURN server with the shared secret does
+func validTURNCredentials(secret []byte, username, credential string, now time.Time) bool {
+    expiry, _, ok := strings.Cut(username, ":")
+    if !ok {
+        return false
+    }
+    expires, err := strconv.ParseInt(expiry, 10, 64)
+    if err != nil || now.Unix() >= expires {
+        return false
+    }
+    mac := hmac.New(sha1.New, secret)
+    mac.Write([]byte(username))
+    return hmac.Equal([]byte(credential), []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil))))
+}
+
+func TestMintedTURNCredentialsValidateAndExpire(t *testing.T) {
+    secret := []byte("shared secret")
+    now := time.Unix(1700000000, 0)
+    username, credential := mintTURNCredentials(secret, "alice", time.Hour, now)
+    if username != "1700003600:alice" {
+        t.Fatalf("username %q", username)
+    }
+    if !validTURNCredentials(secret, username, credential, now) {
+        t.Fatal("fresh credentials rejected")
+    }
+    if validTURNCredentials([]byte("other secret"), username, credential, now) {
+        t.Fatal("credentials accepted under another secret")
+    }
+    if validTURNCredentials(secret, "1700003600:mallory", credential, now) {
+        t.Fatal("credential accepted for another user")
+    }
+    if validTURNCredentials(secret, username, credential, now.Add(time.Hour)) {
+        t.Fatal("credentials accepted after expiry")
+    }
+}
+
+func TestICEHandler(t *testing.T) {
+    config := &TURNConfig{Secret: []byte("shared secret"), URLs: []string{"turn:turn.example:3478"}, TTL: 10 * time.Minute}
+    srv := httptest.NewServer(iceHandler(config))
+    defer srv.Close()
+
+    resp, err := http.Get(srv.URL + "?user=bob")
+    if err != nil {
+        t.Fatal(err)
+    }
+    var ice ICEResponse
+    err = json.NewDecoder(resp.Body).Decode(&ice)
+    resp.Body.Close()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(ice.ICEServers) != 1 || ice.TTL != 600 {
+        t.Fatalf("response %+v", ice)
+    }
+    server := ice.ICEServers[0]
+    if !strings.HasSuffix(server.Username, ":bob") || !validTURNCredentials(config.Secret, server.Username, server.Credential, time.Now()) {
+        t.Fatalf("served credentials %+v do not validate", server)
+    }
+    if validTURNCredentials(config.Secret, server.Username, server.Credential, time.Now().Add(config.TTL+time.Second)) {
+        t.Fatal("served credentials outlive their TTL")
+    }
+
+    resp, err = http.Post(srv.URL, "application/json", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusMethodNotAllowed {
+        t.Fatalf("POST answered %d", resp.StatusCode)
+    }
+
+    // Without a secret no TURN entry is served
+    unconfigured := httptest.NewServer(iceHandler(&TURNConfig{URLs: config.URLs, TTL: time.Minute}))
+    defer unconfigured.Close()
+    resp, err = http.Get(unconfigured.URL)
+    if err != nil {
+        t.Fatal(err)
+    }
+    ice = ICEResponse{}
+    json.NewDecoder(resp.Body).Decode(&ice)
+    resp.Body.Close()
+    if len(ice.ICEServers) != 0 {
+        t.Fatalf("served %+v without a secret", ice.ICEServers)
+    }
+}